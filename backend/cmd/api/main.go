@@ -1,12 +1,17 @@
 package main
 
 import (
+	"context"
 	"log"
+	"time"
 	"cloudbp-backend/internal/config"
 	"cloudbp-backend/internal/handler"
 	"cloudbp-backend/internal/middleware"
+	"cloudbp-backend/internal/service"
+	"cloudbp-backend/internal/task"
 	"cloudbp-backend/pkg/database"
 	"cloudbp-backend/pkg/cache"
+	"cloudbp-backend/pkg/idgen"
 	"cloudbp-backend/pkg/logger"
 	
 	"github.com/gin-gonic/gin"
@@ -54,6 +59,24 @@ func main() {
 		log.Fatal("Redis初始化失败:", err)
 	}
 
+	// 设置单号生成节点号，未配置时通过Redis分配，分配失败则不能启动
+	if cfg.Server.NodeID >= 0 {
+		if err := idgen.SetNode(cfg.Server.NodeID); err != nil {
+			log.Fatal("单号生成器初始化失败:", err)
+		}
+	} else {
+		node, err := idgen.AssignNode(context.Background(), rdb)
+		if err != nil {
+			log.Fatal("单号生成器初始化失败:", err)
+		}
+		log.Printf("单号生成节点号: %d", node)
+	}
+
+	// 启动后台任务
+	orderService := service.NewOrderService(db, rdb)
+	scheduler := task.NewScheduler(rdb)
+	scheduler.Register("close_expired_orders", time.Minute, orderService.CloseExpiredOrders)
+
 	// 设置Gin模式
 	if cfg.Server.Mode == "release" {
 		gin.SetMode(gin.ReleaseMode)
@@ -79,9 +102,13 @@ func main() {
 	apiV1 := r.Group("/api/v1")
 	{
 		// 注册路由
-		handler.RegisterRoutes(apiV1, db, rdb)
+		handler.RegisterRoutes(apiV1, db, rdb, scheduler)
 	}
 
+	// 路由注册的后台任务也已就绪，启动调度器
+	scheduler.Start()
+	defer scheduler.Stop()
+
 	// 启动服务器
 	log.Printf("服务器启动在端口: %s", cfg.Server.Port)
 	if err := r.Run(":" + cfg.Server.Port); err != nil {
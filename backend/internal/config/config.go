@@ -1,20 +1,39 @@
 package config
 
 import (
+	"errors"
+	"fmt"
+	"reflect"
+
+	"github.com/mitchellh/mapstructure"
+	"github.com/shopspring/decimal"
 	"github.com/spf13/viper"
 )
 
+// MockPaySecretPlaceholder 示例配置中的模拟支付密钥，不允许直接使用
+const MockPaySecretPlaceholder = "mock-secret-change-in-production"
+
 type Config struct {
 	Server   ServerConfig   `mapstructure:"server"`
 	Database DatabaseConfig `mapstructure:"database"`
 	Redis    RedisConfig    `mapstructure:"redis"`
 	JWT      JWTConfig      `mapstructure:"jwt"`
 	Log      LogConfig      `mapstructure:"log"`
+	Payment  PaymentConfig  `mapstructure:"payment"`
+	Refund   RefundConfig   `mapstructure:"refund"`
+	Pricing  PricingConfig  `mapstructure:"pricing"`
+	Invoice  InvoiceConfig  `mapstructure:"invoice"`
+	Currency CurrencyConfig `mapstructure:"currency"`
+	Catalog  CatalogConfig  `mapstructure:"catalog"`
+	Snapshot SnapshotConfig `mapstructure:"snapshot"`
+	Console  ConsoleConfig  `mapstructure:"console"`
 }
 
 type ServerConfig struct {
-	Port string `mapstructure:"port"`
-	Mode string `mapstructure:"mode"`
+	Port           string `mapstructure:"port"`
+	Mode           string `mapstructure:"mode"`
+	NodeID         int    `mapstructure:"node_id"`         // 单号生成节点号(0-999)，多实例部署时各实例需不同，-1表示启动时通过Redis自动分配
+	IdempotencyTTL int    `mapstructure:"idempotency_ttl"` // 幂等键保留时长（秒）
 }
 
 type DatabaseConfig struct {
@@ -42,6 +61,98 @@ type LogConfig struct {
 	Level string `mapstructure:"level"`
 }
 
+type PaymentConfig struct {
+	NotifyBaseURL string          `mapstructure:"notify_base_url"` // 异步通知地址前缀，如 https://api.example.com/api/v1
+	ReturnURL     string          `mapstructure:"return_url"`      // 支付完成后的前端跳转地址
+	Wechat        WechatPayConfig `mapstructure:"wechat"`
+	Alipay        AlipayConfig    `mapstructure:"alipay"`
+	Mock          MockPayConfig   `mapstructure:"mock"`
+}
+
+type WechatPayConfig struct {
+	Enabled          bool   `mapstructure:"enabled"`
+	AppID            string `mapstructure:"app_id"`
+	MchID            string `mapstructure:"mch_id"`
+	SerialNo         string `mapstructure:"serial_no"`          // 商户API证书序列号
+	APIv3Key         string `mapstructure:"api_v3_key"`         // APIv3密钥，用于解密回调
+	PrivateKeyPath   string `mapstructure:"private_key_path"`   // 商户API私钥
+	PlatformCertPath string `mapstructure:"platform_cert_path"` // 微信支付平台证书，用于验签
+}
+
+type AlipayConfig struct {
+	Enabled             bool   `mapstructure:"enabled"`
+	AppID               string `mapstructure:"app_id"`
+	GatewayURL          string `mapstructure:"gateway_url"`
+	PrivateKeyPath      string `mapstructure:"private_key_path"`       // 应用私钥
+	AlipayPublicKeyPath string `mapstructure:"alipay_public_key_path"` // 支付宝公钥，用于验签
+}
+
+type MockPayConfig struct {
+	Enabled bool   `mapstructure:"enabled"`
+	Secret  string `mapstructure:"secret"` // 模拟回调签名密钥
+}
+
+// validate 校验模拟支付配置：仅允许在debug模式下启用，且必须配置非默认密钥
+func (c MockPayConfig) validate(mode string) error {
+	if !c.Enabled {
+		return nil
+	}
+	if mode != "debug" {
+		return errors.New("模拟支付仅允许在debug模式下启用")
+	}
+	if c.Secret == "" || c.Secret == MockPaySecretPlaceholder {
+		return errors.New("模拟支付密钥未配置或仍为示例值")
+	}
+	return nil
+}
+
+type RefundConfig struct {
+	TerminationFeeRate float64 `mapstructure:"termination_fee_rate"` // 提前退订手续费比例，如0.1表示扣除10%
+	AutoApproveLimit   float64 `mapstructure:"auto_approve_limit"`   // 不超过该金额的退订退款自动通过，0表示全部人工审核
+}
+
+type PricingConfig struct {
+	TaxRate         float64          `mapstructure:"tax_rate"`         // 税率，如0.06表示6%，在优惠之后计算
+	PeriodDiscounts []PeriodDiscount `mapstructure:"period_discounts"` // 长周期折扣阶梯
+}
+
+type CatalogConfig struct {
+	MarkupRate   float64 `mapstructure:"markup_rate"`   // 未匹配任何标准售价规则时的默认加价比例，如0.2表示在成本价上加价20%
+	SyncInterval int     `mapstructure:"sync_interval"` // 定时同步间隔（分钟）
+	CacheTTL     int     `mapstructure:"cache_ttl"`     // 地域和镜像缓存时长（分钟）
+}
+
+type SnapshotConfig struct {
+	FreeQuota    int             `mapstructure:"free_quota"`     // 每台服务器免费的快照数
+	MaxPerServer int             `mapstructure:"max_per_server"` // 每台服务器最多保留的快照数
+	Price        decimal.Decimal `mapstructure:"price"`          // 超出免费额度的快照每月价格（本位币）
+}
+
+type ConsoleConfig struct {
+	MaxDuration    int      `mapstructure:"max_duration"`    // 单次控制台会话最长时长（分钟）
+	AllowedOrigins []string `mapstructure:"allowed_origins"` // 允许发起WebSocket连接的前端来源，为空时不限制
+}
+
+type CurrencyConfig struct {
+	Base string `mapstructure:"base"` // 本位币，钱包账本和报表均以本位币计，启用后不可修改
+}
+
+type InvoiceConfig struct {
+	Prefix      string  `mapstructure:"prefix"`        // 发票号码前缀
+	TaxRate     float64 `mapstructure:"tax_rate"`      // 充值发票税率，订单发票使用下单时计算的税额
+	ItemName    string  `mapstructure:"item_name"`     // 开票项目名称
+	SellerName  string  `mapstructure:"seller_name"`   // 销售方名称
+	SellerTaxNo string  `mapstructure:"seller_tax_no"` // 销售方纳税人识别号
+	SellerAddr  string  `mapstructure:"seller_addr"`   // 销售方地址、电话
+	SellerBank  string  `mapstructure:"seller_bank"`   // 销售方开户行及账号
+	FontPath    string  `mapstructure:"font_path"`     // 中文TrueType字体路径，未配置时使用内置西文字体
+}
+
+type PeriodDiscount struct {
+	MinPeriod int     `mapstructure:"min_period"` // 购买周期不少于该月数时适用
+	Rate      float64 `mapstructure:"rate"`       // 折扣比例，如0.1表示减免10%
+}
+
 func Load() (*Config, error) {
 	viper.SetConfigName("config")
 	viper.SetConfigType("yaml")
@@ -51,6 +162,8 @@ func Load() (*Config, error) {
 	// 设置默认值
 	viper.SetDefault("server.port", "8080")
 	viper.SetDefault("server.mode", "debug")
+	viper.SetDefault("server.node_id", -1)
+	viper.SetDefault("server.idempotency_ttl", 86400)
 	viper.SetDefault("database.host", "localhost")
 	viper.SetDefault("database.port", "5432")
 	viper.SetDefault("database.sslmode", "disable")
@@ -59,6 +172,19 @@ func Load() (*Config, error) {
 	viper.SetDefault("redis.db", 0)
 	viper.SetDefault("jwt.expire_time", 86400)
 	viper.SetDefault("log.level", "info")
+	viper.SetDefault("payment.notify_base_url", "http://localhost:8080/api/v1")
+	viper.SetDefault("payment.alipay.gateway_url", "https://openapi.alipay.com/gateway.do")
+	viper.SetDefault("payment.mock.enabled", false)
+	viper.SetDefault("currency.base", "CNY")
+	viper.SetDefault("catalog.markup_rate", 0.2)
+	viper.SetDefault("catalog.sync_interval", 360)
+	viper.SetDefault("catalog.cache_ttl", 1440)
+	viper.SetDefault("snapshot.free_quota", 1)
+	viper.SetDefault("snapshot.max_per_server", 5)
+	viper.SetDefault("snapshot.price", 5)
+	viper.SetDefault("console.max_duration", 30)
+	viper.SetDefault("invoice.prefix", "INV")
+	viper.SetDefault("invoice.item_name", "*信息技术服务*云服务器")
 
 	// 环境变量绑定
 	viper.AutomaticEnv()
@@ -71,9 +197,29 @@ func Load() (*Config, error) {
 	}
 
 	var config Config
-	if err := viper.Unmarshal(&config); err != nil {
+	if err := viper.Unmarshal(&config, viper.DecodeHook(mapstructure.ComposeDecodeHookFunc(
+		mapstructure.StringToTimeDurationHookFunc(),
+		mapstructure.StringToSliceHookFunc(","),
+		decimalHookFunc,
+	))); err != nil {
+		return nil, err
+	}
+
+	if err := config.Payment.Mock.validate(config.Server.Mode); err != nil {
 		return nil, err
 	}
 
 	return &config, nil
+}
+
+// decimalHookFunc 金额配置解析为定点数，支持数字和字符串写法
+func decimalHookFunc(from reflect.Type, to reflect.Type, data interface{}) (interface{}, error) {
+	if to != reflect.TypeOf(decimal.Decimal{}) {
+		return data, nil
+	}
+	switch from.Kind() {
+	case reflect.String, reflect.Int, reflect.Int64, reflect.Float64:
+		return decimal.NewFromString(fmt.Sprint(data))
+	}
+	return data, nil
 }
\ No newline at end of file
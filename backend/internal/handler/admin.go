@@ -15,9 +15,10 @@ import (
 
 // AdminHandler 管理员处理器
 type AdminHandler struct {
-	db           *gorm.DB
-	rdb          *redis.Client
-	adminService *service.AdminService
+	db            *gorm.DB
+	rdb           *redis.Client
+	adminService  *service.AdminService
+	ledgerService *service.LedgerService
 }
 
 // NewAdminHandler 创建管理员处理器
@@ -25,9 +26,10 @@ func NewAdminHandler(db *gorm.DB, rdb *redis.Client) *AdminHandler {
 	adminService := service.NewAdminService(db, rdb)
 
 	return &AdminHandler{
-		db:           db,
-		rdb:          rdb,
-		adminService: adminService,
+		db:            db,
+		rdb:           rdb,
+		adminService:  adminService,
+		ledgerService: service.NewLedgerService(db),
 	}
 }
 
@@ -171,4 +173,79 @@ func (h *AdminHandler) GetProducts(c *gin.Context) {
 		"message": "获取成功",
 		"data":    resp,
 	})
+}
+
+// AdjustUserBalance 调整用户余额
+// @Summary 调整用户余额
+// @Description 管理员为用户加款或扣款，生成调账流水（管理员）
+// @Tags 管理员
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "用户ID"
+// @Param body body service.AdjustBalanceRequest true "调账请求"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Router /admin/users/{id}/balance [post]
+func (h *AdminHandler) AdjustUserBalance(c *gin.Context) {
+	operatorID, _ := c.Get("user_id")
+
+	userID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的用户ID"})
+		return
+	}
+
+	var req service.AdjustBalanceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.Log.Error("绑定调账请求失败", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+
+	req.UserID = uint(userID)
+	req.OperatorID = operatorID.(uint)
+
+	resp, err := h.ledgerService.AdjustBalance(c.Request.Context(), &req)
+	if err != nil {
+		logger.Log.Error("调整用户余额失败", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	logger.Log.Info("调整用户余额成功", zap.Any("operator_id", operatorID), zap.Uint64("user_id", userID), zap.String("amount", req.Amount.String()))
+	c.JSON(http.StatusOK, gin.H{
+		"message": "调账成功",
+		"data":    resp,
+	})
+}
+
+// ReconcileLedger 核对账本
+// @Summary 核对账本
+// @Description 以账本分录为准核对账户余额和用户余额，fix=true时修正差异（管理员）
+// @Tags 管理员
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param fix query bool false "是否修正差异"
+// @Success 200 {object} map[string]interface{}
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Router /admin/ledger/reconcile [post]
+func (h *AdminHandler) ReconcileLedger(c *gin.Context) {
+	fix, _ := strconv.ParseBool(c.Query("fix"))
+
+	resp, err := h.ledgerService.Reconcile(c.Request.Context(), fix)
+	if err != nil {
+		logger.Log.Error("核对账本失败", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "核对完成",
+		"data":    resp,
+	})
 }
\ No newline at end of file
@@ -0,0 +1,87 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"cloudbp-backend/internal/service"
+	"cloudbp-backend/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// CatalogHandler 产品目录处理器
+type CatalogHandler struct {
+	db             *gorm.DB
+	rdb            *redis.Client
+	catalogService *service.CatalogService
+}
+
+// NewCatalogHandler 创建产品目录处理器
+func NewCatalogHandler(db *gorm.DB, rdb *redis.Client, catalogService *service.CatalogService) *CatalogHandler {
+	return &CatalogHandler{
+		db:             db,
+		rdb:            rdb,
+		catalogService: catalogService,
+	}
+}
+
+// SyncCatalog 同步厂商产品目录
+// @Summary 同步厂商产品目录
+// @Description 立即从所有启用的厂商导入规格为待上架产品，更新成本价，标记厂商已下线的规格，同步镜像目录并刷新地域缓存，后台任务也会定期同步（管理员）
+// @Tags 管理员
+// @Produce json
+// @Security ApiKeyAuth
+// @Success 200 {object} map[string]interface{}
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Router /admin/catalog/sync [post]
+func (h *CatalogHandler) SyncCatalog(c *gin.Context) {
+	operatorID, _ := c.Get("user_id")
+
+	results, err := h.catalogService.SyncCatalog(c.Request.Context())
+	if err != nil {
+		logger.Log.Error("同步产品目录失败", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	logger.Log.Info("手动同步产品目录", zap.Any("operator_id", operatorID), zap.Int("providers", len(results)))
+	c.JSON(http.StatusOK, gin.H{
+		"message": "同步完成",
+		"data":    results,
+	})
+}
+
+// GetRegions 获取厂商可用地域
+// @Summary 获取厂商可用地域
+// @Description 获取云厂商可用地域和可用区，用于购买页选择
+// @Tags 服务器
+// @Produce json
+// @Security ApiKeyAuth
+// @Param provider_id query int true "厂商ID"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Router /server/regions [get]
+func (h *CatalogHandler) GetRegions(c *gin.Context) {
+	providerID, err := strconv.ParseUint(c.Query("provider_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的厂商ID"})
+		return
+	}
+
+	regions, err := h.catalogService.GetRegions(c.Request.Context(), uint(providerID))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "获取成功",
+		"data":    regions,
+	})
+}
@@ -0,0 +1,104 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"cloudbp-backend/internal/service"
+	"cloudbp-backend/pkg/logger"
+	"cloudbp-backend/pkg/provider"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"go.uber.org/zap"
+)
+
+// ConsoleHandler 网页控制台处理器
+type ConsoleHandler struct {
+	consoleService *service.ConsoleService
+	upgrader       websocket.Upgrader
+}
+
+// NewConsoleHandler 创建网页控制台处理器
+func NewConsoleHandler(consoleService *service.ConsoleService) *ConsoleHandler {
+	return &ConsoleHandler{
+		consoleService: consoleService,
+		upgrader: websocket.Upgrader{
+			Subprotocols: []string{"binary"},
+			CheckOrigin: func(r *http.Request) bool {
+				return consoleService.CheckOrigin(r.Header.Get("Origin"))
+			},
+		},
+	}
+}
+
+// Console 打开服务器网页控制台
+// @Summary 打开服务器网页控制台
+// @Description 厂商提供网页控制台时返回地址；提供VNC WebSocket时须以WebSocket方式连接，由平台代理转发，令牌可通过token查询参数传递。同一台服务器同时只允许一个会话，超过最长时长自动断开，访问记录写入操作日志
+// @Tags 服务器
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "服务器ID"
+// @Param token query string false "JWT令牌，WebSocket连接无法设置请求头时使用"
+// @Success 101 {string} string "切换为WebSocket协议"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /server/{id}/console [get]
+func (h *ConsoleHandler) Console(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未找到用户信息"})
+		return
+	}
+
+	serverID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的服务器ID"})
+		return
+	}
+
+	req := &service.OpenConsoleRequest{
+		ServerID:  uint(serverID),
+		UserID:    userID.(uint),
+		Username:  c.GetString("username"),
+		IP:        c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+	}
+
+	session, err := h.consoleService.OpenConsole(c.Request.Context(), req)
+	if err != nil {
+		logger.Log.Error("打开控制台失败", zap.Uint("server_id", req.ServerID), zap.Error(err))
+		if err.Error() == "服务器不存在" {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		} else {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	if session.Console.Type == provider.ConsoleTypeURL {
+		c.JSON(http.StatusOK, gin.H{
+			"message": "获取成功",
+			"data":    session.Console,
+		})
+		return
+	}
+
+	if !websocket.IsWebSocketUpgrade(c.Request) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "该服务器控制台需以WebSocket方式连接"})
+		return
+	}
+
+	// 升级失败时 Upgrader 已写入错误响应
+	conn, err := h.upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		logger.Log.Error("升级WebSocket连接失败", zap.Uint("server_id", req.ServerID), zap.Error(err))
+		return
+	}
+
+	if err := h.consoleService.ProxyConsole(c.Request.Context(), session, conn); err != nil {
+		logger.Log.Error("控制台会话失败", zap.Uint("server_id", req.ServerID), zap.Error(err))
+	}
+}
@@ -0,0 +1,300 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"cloudbp-backend/internal/service"
+	"cloudbp-backend/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// CouponHandler 优惠券和促销活动处理器
+type CouponHandler struct {
+	db            *gorm.DB
+	rdb           *redis.Client
+	couponService *service.CouponService
+}
+
+// NewCouponHandler 创建优惠券处理器
+func NewCouponHandler(db *gorm.DB, rdb *redis.Client) *CouponHandler {
+	return &CouponHandler{
+		db:            db,
+		rdb:           rdb,
+		couponService: service.NewCouponService(db, rdb),
+	}
+}
+
+// GetCoupons 获取优惠券列表
+// @Summary 获取优惠券列表
+// @Description 分页获取优惠券（管理员）
+// @Tags 管理员
+// @Produce json
+// @Security ApiKeyAuth
+// @Param page query int false "页码" default(1)
+// @Param size query int false "每页数量" default(10)
+// @Param keyword query string false "券码或名称"
+// @Param status query int false "状态 1:启用 2:禁用"
+// @Success 200 {object} map[string]interface{}
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Router /admin/coupons [get]
+func (h *CouponHandler) GetCoupons(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	size, _ := strconv.Atoi(c.DefaultQuery("size", "10"))
+	status, _ := strconv.Atoi(c.Query("status"))
+
+	req := &service.GetCouponsRequest{
+		Page:    page,
+		Size:    size,
+		Keyword: c.Query("keyword"),
+		Status:  status,
+	}
+
+	resp, err := h.couponService.GetCoupons(c.Request.Context(), req)
+	if err != nil {
+		logger.Log.Error("获取优惠券列表失败", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "获取成功",
+		"data":    resp,
+	})
+}
+
+// CreateCoupon 创建优惠券
+// @Summary 创建优惠券
+// @Description 创建满减或折扣优惠券（管理员）
+// @Tags 管理员
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param body body service.SaveCouponRequest true "优惠券"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Router /admin/coupons [post]
+func (h *CouponHandler) CreateCoupon(c *gin.Context) {
+	var req service.SaveCouponRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.Log.Error("绑定优惠券请求失败", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+
+	coupon, err := h.couponService.CreateCoupon(c.Request.Context(), &req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "创建成功",
+		"data":    coupon,
+	})
+}
+
+// UpdateCoupon 更新优惠券
+// @Summary 更新优惠券
+// @Description 更新优惠券规则，已使用次数不变（管理员）
+// @Tags 管理员
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "优惠券ID"
+// @Param body body service.SaveCouponRequest true "优惠券"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Router /admin/coupons/{id} [put]
+func (h *CouponHandler) UpdateCoupon(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的优惠券ID"})
+		return
+	}
+
+	var req service.SaveCouponRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.Log.Error("绑定优惠券请求失败", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+
+	coupon, err := h.couponService.UpdateCoupon(c.Request.Context(), uint(id), &req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "更新成功",
+		"data":    coupon,
+	})
+}
+
+// DeleteCoupon 删除优惠券
+// @Summary 删除优惠券
+// @Description 删除优惠券（管理员）
+// @Tags 管理员
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "优惠券ID"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Router /admin/coupons/{id} [delete]
+func (h *CouponHandler) DeleteCoupon(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的优惠券ID"})
+		return
+	}
+
+	if err := h.couponService.DeleteCoupon(c.Request.Context(), uint(id)); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "删除成功",
+	})
+}
+
+// GetPromotions 获取促销活动列表
+// @Summary 获取促销活动列表
+// @Description 获取促销活动（管理员）
+// @Tags 管理员
+// @Produce json
+// @Security ApiKeyAuth
+// @Param status query int false "状态 1:启用 2:禁用"
+// @Success 200 {object} map[string]interface{}
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Router /admin/promotions [get]
+func (h *CouponHandler) GetPromotions(c *gin.Context) {
+	status, _ := strconv.Atoi(c.Query("status"))
+
+	promotions, err := h.couponService.GetPromotions(c.Request.Context(), status)
+	if err != nil {
+		logger.Log.Error("获取促销活动失败", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "获取成功",
+		"data":    promotions,
+	})
+}
+
+// CreatePromotion 创建促销活动
+// @Summary 创建促销活动
+// @Description 创建自动生效的促销活动（管理员）
+// @Tags 管理员
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param body body service.SavePromotionRequest true "促销活动"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Router /admin/promotions [post]
+func (h *CouponHandler) CreatePromotion(c *gin.Context) {
+	var req service.SavePromotionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.Log.Error("绑定促销活动请求失败", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+
+	promotion, err := h.couponService.CreatePromotion(c.Request.Context(), &req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "创建成功",
+		"data":    promotion,
+	})
+}
+
+// UpdatePromotion 更新促销活动
+// @Summary 更新促销活动
+// @Description 更新促销活动规则（管理员）
+// @Tags 管理员
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "促销活动ID"
+// @Param body body service.SavePromotionRequest true "促销活动"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Router /admin/promotions/{id} [put]
+func (h *CouponHandler) UpdatePromotion(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的促销活动ID"})
+		return
+	}
+
+	var req service.SavePromotionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.Log.Error("绑定促销活动请求失败", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+
+	promotion, err := h.couponService.UpdatePromotion(c.Request.Context(), uint(id), &req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "更新成功",
+		"data":    promotion,
+	})
+}
+
+// DeletePromotion 删除促销活动
+// @Summary 删除促销活动
+// @Description 删除促销活动（管理员）
+// @Tags 管理员
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "促销活动ID"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Router /admin/promotions/{id} [delete]
+func (h *CouponHandler) DeletePromotion(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的促销活动ID"})
+		return
+	}
+
+	if err := h.couponService.DeletePromotion(c.Request.Context(), uint(id)); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "删除成功",
+	})
+}
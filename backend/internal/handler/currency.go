@@ -0,0 +1,221 @@
+package handler
+
+import (
+	"net/http"
+
+	"cloudbp-backend/internal/service"
+	"cloudbp-backend/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// CurrencyHandler 币种和汇率处理器
+type CurrencyHandler struct {
+	db              *gorm.DB
+	rdb             *redis.Client
+	currencyService *service.CurrencyService
+}
+
+// NewCurrencyHandler 创建币种处理器
+func NewCurrencyHandler(db *gorm.DB, rdb *redis.Client, currencyService *service.CurrencyService) *CurrencyHandler {
+	return &CurrencyHandler{
+		db:              db,
+		rdb:             rdb,
+		currencyService: currencyService,
+	}
+}
+
+// GetCurrencies 获取可用币种
+// @Summary 获取可用币种
+// @Description 获取本位币及已配置汇率的外币
+// @Tags 支付
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Router /payment/currencies [get]
+func (h *CurrencyHandler) GetCurrencies(c *gin.Context) {
+	currencies, err := h.currencyService.GetCurrencies(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "获取成功",
+		"data":    currencies,
+	})
+}
+
+// SetUserCurrencyRequest 设置结算币种请求
+type SetUserCurrencyRequest struct {
+	Currency string `json:"currency" binding:"required"`
+}
+
+// SetUserCurrency 设置结算币种
+// @Summary 设置结算币种
+// @Description 设置当前用户的结算币种，之后的报价和订单以该币种计价
+// @Tags 用户
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param body body SetUserCurrencyRequest true "结算币种"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Router /user/currency [put]
+func (h *CurrencyHandler) SetUserCurrency(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未找到用户信息"})
+		return
+	}
+
+	var req SetUserCurrencyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+
+	if err := h.currencyService.SetUserCurrency(c.Request.Context(), userID.(uint), req.Currency); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "设置成功",
+	})
+}
+
+// GetExchangeRates 获取汇率列表
+// @Summary 获取汇率列表
+// @Description 获取全部外币汇率（管理员）
+// @Tags 管理员
+// @Produce json
+// @Security ApiKeyAuth
+// @Success 200 {object} map[string]interface{}
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Router /admin/exchange-rates [get]
+func (h *CurrencyHandler) GetExchangeRates(c *gin.Context) {
+	rates, err := h.currencyService.GetExchangeRates(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "获取成功",
+		"data": gin.H{
+			"base_currency": h.currencyService.BaseCurrency(),
+			"rates":         rates,
+		},
+	})
+}
+
+// SaveExchangeRate 设置汇率
+// @Summary 设置汇率
+// @Description 新增或更新外币汇率，只影响之后创建的订单（管理员）
+// @Tags 管理员
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param currency path string true "币种代码"
+// @Param body body service.SaveExchangeRateRequest true "汇率"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Router /admin/exchange-rates/{currency} [put]
+func (h *CurrencyHandler) SaveExchangeRate(c *gin.Context) {
+	operatorID, _ := c.Get("user_id")
+
+	var req service.SaveExchangeRateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.Log.Error("绑定汇率请求失败", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+
+	req.Currency = c.Param("currency")
+	req.OperatorID = operatorID.(uint)
+
+	rate, err := h.currencyService.SaveExchangeRate(c.Request.Context(), &req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	logger.Log.Info("设置汇率", zap.Uint("operator_id", req.OperatorID),
+		zap.String("currency", rate.Currency), zap.String("rate", rate.Rate.String()))
+	c.JSON(http.StatusOK, gin.H{
+		"message": "保存成功",
+		"data":    rate,
+	})
+}
+
+// DeleteExchangeRate 删除汇率
+// @Summary 删除汇率
+// @Description 删除外币汇率，删除后该币种不可再下单（管理员）
+// @Tags 管理员
+// @Produce json
+// @Security ApiKeyAuth
+// @Param currency path string true "币种代码"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Router /admin/exchange-rates/{currency} [delete]
+func (h *CurrencyHandler) DeleteExchangeRate(c *gin.Context) {
+	if err := h.currencyService.DeleteExchangeRate(c.Request.Context(), c.Param("currency")); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "删除成功",
+	})
+}
+
+// ImportExchangeRates 导入汇率文件
+// @Summary 导入汇率文件
+// @Description 上传CSV文件批量导入汇率，每行格式为“币种,汇率”，任一行不合法时全部不导入（管理员）
+// @Tags 管理员
+// @Accept multipart/form-data
+// @Produce json
+// @Security ApiKeyAuth
+// @Param file formData file true "汇率CSV文件"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Router /admin/exchange-rates/import [post]
+func (h *CurrencyHandler) ImportExchangeRates(c *gin.Context) {
+	operatorID, _ := c.Get("user_id")
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请上传汇率文件"})
+		return
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "读取汇率文件失败"})
+		return
+	}
+	defer file.Close()
+
+	rates, err := h.currencyService.ImportExchangeRates(c.Request.Context(), file, operatorID.(uint))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	logger.Log.Info("导入汇率", zap.Uint("operator_id", operatorID.(uint)), zap.Int("count", len(rates)))
+	c.JSON(http.StatusOK, gin.H{
+		"message": "导入成功",
+		"data":    rates,
+	})
+}
@@ -0,0 +1,183 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"cloudbp-backend/internal/service"
+	"cloudbp-backend/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// FirewallHandler 服务器防火墙处理器
+type FirewallHandler struct {
+	db              *gorm.DB
+	rdb             *redis.Client
+	firewallService *service.FirewallService
+}
+
+// NewFirewallHandler 创建服务器防火墙处理器
+func NewFirewallHandler(db *gorm.DB, rdb *redis.Client, firewallService *service.FirewallService) *FirewallHandler {
+	return &FirewallHandler{
+		db:              db,
+		rdb:             rdb,
+		firewallService: firewallService,
+	}
+}
+
+// GetFirewallPresets 获取防火墙预设
+// @Summary 获取防火墙预设
+// @Description 获取可一键应用的防火墙规则模板：Web服务、仅SSH、数据库
+// @Tags 服务器
+// @Produce json
+// @Security ApiKeyAuth
+// @Success 200 {object} map[string]interface{}
+// @Failure 401 {object} map[string]string
+// @Router /server/firewall-presets [get]
+func (h *FirewallHandler) GetFirewallPresets(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"message": "获取成功",
+		"data":    h.firewallService.GetPresets(),
+	})
+}
+
+// GetFirewallRules 获取服务器防火墙规则
+// @Summary 获取服务器防火墙规则
+// @Description 获取服务器的防火墙规则，首次查看时导入厂商的默认规则
+// @Tags 服务器
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "服务器ID"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /server/{id}/firewall [get]
+func (h *FirewallHandler) GetFirewallRules(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未找到用户信息"})
+		return
+	}
+
+	serverID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的服务器ID"})
+		return
+	}
+
+	rules, err := h.firewallService.GetFirewallRules(c.Request.Context(), userID.(uint), uint(serverID))
+	if err != nil {
+		respondFirewallError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "获取成功",
+		"data":    rules,
+	})
+}
+
+// AddFirewallRules 添加服务器防火墙规则
+// @Summary 添加服务器防火墙规则
+// @Description 添加自定义规则或应用预设，端口支持单个、范围和逗号分隔，来源支持IP和CIDR网段，与现有规则重复的自动跳过
+// @Tags 服务器
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "服务器ID"
+// @Param body body service.AddFirewallRulesRequest true "防火墙规则"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /server/{id}/firewall [post]
+func (h *FirewallHandler) AddFirewallRules(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未找到用户信息"})
+		return
+	}
+
+	serverID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的服务器ID"})
+		return
+	}
+
+	var req service.AddFirewallRulesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.Log.Error("绑定防火墙规则请求失败", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+
+	req.ServerID = uint(serverID)
+	req.UserID = userID.(uint)
+
+	rules, err := h.firewallService.AddFirewallRules(c.Request.Context(), &req)
+	if err != nil {
+		logger.Log.Error("添加防火墙规则失败", zap.Uint("server_id", req.ServerID), zap.Error(err))
+		respondFirewallError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "添加成功",
+		"data":    rules,
+	})
+}
+
+// DeleteFirewallRule 删除服务器防火墙规则
+// @Summary 删除服务器防火墙规则
+// @Description 删除服务器的一条防火墙规则
+// @Tags 服务器
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "服务器ID"
+// @Param rule_id path int true "规则ID"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /server/{id}/firewall/{rule_id} [delete]
+func (h *FirewallHandler) DeleteFirewallRule(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未找到用户信息"})
+		return
+	}
+
+	serverID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的服务器ID"})
+		return
+	}
+	ruleID, err := strconv.ParseUint(c.Param("rule_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的规则ID"})
+		return
+	}
+
+	if err := h.firewallService.DeleteFirewallRule(c.Request.Context(), userID.(uint), uint(serverID), uint(ruleID)); err != nil {
+		logger.Log.Error("删除防火墙规则失败", zap.Uint64("server_id", serverID), zap.Error(err))
+		respondFirewallError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "删除成功"})
+}
+
+// respondFirewallError 按错误类型返回状态码
+func respondFirewallError(c *gin.Context, err error) {
+	switch err.Error() {
+	case "服务器不存在", "防火墙规则不存在":
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	}
+}
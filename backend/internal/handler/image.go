@@ -0,0 +1,215 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"cloudbp-backend/internal/service"
+	"cloudbp-backend/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// ImageHandler 镜像目录处理器
+type ImageHandler struct {
+	db           *gorm.DB
+	rdb          *redis.Client
+	imageService *service.ImageService
+}
+
+// NewImageHandler 创建镜像目录处理器
+func NewImageHandler(db *gorm.DB, rdb *redis.Client, imageService *service.ImageService) *ImageHandler {
+	return &ImageHandler{
+		db:           db,
+		rdb:          rdb,
+		imageService: imageService,
+	}
+}
+
+// GetImages 获取可选镜像
+// @Summary 获取可选镜像
+// @Description 获取购买时可选的系统镜像和应用镜像，指定产品时只返回该产品所在地域且配置满足要求的镜像
+// @Tags 服务器
+// @Produce json
+// @Security ApiKeyAuth
+// @Param product_id query int false "产品ID"
+// @Param provider_id query int false "厂商ID，未指定产品时必填"
+// @Param region query string false "地域，未指定产品时必填，指定产品时默认产品所在地域"
+// @Param category query string false "分类 os、app"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Router /server/images [get]
+func (h *ImageHandler) GetImages(c *gin.Context) {
+	productID, _ := strconv.ParseUint(c.Query("product_id"), 10, 32)
+	providerID, _ := strconv.ParseUint(c.Query("provider_id"), 10, 32)
+
+	req := &service.GetAvailableImagesRequest{
+		ProductID:  uint(productID),
+		ProviderID: uint(providerID),
+		Region:     c.Query("region"),
+		Category:   c.Query("category"),
+	}
+
+	images, err := h.imageService.GetAvailableImages(c.Request.Context(), req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "获取成功",
+		"data":    images,
+	})
+}
+
+// GetImageCatalog 获取镜像目录
+// @Summary 获取镜像目录
+// @Description 分页获取镜像目录，包括已禁用和厂商已下线的镜像（管理员）
+// @Tags 管理员
+// @Produce json
+// @Security ApiKeyAuth
+// @Param page query int false "页码" default(1)
+// @Param size query int false "每页数量" default(10)
+// @Param provider_id query int false "厂商ID"
+// @Param region query string false "地域"
+// @Param category query string false "分类 os、app"
+// @Param status query int false "状态 1:启用 2:禁用"
+// @Param keyword query string false "名称、镜像ID或应用名称"
+// @Success 200 {object} map[string]interface{}
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Router /admin/images [get]
+func (h *ImageHandler) GetImageCatalog(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	size, _ := strconv.Atoi(c.DefaultQuery("size", "10"))
+	providerID, _ := strconv.Atoi(c.Query("provider_id"))
+	status, _ := strconv.Atoi(c.Query("status"))
+
+	req := &service.GetImageCatalogRequest{
+		Page:       page,
+		Size:       size,
+		ProviderID: uint(providerID),
+		Region:     c.Query("region"),
+		Category:   c.Query("category"),
+		Status:     status,
+		Keyword:    c.Query("keyword"),
+	}
+
+	resp, err := h.imageService.GetImageCatalog(c.Request.Context(), req)
+	if err != nil {
+		logger.Log.Error("获取镜像目录失败", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "获取成功",
+		"data":    resp,
+	})
+}
+
+// CreateImage 添加镜像
+// @Summary 添加镜像
+// @Description 添加厂商公共镜像之外的应用镜像或自定义镜像（管理员）
+// @Tags 管理员
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param body body service.SaveImageRequest true "镜像"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Router /admin/images [post]
+func (h *ImageHandler) CreateImage(c *gin.Context) {
+	var req service.SaveImageRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.Log.Error("绑定镜像请求失败", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+
+	image, err := h.imageService.CreateImage(c.Request.Context(), &req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "添加成功",
+		"data":    image,
+	})
+}
+
+// UpdateImage 更新镜像
+// @Summary 更新镜像
+// @Description 更新镜像名称、分类、最低配置、排序和状态，厂商同步镜像的操作系统信息不可修改（管理员）
+// @Tags 管理员
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "镜像记录ID"
+// @Param body body service.SaveImageRequest true "镜像"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Router /admin/images/{id} [put]
+func (h *ImageHandler) UpdateImage(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的镜像ID"})
+		return
+	}
+
+	var req service.SaveImageRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.Log.Error("绑定镜像请求失败", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+
+	image, err := h.imageService.UpdateImage(c.Request.Context(), uint(id), &req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "更新成功",
+		"data":    image,
+	})
+}
+
+// DeleteImage 删除镜像
+// @Summary 删除镜像
+// @Description 删除管理员添加的镜像，厂商同步的镜像只能禁用（管理员）
+// @Tags 管理员
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "镜像记录ID"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Router /admin/images/{id} [delete]
+func (h *ImageHandler) DeleteImage(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的镜像ID"})
+		return
+	}
+
+	if err := h.imageService.DeleteImage(c.Request.Context(), uint(id)); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "删除成功",
+	})
+}
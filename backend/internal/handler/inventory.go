@@ -0,0 +1,97 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"cloudbp-backend/internal/service"
+	"cloudbp-backend/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// InventoryHandler 产品库存处理器
+type InventoryHandler struct {
+	db               *gorm.DB
+	rdb              *redis.Client
+	inventoryService *service.InventoryService
+}
+
+// NewInventoryHandler 创建库存处理器
+func NewInventoryHandler(db *gorm.DB, rdb *redis.Client, inventoryService *service.InventoryService) *InventoryHandler {
+	return &InventoryHandler{
+		db:               db,
+		rdb:              rdb,
+		inventoryService: inventoryService,
+	}
+}
+
+// UpdateInventory 设置产品库存
+// @Summary 设置产品库存
+// @Description 设置产品剩余可售库存（-1不限）和每个用户限购台数（0不限），未完成订单占用的库存不受影响（管理员）
+// @Tags 管理员
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "产品ID"
+// @Param body body service.UpdateInventoryRequest true "库存设置"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Router /admin/products/{id}/inventory [put]
+func (h *InventoryHandler) UpdateInventory(c *gin.Context) {
+	operatorID, _ := c.Get("user_id")
+
+	productID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的产品ID"})
+		return
+	}
+
+	var req service.UpdateInventoryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.Log.Error("绑定库存请求失败", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+	req.ProductID = uint(productID)
+
+	product, err := h.inventoryService.UpdateInventory(c.Request.Context(), &req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	logger.Log.Info("设置产品库存", zap.Any("operator_id", operatorID), zap.Uint("product_id", product.ID),
+		zap.Int("stock", product.Stock), zap.Int("purchase_limit", product.PurchaseLimit))
+	c.JSON(http.StatusOK, gin.H{
+		"message": "保存成功",
+		"data":    product,
+	})
+}
+
+// SyncAvailability 同步产品售罄状态
+// @Summary 同步产品售罄状态
+// @Description 立即按厂商可售规格同步所有上架产品的售罄状态，后台任务也会定期同步（管理员）
+// @Tags 管理员
+// @Produce json
+// @Security ApiKeyAuth
+// @Success 200 {object} map[string]interface{}
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Router /admin/products/sync-availability [post]
+func (h *InventoryHandler) SyncAvailability(c *gin.Context) {
+	if err := h.inventoryService.SyncAvailability(c.Request.Context()); err != nil {
+		logger.Log.Error("同步产品售罄状态失败", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "同步完成",
+	})
+}
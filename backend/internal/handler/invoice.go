@@ -0,0 +1,345 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"cloudbp-backend/internal/service"
+	"cloudbp-backend/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// InvoiceHandler 发票处理器
+type InvoiceHandler struct {
+	db             *gorm.DB
+	rdb            *redis.Client
+	invoiceService *service.InvoiceService
+}
+
+// NewInvoiceHandler 创建发票处理器
+func NewInvoiceHandler(db *gorm.DB, rdb *redis.Client, invoiceService *service.InvoiceService) *InvoiceHandler {
+	return &InvoiceHandler{
+		db:             db,
+		rdb:            rdb,
+		invoiceService: invoiceService,
+	}
+}
+
+// GetInvoiceProfile 获取开票信息
+// @Summary 获取开票信息
+// @Description 获取当前用户的发票抬头、纳税人识别号等开票信息
+// @Tags 用户
+// @Produce json
+// @Security ApiKeyAuth
+// @Success 200 {object} map[string]interface{}
+// @Failure 401 {object} map[string]string
+// @Router /user/invoice-profile [get]
+func (h *InvoiceHandler) GetInvoiceProfile(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未找到用户信息"})
+		return
+	}
+
+	profile, err := h.invoiceService.GetInvoiceProfile(c.Request.Context(), userID.(uint))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "获取成功",
+		"data":    profile,
+	})
+}
+
+// UpdateInvoiceProfile 更新开票信息
+// @Summary 更新开票信息
+// @Description 更新当前用户的开票信息，企业抬头须填写纳税人识别号
+// @Tags 用户
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param body body service.InvoiceProfile true "开票信息"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Router /user/invoice-profile [put]
+func (h *InvoiceHandler) UpdateInvoiceProfile(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未找到用户信息"})
+		return
+	}
+
+	var req service.InvoiceProfile
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.Log.Error("绑定开票信息失败", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+
+	if err := h.invoiceService.UpdateInvoiceProfile(c.Request.Context(), userID.(uint), &req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "更新成功",
+		"data":    req,
+	})
+}
+
+// CreateInvoice 申请开票
+// @Summary 申请开票
+// @Description 为已完成的订单或已到账的充值单开具发票
+// @Tags 用户
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param body body service.CreateInvoiceRequest true "开票请求"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Router /user/invoices [post]
+func (h *InvoiceHandler) CreateInvoice(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未找到用户信息"})
+		return
+	}
+
+	var req service.CreateInvoiceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.Log.Error("绑定开票请求失败", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+
+	req.UserID = userID.(uint)
+
+	inv, err := h.invoiceService.CreateInvoice(c.Request.Context(), &req)
+	if err != nil {
+		logger.Log.Error("开具发票失败", zap.Uint("user_id", req.UserID), zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	logger.Log.Info("开具发票", zap.Uint("user_id", req.UserID), zap.String("invoice_no", inv.InvoiceNo))
+	c.JSON(http.StatusOK, gin.H{
+		"message": "开票成功",
+		"data":    inv,
+	})
+}
+
+// GetUserInvoices 获取用户发票列表
+// @Summary 获取用户发票列表
+// @Description 获取当前用户的发票
+// @Tags 用户
+// @Produce json
+// @Security ApiKeyAuth
+// @Param page query int false "页码" default(1)
+// @Param size query int false "每页数量" default(10)
+// @Param status query string false "状态"
+// @Success 200 {object} map[string]interface{}
+// @Failure 401 {object} map[string]string
+// @Router /user/invoices [get]
+func (h *InvoiceHandler) GetUserInvoices(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未找到用户信息"})
+		return
+	}
+
+	h.getInvoices(c, userID.(uint))
+}
+
+// GetInvoices 获取发票列表
+// @Summary 获取发票列表
+// @Description 获取全部用户的发票（管理员）
+// @Tags 管理员
+// @Produce json
+// @Security ApiKeyAuth
+// @Param page query int false "页码" default(1)
+// @Param size query int false "每页数量" default(10)
+// @Param status query string false "状态"
+// @Success 200 {object} map[string]interface{}
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Router /admin/invoices [get]
+func (h *InvoiceHandler) GetInvoices(c *gin.Context) {
+	h.getInvoices(c, 0)
+}
+
+// getInvoices 分页查询发票，userID为0时查询全部
+func (h *InvoiceHandler) getInvoices(c *gin.Context, userID uint) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	size, _ := strconv.Atoi(c.DefaultQuery("size", "10"))
+
+	req := &service.GetInvoicesRequest{
+		UserID: userID,
+		Status: c.Query("status"),
+		Page:   page,
+		Size:   size,
+	}
+
+	resp, err := h.invoiceService.GetInvoices(c.Request.Context(), req)
+	if err != nil {
+		logger.Log.Error("获取发票列表失败", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "获取成功",
+		"data":    resp,
+	})
+}
+
+// GetUserInvoice 获取发票详情
+// @Summary 获取发票详情
+// @Description 获取当前用户的发票详情
+// @Tags 用户
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "发票ID"
+// @Success 200 {object} map[string]interface{}
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /user/invoices/{id} [get]
+func (h *InvoiceHandler) GetUserInvoice(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未找到用户信息"})
+		return
+	}
+
+	invoiceID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的发票ID"})
+		return
+	}
+
+	inv, err := h.invoiceService.GetInvoice(c.Request.Context(), userID.(uint), uint(invoiceID))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "获取成功",
+		"data":    inv,
+	})
+}
+
+// DownloadUserInvoice 下载PDF发票
+// @Summary 下载PDF发票
+// @Description 下载当前用户的PDF发票
+// @Tags 用户
+// @Produce application/pdf
+// @Security ApiKeyAuth
+// @Param id path int true "发票ID"
+// @Success 200 {file} file
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /user/invoices/{id}/download [get]
+func (h *InvoiceHandler) DownloadUserInvoice(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未找到用户信息"})
+		return
+	}
+
+	h.downloadInvoice(c, userID.(uint))
+}
+
+// DownloadInvoice 下载PDF发票
+// @Summary 下载PDF发票
+// @Description 下载任意用户的PDF发票（管理员）
+// @Tags 管理员
+// @Produce application/pdf
+// @Security ApiKeyAuth
+// @Param id path int true "发票ID"
+// @Success 200 {file} file
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /admin/invoices/{id}/download [get]
+func (h *InvoiceHandler) DownloadInvoice(c *gin.Context) {
+	h.downloadInvoice(c, 0)
+}
+
+// downloadInvoice 输出PDF发票，userID为0时不校验归属
+func (h *InvoiceHandler) downloadInvoice(c *gin.Context, userID uint) {
+	invoiceID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的发票ID"})
+		return
+	}
+
+	inv, data, err := h.invoiceService.RenderInvoicePDF(c.Request.Context(), userID, uint(invoiceID))
+	if err != nil {
+		if err.Error() == "发票不存在" {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		logger.Log.Error("生成PDF发票失败", zap.Uint64("invoice_id", invoiceID), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.pdf"`, inv.InvoiceNo))
+	c.Data(http.StatusOK, "application/pdf", data)
+}
+
+// VoidInvoice 作废发票
+// @Summary 作废发票
+// @Description 作废已开具的发票，作废后该笔交易可重新开票（管理员）
+// @Tags 管理员
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "发票ID"
+// @Param body body service.VoidInvoiceRequest false "作废原因"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Router /admin/invoices/{id}/void [post]
+func (h *InvoiceHandler) VoidInvoice(c *gin.Context) {
+	operatorID, _ := c.Get("user_id")
+
+	invoiceID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的发票ID"})
+		return
+	}
+
+	var req service.VoidInvoiceRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+			return
+		}
+	}
+
+	req.InvoiceID = uint(invoiceID)
+	req.OperatorID = operatorID.(uint)
+
+	inv, err := h.invoiceService.VoidInvoice(c.Request.Context(), &req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	logger.Log.Info("作废发票", zap.Uint("operator_id", req.OperatorID), zap.String("invoice_no", inv.InvoiceNo))
+	c.JSON(http.StatusOK, gin.H{
+		"message": "已作废",
+		"data":    inv,
+	})
+}
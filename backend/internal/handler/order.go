@@ -0,0 +1,258 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"cloudbp-backend/internal/model"
+	"cloudbp-backend/internal/service"
+	"cloudbp-backend/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// OrderHandler 用户订单处理器
+type OrderHandler struct {
+	db             *gorm.DB
+	rdb            *redis.Client
+	orderService     *service.OrderService
+	paymentService   *service.PaymentService
+	provisionService *service.ProvisionService
+}
+
+// NewOrderHandler 创建订单处理器
+func NewOrderHandler(db *gorm.DB, rdb *redis.Client, paymentService *service.PaymentService, provisionService *service.ProvisionService) *OrderHandler {
+	return &OrderHandler{
+		db:               db,
+		rdb:              rdb,
+		orderService:     service.NewOrderService(db, rdb),
+		paymentService:   paymentService,
+		provisionService: provisionService,
+	}
+}
+
+// GetUserOrders 获取用户订单列表
+// @Summary 获取用户订单列表
+// @Description 获取当前用户的订单列表，可按状态、类型和下单日期筛选
+// @Tags 用户
+// @Produce json
+// @Security ApiKeyAuth
+// @Param page query int false "页码" default(1)
+// @Param size query int false "每页数量" default(10)
+// @Param status query string false "订单状态"
+// @Param type query string false "订单类型：new、renew、upgrade、downgrade"
+// @Param start_date query string false "下单日期起，格式2006-01-02"
+// @Param end_date query string false "下单日期止（含当天），格式2006-01-02"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Router /user/orders [get]
+func (h *OrderHandler) GetUserOrders(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未找到用户信息"})
+		return
+	}
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	size, _ := strconv.Atoi(c.DefaultQuery("size", "10"))
+
+	req := &service.GetUserOrdersRequest{
+		UserID: userID.(uint),
+		Status: c.Query("status"),
+		Type:   c.Query("type"),
+		Page:   page,
+		Size:   size,
+	}
+
+	if startDate := c.Query("start_date"); startDate != "" {
+		start, err := time.ParseInLocation("2006-01-02", startDate, time.Local)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "开始日期格式错误"})
+			return
+		}
+		req.StartTime = &start
+	}
+	if endDate := c.Query("end_date"); endDate != "" {
+		end, err := time.ParseInLocation("2006-01-02", endDate, time.Local)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "结束日期格式错误"})
+			return
+		}
+		end = end.AddDate(0, 0, 1)
+		req.EndTime = &end
+	}
+
+	resp, err := h.orderService.GetUserOrders(c.Request.Context(), req)
+	if err != nil {
+		logger.Log.Error("获取用户订单列表失败", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "获取成功",
+		"data":    resp,
+	})
+}
+
+// GetUserOrder 获取订单详情
+// @Summary 获取订单详情
+// @Description 获取当前用户的订单详情，包含支付记录和开通的服务器
+// @Tags 用户
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "订单ID"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /user/orders/{id} [get]
+func (h *OrderHandler) GetUserOrder(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未找到用户信息"})
+		return
+	}
+
+	orderID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的订单ID"})
+		return
+	}
+
+	order, err := h.orderService.GetUserOrder(c.Request.Context(), userID.(uint), uint(orderID))
+	if err != nil {
+		if err.Error() == "订单不存在" {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		} else {
+			logger.Log.Error("获取订单详情失败", zap.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "获取成功",
+		"data":    order,
+	})
+}
+
+// CancelOrder 取消订单
+// @Summary 取消订单
+// @Description 取消当前用户的待支付订单，释放占用的优惠券
+// @Tags 用户
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "订单ID"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /user/orders/{id}/cancel [post]
+func (h *OrderHandler) CancelOrder(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未找到用户信息"})
+		return
+	}
+
+	orderID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的订单ID"})
+		return
+	}
+
+	if err := h.orderService.CancelOrder(c.Request.Context(), userID.(uint), uint(orderID)); err != nil {
+		logger.Log.Error("取消订单失败", zap.Error(err))
+		if err.Error() == "订单不存在" {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		} else {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "订单已取消",
+	})
+}
+
+// PayOrder 重新支付订单
+// @Summary 重新支付订单
+// @Description 重新支付当前用户的待支付订单，可更换支付方式，金额和支付截止时间沿用下单时的结果
+// @Tags 用户
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param Idempotency-Key header string false "幂等键，相同幂等键的重复请求返回首次结果"
+// @Param id path int true "订单ID"
+// @Param body body service.PayOrderRequest false "支付请求"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 402 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /user/orders/{id}/pay [post]
+func (h *OrderHandler) PayOrder(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未找到用户信息"})
+		return
+	}
+
+	orderID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的订单ID"})
+		return
+	}
+
+	// 请求体可省略，省略时沿用订单的支付方式
+	var req service.PayOrderRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		logger.Log.Error("绑定支付请求失败", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+
+	req.OrderID = uint(orderID)
+	req.UserID = userID.(uint)
+	req.ClientIP = c.ClientIP()
+
+	resp, err := h.paymentService.PayOrder(c.Request.Context(), &req)
+	if err != nil {
+		logger.Log.Error("支付订单失败", zap.Error(err))
+		if err.Error() == "订单不存在" {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		} else if errors.Is(err, service.ErrInsufficientBalance) {
+			c.JSON(http.StatusPaymentRequired, gin.H{"error": err.Error()})
+		} else {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	message := "支付成功"
+	if resp.Status == model.OrderStatusPending {
+		message = "请完成支付"
+	} else {
+		// 余额支付已完成，新购订单立即开通服务器，续费订单续费厂商实例
+		go func(orderID uint) {
+			if err := h.provisionService.ProvisionOrder(context.Background(), orderID); err != nil {
+				logger.Log.Error("开通订单失败", zap.Uint("order_id", orderID), zap.Error(err))
+			}
+		}(resp.OrderID)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": message,
+		"data":    resp,
+	})
+}
@@ -0,0 +1,212 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"cloudbp-backend/internal/service"
+	"cloudbp-backend/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// PaymentHandler 支付处理器
+type PaymentHandler struct {
+	db             *gorm.DB
+	rdb            *redis.Client
+	paymentService *service.PaymentService
+}
+
+// NewPaymentHandler 创建支付处理器
+func NewPaymentHandler(db *gorm.DB, rdb *redis.Client, paymentService *service.PaymentService) *PaymentHandler {
+	return &PaymentHandler{
+		db:             db,
+		rdb:            rdb,
+		paymentService: paymentService,
+	}
+}
+
+// GetPaymentMethods 获取可用支付方式
+// @Summary 获取可用支付方式
+// @Description 获取当前启用的第三方支付方式
+// @Tags 支付
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Router /payment/methods [get]
+func (h *PaymentHandler) GetPaymentMethods(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"message": "获取成功",
+		"data":    h.paymentService.GetPaymentMethods(c.Request.Context()),
+	})
+}
+
+// HandleNotify 处理支付通道异步通知
+// @Summary 支付结果通知
+// @Description 接收微信支付、支付宝的异步支付结果通知，验签后入账
+// @Tags 支付
+// @Param method path string true "支付方式"
+// @Success 200 {string} string
+// @Router /payment/notify/{method} [post]
+func (h *PaymentHandler) HandleNotify(c *gin.Context) {
+	method := c.Param("method")
+
+	resp, err := h.paymentService.HandleNotify(c.Request.Context(), method, c.Request)
+	if err != nil {
+		logger.Log.Error("处理支付通知失败", zap.String("method", method), zap.Error(err))
+		if resp == nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	c.Data(resp.StatusCode, resp.ContentType, []byte(resp.Body))
+}
+
+// MockPay 模拟支付
+// @Summary 模拟支付
+// @Description 模拟第三方支付成功并触发回调，仅在启用模拟支付时可用
+// @Tags 支付
+// @Produce json
+// @Param trade_no query string true "交易号"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Router /payment/mock/pay [get]
+func (h *PaymentHandler) MockPay(c *gin.Context) {
+	tradeNo := c.Query("trade_no")
+	if tradeNo == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "缺少交易号"})
+		return
+	}
+
+	if err := h.paymentService.MockPay(c.Request.Context(), tradeNo); err != nil {
+		logger.Log.Error("模拟支付失败", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "支付成功",
+	})
+}
+
+// CreateRecharge 创建充值
+// @Summary 余额充值
+// @Description 通过微信支付或支付宝充值账户余额
+// @Tags 支付
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param Idempotency-Key header string false "幂等键，相同幂等键的重复请求返回首次结果"
+// @Param request body service.CreateRechargeRequest true "充值信息"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Router /user/recharges [post]
+func (h *PaymentHandler) CreateRecharge(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未找到用户信息"})
+		return
+	}
+
+	var req service.CreateRechargeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误: " + err.Error()})
+		return
+	}
+
+	req.UserID = userID.(uint)
+	req.ClientIP = c.ClientIP()
+
+	resp, err := h.paymentService.CreateRecharge(c.Request.Context(), &req)
+	if err != nil {
+		logger.Log.Error("创建充值失败", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "创建成功",
+		"data":    resp,
+	})
+}
+
+// GetRecharges 获取充值记录
+// @Summary 获取充值记录
+// @Description 获取当前用户的充值记录
+// @Tags 支付
+// @Produce json
+// @Security ApiKeyAuth
+// @Param page query int false "页码" default(1)
+// @Param size query int false "每页数量" default(10)
+// @Param status query string false "状态"
+// @Success 200 {object} map[string]interface{}
+// @Failure 401 {object} map[string]string
+// @Router /user/recharges [get]
+func (h *PaymentHandler) GetRecharges(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未找到用户信息"})
+		return
+	}
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	size, _ := strconv.Atoi(c.DefaultQuery("size", "10"))
+
+	req := &service.GetRechargesRequest{
+		UserID: userID.(uint),
+		Page:   page,
+		Size:   size,
+		Status: c.Query("status"),
+	}
+
+	resp, err := h.paymentService.GetRecharges(c.Request.Context(), req)
+	if err != nil {
+		logger.Log.Error("获取充值记录失败", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "获取成功",
+		"data":    resp,
+	})
+}
+
+// GetRecharge 获取充值单详情
+// @Summary 获取充值单详情
+// @Description 获取充值单状态，前端可轮询此接口确认到账
+// @Tags 支付
+// @Produce json
+// @Security ApiKeyAuth
+// @Param no path string true "充值单号"
+// @Success 200 {object} map[string]interface{}
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /user/recharges/{no} [get]
+func (h *PaymentHandler) GetRecharge(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未找到用户信息"})
+		return
+	}
+
+	recharge, err := h.paymentService.GetRecharge(c.Request.Context(), userID.(uint), c.Param("no"))
+	if err != nil {
+		if err.Error() == "充值单不存在" {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		logger.Log.Error("获取充值单失败", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "获取成功",
+		"data":    recharge,
+	})
+}
@@ -0,0 +1,284 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"cloudbp-backend/internal/service"
+	"cloudbp-backend/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// PricingRuleHandler 定价规则处理器
+type PricingRuleHandler struct {
+	db                 *gorm.DB
+	rdb                *redis.Client
+	pricingRuleService *service.PricingRuleService
+}
+
+// NewPricingRuleHandler 创建定价规则处理器
+func NewPricingRuleHandler(db *gorm.DB, rdb *redis.Client, pricingRuleService *service.PricingRuleService) *PricingRuleHandler {
+	return &PricingRuleHandler{
+		db:                 db,
+		rdb:                rdb,
+		pricingRuleService: pricingRuleService,
+	}
+}
+
+// GetPricingRules 获取定价规则列表
+// @Summary 获取定价规则列表
+// @Description 获取定价规则，按用户分组和优先级排序（管理员）
+// @Tags 管理员
+// @Produce json
+// @Security ApiKeyAuth
+// @Param user_group query string false "用户分组，传空字符串只看标准售价规则"
+// @Param status query int false "状态 1:启用 2:禁用"
+// @Success 200 {object} map[string]interface{}
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Router /admin/pricing-rules [get]
+func (h *PricingRuleHandler) GetPricingRules(c *gin.Context) {
+	status, _ := strconv.Atoi(c.Query("status"))
+
+	var userGroup *string
+	if group, ok := c.GetQuery("user_group"); ok {
+		userGroup = &group
+	}
+
+	rules, err := h.pricingRuleService.GetPricingRules(c.Request.Context(), userGroup, status)
+	if err != nil {
+		logger.Log.Error("获取定价规则失败", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "获取成功",
+		"data":    rules,
+	})
+}
+
+// CreatePricingRule 创建定价规则
+// @Summary 创建定价规则
+// @Description 创建按比例或固定金额加价的定价规则，可限定厂商、产品类型、地域和用户分组（管理员）
+// @Tags 管理员
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param body body service.SavePricingRuleRequest true "定价规则"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Router /admin/pricing-rules [post]
+func (h *PricingRuleHandler) CreatePricingRule(c *gin.Context) {
+	var req service.SavePricingRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.Log.Error("绑定定价规则请求失败", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+
+	rule, err := h.pricingRuleService.CreatePricingRule(c.Request.Context(), &req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "创建成功",
+		"data":    rule,
+	})
+}
+
+// UpdatePricingRule 更新定价规则
+// @Summary 更新定价规则
+// @Description 更新定价规则，标准售价需预览后发布才会生效，分组专属价立即生效（管理员）
+// @Tags 管理员
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "规则ID"
+// @Param body body service.SavePricingRuleRequest true "定价规则"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Router /admin/pricing-rules/{id} [put]
+func (h *PricingRuleHandler) UpdatePricingRule(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的规则ID"})
+		return
+	}
+
+	var req service.SavePricingRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.Log.Error("绑定定价规则请求失败", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+
+	rule, err := h.pricingRuleService.UpdatePricingRule(c.Request.Context(), uint(id), &req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "更新成功",
+		"data":    rule,
+	})
+}
+
+// DeletePricingRule 删除定价规则
+// @Summary 删除定价规则
+// @Description 删除定价规则（管理员）
+// @Tags 管理员
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "规则ID"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Router /admin/pricing-rules/{id} [delete]
+func (h *PricingRuleHandler) DeletePricingRule(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的规则ID"})
+		return
+	}
+
+	if err := h.pricingRuleService.DeletePricingRule(c.Request.Context(), uint(id)); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "删除成功",
+	})
+}
+
+// PreviewPrices 预览价格表
+// @Summary 预览价格表
+// @Description 按当前定价规则计算产品价格表，列出成本价、当前售价、新售价和命中的规则，不修改产品（管理员）
+// @Tags 管理员
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param body body service.PreviewPricesRequest true "筛选条件"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Router /admin/pricing-rules/preview [post]
+func (h *PricingRuleHandler) PreviewPrices(c *gin.Context) {
+	var req service.PreviewPricesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.Log.Error("绑定价格预览请求失败", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+
+	items, err := h.pricingRuleService.PreviewPrices(c.Request.Context(), &req)
+	if err != nil {
+		logger.Log.Error("预览价格表失败", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "获取成功",
+		"data":    items,
+	})
+}
+
+// PublishPrices 发布价格
+// @Summary 发布价格
+// @Description 按当前标准售价规则更新所选产品的售价，可同时上架待上架产品（管理员）
+// @Tags 管理员
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param body body service.PublishPricesRequest true "发布的产品"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Router /admin/pricing-rules/publish [post]
+func (h *PricingRuleHandler) PublishPrices(c *gin.Context) {
+	operatorID, _ := c.Get("user_id")
+
+	var req service.PublishPricesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.Log.Error("绑定价格发布请求失败", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+
+	result, err := h.pricingRuleService.PublishPrices(c.Request.Context(), &req)
+	if err != nil {
+		logger.Log.Error("发布价格失败", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	logger.Log.Info("发布产品价格", zap.Any("operator_id", operatorID), zap.Int("products", len(req.ProductIDs)),
+		zap.Int("updated", result.Updated), zap.Int("onlined", result.Onlined))
+	c.JSON(http.StatusOK, gin.H{
+		"message": "发布成功",
+		"data":    result,
+	})
+}
+
+// SetUserPriceGroupRequest 设置用户定价分组请求
+type SetUserPriceGroupRequest struct {
+	PriceGroup string `json:"price_group"` // 为空表示恢复标准售价
+}
+
+// SetUserPriceGroup 设置用户定价分组
+// @Summary 设置用户定价分组
+// @Description 设置用户所属定价分组，下单时按分组专属价计价（管理员）
+// @Tags 管理员
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "用户ID"
+// @Param body body SetUserPriceGroupRequest true "定价分组"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Router /admin/users/{id}/price-group [put]
+func (h *PricingRuleHandler) SetUserPriceGroup(c *gin.Context) {
+	operatorID, _ := c.Get("user_id")
+
+	userID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的用户ID"})
+		return
+	}
+
+	var req SetUserPriceGroupRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.Log.Error("绑定定价分组请求失败", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+
+	if err := h.pricingRuleService.SetUserPriceGroup(c.Request.Context(), uint(userID), req.PriceGroup); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	logger.Log.Info("设置用户定价分组", zap.Any("operator_id", operatorID), zap.Uint64("user_id", userID),
+		zap.String("price_group", req.PriceGroup))
+	c.JSON(http.StatusOK, gin.H{
+		"message": "设置成功",
+	})
+}
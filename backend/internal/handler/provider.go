@@ -0,0 +1,51 @@
+package handler
+
+import (
+	"net/http"
+
+	"cloudbp-backend/internal/service"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+)
+
+// ProviderHandler 云厂商处理器
+type ProviderHandler struct {
+	db              *gorm.DB
+	rdb             *redis.Client
+	providerService *service.ProviderService
+}
+
+// NewProviderHandler 创建云厂商处理器
+func NewProviderHandler(db *gorm.DB, rdb *redis.Client, providerService *service.ProviderService) *ProviderHandler {
+	return &ProviderHandler{
+		db:              db,
+		rdb:             rdb,
+		providerService: providerService,
+	}
+}
+
+// GetCapabilities 获取厂商支持的可选能力
+// @Summary 获取厂商能力
+// @Description 获取厂商是否支持变更规格、快照、防火墙、密钥对、网页控制台和厂商侧续费，前端据此隐藏不支持的操作
+// @Tags 服务器
+// @Produce json
+// @Security ApiKeyAuth
+// @Param code path string true "厂商代码"
+// @Success 200 {object} map[string]interface{}
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /providers/{code}/capabilities [get]
+func (h *ProviderHandler) GetCapabilities(c *gin.Context) {
+	capabilities, err := h.providerService.GetCapabilities(c.Param("code"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "获取成功",
+		"data":    capabilities,
+	})
+}
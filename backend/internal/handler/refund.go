@@ -0,0 +1,350 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"cloudbp-backend/internal/service"
+	"cloudbp-backend/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// RefundHandler 退款处理器
+type RefundHandler struct {
+	db            *gorm.DB
+	rdb           *redis.Client
+	refundService *service.RefundService
+}
+
+// NewRefundHandler 创建退款处理器
+func NewRefundHandler(db *gorm.DB, rdb *redis.Client, refundService *service.RefundService) *RefundHandler {
+	return &RefundHandler{
+		db:            db,
+		rdb:           rdb,
+		refundService: refundService,
+	}
+}
+
+// GetRefundQuote 退订退款试算
+// @Summary 退订退款试算
+// @Description 按剩余时长和手续费比例试算提前退订可退金额，续费购买的时长按续费订单分别折算
+// @Tags 服务器
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "服务器ID"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /server/{id}/refund-quote [get]
+func (h *RefundHandler) GetRefundQuote(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未找到用户信息"})
+		return
+	}
+
+	serverID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的服务器ID"})
+		return
+	}
+
+	quote, err := h.refundService.QuoteTermination(c.Request.Context(), userID.(uint), uint(serverID))
+	if err != nil {
+		if err.Error() == "服务器不存在" {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "获取成功",
+		"data":    quote,
+	})
+}
+
+// TerminateServer 申请提前退订
+// @Summary 申请提前退订
+// @Description 申请提前退订服务器，审核通过后释放实例并按剩余时长退款
+// @Tags 服务器
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "服务器ID"
+// @Param body body service.TerminateServerRequest false "退订原因"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /server/{id}/terminate [post]
+func (h *RefundHandler) TerminateServer(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未找到用户信息"})
+		return
+	}
+
+	serverID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的服务器ID"})
+		return
+	}
+
+	var req service.TerminateServerRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+			return
+		}
+	}
+
+	req.ServerID = uint(serverID)
+	req.UserID = userID.(uint)
+
+	refunds, err := h.refundService.TerminateServer(c.Request.Context(), &req)
+	if err != nil {
+		logger.Log.Error("申请退订失败", zap.Error(err))
+		if err.Error() == "服务器不存在" {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "退订申请已提交",
+		"data":    refunds,
+	})
+}
+
+// GetUserRefunds 获取用户退款记录
+// @Summary 获取用户退款记录
+// @Description 获取当前用户的退款记录
+// @Tags 用户
+// @Produce json
+// @Security ApiKeyAuth
+// @Param page query int false "页码" default(1)
+// @Param size query int false "每页数量" default(10)
+// @Param status query string false "状态"
+// @Success 200 {object} map[string]interface{}
+// @Failure 401 {object} map[string]string
+// @Router /user/refunds [get]
+func (h *RefundHandler) GetUserRefunds(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未找到用户信息"})
+		return
+	}
+
+	h.getRefunds(c, userID.(uint))
+}
+
+// GetRefunds 获取退款单列表
+// @Summary 获取退款单列表
+// @Description 获取全部退款单，可按状态筛选待审核的退款（管理员）
+// @Tags 管理员
+// @Produce json
+// @Security ApiKeyAuth
+// @Param page query int false "页码" default(1)
+// @Param size query int false "每页数量" default(10)
+// @Param status query string false "状态"
+// @Success 200 {object} map[string]interface{}
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Router /admin/refunds [get]
+func (h *RefundHandler) GetRefunds(c *gin.Context) {
+	h.getRefunds(c, 0)
+}
+
+// getRefunds 分页查询退款单，userID为0时查询全部
+func (h *RefundHandler) getRefunds(c *gin.Context, userID uint) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	size, _ := strconv.Atoi(c.DefaultQuery("size", "10"))
+
+	req := &service.GetRefundsRequest{
+		UserID: userID,
+		Status: c.Query("status"),
+		Page:   page,
+		Size:   size,
+	}
+
+	resp, err := h.refundService.GetRefunds(c.Request.Context(), req)
+	if err != nil {
+		logger.Log.Error("获取退款单列表失败", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "获取成功",
+		"data":    resp,
+	})
+}
+
+// CreateRefund 创建人工退款
+// @Summary 创建人工退款
+// @Description 为订单创建人工退款单，需审核通过后执行（管理员）
+// @Tags 管理员
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param body body service.CreateRefundRequest true "退款请求"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Router /admin/refunds [post]
+func (h *RefundHandler) CreateRefund(c *gin.Context) {
+	operatorID, _ := c.Get("user_id")
+
+	var req service.CreateRefundRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.Log.Error("绑定退款请求失败", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+
+	req.OperatorID = operatorID.(uint)
+
+	refund, err := h.refundService.CreateRefund(c.Request.Context(), &req)
+	if err != nil {
+		logger.Log.Error("创建退款单失败", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "创建成功",
+		"data":    refund,
+	})
+}
+
+// ApproveRefund 审核通过退款
+// @Summary 审核通过退款
+// @Description 审核通过并执行退款，执行失败的退款单可再次审核重试（管理员）
+// @Tags 管理员
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "退款单ID"
+// @Param body body service.ReviewRefundRequest false "审核备注"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Router /admin/refunds/{id}/approve [post]
+func (h *RefundHandler) ApproveRefund(c *gin.Context) {
+	req, ok := h.bindReview(c)
+	if !ok {
+		return
+	}
+
+	refund, err := h.refundService.ApproveRefund(c.Request.Context(), req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	logger.Log.Info("审核通过退款", zap.Uint("operator_id", req.OperatorID), zap.String("refund_no", refund.RefundNo))
+	c.JSON(http.StatusOK, gin.H{
+		"message": "退款成功",
+		"data":    refund,
+	})
+}
+
+// RejectRefund 驳回退款
+// @Summary 驳回退款
+// @Description 驳回退款申请（管理员）
+// @Tags 管理员
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "退款单ID"
+// @Param body body service.ReviewRefundRequest false "驳回原因"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Router /admin/refunds/{id}/reject [post]
+func (h *RefundHandler) RejectRefund(c *gin.Context) {
+	req, ok := h.bindReview(c)
+	if !ok {
+		return
+	}
+
+	refund, err := h.refundService.RejectRefund(c.Request.Context(), req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "已驳回",
+		"data":    refund,
+	})
+}
+
+// bindReview 解析审核请求
+func (h *RefundHandler) bindReview(c *gin.Context) (*service.ReviewRefundRequest, bool) {
+	operatorID, _ := c.Get("user_id")
+
+	refundID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的退款单ID"})
+		return nil, false
+	}
+
+	var req service.ReviewRefundRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+			return nil, false
+		}
+	}
+
+	req.RefundID = uint(refundID)
+	req.OperatorID = operatorID.(uint)
+	return &req, true
+}
+
+// RefundFailedOrder 开通失败订单全额退款
+// @Summary 开通失败订单全额退款
+// @Description 对开通失败的订单全额退款，无需审核（管理员）
+// @Tags 管理员
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "订单ID"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Router /admin/orders/{id}/refund [post]
+func (h *RefundHandler) RefundFailedOrder(c *gin.Context) {
+	operatorID, _ := c.Get("user_id")
+
+	orderID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的订单ID"})
+		return
+	}
+
+	operator := operatorID.(uint)
+	refund, err := h.refundService.RefundFailedOrder(c.Request.Context(), uint(orderID), &operator)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "退款成功",
+		"data":    refund,
+	})
+}
@@ -0,0 +1,136 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"cloudbp-backend/internal/service"
+	"cloudbp-backend/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// RegionHandler 产品地域处理器
+type RegionHandler struct {
+	db            *gorm.DB
+	rdb           *redis.Client
+	regionService *service.RegionService
+}
+
+// NewRegionHandler 创建产品地域处理器
+func NewRegionHandler(db *gorm.DB, rdb *redis.Client, regionService *service.RegionService) *RegionHandler {
+	return &RegionHandler{
+		db:            db,
+		rdb:           rdb,
+		regionService: regionService,
+	}
+}
+
+// GetProductRegions 获取产品可售地域
+// @Summary 获取产品可售地域
+// @Description 获取产品可购买的地域、可用区和地域售价，下单时从中选择地域和可用区
+// @Tags 服务器
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "产品ID"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Router /server/products/{id}/regions [get]
+func (h *RegionHandler) GetProductRegions(c *gin.Context) {
+	productID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的产品ID"})
+		return
+	}
+
+	offers, err := h.regionService.GetProductRegions(c.Request.Context(), uint(productID))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "获取成功",
+		"data":    offers,
+	})
+}
+
+// GetProductRegionSettings 获取产品地域配置
+// @Summary 获取产品地域配置
+// @Description 获取产品的可售地域、可用区和地域售价配置，未配置时只在产品自身的地域销售（管理员）
+// @Tags 管理员
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "产品ID"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Router /admin/products/{id}/regions [get]
+func (h *RegionHandler) GetProductRegionSettings(c *gin.Context) {
+	productID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的产品ID"})
+		return
+	}
+
+	regions, err := h.regionService.GetProductRegionSettings(c.Request.Context(), uint(productID))
+	if err != nil {
+		logger.Log.Error("获取产品地域失败", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "获取成功",
+		"data":    regions,
+	})
+}
+
+// SetProductRegions 设置产品地域
+// @Summary 设置产品地域
+// @Description 覆盖产品的可售地域配置，地域和可用区须为厂商当前提供的，售价为0表示使用产品售价；传空列表表示只在产品自身的地域销售（管理员）
+// @Tags 管理员
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "产品ID"
+// @Param body body []service.SaveProductRegionRequest true "地域配置"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Router /admin/products/{id}/regions [put]
+func (h *RegionHandler) SetProductRegions(c *gin.Context) {
+	operatorID, _ := c.Get("user_id")
+
+	productID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的产品ID"})
+		return
+	}
+
+	var req []service.SaveProductRegionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.Log.Error("绑定产品地域请求失败", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+
+	regions, err := h.regionService.SetProductRegions(c.Request.Context(), uint(productID), req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	logger.Log.Info("设置产品地域", zap.Any("operator_id", operatorID), zap.Uint64("product_id", productID),
+		zap.Int("regions", len(regions)))
+	c.JSON(http.StatusOK, gin.H{
+		"message": "保存成功",
+		"data":    regions,
+	})
+}
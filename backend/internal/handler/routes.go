@@ -5,14 +5,17 @@ import (
 	"cloudbp-backend/internal/service"
 	"cloudbp-backend/internal/middleware"
 	"cloudbp-backend/internal/config"
+	"cloudbp-backend/internal/task"
 	"cloudbp-backend/pkg/auth"
+	"cloudbp-backend/pkg/logger"
 
 	"github.com/gin-gonic/gin"
 	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
 	"gorm.io/gorm"
 )
 
-func RegisterRoutes(r *gin.RouterGroup, db *gorm.DB, rdb *redis.Client) {
+func RegisterRoutes(r *gin.RouterGroup, db *gorm.DB, rdb *redis.Client, scheduler *task.Scheduler) {
 	// 加载配置
 	cfg, _ := config.Load()
 	
@@ -26,11 +29,72 @@ func RegisterRoutes(r *gin.RouterGroup, db *gorm.DB, rdb *redis.Client) {
 
 	// 创建服务
 	userService := service.NewUserService(db, rdb, jwtManager)
+	providerService := service.NewProviderService(db)
+	paymentService := service.NewPaymentService(db, rdb, cfg.Payment)
+
+	// 初始化云厂商
+	if err := providerService.InitProviders(); err != nil {
+		logger.Log.Error("初始化云厂商失败", zap.Error(err))
+	}
+
+	// 初始化支付通道
+	if err := paymentService.InitGateways(); err != nil {
+		logger.Log.Error("初始化支付通道失败", zap.Error(err))
+	}
+
+	currencyService := service.NewCurrencyService(db, rdb, cfg.Currency)
+	pricingRuleService := service.NewPricingRuleService(db, rdb, cfg.Catalog.MarkupRate)
+	imageService := service.NewImageService(db, rdb)
+	catalogService := service.NewCatalogService(db, rdb, cfg.Catalog, providerService, pricingRuleService, imageService)
+	regionService := service.NewRegionService(db, rdb, catalogService)
+	pricingService := service.NewPricingService(db, rdb, cfg.Pricing, currencyService, pricingRuleService, regionService)
+	refundService := service.NewRefundService(db, rdb, cfg.Refund, paymentService, providerService)
+	invoiceService := service.NewInvoiceService(db, rdb, cfg.Invoice, currencyService.BaseCurrency())
+	provisionService := service.NewProvisionService(db, rdb, providerService, refundService)
+	inventoryService := service.NewInventoryService(db, rdb, providerService)
+	sshKeyService := service.NewSSHKeyService(db, rdb, providerService)
+	userDataService := service.NewUserDataService(db, rdb)
+	firewallService := service.NewFirewallService(db, rdb, providerService)
+	snapshotService := service.NewSnapshotService(db, rdb, cfg.Snapshot, providerService)
+	consoleService := service.NewConsoleService(db, rdb, cfg.Console, providerService)
+	serverGroupService := service.NewServerGroupService(db, rdb)
+	serverService := service.NewServerService(db, rdb, providerService, paymentService, pricingService)
+
+	// 注册后台任务：第三方支付到账的订单由后台开通并接管中断的开通，定期同步厂商售罄状态和产品目录，执行定期备份并续扣快照月费，核对中断的变更套餐订单
+	scheduler.Register("provision_paid_orders", 15*time.Second, provisionService.ProvisionPaidOrders)
+	scheduler.Register("sync_product_availability", 10*time.Minute, inventoryService.SyncAvailability)
+	scheduler.Register("sync_catalog", time.Duration(cfg.Catalog.SyncInterval)*time.Minute, catalogService.RunScheduledSync)
+	scheduler.Register("run_backup_schedules", 5*time.Minute, snapshotService.RunBackupSchedules)
+	scheduler.Register("bill_snapshots", time.Hour, snapshotService.BillSnapshots)
+	scheduler.Register("reconcile_change_plans", 5*time.Minute, serverService.ReconcileChangePlans)
+	scheduler.Register("retry_auto_refunds", 5*time.Minute, refundService.RetryAutoRefunds)
 
 	// 创建处理器
 	authHandler := NewAuthHandler(userService)
-	serverHandler := NewServerHandler(db, rdb)
+	serverHandler := NewServerHandler(db, rdb, providerService, paymentService, pricingService, provisionService)
 	adminHandler := NewAdminHandler(db, rdb)
+	walletHandler := NewWalletHandler(db)
+	paymentHandler := NewPaymentHandler(db, rdb, paymentService)
+	refundHandler := NewRefundHandler(db, rdb, refundService)
+	couponHandler := NewCouponHandler(db, rdb)
+	invoiceHandler := NewInvoiceHandler(db, rdb, invoiceService)
+	currencyHandler := NewCurrencyHandler(db, rdb, currencyService)
+	inventoryHandler := NewInventoryHandler(db, rdb, inventoryService)
+	catalogHandler := NewCatalogHandler(db, rdb, catalogService)
+	pricingRuleHandler := NewPricingRuleHandler(db, rdb, pricingRuleService)
+	imageHandler := NewImageHandler(db, rdb, imageService)
+	regionHandler := NewRegionHandler(db, rdb, regionService)
+	orderHandler := NewOrderHandler(db, rdb, paymentService, provisionService)
+	sshKeyHandler := NewSSHKeyHandler(db, rdb, sshKeyService)
+	userDataHandler := NewUserDataHandler(db, rdb, userDataService)
+	firewallHandler := NewFirewallHandler(db, rdb, firewallService)
+	snapshotHandler := NewSnapshotHandler(db, rdb, snapshotService)
+	providerHandler := NewProviderHandler(db, rdb, providerService)
+	consoleHandler := NewConsoleHandler(consoleService)
+	serverGroupHandler := NewServerGroupHandler(db, rdb, serverGroupService)
+
+	// 下单和支付接口支持 Idempotency-Key，防止重复提交
+	idempotency := middleware.Idempotency(rdb, time.Duration(cfg.Server.IdempotencyTTL)*time.Second)
 
 	// 用户认证相关路由（不需要JWT验证）
 	auth := r.Group("/auth")
@@ -49,18 +113,91 @@ func RegisterRoutes(r *gin.RouterGroup, db *gorm.DB, rdb *redis.Client) {
 		user.PUT("/profile", authHandler.UpdateProfile)
 		user.POST("/change-password", authHandler.ChangePassword)
 		user.GET("/servers", serverHandler.GetUserServers)
+		user.PUT("/currency", currencyHandler.SetUserCurrency)
+		user.GET("/orders", orderHandler.GetUserOrders)
+		user.GET("/orders/:id", orderHandler.GetUserOrder)
+		user.POST("/orders/:id/cancel", orderHandler.CancelOrder)
+		user.POST("/orders/:id/pay", idempotency, orderHandler.PayOrder)
+		user.GET("/wallet", walletHandler.GetWallet)
+		user.GET("/wallet/statement", walletHandler.GetWalletStatement)
+		user.POST("/recharges", idempotency, paymentHandler.CreateRecharge)
+		user.GET("/recharges", paymentHandler.GetRecharges)
+		user.GET("/recharges/:no", paymentHandler.GetRecharge)
+		user.GET("/refunds", refundHandler.GetUserRefunds)
+		user.GET("/invoice-profile", invoiceHandler.GetInvoiceProfile)
+		user.PUT("/invoice-profile", invoiceHandler.UpdateInvoiceProfile)
+		user.POST("/invoices", invoiceHandler.CreateInvoice)
+		user.GET("/invoices", invoiceHandler.GetUserInvoices)
+		user.GET("/invoices/:id", invoiceHandler.GetUserInvoice)
+		user.GET("/invoices/:id/download", invoiceHandler.DownloadUserInvoice)
+		user.GET("/ssh-keys", sshKeyHandler.GetSSHKeys)
+		user.POST("/ssh-keys", sshKeyHandler.CreateSSHKey)
+		user.DELETE("/ssh-keys/:id", sshKeyHandler.DeleteSSHKey)
+		user.GET("/user-data-templates", userDataHandler.GetTemplates)
+		user.POST("/user-data-templates", userDataHandler.CreateTemplate)
+		user.PUT("/user-data-templates/:id", userDataHandler.UpdateTemplate)
+		user.DELETE("/user-data-templates/:id", userDataHandler.DeleteTemplate)
+		user.GET("/server-groups", serverGroupHandler.GetGroups)
+		user.POST("/server-groups", serverGroupHandler.CreateGroup)
+		user.PUT("/server-groups/:id", serverGroupHandler.UpdateGroup)
+		user.DELETE("/server-groups/:id", serverGroupHandler.DeleteGroup)
+		user.GET("/server-tags", serverGroupHandler.GetTagKeys)
 	}
 
+	// 支付相关路由（第三方回调不需要JWT验证，依靠通道签名校验）
+	payment := r.Group("/payment")
+	{
+		payment.GET("/methods", paymentHandler.GetPaymentMethods)
+		payment.GET("/currencies", currencyHandler.GetCurrencies)
+		payment.POST("/notify/:method", paymentHandler.HandleNotify)
+		// 模拟收银台会直接触发支付成功，仅在debug模式下开放
+		if cfg.Payment.Mock.Enabled && cfg.Server.Mode == "debug" {
+			payment.GET("/mock/pay", paymentHandler.MockPay)
+		}
+	}
+
+	// 云厂商相关路由（需要JWT验证）
+	providers := r.Group("/providers")
+	providers.Use(middleware.AuthMiddleware(jwtManager))
+	{
+		providers.GET("/:code/capabilities", providerHandler.GetCapabilities)
+	}
+
+	// 服务器网页控制台（WebSocket连接，JWT可通过查询参数传递）
+	r.GET("/server/:id/console", middleware.WebSocketAuthMiddleware(jwtManager), consoleHandler.Console)
+
 	// 服务器相关路由（需要JWT验证）
 	server := r.Group("/server")
 	server.Use(middleware.AuthMiddleware(jwtManager))
 	{
 		server.GET("/products", serverHandler.GetServerProducts)
-		server.POST("/purchase", serverHandler.PurchaseServer)
+		server.GET("/products/:id/regions", regionHandler.GetProductRegions)
+		server.GET("/regions", catalogHandler.GetRegions)
+		server.GET("/images", imageHandler.GetImages)
+		server.GET("/firewall-presets", firewallHandler.GetFirewallPresets)
+		server.POST("/quote", serverHandler.QuoteOrder)
+		server.POST("/purchase", idempotency, serverHandler.PurchaseServer)
 		server.GET("/:id", serverHandler.GetServerDetail)
 		server.POST("/:id/start", serverHandler.StartServer)
 		server.POST("/:id/stop", serverHandler.StopServer)
 		server.POST("/:id/restart", serverHandler.RestartServer)
+		server.POST("/:id/renew", idempotency, serverHandler.RenewServer)
+		server.POST("/:id/change-plan", idempotency, serverHandler.ChangePlan)
+		server.POST("/:id/rebuild", serverHandler.RebuildServer)
+		server.PUT("/:id/group", serverGroupHandler.SetServerGroup)
+		server.PUT("/:id/tags", serverGroupHandler.SetServerTags)
+		server.GET("/:id/firewall", firewallHandler.GetFirewallRules)
+		server.POST("/:id/firewall", firewallHandler.AddFirewallRules)
+		server.DELETE("/:id/firewall/:rule_id", firewallHandler.DeleteFirewallRule)
+		server.GET("/:id/snapshots", snapshotHandler.GetSnapshots)
+		server.POST("/:id/snapshots", idempotency, snapshotHandler.CreateSnapshot)
+		server.POST("/:id/snapshots/:snapshot_id/restore", snapshotHandler.RestoreSnapshot)
+		server.DELETE("/:id/snapshots/:snapshot_id", snapshotHandler.DeleteSnapshot)
+		server.GET("/:id/backup-schedule", snapshotHandler.GetBackupSchedule)
+		server.PUT("/:id/backup-schedule", snapshotHandler.SaveBackupSchedule)
+		server.DELETE("/:id/backup-schedule", snapshotHandler.DeleteBackupSchedule)
+		server.GET("/:id/refund-quote", refundHandler.GetRefundQuote)
+		server.POST("/:id/terminate", refundHandler.TerminateServer)
 	}
 
 	// 管理员相关路由（需要JWT验证和管理员权限）
@@ -69,7 +206,49 @@ func RegisterRoutes(r *gin.RouterGroup, db *gorm.DB, rdb *redis.Client) {
 	{
 		admin.GET("/dashboard", adminHandler.GetDashboard)
 		admin.GET("/users", adminHandler.GetUsers)
+		admin.POST("/users/:id/balance", adminHandler.AdjustUserBalance)
+		admin.PUT("/users/:id/price-group", pricingRuleHandler.SetUserPriceGroup)
 		admin.GET("/orders", adminHandler.GetOrders)
+		admin.POST("/orders/:id/refund", refundHandler.RefundFailedOrder)
+		admin.GET("/refunds", refundHandler.GetRefunds)
+		admin.POST("/refunds", refundHandler.CreateRefund)
+		admin.POST("/refunds/:id/approve", refundHandler.ApproveRefund)
+		admin.POST("/refunds/:id/reject", refundHandler.RejectRefund)
+		admin.GET("/invoices", invoiceHandler.GetInvoices)
+		admin.GET("/invoices/:id/download", invoiceHandler.DownloadInvoice)
+		admin.POST("/invoices/:id/void", invoiceHandler.VoidInvoice)
 		admin.GET("/products", adminHandler.GetProducts)
+		admin.POST("/products/sync-availability", inventoryHandler.SyncAvailability)
+		admin.PUT("/products/:id/inventory", inventoryHandler.UpdateInventory)
+		admin.GET("/products/:id/regions", regionHandler.GetProductRegionSettings)
+		admin.PUT("/products/:id/regions", regionHandler.SetProductRegions)
+		admin.POST("/catalog/sync", catalogHandler.SyncCatalog)
+		admin.GET("/images", imageHandler.GetImageCatalog)
+		admin.POST("/images", imageHandler.CreateImage)
+		admin.PUT("/images/:id", imageHandler.UpdateImage)
+		admin.DELETE("/images/:id", imageHandler.DeleteImage)
+		admin.GET("/user-data-templates", userDataHandler.GetGlobalTemplates)
+		admin.POST("/user-data-templates", userDataHandler.CreateGlobalTemplate)
+		admin.PUT("/user-data-templates/:id", userDataHandler.UpdateGlobalTemplate)
+		admin.DELETE("/user-data-templates/:id", userDataHandler.DeleteGlobalTemplate)
+		admin.GET("/pricing-rules", pricingRuleHandler.GetPricingRules)
+		admin.POST("/pricing-rules", pricingRuleHandler.CreatePricingRule)
+		admin.PUT("/pricing-rules/:id", pricingRuleHandler.UpdatePricingRule)
+		admin.DELETE("/pricing-rules/:id", pricingRuleHandler.DeletePricingRule)
+		admin.POST("/pricing-rules/preview", pricingRuleHandler.PreviewPrices)
+		admin.POST("/pricing-rules/publish", pricingRuleHandler.PublishPrices)
+		admin.GET("/exchange-rates", currencyHandler.GetExchangeRates)
+		admin.POST("/exchange-rates/import", currencyHandler.ImportExchangeRates)
+		admin.PUT("/exchange-rates/:currency", currencyHandler.SaveExchangeRate)
+		admin.DELETE("/exchange-rates/:currency", currencyHandler.DeleteExchangeRate)
+		admin.GET("/coupons", couponHandler.GetCoupons)
+		admin.POST("/coupons", couponHandler.CreateCoupon)
+		admin.PUT("/coupons/:id", couponHandler.UpdateCoupon)
+		admin.DELETE("/coupons/:id", couponHandler.DeleteCoupon)
+		admin.GET("/promotions", couponHandler.GetPromotions)
+		admin.POST("/promotions", couponHandler.CreatePromotion)
+		admin.PUT("/promotions/:id", couponHandler.UpdatePromotion)
+		admin.DELETE("/promotions/:id", couponHandler.DeletePromotion)
+		admin.POST("/ledger/reconcile", adminHandler.ReconcileLedger)
 	}
 }
\ No newline at end of file
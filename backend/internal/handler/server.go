@@ -1,9 +1,12 @@
 package handler
 
 import (
+	"context"
+	"errors"
 	"net/http"
 	"strconv"
 
+	"cloudbp-backend/internal/model"
 	"cloudbp-backend/internal/service"
 	"cloudbp-backend/pkg/logger"
 
@@ -15,37 +18,43 @@ import (
 
 // ServerHandler 服务器处理器
 type ServerHandler struct {
-	db              *gorm.DB
-	rdb             *redis.Client
-	serverService   *service.ServerService
-	providerService *service.ProviderService
+	db               *gorm.DB
+	rdb              *redis.Client
+	serverService    *service.ServerService
+	providerService  *service.ProviderService
+	pricingService   *service.PricingService
+	provisionService *service.ProvisionService
 }
 
 // NewServerHandler 创建服务器处理器
-func NewServerHandler(db *gorm.DB, rdb *redis.Client) *ServerHandler {
-	serverService := service.NewServerService(db, rdb)
-	providerService := service.NewProviderService(db)
-	
-	// 初始化云厂商
-	if err := providerService.InitProviders(); err != nil {
-		logger.Log.Error("初始化云厂商失败", zap.Error(err))
-	}
+func NewServerHandler(db *gorm.DB, rdb *redis.Client, providerService *service.ProviderService, paymentService *service.PaymentService, pricingService *service.PricingService, provisionService *service.ProvisionService) *ServerHandler {
+	serverService := service.NewServerService(db, rdb, providerService, paymentService, pricingService)
 
 	return &ServerHandler{
-		db:              db,
-		rdb:             rdb,
-		serverService:   serverService,
-		providerService: providerService,
+		db:               db,
+		rdb:              rdb,
+		serverService:    serverService,
+		providerService:  providerService,
+		pricingService:   pricingService,
+		provisionService: provisionService,
 	}
 }
 
 // GetUserServers 获取用户服务器列表
 // @Summary 获取用户服务器列表
-// @Description 获取当前用户的服务器列表
+// @Description 获取当前用户的服务器列表，支持按状态、地域、厂商、分组、标签筛选和按名称或IP搜索
 // @Tags 服务器
 // @Accept json
 // @Produce json
 // @Security ApiKeyAuth
+// @Param status query string false "服务器状态"
+// @Param region query string false "地域"
+// @Param provider_id query int false "厂商ID"
+// @Param group_id query int false "分组ID"
+// @Param tag query []string false "标签，格式为 键 或 键:值，可传多个，须同时满足" collectionFormat(multi)
+// @Param keyword query string false "按名称、公网IP、私网IP或实例ID搜索"
+// @Param sort query string false "排序字段" Enums(created_at, expire_time, name)
+// @Param order query string false "排序方向，默认创建时间倒序，到期时间和名称正序" Enums(asc, desc)
 // @Param page query int false "页码" default(1)
 // @Param size query int false "每页数量" default(10)
 // @Success 200 {object} map[string]interface{}
@@ -62,10 +71,21 @@ func (h *ServerHandler) GetUserServers(c *gin.Context) {
 	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
 	size, _ := strconv.Atoi(c.DefaultQuery("size", "10"))
 
+	providerID, _ := strconv.ParseUint(c.Query("provider_id"), 10, 32)
+	groupID, _ := strconv.ParseUint(c.Query("group_id"), 10, 32)
+
 	req := &service.GetUserServersRequest{
-		UserID: userID.(uint),
-		Page:   page,
-		Size:   size,
+		UserID:     userID.(uint),
+		Status:     c.Query("status"),
+		Region:     c.Query("region"),
+		ProviderID: uint(providerID),
+		GroupID:    uint(groupID),
+		Tags:       c.QueryArray("tag"),
+		Keyword:    c.Query("keyword"),
+		Sort:       c.Query("sort"),
+		Order:      c.Query("order"),
+		Page:       page,
+		Size:       size,
 	}
 
 	resp, err := h.serverService.GetUserServers(c.Request.Context(), req)
@@ -119,15 +139,18 @@ func (h *ServerHandler) GetServerProducts(c *gin.Context) {
 
 // PurchaseServer 购买服务器
 // @Summary 购买服务器
-// @Description 创建服务器购买订单
+// @Description 创建服务器购买订单，可选择产品可售的地域和可用区，购买多台时按名称模板（如 web-{n}）命名；余额支付立即开通，第三方支付返回支付二维码或跳转地址，到账后开通；开通失败的台数自动退款
 // @Tags 服务器
 // @Accept json
 // @Produce json
 // @Security ApiKeyAuth
+// @Param Idempotency-Key header string false "幂等键，相同幂等键的重复请求返回首次结果"
 // @Param body body service.PurchaseServerRequest true "购买请求"
 // @Success 200 {object} map[string]interface{}
 // @Failure 400 {object} map[string]string
 // @Failure 401 {object} map[string]string
+// @Failure 402 {object} map[string]string
+// @Failure 409 {object} map[string]string
 // @Router /server/purchase [post]
 func (h *ServerHandler) PurchaseServer(c *gin.Context) {
 	userID, exists := c.Get("user_id")
@@ -144,16 +167,31 @@ func (h *ServerHandler) PurchaseServer(c *gin.Context) {
 	}
 
 	req.UserID = userID.(uint)
+	req.ClientIP = c.ClientIP()
 
 	resp, err := h.serverService.PurchaseServer(c.Request.Context(), &req)
 	if err != nil {
 		logger.Log.Error("购买服务器失败", zap.Error(err))
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		if errors.Is(err, service.ErrInsufficientBalance) {
+			c.JSON(http.StatusPaymentRequired, gin.H{"error": err.Error()})
+		} else if errors.Is(err, service.ErrOutOfStock) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		} else {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		}
 		return
 	}
 
+	message := "购买成功，服务器开通中"
+	if resp.Status == model.OrderStatusPending {
+		message = "订单已创建，请完成支付"
+	} else {
+		// 余额支付已完成，立即开通服务器
+		go h.provisionOrder(resp.OrderID)
+	}
+
 	c.JSON(http.StatusOK, gin.H{
-		"message": "购买成功",
+		"message": message,
 		"data":    resp,
 	})
 }
@@ -333,4 +371,230 @@ func (h *ServerHandler) RestartServer(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{
 		"message": "重启命令已发送",
 	})
-}
\ No newline at end of file
+}
+
+// RebuildServer 重装服务器系统
+// @Summary 重装服务器系统
+// @Description 使用服务器所在地域下可选的镜像重装系统，可重新选择注入的SSH公钥、禁用密码登录并指定用户数据或模板，系统盘数据将被清除
+// @Tags 服务器
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "服务器ID"
+// @Param body body service.RebuildServerRequest true "重装系统请求"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /server/{id}/rebuild [post]
+func (h *ServerHandler) RebuildServer(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未找到用户信息"})
+		return
+	}
+
+	serverID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的服务器ID"})
+		return
+	}
+
+	var req service.RebuildServerRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.Log.Error("绑定重装系统请求失败", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+
+	req.ServerID = uint(serverID)
+	req.UserID = userID.(uint)
+
+	server, err := h.serverService.RebuildServer(c.Request.Context(), &req)
+	if err != nil {
+		logger.Log.Error("重装系统失败", zap.Uint("server_id", req.ServerID), zap.Error(err))
+		if err.Error() == "服务器不存在" {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		} else {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "重装成功",
+		"data":    server,
+	})
+}
+
+// ChangePlan 变更服务器套餐
+// @Summary 变更服务器套餐
+// @Description 将服务器升级或降级到同厂商同类型的其他套餐，按剩余时长折算差价并从余额补缴或退还
+// @Tags 服务器
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param Idempotency-Key header string false "幂等键，相同幂等键的重复请求返回首次结果"
+// @Param id path int true "服务器ID"
+// @Param body body service.ChangePlanRequest true "变更套餐请求"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 402 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /server/{id}/change-plan [post]
+func (h *ServerHandler) ChangePlan(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未找到用户信息"})
+		return
+	}
+
+	serverID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的服务器ID"})
+		return
+	}
+
+	var req service.ChangePlanRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.Log.Error("绑定变更套餐请求失败", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+
+	req.ServerID = uint(serverID)
+	req.UserID = userID.(uint)
+
+	resp, err := h.serverService.ChangePlan(c.Request.Context(), &req)
+	if err != nil {
+		logger.Log.Error("变更套餐失败", zap.Error(err))
+		if err.Error() == "服务器不存在" {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		} else if errors.Is(err, service.ErrInsufficientBalance) {
+			c.JSON(http.StatusPaymentRequired, gin.H{"error": err.Error()})
+		} else {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "变更成功",
+		"data":    resp,
+	})
+}
+
+// QuoteOrder 订单报价
+// @Summary 订单报价
+// @Description 计算新购、续费或升降配订单的计价明细：原价、长周期折扣、促销、优惠券、税费和应付金额
+// @Tags 服务器
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param body body service.QuoteRequest true "报价请求"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Router /server/quote [post]
+func (h *ServerHandler) QuoteOrder(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未找到用户信息"})
+		return
+	}
+
+	var req service.QuoteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.Log.Error("绑定报价请求失败", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+
+	req.UserID = userID.(uint)
+
+	resp, err := h.pricingService.Quote(c.Request.Context(), &req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "获取成功",
+		"data":    resp,
+	})
+}
+
+// RenewServer 续费服务器
+// @Summary 续费服务器
+// @Description 续费服务器，支持优惠券和第三方支付，支付完成后续费厂商实例并顺延到期时间
+// @Tags 服务器
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param Idempotency-Key header string false "幂等键，相同幂等键的重复请求返回首次结果"
+// @Param id path int true "服务器ID"
+// @Param body body service.RenewServerRequest true "续费请求"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 402 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /server/{id}/renew [post]
+func (h *ServerHandler) RenewServer(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未找到用户信息"})
+		return
+	}
+
+	serverID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的服务器ID"})
+		return
+	}
+
+	var req service.RenewServerRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.Log.Error("绑定续费请求失败", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+
+	req.ServerID = uint(serverID)
+	req.UserID = userID.(uint)
+	req.ClientIP = c.ClientIP()
+
+	resp, err := h.serverService.RenewServer(c.Request.Context(), &req)
+	if err != nil {
+		logger.Log.Error("续费服务器失败", zap.Error(err))
+		if err.Error() == "服务器不存在" {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		} else if errors.Is(err, service.ErrInsufficientBalance) {
+			c.JSON(http.StatusPaymentRequired, gin.H{"error": err.Error()})
+		} else {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	message := "支付成功，正在续费"
+	if resp.Status == model.OrderStatusPending {
+		message = "订单已创建，请完成支付"
+	} else {
+		// 余额支付已完成，后台续费厂商实例并顺延到期时间
+		go h.provisionOrder(resp.OrderID)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": message,
+		"data":    resp,
+	})
+}
+
+// provisionOrder 后台开通已支付订单的服务器或续费厂商实例，失败时由后台任务重试
+func (h *ServerHandler) provisionOrder(orderID uint) {
+	if err := h.provisionService.ProvisionOrder(context.Background(), orderID); err != nil {
+		logger.Log.Error("开通订单失败", zap.Uint("order_id", orderID), zap.Error(err))
+	}
+}
@@ -0,0 +1,308 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"cloudbp-backend/internal/service"
+	"cloudbp-backend/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// ServerGroupHandler 服务器分组和标签处理器
+type ServerGroupHandler struct {
+	db                 *gorm.DB
+	rdb                *redis.Client
+	serverGroupService *service.ServerGroupService
+}
+
+// NewServerGroupHandler 创建服务器分组和标签处理器
+func NewServerGroupHandler(db *gorm.DB, rdb *redis.Client, serverGroupService *service.ServerGroupService) *ServerGroupHandler {
+	return &ServerGroupHandler{
+		db:                 db,
+		rdb:                rdb,
+		serverGroupService: serverGroupService,
+	}
+}
+
+// GetGroups 获取服务器分组
+// @Summary 获取服务器分组
+// @Description 获取当前用户的服务器分组（项目）及各分组的服务器数量
+// @Tags 服务器
+// @Produce json
+// @Security ApiKeyAuth
+// @Success 200 {object} map[string]interface{}
+// @Failure 401 {object} map[string]string
+// @Router /user/server-groups [get]
+func (h *ServerGroupHandler) GetGroups(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未找到用户信息"})
+		return
+	}
+
+	groups, err := h.serverGroupService.GetGroups(c.Request.Context(), userID.(uint))
+	if err != nil {
+		logger.Log.Error("获取服务器分组失败", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "获取成功",
+		"data":    groups,
+	})
+}
+
+// CreateGroup 创建服务器分组
+// @Summary 创建服务器分组
+// @Description 创建服务器分组（项目），同一用户下名称不能重复
+// @Tags 服务器
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param body body service.ServerGroupRequest true "分组信息"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Router /user/server-groups [post]
+func (h *ServerGroupHandler) CreateGroup(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未找到用户信息"})
+		return
+	}
+
+	var req service.ServerGroupRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.Log.Error("绑定分组请求失败", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+
+	group, err := h.serverGroupService.CreateGroup(c.Request.Context(), userID.(uint), &req)
+	if err != nil {
+		logger.Log.Error("创建服务器分组失败", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "创建成功",
+		"data":    group,
+	})
+}
+
+// UpdateGroup 修改服务器分组
+// @Summary 修改服务器分组
+// @Description 修改服务器分组的名称和描述
+// @Tags 服务器
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "分组ID"
+// @Param body body service.ServerGroupRequest true "分组信息"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /user/server-groups/{id} [put]
+func (h *ServerGroupHandler) UpdateGroup(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未找到用户信息"})
+		return
+	}
+
+	groupID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的分组ID"})
+		return
+	}
+
+	var req service.ServerGroupRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.Log.Error("绑定分组请求失败", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+
+	group, err := h.serverGroupService.UpdateGroup(c.Request.Context(), userID.(uint), uint(groupID), &req)
+	if err != nil {
+		logger.Log.Error("修改服务器分组失败", zap.Uint64("group_id", groupID), zap.Error(err))
+		respondServerGroupError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "修改成功",
+		"data":    group,
+	})
+}
+
+// DeleteGroup 删除服务器分组
+// @Summary 删除服务器分组
+// @Description 删除服务器分组，分组内的服务器移出分组
+// @Tags 服务器
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "分组ID"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /user/server-groups/{id} [delete]
+func (h *ServerGroupHandler) DeleteGroup(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未找到用户信息"})
+		return
+	}
+
+	groupID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的分组ID"})
+		return
+	}
+
+	if err := h.serverGroupService.DeleteGroup(c.Request.Context(), userID.(uint), uint(groupID)); err != nil {
+		logger.Log.Error("删除服务器分组失败", zap.Uint64("group_id", groupID), zap.Error(err))
+		respondServerGroupError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "删除成功"})
+}
+
+// GetTagKeys 获取已使用的服务器标签
+// @Summary 获取已使用的服务器标签
+// @Description 获取当前用户服务器已使用的标签键和值，用于列表筛选
+// @Tags 服务器
+// @Produce json
+// @Security ApiKeyAuth
+// @Success 200 {object} map[string]interface{}
+// @Failure 401 {object} map[string]string
+// @Router /user/server-tags [get]
+func (h *ServerGroupHandler) GetTagKeys(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未找到用户信息"})
+		return
+	}
+
+	tags, err := h.serverGroupService.GetTagKeys(c.Request.Context(), userID.(uint))
+	if err != nil {
+		logger.Log.Error("获取服务器标签失败", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "获取成功",
+		"data":    tags,
+	})
+}
+
+// SetServerGroup 设置服务器分组
+// @Summary 设置服务器分组
+// @Description 设置服务器所属分组，group_id为空时移出分组
+// @Tags 服务器
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "服务器ID"
+// @Param body body service.SetServerGroupRequest true "分组"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /server/{id}/group [put]
+func (h *ServerGroupHandler) SetServerGroup(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未找到用户信息"})
+		return
+	}
+
+	serverID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的服务器ID"})
+		return
+	}
+
+	var req service.SetServerGroupRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.Log.Error("绑定服务器分组请求失败", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+
+	if err := h.serverGroupService.SetServerGroup(c.Request.Context(), userID.(uint), uint(serverID), &req); err != nil {
+		logger.Log.Error("设置服务器分组失败", zap.Uint64("server_id", serverID), zap.Error(err))
+		respondServerGroupError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "设置成功"})
+}
+
+// SetServerTags 设置服务器标签
+// @Summary 设置服务器标签
+// @Description 整体替换服务器的键值标签，每台最多20个，键不能重复且不能包含冒号
+// @Tags 服务器
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "服务器ID"
+// @Param body body service.SetServerTagsRequest true "标签"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /server/{id}/tags [put]
+func (h *ServerGroupHandler) SetServerTags(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未找到用户信息"})
+		return
+	}
+
+	serverID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的服务器ID"})
+		return
+	}
+
+	var req service.SetServerTagsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.Log.Error("绑定服务器标签请求失败", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+
+	tags, err := h.serverGroupService.SetServerTags(c.Request.Context(), userID.(uint), uint(serverID), &req)
+	if err != nil {
+		logger.Log.Error("设置服务器标签失败", zap.Uint64("server_id", serverID), zap.Error(err))
+		respondServerGroupError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "设置成功",
+		"data":    tags,
+	})
+}
+
+// respondServerGroupError 按错误类型返回状态码
+func respondServerGroupError(c *gin.Context, err error) {
+	switch err.Error() {
+	case "服务器不存在", "分组不存在":
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,336 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"cloudbp-backend/internal/service"
+	"cloudbp-backend/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// SnapshotHandler 服务器快照和定期备份处理器
+type SnapshotHandler struct {
+	db              *gorm.DB
+	rdb             *redis.Client
+	snapshotService *service.SnapshotService
+}
+
+// NewSnapshotHandler 创建服务器快照和定期备份处理器
+func NewSnapshotHandler(db *gorm.DB, rdb *redis.Client, snapshotService *service.SnapshotService) *SnapshotHandler {
+	return &SnapshotHandler{
+		db:              db,
+		rdb:             rdb,
+		snapshotService: snapshotService,
+	}
+}
+
+// GetSnapshots 获取服务器快照
+// @Summary 获取服务器快照
+// @Description 获取服务器的快照列表，以及免费额度、数量上限和超出额度的月费
+// @Tags 服务器
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "服务器ID"
+// @Success 200 {object} service.GetSnapshotsResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /server/{id}/snapshots [get]
+func (h *SnapshotHandler) GetSnapshots(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未找到用户信息"})
+		return
+	}
+
+	serverID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的服务器ID"})
+		return
+	}
+
+	resp, err := h.snapshotService.GetSnapshots(c.Request.Context(), userID.(uint), uint(serverID))
+	if err != nil {
+		respondSnapshotError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "获取成功",
+		"data":    resp,
+	})
+}
+
+// CreateSnapshot 创建服务器快照
+// @Summary 创建服务器快照
+// @Description 为服务器创建手动快照，超出免费额度时从余额扣除首月费用，之后按月续扣
+// @Tags 服务器
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "服务器ID"
+// @Param Idempotency-Key header string false "幂等键"
+// @Param body body service.CreateSnapshotRequest false "快照信息"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 402 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /server/{id}/snapshots [post]
+func (h *SnapshotHandler) CreateSnapshot(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未找到用户信息"})
+		return
+	}
+
+	serverID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的服务器ID"})
+		return
+	}
+
+	var req service.CreateSnapshotRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			logger.Log.Error("绑定创建快照请求失败", zap.Error(err))
+			c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+			return
+		}
+	}
+
+	req.ServerID = uint(serverID)
+	req.UserID = userID.(uint)
+
+	snapshot, err := h.snapshotService.CreateSnapshot(c.Request.Context(), &req)
+	if err != nil {
+		logger.Log.Error("创建快照失败", zap.Uint("server_id", req.ServerID), zap.Error(err))
+		respondSnapshotError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "创建成功",
+		"data":    snapshot,
+	})
+}
+
+// RestoreSnapshot 回滚服务器快照
+// @Summary 回滚服务器快照
+// @Description 使用快照回滚服务器，快照之后写入的数据将丢失
+// @Tags 服务器
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "服务器ID"
+// @Param snapshot_id path int true "快照ID"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /server/{id}/snapshots/{snapshot_id}/restore [post]
+func (h *SnapshotHandler) RestoreSnapshot(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未找到用户信息"})
+		return
+	}
+
+	serverID, snapshotID, ok := parseSnapshotParams(c)
+	if !ok {
+		return
+	}
+
+	if err := h.snapshotService.RestoreSnapshot(c.Request.Context(), userID.(uint), serverID, snapshotID); err != nil {
+		logger.Log.Error("回滚快照失败", zap.Uint("server_id", serverID), zap.Error(err))
+		respondSnapshotError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "回滚成功"})
+}
+
+// DeleteSnapshot 删除服务器快照
+// @Summary 删除服务器快照
+// @Description 删除服务器快照，已扣除的当月费用不退还
+// @Tags 服务器
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "服务器ID"
+// @Param snapshot_id path int true "快照ID"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /server/{id}/snapshots/{snapshot_id} [delete]
+func (h *SnapshotHandler) DeleteSnapshot(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未找到用户信息"})
+		return
+	}
+
+	serverID, snapshotID, ok := parseSnapshotParams(c)
+	if !ok {
+		return
+	}
+
+	if err := h.snapshotService.DeleteSnapshot(c.Request.Context(), userID.(uint), serverID, snapshotID); err != nil {
+		logger.Log.Error("删除快照失败", zap.Uint("server_id", serverID), zap.Error(err))
+		respondSnapshotError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "删除成功"})
+}
+
+// GetBackupSchedule 获取定期备份计划
+// @Summary 获取定期备份计划
+// @Description 获取服务器的定期备份计划，未设置时data为null
+// @Tags 服务器
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "服务器ID"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Router /server/{id}/backup-schedule [get]
+func (h *SnapshotHandler) GetBackupSchedule(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未找到用户信息"})
+		return
+	}
+
+	serverID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的服务器ID"})
+		return
+	}
+
+	schedule, err := h.snapshotService.GetBackupSchedule(c.Request.Context(), userID.(uint), uint(serverID))
+	if err != nil {
+		respondSnapshotError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "获取成功",
+		"data":    schedule,
+	})
+}
+
+// SaveBackupSchedule 设置定期备份计划
+// @Summary 设置定期备份计划
+// @Description 设置每天或每周指定时刻自动创建快照，只保留最近的若干个定期快照，快照数量已满时先删除最早的定期快照
+// @Tags 服务器
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "服务器ID"
+// @Param body body service.SaveBackupScheduleRequest true "备份计划"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /server/{id}/backup-schedule [put]
+func (h *SnapshotHandler) SaveBackupSchedule(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未找到用户信息"})
+		return
+	}
+
+	serverID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的服务器ID"})
+		return
+	}
+
+	var req service.SaveBackupScheduleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.Log.Error("绑定备份计划请求失败", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+
+	req.ServerID = uint(serverID)
+	req.UserID = userID.(uint)
+
+	schedule, err := h.snapshotService.SaveBackupSchedule(c.Request.Context(), &req)
+	if err != nil {
+		logger.Log.Error("设置备份计划失败", zap.Uint("server_id", req.ServerID), zap.Error(err))
+		respondSnapshotError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "设置成功",
+		"data":    schedule,
+	})
+}
+
+// DeleteBackupSchedule 删除定期备份计划
+// @Summary 删除定期备份计划
+// @Description 删除服务器的定期备份计划，已创建的定期快照保留
+// @Tags 服务器
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "服务器ID"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /server/{id}/backup-schedule [delete]
+func (h *SnapshotHandler) DeleteBackupSchedule(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未找到用户信息"})
+		return
+	}
+
+	serverID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的服务器ID"})
+		return
+	}
+
+	if err := h.snapshotService.DeleteBackupSchedule(c.Request.Context(), userID.(uint), uint(serverID)); err != nil {
+		respondSnapshotError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "删除成功"})
+}
+
+// parseSnapshotParams 解析服务器ID和快照ID，失败时已写入响应
+func parseSnapshotParams(c *gin.Context) (uint, uint, bool) {
+	serverID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的服务器ID"})
+		return 0, 0, false
+	}
+	snapshotID, err := strconv.ParseUint(c.Param("snapshot_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的快照ID"})
+		return 0, 0, false
+	}
+	return uint(serverID), uint(snapshotID), true
+}
+
+// respondSnapshotError 按错误类型返回状态码
+func respondSnapshotError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrInsufficientBalance):
+		c.JSON(http.StatusPaymentRequired, gin.H{"error": err.Error()})
+	case err.Error() == "服务器不存在", err.Error() == "快照不存在", err.Error() == "备份计划不存在":
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	}
+}
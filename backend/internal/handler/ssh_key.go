@@ -0,0 +1,133 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"cloudbp-backend/internal/service"
+	"cloudbp-backend/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// SSHKeyHandler SSH公钥处理器
+type SSHKeyHandler struct {
+	db            *gorm.DB
+	rdb           *redis.Client
+	sshKeyService *service.SSHKeyService
+}
+
+// NewSSHKeyHandler 创建SSH公钥处理器
+func NewSSHKeyHandler(db *gorm.DB, rdb *redis.Client, sshKeyService *service.SSHKeyService) *SSHKeyHandler {
+	return &SSHKeyHandler{
+		db:            db,
+		rdb:           rdb,
+		sshKeyService: sshKeyService,
+	}
+}
+
+// GetSSHKeys 获取SSH公钥列表
+// @Summary 获取SSH公钥列表
+// @Description 获取当前用户保存的SSH公钥，购买和重装服务器时可选择注入
+// @Tags 用户
+// @Produce json
+// @Security ApiKeyAuth
+// @Success 200 {object} map[string]interface{}
+// @Failure 401 {object} map[string]string
+// @Router /user/ssh-keys [get]
+func (h *SSHKeyHandler) GetSSHKeys(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未找到用户信息"})
+		return
+	}
+
+	keys, err := h.sshKeyService.GetSSHKeys(c.Request.Context(), userID.(uint))
+	if err != nil {
+		logger.Log.Error("获取公钥列表失败", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "获取成功",
+		"data":    keys,
+	})
+}
+
+// CreateSSHKey 添加SSH公钥
+// @Summary 添加SSH公钥
+// @Description 添加OpenSSH格式的公钥，支持Ed25519、ECDSA和不少于2048位的RSA公钥，同一公钥不能重复添加
+// @Tags 用户
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param body body service.CreateSSHKeyRequest true "公钥信息"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Router /user/ssh-keys [post]
+func (h *SSHKeyHandler) CreateSSHKey(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未找到用户信息"})
+		return
+	}
+
+	var req service.CreateSSHKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+
+	key, err := h.sshKeyService.CreateSSHKey(c.Request.Context(), userID.(uint), &req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "添加成功",
+		"data":    key,
+	})
+}
+
+// DeleteSSHKey 删除SSH公钥
+// @Summary 删除SSH公钥
+// @Description 删除公钥并清理已导入厂商的密钥对，已注入服务器的公钥不受影响
+// @Tags 用户
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "公钥ID"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /user/ssh-keys/{id} [delete]
+func (h *SSHKeyHandler) DeleteSSHKey(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未找到用户信息"})
+		return
+	}
+
+	keyID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的公钥ID"})
+		return
+	}
+
+	if err := h.sshKeyService.DeleteSSHKey(c.Request.Context(), userID.(uint), uint(keyID)); err != nil {
+		if err.Error() == "公钥不存在" {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		} else {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "删除成功"})
+}
@@ -0,0 +1,276 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"cloudbp-backend/internal/service"
+	"cloudbp-backend/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// UserDataHandler 用户数据模板处理器
+type UserDataHandler struct {
+	db              *gorm.DB
+	rdb             *redis.Client
+	userDataService *service.UserDataService
+}
+
+// NewUserDataHandler 创建用户数据模板处理器
+func NewUserDataHandler(db *gorm.DB, rdb *redis.Client, userDataService *service.UserDataService) *UserDataHandler {
+	return &UserDataHandler{
+		db:              db,
+		rdb:             rdb,
+		userDataService: userDataService,
+	}
+}
+
+// GetTemplates 获取用户数据模板
+// @Summary 获取用户数据模板
+// @Description 获取自己的用户数据模板和管理员发布的全局模板，购买和重装服务器时可选择
+// @Tags 用户
+// @Produce json
+// @Security ApiKeyAuth
+// @Success 200 {object} map[string]interface{}
+// @Failure 401 {object} map[string]string
+// @Router /user/user-data-templates [get]
+func (h *UserDataHandler) GetTemplates(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未找到用户信息"})
+		return
+	}
+
+	templates, err := h.userDataService.GetTemplates(c.Request.Context(), userID.(uint))
+	if err != nil {
+		logger.Log.Error("获取用户数据模板失败", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "获取成功",
+		"data":    templates,
+	})
+}
+
+// CreateTemplate 添加用户数据模板
+// @Summary 添加用户数据模板
+// @Description 添加 cloud-config 或 shell 脚本模板，内容中的 {{变量名}} 在购买时替换，内置变量 server_name、region、zone 按服务器替换
+// @Tags 用户
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param body body service.SaveUserDataTemplateRequest true "模板"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Router /user/user-data-templates [post]
+func (h *UserDataHandler) CreateTemplate(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未找到用户信息"})
+		return
+	}
+
+	var req service.SaveUserDataTemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+
+	uid := userID.(uint)
+	template, err := h.userDataService.CreateTemplate(c.Request.Context(), &uid, &req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "添加成功",
+		"data":    template,
+	})
+}
+
+// UpdateTemplate 更新用户数据模板
+// @Summary 更新用户数据模板
+// @Description 更新自己的用户数据模板
+// @Tags 用户
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "模板ID"
+// @Param body body service.SaveUserDataTemplateRequest true "模板"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Router /user/user-data-templates/{id} [put]
+func (h *UserDataHandler) UpdateTemplate(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未找到用户信息"})
+		return
+	}
+	uid := userID.(uint)
+	h.updateTemplate(c, &uid)
+}
+
+// DeleteTemplate 删除用户数据模板
+// @Summary 删除用户数据模板
+// @Description 删除自己的用户数据模板，已开通的服务器不受影响
+// @Tags 用户
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "模板ID"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Router /user/user-data-templates/{id} [delete]
+func (h *UserDataHandler) DeleteTemplate(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未找到用户信息"})
+		return
+	}
+	uid := userID.(uint)
+	h.deleteTemplate(c, &uid)
+}
+
+// GetGlobalTemplates 获取全局用户数据模板
+// @Summary 获取全局用户数据模板
+// @Description 获取管理员发布的全局模板，包括已停用的（管理员）
+// @Tags 管理员
+// @Produce json
+// @Security ApiKeyAuth
+// @Success 200 {object} map[string]interface{}
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Router /admin/user-data-templates [get]
+func (h *UserDataHandler) GetGlobalTemplates(c *gin.Context) {
+	templates, err := h.userDataService.GetGlobalTemplates(c.Request.Context())
+	if err != nil {
+		logger.Log.Error("获取全局用户数据模板失败", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "获取成功",
+		"data":    templates,
+	})
+}
+
+// CreateGlobalTemplate 发布全局用户数据模板
+// @Summary 发布全局用户数据模板
+// @Description 发布对所有用户可见的用户数据模板，如安装Docker、配置监控代理（管理员）
+// @Tags 管理员
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param body body service.SaveUserDataTemplateRequest true "模板"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Router /admin/user-data-templates [post]
+func (h *UserDataHandler) CreateGlobalTemplate(c *gin.Context) {
+	var req service.SaveUserDataTemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.Log.Error("绑定用户数据模板请求失败", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+
+	template, err := h.userDataService.CreateTemplate(c.Request.Context(), nil, &req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	operatorID, _ := c.Get("user_id")
+	logger.Log.Info("发布全局用户数据模板", zap.Any("operator_id", operatorID), zap.Uint("template_id", template.ID))
+	c.JSON(http.StatusOK, gin.H{
+		"message": "添加成功",
+		"data":    template,
+	})
+}
+
+// UpdateGlobalTemplate 更新全局用户数据模板
+// @Summary 更新全局用户数据模板
+// @Description 更新全局模板内容或停用，停用后用户不可再选择（管理员）
+// @Tags 管理员
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "模板ID"
+// @Param body body service.SaveUserDataTemplateRequest true "模板"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Router /admin/user-data-templates/{id} [put]
+func (h *UserDataHandler) UpdateGlobalTemplate(c *gin.Context) {
+	h.updateTemplate(c, nil)
+}
+
+// DeleteGlobalTemplate 删除全局用户数据模板
+// @Summary 删除全局用户数据模板
+// @Description 删除全局模板（管理员）
+// @Tags 管理员
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path int true "模板ID"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Router /admin/user-data-templates/{id} [delete]
+func (h *UserDataHandler) DeleteGlobalTemplate(c *gin.Context) {
+	h.deleteTemplate(c, nil)
+}
+
+// updateTemplate 更新模板，userID为空时更新全局模板
+func (h *UserDataHandler) updateTemplate(c *gin.Context, userID *uint) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的模板ID"})
+		return
+	}
+
+	var req service.SaveUserDataTemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+
+	template, err := h.userDataService.UpdateTemplate(c.Request.Context(), userID, uint(id), &req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "更新成功",
+		"data":    template,
+	})
+}
+
+// deleteTemplate 删除模板，userID为空时删除全局模板
+func (h *UserDataHandler) deleteTemplate(c *gin.Context, userID *uint) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的模板ID"})
+		return
+	}
+
+	if err := h.userDataService.DeleteTemplate(c.Request.Context(), userID, uint(id)); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "删除成功"})
+}
@@ -0,0 +1,122 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"cloudbp-backend/internal/service"
+	"cloudbp-backend/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// WalletHandler 钱包处理器
+type WalletHandler struct {
+	db            *gorm.DB
+	ledgerService *service.LedgerService
+}
+
+// NewWalletHandler 创建钱包处理器
+func NewWalletHandler(db *gorm.DB) *WalletHandler {
+	return &WalletHandler{
+		db:            db,
+		ledgerService: service.NewLedgerService(db),
+	}
+}
+
+// GetWallet 获取钱包余额
+// @Summary 获取钱包余额
+// @Description 获取当前用户的钱包账户和余额
+// @Tags 钱包
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Success 200 {object} map[string]interface{}
+// @Failure 401 {object} map[string]string
+// @Router /user/wallet [get]
+func (h *WalletHandler) GetWallet(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未找到用户信息"})
+		return
+	}
+
+	resp, err := h.ledgerService.GetWallet(c.Request.Context(), userID.(uint))
+	if err != nil {
+		logger.Log.Error("获取钱包失败", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "获取成功",
+		"data":    resp,
+	})
+}
+
+// GetWalletStatement 获取钱包对账单
+// @Summary 获取钱包对账单
+// @Description 获取当前用户钱包的收支明细、期初期末余额
+// @Tags 钱包
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param page query int false "页码" default(1)
+// @Param size query int false "每页数量" default(10)
+// @Param type query string false "交易类型"
+// @Param start_date query string false "开始日期(2006-01-02)"
+// @Param end_date query string false "结束日期(2006-01-02)，包含当天"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Router /user/wallet/statement [get]
+func (h *WalletHandler) GetWalletStatement(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未找到用户信息"})
+		return
+	}
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	size, _ := strconv.Atoi(c.DefaultQuery("size", "10"))
+
+	req := &service.GetWalletStatementRequest{
+		UserID: userID.(uint),
+		Page:   page,
+		Size:   size,
+		Type:   c.Query("type"),
+	}
+
+	if startDate := c.Query("start_date"); startDate != "" {
+		startTime, err := time.ParseInLocation("2006-01-02", startDate, time.Local)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "开始日期格式错误"})
+			return
+		}
+		req.StartTime = &startTime
+	}
+	if endDate := c.Query("end_date"); endDate != "" {
+		endTime, err := time.ParseInLocation("2006-01-02", endDate, time.Local)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "结束日期格式错误"})
+			return
+		}
+		endTime = endTime.AddDate(0, 0, 1)
+		req.EndTime = &endTime
+	}
+
+	resp, err := h.ledgerService.GetWalletStatement(c.Request.Context(), req)
+	if err != nil {
+		logger.Log.Error("获取钱包对账单失败", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "获取成功",
+		"data":    resp,
+	})
+}
@@ -0,0 +1,160 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"cloudbp-backend/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+const (
+	// IdempotencyHeader 幂等键请求头
+	IdempotencyHeader = "Idempotency-Key"
+	// idempotencyReplayedHeader 标记响应为重放的缓存结果
+	idempotencyReplayedHeader = "Idempotent-Replayed"
+	// maxIdempotencyKeyLen 幂等键最大长度
+	maxIdempotencyKeyLen = 128
+	// idempotencyLockTTL 请求处理中状态的最长保留时间，防止进程异常退出后幂等键一直被占用
+	idempotencyLockTTL = 2 * time.Minute
+)
+
+// idempotencyRecord 幂等键在Redis中保存的记录
+type idempotencyRecord struct {
+	Fingerprint string `json:"fingerprint"`            // 请求体摘要，同一幂等键不允许用于不同请求
+	Processing  bool   `json:"processing"`             // 请求是否仍在处理中
+	Status      int    `json:"status,omitempty"`       // 缓存的响应状态码
+	ContentType string `json:"content_type,omitempty"` // 缓存的响应类型
+	Body        []byte `json:"body,omitempty"`         // 缓存的响应体
+}
+
+// responseRecorder 在写出响应的同时保留一份响应体
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *responseRecorder) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *responseRecorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// Idempotency 幂等键中间件
+// 请求携带 Idempotency-Key 头时，同一用户同一接口的相同幂等键只会执行一次，
+// 重复请求直接返回首次请求缓存的响应；未携带时按普通请求处理。
+// 需放在JWT认证中间件之后，幂等键按用户隔离。
+func Idempotency(rdb *redis.Client, ttl time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(IdempotencyHeader)
+		if key == "" {
+			c.Next()
+			return
+		}
+		if len(key) > maxIdempotencyKeyLen {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Idempotency-Key长度不能超过%d", maxIdempotencyKeyLen)})
+			c.Abort()
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "读取请求体失败"})
+			c.Abort()
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		sum := sha256.Sum256(body)
+		fingerprint := hex.EncodeToString(sum[:])
+		userID, _ := c.Get("user_id")
+		redisKey := fmt.Sprintf("idempotency:%v:%s:%s:%s", userID, c.Request.Method, c.FullPath(), key)
+		ctx := c.Request.Context()
+
+		// 占用幂等键，占用失败说明已有相同幂等键的请求
+		lock, _ := json.Marshal(idempotencyRecord{Fingerprint: fingerprint, Processing: true})
+		acquired, err := rdb.SetNX(ctx, redisKey, lock, idempotencyLockTTL).Result()
+		if err != nil {
+			logger.Log.Error("占用幂等键失败", zap.String("key", redisKey), zap.Error(err))
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "幂等校验暂不可用，请稍后重试"})
+			c.Abort()
+			return
+		}
+		if !acquired {
+			replayIdempotentResponse(c, rdb, redisKey, fingerprint)
+			return
+		}
+
+		recorder := &responseRecorder{ResponseWriter: c.Writer}
+		c.Writer = recorder
+		c.Next()
+
+		// 服务端错误不缓存，释放幂等键允许客户端重试
+		status := recorder.Status()
+		if status >= http.StatusInternalServerError {
+			if err := rdb.Del(context.WithoutCancel(ctx), redisKey).Err(); err != nil {
+				logger.Log.Error("释放幂等键失败", zap.String("key", redisKey), zap.Error(err))
+			}
+			return
+		}
+
+		record, _ := json.Marshal(idempotencyRecord{
+			Fingerprint: fingerprint,
+			Status:      status,
+			ContentType: recorder.Header().Get("Content-Type"),
+			Body:        recorder.body.Bytes(),
+		})
+		if err := rdb.Set(context.WithoutCancel(ctx), redisKey, record, ttl).Err(); err != nil {
+			logger.Log.Error("缓存幂等响应失败", zap.String("key", redisKey), zap.Error(err))
+		}
+	}
+}
+
+// replayIdempotentResponse 返回幂等键对应的缓存响应
+func replayIdempotentResponse(c *gin.Context, rdb *redis.Client, redisKey, fingerprint string) {
+	data, err := rdb.Get(c.Request.Context(), redisKey).Bytes()
+	if err != nil {
+		// 首个请求恰好失败释放了幂等键
+		if err == redis.Nil {
+			c.JSON(http.StatusConflict, gin.H{"error": "请求正在处理中，请稍后重试"})
+		} else {
+			logger.Log.Error("读取幂等记录失败", zap.String("key", redisKey), zap.Error(err))
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "幂等校验暂不可用，请稍后重试"})
+		}
+		c.Abort()
+		return
+	}
+
+	var record idempotencyRecord
+	if err := json.Unmarshal(data, &record); err != nil {
+		logger.Log.Error("解析幂等记录失败", zap.String("key", redisKey), zap.Error(err))
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "幂等校验暂不可用，请稍后重试"})
+		c.Abort()
+		return
+	}
+
+	switch {
+	case record.Fingerprint != fingerprint:
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Idempotency-Key已用于其他请求"})
+	case record.Processing:
+		c.JSON(http.StatusConflict, gin.H{"error": "请求正在处理中，请稍后重试"})
+	default:
+		c.Header(idempotencyReplayedHeader, "true")
+		c.Data(record.Status, record.ContentType, record.Body)
+	}
+	c.Abort()
+}
@@ -2,6 +2,7 @@ package middleware
 
 import (
 	"net/http"
+	"net/url"
 	"strings"
 	"time"
 
@@ -29,7 +30,7 @@ func Logger() gin.HandlerFunc {
 		bodySize := c.Writer.Size()
 
 		if raw != "" {
-			path = path + "?" + raw
+			path = path + "?" + redactQuery(raw)
 		}
 
 		logger.Log.Info("HTTP请求",
@@ -43,12 +44,23 @@ func Logger() gin.HandlerFunc {
 	}
 }
 
+// redactQuery 隐藏查询参数中的令牌，避免写入日志
+func redactQuery(raw string) string {
+	values, err := url.ParseQuery(raw)
+	if err != nil || !values.Has("token") {
+		return raw
+	}
+	values.Set("token", "***")
+	return values.Encode()
+}
+
 func Cors() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		c.Header("Access-Control-Allow-Origin", "*")
 		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		c.Header("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		c.Header("Access-Control-Allow-Headers", "Content-Type, Authorization, Idempotency-Key")
 		c.Header("Access-Control-Max-Age", "86400")
+		c.Header("Access-Control-Expose-Headers", "Idempotent-Replayed")
 
 		if c.Request.Method == "OPTIONS" {
 			c.AbortWithStatus(204)
@@ -97,6 +109,36 @@ func AuthMiddleware(jwtManager *auth.JWTManager) gin.HandlerFunc {
 	}
 }
 
+// WebSocket认证中间件，浏览器建立WebSocket连接时不能设置请求头，允许通过token查询参数传递JWT令牌
+func WebSocketAuthMiddleware(jwtManager *auth.JWTManager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := c.Query("token")
+		if authHeader := c.GetHeader("Authorization"); authHeader != "" {
+			token = strings.TrimPrefix(authHeader, "Bearer ")
+		}
+		if token == "" {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "缺少令牌"})
+			c.Abort()
+			return
+		}
+
+		// 验证JWT令牌
+		claims, err := jwtManager.ValidateToken(token)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "无效的令牌"})
+			c.Abort()
+			return
+		}
+
+		// 将用户信息设置到上下文中
+		c.Set("user_id", claims.UserID)
+		c.Set("username", claims.Username)
+		c.Set("role", claims.Role)
+
+		c.Next()
+	}
+}
+
 // 管理员权限中间件
 func AdminMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -0,0 +1,80 @@
+package model
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+// Coupon 优惠券模型，用户下单时输入券码使用
+type Coupon struct {
+	ID                uint            `gorm:"primaryKey" json:"id"`
+	Code              string          `gorm:"unique;not null" json:"code"`                      // 券码
+	Name              string          `gorm:"not null" json:"name"`                             // 名称
+	Type              string          `gorm:"not null" json:"type"`                             // fixed:满减 percent:折扣
+	Value             decimal.Decimal `gorm:"type:decimal(10,2);not null" json:"value"`         // 满减金额，或折扣百分比（20表示减免20%）
+	MaxDiscount       decimal.Decimal `gorm:"type:decimal(10,2);default:0" json:"max_discount"` // 折扣券最高优惠金额，0表示不限
+	MinAmount         decimal.Decimal `gorm:"type:decimal(10,2);default:0" json:"min_amount"`   // 最低消费金额
+	ProductIDs        []uint          `gorm:"type:text;serializer:json" json:"product_ids"`     // 适用产品，为空表示不限
+	ProviderIDs       []uint          `gorm:"type:text;serializer:json" json:"provider_ids"`    // 适用厂商，为空表示不限
+	OrderTypes        []string        `gorm:"type:text;serializer:json" json:"order_types"`     // 适用订单类型new、renew，为空表示不限
+	FirstPurchaseOnly bool            `gorm:"default:false" json:"first_purchase_only"`         // 仅限首单
+	TotalLimit        int             `gorm:"default:0" json:"total_limit"`                     // 总发放次数，0表示不限
+	PerUserLimit      int             `gorm:"default:1" json:"per_user_limit"`                  // 每用户可用次数，0表示不限
+	UsedCount         int             `gorm:"default:0" json:"used_count"`                      // 已使用次数
+	StartTime         *time.Time      `json:"start_time"`                                       // 生效时间
+	EndTime           *time.Time      `json:"end_time"`                                         // 失效时间
+	Status            int             `gorm:"default:1" json:"status"`                          // 1:启用 2:禁用
+	Description       string          `gorm:"type:text" json:"description"`                     // 描述
+	CreatedAt         time.Time       `json:"created_at"`
+	UpdatedAt         time.Time       `json:"updated_at"`
+	DeletedAt         gorm.DeletedAt  `gorm:"index" json:"-"`
+}
+
+// TableName 指定表名
+func (Coupon) TableName() string {
+	return "coupons"
+}
+
+// CouponUsage 优惠券使用记录，订单关闭时释放
+type CouponUsage struct {
+	ID             uint            `gorm:"primaryKey" json:"id"`
+	CouponID       uint            `gorm:"not null;index" json:"coupon_id"`                    // 优惠券ID
+	UserID         uint            `gorm:"not null;index" json:"user_id"`                      // 用户ID
+	OrderID        uint            `gorm:"not null;uniqueIndex" json:"order_id"`               // 订单ID
+	DiscountAmount decimal.Decimal `gorm:"type:decimal(10,2);not null" json:"discount_amount"` // 优惠金额
+	CreatedAt      time.Time       `json:"created_at"`
+}
+
+// TableName 指定表名
+func (CouponUsage) TableName() string {
+	return "coupon_usages"
+}
+
+// Promotion 自动促销活动，下单时自动匹配优惠力度最大的一个
+type Promotion struct {
+	ID                uint            `gorm:"primaryKey" json:"id"`
+	Name              string          `gorm:"not null" json:"name"`                             // 活动名称
+	Type              string          `gorm:"not null" json:"type"`                             // fixed:满减 percent:折扣
+	Value             decimal.Decimal `gorm:"type:decimal(10,2);not null" json:"value"`         // 满减金额，或折扣百分比
+	MaxDiscount       decimal.Decimal `gorm:"type:decimal(10,2);default:0" json:"max_discount"` // 折扣最高优惠金额，0表示不限
+	MinAmount         decimal.Decimal `gorm:"type:decimal(10,2);default:0" json:"min_amount"`   // 最低消费金额
+	MinPeriod         int             `gorm:"default:0" json:"min_period"`                      // 最短购买月数，如年付优惠填12
+	ProductIDs        []uint          `gorm:"type:text;serializer:json" json:"product_ids"`     // 适用产品，为空表示不限
+	ProviderIDs       []uint          `gorm:"type:text;serializer:json" json:"provider_ids"`    // 适用厂商，为空表示不限
+	OrderTypes        []string        `gorm:"type:text;serializer:json" json:"order_types"`     // 适用订单类型new、renew，为空表示不限
+	FirstPurchaseOnly bool            `gorm:"default:false" json:"first_purchase_only"`         // 仅限首单
+	StartTime         *time.Time      `json:"start_time"`                                       // 开始时间
+	EndTime           *time.Time      `json:"end_time"`                                         // 结束时间
+	Status            int             `gorm:"default:1" json:"status"`                          // 1:启用 2:禁用
+	Description       string          `gorm:"type:text" json:"description"`                     // 描述
+	CreatedAt         time.Time       `json:"created_at"`
+	UpdatedAt         time.Time       `json:"updated_at"`
+	DeletedAt         gorm.DeletedAt  `gorm:"index" json:"-"`
+}
+
+// TableName 指定表名
+func (Promotion) TableName() string {
+	return "promotions"
+}
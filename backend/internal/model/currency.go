@@ -0,0 +1,23 @@
+package model
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// ExchangeRate 汇率，每个外币一行，本位币不需要配置
+type ExchangeRate struct {
+	ID         uint            `gorm:"primaryKey" json:"id"`
+	Currency   string          `gorm:"unique;not null" json:"currency"`         // 币种代码，如USD
+	Rate       decimal.Decimal `gorm:"type:decimal(18,8);not null" json:"rate"` // 1单位该币种折合本位币
+	Source     string          `gorm:"default:manual" json:"source"`            // manual、file
+	OperatorID *uint           `json:"operator_id"`                             // 最后更新人
+	CreatedAt  time.Time       `json:"created_at"`
+	UpdatedAt  time.Time       `json:"updated_at"`
+}
+
+// TableName 指定表名
+func (ExchangeRate) TableName() string {
+	return "exchange_rates"
+}
@@ -0,0 +1,23 @@
+package model
+
+import (
+	"time"
+)
+
+// FirewallRule 服务器防火墙规则，与厂商侧规则保持一致；首次查看时从厂商导入实例的默认规则
+type FirewallRule struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	ServerID    uint      `gorm:"not null;index" json:"server_id"`  // 服务器ID
+	Protocol    string    `gorm:"not null" json:"protocol"`         // TCP、UDP、ICMP、ALL
+	Port        string    `gorm:"not null" json:"port"`             // 端口，如 22、80,443、8000-9000，ICMP和ALL协议为ALL
+	CIDR        string    `gorm:"column:cidr;not null" json:"cidr"` // 来源网段
+	Action      string    `gorm:"not null" json:"action"`           // ACCEPT、DROP
+	Description string    `json:"description"`                      // 备注
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// TableName 指定表名
+func (FirewallRule) TableName() string {
+	return "firewall_rules"
+}
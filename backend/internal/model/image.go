@@ -0,0 +1,34 @@
+package model
+
+import (
+	"time"
+)
+
+// Image 镜像目录，系统镜像从厂商同步，应用镜像（如Docker、WordPress）由管理员维护；
+// 购买时只能选择产品所在厂商和地域下启用的镜像
+type Image struct {
+	ID          uint       `gorm:"primaryKey" json:"id"`
+	ProviderID  uint       `gorm:"not null;uniqueIndex:idx_images_provider_region_image" json:"provider_id"` // 提供商ID
+	Region      string     `gorm:"not null;uniqueIndex:idx_images_provider_region_image" json:"region"`      // 地域
+	ImageID     string     `gorm:"not null;uniqueIndex:idx_images_provider_region_image" json:"image_id"`    // 厂商镜像ID
+	Name        string     `gorm:"not null" json:"name"`                                                     // 展示名称
+	OSType      string     `json:"os_type"`                                                                  // 操作系统类型 LINUX、WINDOWS
+	OSName      string     `json:"os_name"`                                                                  // 操作系统名称
+	Category    string     `gorm:"default:os" json:"category"`                                               // os:系统镜像 app:应用镜像
+	AppName     string     `json:"app_name"`                                                                 // 应用名称，如Docker、WordPress
+	ImageType   string     `json:"image_type"`                                                               // 厂商镜像类型
+	MinStorage  int        `gorm:"default:0" json:"min_storage"`                                             // 系统盘最小容量GB
+	MinMemory   int        `gorm:"default:0" json:"min_memory"`                                              // 最低内存GB，0表示不限
+	Source      string     `gorm:"default:sync" json:"source"`                                               // sync:厂商同步 manual:管理员添加
+	Status      int        `gorm:"default:1" json:"status"`                                                  // 1:启用 2:禁用
+	SortOrder   int        `gorm:"default:0" json:"sort_order"`                                              // 排序，数值越小越靠前
+	Description string     `gorm:"type:text" json:"description"`                                             // 描述
+	RemovedAt   *time.Time `json:"removed_at"`                                                               // 厂商下线该镜像的时间，由目录同步检测
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+// TableName 指定表名
+func (Image) TableName() string {
+	return "images"
+}
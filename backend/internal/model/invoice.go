@@ -0,0 +1,55 @@
+package model
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+// Invoice 发票，为已支付订单和充值单开具，开具时快照购买方开票信息
+type Invoice struct {
+	ID         uint            `gorm:"primaryKey" json:"id"`
+	InvoiceNo  string          `gorm:"unique;not null" json:"invoice_no"`              // 发票号码，按年连续编号
+	UserID     uint            `gorm:"not null;index" json:"user_id"`                  // 用户ID
+	Type       string          `gorm:"not null" json:"type"`                           // normal:增值税普通发票 special:增值税专用发票
+	SourceType string          `gorm:"not null" json:"source_type"`                    // order、recharge
+	OrderID    *uint           `gorm:"index" json:"order_id"`                          // 开票订单
+	RechargeID *uint           `gorm:"index" json:"recharge_id"`                       // 开票充值单
+	SourceNo   string          `json:"source_no"`                                      // 订单号或充值单号
+	ItemName   string          `gorm:"not null" json:"item_name"`                      // 货物或应税劳务名称
+	Amount     decimal.Decimal `gorm:"type:decimal(10,2);not null" json:"amount"`      // 价税合计
+	Currency   string          `gorm:"default:CNY" json:"currency"`                    // 币种
+	TaxRate    decimal.Decimal `gorm:"type:decimal(5,4);default:0" json:"tax_rate"`    // 税率
+	TaxAmount  decimal.Decimal `gorm:"type:decimal(10,2);default:0" json:"tax_amount"` // 税额
+	BuyerType  string          `gorm:"not null" json:"buyer_type"`                     // personal、company
+	BuyerName  string          `gorm:"not null" json:"buyer_name"`                     // 购买方名称（发票抬头）
+	BuyerTaxNo string          `json:"buyer_tax_no"`                                   // 纳税人识别号
+	BuyerAddr  string          `json:"buyer_addr"`                                     // 地址、电话
+	BuyerBank  string          `json:"buyer_bank"`                                     // 开户行及账号
+	Email      string          `json:"email"`                                          // 接收邮箱
+	Status     string          `gorm:"default:issued;index" json:"status"`             // issued、voided
+	IssuedAt   time.Time       `json:"issued_at"`                                      // 开具时间
+	VoidedAt   *time.Time      `json:"voided_at"`                                      // 作废时间
+	OperatorID *uint           `json:"operator_id"`                                    // 作废操作人
+	Remark     string          `gorm:"type:text" json:"remark"`                        // 备注
+	CreatedAt  time.Time       `json:"created_at"`
+	UpdatedAt  time.Time       `json:"updated_at"`
+	DeletedAt  gorm.DeletedAt  `gorm:"index" json:"-"`
+}
+
+// TableName 指定表名
+func (Invoice) TableName() string {
+	return "invoices"
+}
+
+// InvoiceSequence 发票号码序列，每年一行，取号时加行锁保证连续不重复
+type InvoiceSequence struct {
+	Year   int   `gorm:"primaryKey;autoIncrement:false" json:"year"`
+	LastNo int64 `gorm:"not null;default:0" json:"last_no"`
+}
+
+// TableName 指定表名
+func (InvoiceSequence) TableName() string {
+	return "invoice_sequences"
+}
@@ -0,0 +1,89 @@
+package model
+
+import (
+	"errors"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+// ErrLedgerImmutable 账本流水写入后不允许修改或删除
+var ErrLedgerImmutable = errors.New("账本流水不可修改")
+
+// LedgerAccount 账本账户，用户钱包和平台科目都是一个账户
+type LedgerAccount struct {
+	ID        uint            `gorm:"primaryKey" json:"id"`
+	Code      string          `gorm:"unique;not null" json:"code"`                // 账户编码，如 wallet:1、system:revenue
+	Type      string          `gorm:"not null" json:"type"`                       // wallet、system
+	UserID    *uint           `gorm:"index" json:"user_id"`                       // 钱包所属用户
+	Name      string          `json:"name"`                                       // 账户名称
+	Balance   decimal.Decimal `gorm:"type:decimal(12,2);default:0" json:"balance"` // 账户余额，等于全部分录之和
+	CreatedAt time.Time       `json:"created_at"`
+	UpdatedAt time.Time       `json:"updated_at"`
+}
+
+// TableName 指定表名
+func (LedgerAccount) TableName() string {
+	return "ledger_accounts"
+}
+
+// LedgerTransaction 账本交易，一笔交易包含若干条金额之和为零的分录
+type LedgerTransaction struct {
+	ID          uint            `gorm:"primaryKey" json:"id"`
+	TxNo        string          `gorm:"unique;not null" json:"tx_no"`             // 交易流水号
+	Type        string          `gorm:"not null;index" json:"type"`               // topup、purchase、renew、upgrade、downgrade、refund、adjust、opening
+	UserID      uint            `gorm:"index" json:"user_id"`                     // 关联用户
+	OrderID     *uint           `gorm:"index" json:"order_id"`                    // 关联订单
+	Amount      decimal.Decimal `gorm:"type:decimal(12,2);not null" json:"amount"` // 交易金额（正数）
+	Description string          `gorm:"type:text" json:"description"`             // 交易说明
+	OperatorID  uint            `json:"operator_id"`                              // 操作人（管理员调账时记录）
+	CreatedAt   time.Time       `json:"created_at"`
+
+	// 关联
+	Entries []LedgerEntry `gorm:"foreignKey:TransactionID" json:"entries,omitempty"`
+}
+
+// TableName 指定表名
+func (LedgerTransaction) TableName() string {
+	return "ledger_transactions"
+}
+
+// BeforeUpdate 禁止修改已记账的交易
+func (LedgerTransaction) BeforeUpdate(tx *gorm.DB) error {
+	return ErrLedgerImmutable
+}
+
+// BeforeDelete 禁止删除已记账的交易
+func (LedgerTransaction) BeforeDelete(tx *gorm.DB) error {
+	return ErrLedgerImmutable
+}
+
+// LedgerEntry 账本分录，金额为正表示账户余额增加，为负表示减少
+type LedgerEntry struct {
+	ID            uint            `gorm:"primaryKey" json:"id"`
+	TransactionID uint            `gorm:"not null;index" json:"transaction_id"`            // 交易ID
+	AccountID     uint            `gorm:"not null;index" json:"account_id"`                // 账户ID
+	Amount        decimal.Decimal `gorm:"type:decimal(12,2);not null" json:"amount"`       // 变动金额
+	BalanceAfter  decimal.Decimal `gorm:"type:decimal(12,2);not null" json:"balance_after"` // 变动后余额
+	CreatedAt     time.Time       `json:"created_at"`
+
+	// 关联
+	Transaction LedgerTransaction `gorm:"foreignKey:TransactionID" json:"transaction,omitempty"`
+	Account     LedgerAccount     `gorm:"foreignKey:AccountID" json:"account,omitempty"`
+}
+
+// TableName 指定表名
+func (LedgerEntry) TableName() string {
+	return "ledger_entries"
+}
+
+// BeforeUpdate 禁止修改已记账的分录
+func (LedgerEntry) BeforeUpdate(tx *gorm.DB) error {
+	return ErrLedgerImmutable
+}
+
+// BeforeDelete 禁止删除已记账的分录
+func (LedgerEntry) BeforeDelete(tx *gorm.DB) error {
+	return ErrLedgerImmutable
+}
@@ -16,6 +16,28 @@ func AutoMigrate(db *gorm.DB) error {
 		&Payment{},
 		&Config{},
 		&OperationLog{},
+		&LedgerAccount{},
+		&LedgerTransaction{},
+		&LedgerEntry{},
+		&Recharge{},
+		&Refund{},
+		&Coupon{},
+		&CouponUsage{},
+		&Promotion{},
+		&Invoice{},
+		&InvoiceSequence{},
+		&ExchangeRate{},
+		&PricingRule{},
+		&Image{},
+		&ProductRegion{},
+		&SSHKey{},
+		&SSHKeyBinding{},
+		&UserDataTemplate{},
+		&FirewallRule{},
+		&Snapshot{},
+		&BackupSchedule{},
+		&ServerGroup{},
+		&ServerTag{},
 	)
 }
 
@@ -36,13 +58,21 @@ const (
 	// 产品状态
 	ProductStatusOnline  = 1
 	ProductStatusOffline = 2
+	ProductStatusDraft   = 3 // 目录同步导入，待管理员审核上架
+	
+	// 产品地域状态
+	ProductRegionStatusActive   = 1
+	ProductRegionStatusInactive = 2
 	
 	// 服务器状态
-	ServerStatusCreating = "creating"
-	ServerStatusRunning  = "running"
-	ServerStatusStopped  = "stopped"
-	ServerStatusExpired  = "expired"
-	ServerStatusError    = "error"
+	ServerStatusCreating   = "creating"
+	ServerStatusRunning    = "running"
+	ServerStatusStopped    = "stopped"
+	ServerStatusExpired    = "expired"
+	ServerStatusError      = "error"
+	ServerStatusTerminated = "terminated"
+	ServerStatusResizing   = "resizing"  // 变更套餐中
+	ServerStatusReleasing  = "releasing" // 退订释放实例中
 	
 	// 订单状态
 	OrderStatusPending    = "pending"
@@ -51,21 +81,153 @@ const (
 	OrderStatusSuccess    = "success"
 	OrderStatusFailed     = "failed"
 	OrderStatusCancelled  = "cancelled"
+	OrderStatusRefunded   = "refunded"
 	
 	// 订单类型
-	OrderTypeNew     = "new"
-	OrderTypeRenew   = "renew"
-	OrderTypeUpgrade = "upgrade"
+	OrderTypeNew       = "new"
+	OrderTypeRenew     = "renew"
+	OrderTypeUpgrade   = "upgrade"
+	OrderTypeDowngrade = "downgrade"
 	
 	// 支付状态
 	PaymentStatusPending = "pending"
 	PaymentStatusSuccess = "success"
 	PaymentStatusFailed  = "failed"
 	
+	// 支付记录类型
+	PaymentTypePay    = "pay"
+	PaymentTypeRefund = "refund"
+	
 	// 支付方式
 	PaymentMethodBalance = "balance"
 	PaymentMethodWechat  = "wechat"
 	PaymentMethodAlipay  = "alipay"
+	PaymentMethodMock    = "mock"
+	
+	// 充值状态
+	RechargeStatusPending = "pending"
+	RechargeStatusSuccess = "success"
+	RechargeStatusClosed  = "closed"
+	
+	// 退款类型
+	RefundTypeFailed      = "failed"      // 开通失败全额退款
+	RefundTypeTermination = "termination" // 提前退订按剩余时长退款
+	RefundTypeManual      = "manual"      // 人工退款
+	
+	// 退款状态
+	RefundStatusPending    = "pending"
+	RefundStatusProcessing = "processing" // 已锁定金额，正在调用支付通道退款
+	RefundStatusSuccess    = "success"
+	RefundStatusFailed     = "failed"
+	RefundStatusRejected   = "rejected"
+	
+	// 优惠类型
+	DiscountTypeFixed   = "fixed"
+	DiscountTypePercent = "percent"
+	
+	// 优惠券、促销活动状态
+	CouponStatusActive   = 1
+	CouponStatusInactive = 2
+	
+	// 镜像分类
+	ImageCategoryOS  = "os"
+	ImageCategoryApp = "app"
+	
+	// 镜像来源
+	ImageSourceSync   = "sync"
+	ImageSourceManual = "manual"
+	
+	// 镜像状态
+	ImageStatusActive   = 1
+	ImageStatusInactive = 2
+	
+	// 定价规则加价方式
+	MarkupTypePercent = "percent"
+	MarkupTypeFixed   = "fixed"
+	
+	// 售价取整方式
+	PriceRoundingNone    = "none"
+	PriceRoundingUp      = "up"
+	PriceRoundingDown    = "down"
+	PriceRoundingNearest = "nearest"
+	
+	// 定价规则状态
+	PricingRuleStatusActive   = 1
+	PricingRuleStatusInactive = 2
+	
+	// 用户数据格式
+	UserDataFormatCloudConfig = "cloud-config"
+	UserDataFormatShell       = "shell"
+	
+	// 用户数据模板状态，全局模板启用后对所有用户可见
+	UserDataTemplateStatusActive   = 1
+	UserDataTemplateStatusInactive = 2
+	
+	// 防火墙规则协议
+	FirewallProtocolTCP  = "TCP"
+	FirewallProtocolUDP  = "UDP"
+	FirewallProtocolICMP = "ICMP"
+	FirewallProtocolALL  = "ALL"
+	
+	// 防火墙规则策略
+	FirewallActionAccept = "ACCEPT"
+	FirewallActionDrop   = "DROP"
+	
+	// 快照类型
+	SnapshotTypeManual    = "manual"
+	SnapshotTypeScheduled = "scheduled"
+	
+	// 快照状态
+	SnapshotStatusCreating  = "creating"
+	SnapshotStatusAvailable = "available"
+	
+	// 定期备份频率
+	BackupFrequencyDaily  = "daily"
+	BackupFrequencyWeekly = "weekly"
+	
+	// 汇率来源
+	ExchangeRateSourceManual = "manual"
+	ExchangeRateSourceFile   = "file"
+	
+	// 发票类型
+	InvoiceTypeNormal  = "normal"
+	InvoiceTypeSpecial = "special"
+	
+	// 发票抬头类型
+	InvoiceBuyerPersonal = "personal"
+	InvoiceBuyerCompany  = "company"
+	
+	// 发票来源
+	InvoiceSourceOrder    = "order"
+	InvoiceSourceRecharge = "recharge"
+	
+	// 发票状态
+	InvoiceStatusIssued = "issued"
+	InvoiceStatusVoided = "voided"
+	
+	// 账本账户类型
+	LedgerAccountTypeWallet = "wallet"
+	LedgerAccountTypeSystem = "system"
+	
+	// 平台科目账户编码
+	LedgerAccountRevenue    = "system:revenue"    // 销售收入
+	LedgerAccountGateway    = "system:gateway"    // 第三方支付通道
+	LedgerAccountAdjustment = "system:adjustment" // 人工调账
+	
+	// 账本交易类型
+	LedgerTxTypeTopUp     = "topup"
+	LedgerTxTypePurchase  = "purchase"
+	LedgerTxTypeRenew     = "renew"
+	LedgerTxTypeUpgrade   = "upgrade"
+	LedgerTxTypeDowngrade = "downgrade"
+	LedgerTxTypeRefund    = "refund"
+	LedgerTxTypeAdjust    = "adjust"
+	LedgerTxTypeOpening   = "opening"
+	LedgerTxTypeSnapshot  = "snapshot"
+	
+	// 操作日志状态
+	OperationLogStatusSuccess = 1
+	OperationLogStatusFailed  = 2
 	
 	// 监控指标类型
 	MetricTypeCPU     = "cpu"
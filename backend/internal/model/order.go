@@ -2,6 +2,7 @@ package model
 
 import (
 	"time"
+	"github.com/shopspring/decimal"
 	"gorm.io/gorm"
 )
 
@@ -14,14 +15,23 @@ type Order struct {
 	ProductID     uint           `gorm:"not null" json:"product_id"`      // 产品ID
 	Type          string         `gorm:"not null" json:"type"`            // new、renew、upgrade等
 	Status        string         `gorm:"default:pending" json:"status"`   // pending、paid、processing、success、failed、cancelled
-	Amount        float64        `gorm:"not null" json:"amount"`          // 订单金额
-	DiscountAmount float64       `gorm:"default:0" json:"discount_amount"`// 优惠金额
-	PayAmount     float64        `gorm:"not null" json:"pay_amount"`      // 实付金额
+	Amount        decimal.Decimal `gorm:"type:decimal(10,2);not null" json:"amount"`          // 订单金额
+	DiscountAmount decimal.Decimal `gorm:"type:decimal(10,2);default:0" json:"discount_amount"`// 优惠金额
+	TaxAmount     decimal.Decimal `gorm:"type:decimal(10,2);default:0" json:"tax_amount"`     // 税费
+	PayAmount     decimal.Decimal `gorm:"type:decimal(10,2);not null" json:"pay_amount"`      // 实付金额
+	Currency      string         `gorm:"default:CNY" json:"currency"`     // 结算币种，订单内金额均以该币种计
+	ExchangeRate  decimal.Decimal `gorm:"type:decimal(18,8);default:1" json:"exchange_rate"` // 下单时锁定的汇率，1单位结算币种折合本位币
+	BasePayAmount decimal.Decimal `gorm:"type:decimal(10,2);default:0" json:"base_pay_amount"` // 实付金额折合本位币，用于记账和报表
+	CouponID      *uint          `json:"coupon_id"`                       // 使用的优惠券
+	PromotionID   *uint          `json:"promotion_id"`                    // 命中的促销活动
 	PayMethod     string         `json:"pay_method"`                      // 支付方式
 	PayTime       *time.Time     `json:"pay_time"`                        // 支付时间
+	PayExpireAt   *time.Time     `json:"pay_expire_at"`                   // 支付截止时间，超时未支付自动关闭
 	Period        int            `gorm:"not null" json:"period"`          // 购买周期(月)
 	Quantity      int            `gorm:"default:1" json:"quantity"`       // 数量
-	Config        string         `gorm:"type:text" json:"config"`         // JSON格式的配置信息
+	ReservedStock int            `gorm:"default:0" json:"reserved_stock"` // 占用的产品库存，开通完成或订单关闭时归还
+	Config        string         `gorm:"type:text" json:"-"`              // JSON格式的配置信息，开通前含登录密码和用户数据，不直接返回
+	RedactedConfig map[string]interface{} `gorm:"-" json:"config,omitempty"` // 脱敏后的配置信息，用于接口返回
 	Remark        string         `gorm:"type:text" json:"remark"`         // 备注
 	CreatedAt     time.Time      `json:"created_at"`
 	UpdatedAt     time.Time      `json:"updated_at"`
@@ -32,6 +42,7 @@ type Order struct {
 	Provider Provider `gorm:"foreignKey:ProviderID" json:"provider,omitempty"`
 	Product  Product  `gorm:"foreignKey:ProductID" json:"product,omitempty"`
 	Servers  []Server `gorm:"foreignKey:OrderID" json:"servers,omitempty"`
+	Payments []Payment `gorm:"foreignKey:OrderID" json:"payments,omitempty"`
 }
 
 // TableName 指定表名
@@ -45,8 +56,10 @@ type Payment struct {
 	OrderID     uint           `gorm:"not null" json:"order_id"`      // 订单ID
 	UserID      uint           `gorm:"not null" json:"user_id"`       // 用户ID
 	PaymentNo   string         `gorm:"unique;not null" json:"payment_no"` // 支付单号
+	Type        string         `gorm:"default:pay" json:"type"`       // pay、refund
 	Method      string         `gorm:"not null" json:"method"`        // 支付方式
-	Amount      float64        `gorm:"not null" json:"amount"`        // 支付金额
+	Amount      decimal.Decimal `gorm:"type:decimal(10,2);not null" json:"amount"` // 支付金额
+	Currency    string         `gorm:"default:CNY" json:"currency"`   // 币种
 	Status      string         `gorm:"default:pending" json:"status"` // pending、success、failed
 	TransactionID string       `json:"transaction_id"`               // 第三方交易号
 	PayTime     *time.Time     `json:"pay_time"`                     // 支付时间
@@ -63,4 +76,62 @@ type Payment struct {
 // TableName 指定表名
 func (Payment) TableName() string {
 	return "payments"
-}
\ No newline at end of file
+}
+
+// Recharge 余额充值单
+type Recharge struct {
+	ID            uint            `gorm:"primaryKey" json:"id"`
+	RechargeNo    string          `gorm:"unique;not null" json:"recharge_no"`        // 充值单号，同时作为第三方商户交易号
+	UserID        uint            `gorm:"not null;index" json:"user_id"`             // 用户ID
+	Method        string          `gorm:"not null" json:"method"`                    // 支付方式
+	Amount        decimal.Decimal `gorm:"type:decimal(12,2);not null" json:"amount"` // 充值金额
+	Status        string          `gorm:"default:pending;index" json:"status"`       // pending、success、closed
+	TransactionID string          `json:"transaction_id"`                            // 第三方交易号
+	PayTime       *time.Time      `json:"pay_time"`                                  // 支付时间
+	ExpireAt      time.Time       `json:"expire_at"`                                 // 支付截止时间
+	ClientIP      string          `json:"client_ip"`                                 // 用户IP
+	Remark        string          `gorm:"type:text" json:"remark"`                   // 备注
+	CreatedAt     time.Time       `json:"created_at"`
+	UpdatedAt     time.Time       `json:"updated_at"`
+	DeletedAt     gorm.DeletedAt  `gorm:"index" json:"-"`
+
+	// 关联
+	User User `gorm:"foreignKey:UserID" json:"user,omitempty"`
+}
+
+// TableName 指定表名
+func (Recharge) TableName() string {
+	return "recharges"
+}
+// Refund 退款单，审核通过后退回余额或原路退回支付通道
+type Refund struct {
+	ID            uint           `gorm:"primaryKey" json:"id"`
+	RefundNo      string         `gorm:"unique;not null" json:"refund_no"`    // 退款单号，同时作为第三方商户退款单号
+	OrderID       uint           `gorm:"not null;index" json:"order_id"`      // 订单ID
+	UserID        uint           `gorm:"not null;index" json:"user_id"`       // 用户ID
+	ServerID      *uint          `json:"server_id"`                           // 提前退订的服务器ID
+	PaymentID     uint           `gorm:"not null" json:"payment_id"`          // 原支付记录ID
+	Type          string         `gorm:"not null" json:"type"`                // failed、termination、manual
+	Method        string         `gorm:"not null" json:"method"`              // 退款去向：balance或原支付方式
+	Amount        decimal.Decimal `gorm:"type:decimal(10,2);not null" json:"amount"` // 退款金额，与订单币种一致
+	Currency      string         `gorm:"default:CNY" json:"currency"`         // 币种
+	Status        string         `gorm:"default:pending;index" json:"status"` // pending、processing、success、failed、rejected
+	Reason        string         `gorm:"type:text" json:"reason"`             // 退款原因
+	Remark        string         `gorm:"type:text" json:"remark"`             // 审核备注或失败原因
+	TransactionID string         `json:"transaction_id"`                      // 第三方退款单号
+	OperatorID    *uint          `json:"operator_id"`                         // 审核人
+	ReviewedAt    *time.Time     `json:"reviewed_at"`                         // 审核时间
+	RefundTime    *time.Time     `json:"refund_time"`                         // 退款完成时间
+	CreatedAt     time.Time      `json:"created_at"`
+	UpdatedAt     time.Time      `json:"updated_at"`
+	DeletedAt     gorm.DeletedAt `gorm:"index" json:"-"`
+
+	// 关联
+	Order Order `gorm:"foreignKey:OrderID" json:"order,omitempty"`
+	User  User  `gorm:"foreignKey:UserID" json:"user,omitempty"`
+}
+
+// TableName 指定表名
+func (Refund) TableName() string {
+	return "refunds"
+}
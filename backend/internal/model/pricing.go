@@ -0,0 +1,34 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// PricingRule 定价规则，按厂商、产品类型、地域在成本价上加价得到售价；
+// 用户分组为空的规则计算标准售价，其余规则为对应分组用户的专属价
+type PricingRule struct {
+	ID           uint           `gorm:"primaryKey" json:"id"`
+	Name         string         `gorm:"not null" json:"name"`           // 规则名称
+	Priority     int            `gorm:"default:0" json:"priority"`      // 优先级，数值越大越优先，相同时条件越具体越优先
+	ProviderID   *uint          `gorm:"index" json:"provider_id"`       // 适用厂商，为空表示不限
+	ProductType  string         `json:"product_type"`                   // 适用产品类型，为空表示不限
+	Region       string         `json:"region"`                         // 适用地域，为空表示不限
+	UserGroup    string         `gorm:"index" json:"user_group"`        // 适用用户分组，为空表示标准售价
+	MarkupType   string         `gorm:"not null" json:"markup_type"`    // percent:按比例加价 fixed:固定加价
+	MarkupValue  float64        `gorm:"not null" json:"markup_value"`   // 加价百分比（20表示加价20%），或固定加价金额
+	Rounding     string         `gorm:"default:none" json:"rounding"`   // 取整方式 none、up、down、nearest
+	RoundingUnit float64        `gorm:"default:0" json:"rounding_unit"` // 取整单位，如1表示取整到元、0.1表示取整到角
+	PriceEnding  float64        `gorm:"default:0" json:"price_ending"`  // 尾数，取整后减去该值，如取整到元后减0.01得到x.99
+	Status       int            `gorm:"default:1" json:"status"`        // 1:启用 2:禁用
+	Description  string         `gorm:"type:text" json:"description"`   // 描述
+	CreatedAt    time.Time      `json:"created_at"`
+	UpdatedAt    time.Time      `json:"updated_at"`
+	DeletedAt    gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+// TableName 指定表名
+func (PricingRule) TableName() string {
+	return "pricing_rules"
+}
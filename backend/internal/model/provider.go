@@ -2,6 +2,8 @@ package model
 
 import (
 	"time"
+
+	"github.com/shopspring/decimal"
 	"gorm.io/gorm"
 )
 
@@ -43,9 +45,16 @@ type Product struct {
 	Bandwidth   int            `json:"bandwidth"`                      // 带宽Mbps
 	Traffic     int            `json:"traffic"`                        // 流量包GB
 	OS          string         `json:"os"`                             // 操作系统
-	Price       float64        `gorm:"not null" json:"price"`          // 价格/月
-	OriginalPrice float64      `json:"original_price"`                 // 原价
-	Status      int            `gorm:"default:1" json:"status"`        // 1:上架 2:下架
+	Price       decimal.Decimal `gorm:"type:decimal(10,2);not null" json:"price"` // 价格/月
+	Currency    string         `gorm:"default:CNY" json:"currency"`    // 标价币种
+	OriginalPrice decimal.Decimal `gorm:"type:decimal(10,2)" json:"original_price"` // 原价
+	CostPrice   decimal.Decimal `gorm:"type:decimal(10,2);default:0" json:"cost_price"` // 厂商成本价/月，由目录同步维护
+	Status      int            `gorm:"default:1" json:"status"`        // 1:上架 2:下架 3:待上架
+	Stock       int            `gorm:"default:-1" json:"stock"`        // 剩余可售库存，-1表示不限
+	Reserved    int            `gorm:"default:0" json:"reserved"`      // 未完成订单占用的库存
+	PurchaseLimit int          `gorm:"default:0" json:"purchase_limit"` // 每个用户最多持有台数，0表示不限
+	SoldOut     bool           `gorm:"default:false" json:"sold_out"`  // 厂商规格已售罄，由库存同步任务维护
+	RemovedAt   *time.Time     `json:"removed_at"`                     // 厂商下线该规格的时间，由目录同步检测
 	Description string         `gorm:"type:text" json:"description"`   // 产品描述
 	Features    string         `gorm:"type:text" json:"features"`      // 特性JSON
 	CreatedAt   time.Time      `json:"created_at"`
@@ -53,10 +62,29 @@ type Product struct {
 	DeletedAt   gorm.DeletedAt `gorm:"index" json:"-"`
 	
 	// 关联
-	Provider Provider `gorm:"foreignKey:ProviderID" json:"provider,omitempty"`
+	Provider Provider        `gorm:"foreignKey:ProviderID" json:"provider,omitempty"`
+	Regions  []ProductRegion `gorm:"foreignKey:ProductID" json:"regions,omitempty"`
 }
 
 // TableName 指定表名
 func (Product) TableName() string {
 	return "products"
+}
+
+// ProductRegion 产品可售地域，同一套餐可在多个地域销售并单独定价；
+// 产品未配置可售地域时只在产品自身的地域销售
+type ProductRegion struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	ProductID uint      `gorm:"not null;uniqueIndex:idx_product_regions_product_region" json:"product_id"` // 产品ID
+	Region    string    `gorm:"not null;uniqueIndex:idx_product_regions_product_region" json:"region"`     // 地域
+	Zones     []string  `gorm:"type:text;serializer:json" json:"zones"`                                    // 可选可用区，为空表示该地域全部可用区
+	Price     decimal.Decimal `gorm:"type:decimal(10,2);default:0" json:"price"`                                 // 地域售价/月，0表示使用产品售价
+	Status    int       `gorm:"default:1" json:"status"`                                                   // 1:可售 2:暂停销售
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// TableName 指定表名
+func (ProductRegion) TableName() string {
+	return "product_regions"
 }
\ No newline at end of file
@@ -22,8 +22,12 @@ type Server struct {
 	ExpireTime   time.Time      `json:"expire_time"`                    // 到期时间
 	AutoRenew    bool           `gorm:"default:false" json:"auto_renew"`// 自动续费
 	Password     string         `json:"-"`                              // 登录密码
+	ImageID      string         `json:"image_id"`                       // 购买或重装时选择的镜像
 	OSType       string         `json:"os_type"`                        // 操作系统类型
 	OSName       string         `json:"os_name"`                        // 操作系统名称
+	SSHKeyIDs    []uint         `gorm:"type:text;serializer:json" json:"ssh_key_ids"` // 注入的SSH公钥
+	DisablePassword bool        `gorm:"default:false" json:"disable_password"` // 是否禁用密码登录
+	GroupID      *uint          `gorm:"index" json:"group_id"`          // 所属分组
 	CPU          int            `json:"cpu"`                            // CPU核心数
 	Memory       int            `json:"memory"`                         // 内存GB
 	Storage      int            `json:"storage"`                        // 存储GB
@@ -36,11 +40,13 @@ type Server struct {
 	DeletedAt    gorm.DeletedAt `gorm:"index" json:"-"`
 	
 	// 关联
-	User      User      `gorm:"foreignKey:UserID" json:"user,omitempty"`
-	Order     Order     `gorm:"foreignKey:OrderID" json:"order,omitempty"`
-	Provider  Provider  `gorm:"foreignKey:ProviderID" json:"provider,omitempty"`
-	Product   Product   `gorm:"foreignKey:ProductID" json:"product,omitempty"`
-	Monitors  []Monitor `gorm:"foreignKey:ServerID" json:"monitors,omitempty"`
+	User     User         `gorm:"foreignKey:UserID" json:"user,omitempty"`
+	Order    Order        `gorm:"foreignKey:OrderID" json:"order,omitempty"`
+	Provider Provider     `gorm:"foreignKey:ProviderID" json:"provider,omitempty"`
+	Product  Product      `gorm:"foreignKey:ProductID" json:"product,omitempty"`
+	Monitors []Monitor    `gorm:"foreignKey:ServerID" json:"monitors,omitempty"`
+	Group    *ServerGroup `gorm:"foreignKey:GroupID" json:"group,omitempty"`
+	Tags     []ServerTag  `gorm:"foreignKey:ServerID" json:"tags,omitempty"`
 }
 
 // TableName 指定表名
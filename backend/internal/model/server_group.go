@@ -0,0 +1,36 @@
+package model
+
+import (
+	"time"
+)
+
+// ServerGroup 服务器分组（项目），同一用户下名称不能重复，每台服务器最多属于一个分组
+type ServerGroup struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	UserID      uint      `gorm:"not null;uniqueIndex:idx_server_groups_user_name" json:"user_id"` // 用户ID
+	Name        string    `gorm:"not null;uniqueIndex:idx_server_groups_user_name" json:"name"`    // 分组名称
+	Description string    `json:"description"`                                                     // 描述
+	ServerCount int64     `gorm:"-" json:"server_count"`                                           // 分组内的服务器数量
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// TableName 指定表名
+func (ServerGroup) TableName() string {
+	return "server_groups"
+}
+
+// ServerTag 服务器标签，同一台服务器下标签键不能重复
+type ServerTag struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	ServerID  uint      `gorm:"not null;uniqueIndex:idx_server_tags_server_key" json:"server_id"` // 服务器ID
+	Key       string    `gorm:"not null;uniqueIndex:idx_server_tags_server_key;index" json:"key"` // 标签键
+	Value     string    `json:"value"`                                                            // 标签值
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// TableName 指定表名
+func (ServerTag) TableName() string {
+	return "server_tags"
+}
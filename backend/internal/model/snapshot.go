@@ -0,0 +1,51 @@
+package model
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// Snapshot 服务器快照，超出免费额度的快照按月从余额扣费
+type Snapshot struct {
+	ID          uint            `gorm:"primaryKey" json:"id"`
+	UserID      uint            `gorm:"not null;index" json:"user_id"`             // 用户ID
+	ServerID    uint            `gorm:"not null;index" json:"server_id"`           // 服务器ID
+	SnapshotID  string          `gorm:"unique;not null" json:"snapshot_id"`        // 厂商快照ID，创建中为占位ID
+	Name        string          `gorm:"not null" json:"name"`                      // 快照名称
+	Type        string          `gorm:"not null" json:"type"`                      // manual:手动 scheduled:定期备份
+	Size        int             `json:"size"`                                      // 快照大小GB
+	Status      string          `gorm:"default:available" json:"status"`           // creating:创建中 available:可用
+	Chargeable  bool            `gorm:"default:false" json:"chargeable"`           // 是否超出免费额度需按月计费
+	Price       decimal.Decimal `gorm:"type:decimal(10,2);default:0" json:"price"` // 每月价格（本位币）
+	BilledUntil *time.Time      `json:"billed_until"`                              // 已扣费至，到期后续扣
+	CreatedAt   time.Time       `json:"created_at"`
+	UpdatedAt   time.Time       `json:"updated_at"`
+}
+
+// TableName 指定表名
+func (Snapshot) TableName() string {
+	return "snapshots"
+}
+
+// BackupSchedule 服务器定期备份计划，按频率创建快照并只保留最近的若干个定期快照
+type BackupSchedule struct {
+	ID        uint       `gorm:"primaryKey" json:"id"`
+	ServerID  uint       `gorm:"uniqueIndex;not null" json:"server_id"` // 服务器ID
+	UserID    uint       `gorm:"not null;index" json:"user_id"`         // 用户ID
+	Enabled   bool       `gorm:"default:false" json:"enabled"`          // 是否启用
+	Frequency string     `gorm:"not null" json:"frequency"`             // daily:每天 weekly:每周
+	Weekday   int        `json:"weekday"`                               // 每周备份的星期，0为周日
+	Hour      int        `json:"hour"`                                  // 备份时刻（0-23时）
+	Retention int        `gorm:"not null" json:"retention"`             // 保留的定期快照数
+	NextRunAt time.Time  `gorm:"index" json:"next_run_at"`              // 下次备份时间
+	LastRunAt *time.Time `json:"last_run_at"`                           // 上次备份时间
+	LastError string     `json:"last_error"`                            // 上次备份失败原因
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+// TableName 指定表名
+func (BackupSchedule) TableName() string {
+	return "backup_schedules"
+}
@@ -0,0 +1,37 @@
+package model
+
+import (
+	"time"
+)
+
+// SSHKey 用户SSH公钥，购买和重装服务器时选择注入；同一用户下按指纹去重
+type SSHKey struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	UserID      uint      `gorm:"not null;uniqueIndex:idx_ssh_keys_user_fingerprint" json:"user_id"`     // 用户ID
+	Name        string    `gorm:"not null" json:"name"`                                                  // 密钥名称
+	PublicKey   string    `gorm:"type:text;not null" json:"public_key"`                                  // OpenSSH格式公钥
+	Fingerprint string    `gorm:"not null;uniqueIndex:idx_ssh_keys_user_fingerprint" json:"fingerprint"` // SHA256指纹
+	KeyType     string    `json:"key_type"`                                                              // 密钥类型，如ssh-ed25519、ssh-rsa
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// TableName 指定表名
+func (SSHKey) TableName() string {
+	return "ssh_keys"
+}
+
+// SSHKeyBinding 公钥在厂商侧导入的密钥对，按厂商和地域缓存，避免每次创建实例重复导入
+type SSHKeyBinding struct {
+	ID            uint      `gorm:"primaryKey" json:"id"`
+	SSHKeyID      uint      `gorm:"not null;uniqueIndex:idx_ssh_key_bindings_key_provider_region" json:"ssh_key_id"`  // 公钥ID
+	ProviderID    uint      `gorm:"not null;uniqueIndex:idx_ssh_key_bindings_key_provider_region" json:"provider_id"` // 提供商ID
+	Region        string    `gorm:"not null;uniqueIndex:idx_ssh_key_bindings_key_provider_region" json:"region"`      // 地域
+	ProviderKeyID string    `gorm:"not null" json:"provider_key_id"`                                                  // 厂商密钥对ID
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// TableName 指定表名
+func (SSHKeyBinding) TableName() string {
+	return "ssh_key_bindings"
+}
@@ -2,24 +2,37 @@ package model
 
 import (
 	"time"
+	"github.com/shopspring/decimal"
 	"gorm.io/gorm"
 )
 
 // User 用户模型
 type User struct {
-	ID        uint           `gorm:"primaryKey" json:"id"`
-	Username  string         `gorm:"unique;not null" json:"username"`
-	Email     string         `gorm:"unique;not null" json:"email"`
-	Password  string         `gorm:"not null" json:"-"`
-	Phone     string         `gorm:"unique" json:"phone"`
-	RealName  string         `json:"real_name"`
-	Avatar    string         `json:"avatar"`
-	Status    int            `gorm:"default:1" json:"status"` // 1:正常 2:禁用
-	Role      string         `gorm:"default:user" json:"role"` // user, admin
-	Balance   float64        `gorm:"default:0" json:"balance"` // 账户余额
-	CreatedAt time.Time      `json:"created_at"`
-	UpdatedAt time.Time      `json:"updated_at"`
-	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+	ID        uint            `gorm:"primaryKey" json:"id"`
+	Username  string          `gorm:"unique;not null" json:"username"`
+	Email     string          `gorm:"unique;not null" json:"email"`
+	Password  string          `gorm:"not null" json:"-"`
+	Phone     string          `gorm:"unique" json:"phone"`
+	RealName  string          `json:"real_name"`
+	Avatar    string          `json:"avatar"`
+	Status    int             `gorm:"default:1" json:"status"` // 1:正常 2:禁用
+	Role      string          `gorm:"default:user" json:"role"` // user, admin
+	Balance   decimal.Decimal `gorm:"type:decimal(12,2);default:0" json:"balance"` // 账户余额（本位币），由钱包账本同步
+	Currency  string          `gorm:"default:CNY" json:"currency"`                // 结算币种
+	PriceGroup string         `json:"price_group"`                                // 定价分组，按分组定价规则享受专属价，空表示标准售价
+	
+	// 开票信息
+	InvoiceBuyerType   string `json:"invoice_buyer_type"`   // personal、company
+	InvoiceTitle       string `json:"invoice_title"`        // 发票抬头
+	InvoiceTaxNo       string `json:"invoice_tax_no"`       // 纳税人识别号
+	InvoiceAddress     string `json:"invoice_address"`      // 注册地址
+	InvoicePhone       string `json:"invoice_phone"`        // 注册电话
+	InvoiceBankName    string `json:"invoice_bank_name"`    // 开户银行
+	InvoiceBankAccount string `json:"invoice_bank_account"` // 银行账号
+	InvoiceEmail       string `json:"invoice_email"`        // 发票接收邮箱
+	CreatedAt time.Time       `json:"created_at"`
+	UpdatedAt time.Time       `json:"updated_at"`
+	DeletedAt gorm.DeletedAt  `gorm:"index" json:"-"`
 	
 	// 关联
 	Orders    []Order    `gorm:"foreignKey:UserID" json:"orders,omitempty"`
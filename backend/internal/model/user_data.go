@@ -0,0 +1,36 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// UserDataTemplate 用户数据（cloud-init）模板，UserID为空的是管理员发布的全局模板；
+// 内容中的 {{变量名}} 在购买或重装时替换
+type UserDataTemplate struct {
+	ID          uint               `gorm:"primaryKey" json:"id"`
+	UserID      *uint              `gorm:"index" json:"user_id"`                       // 所属用户，为空表示全局模板
+	Name        string             `gorm:"not null" json:"name"`                       // 模板名称
+	Format      string             `gorm:"not null" json:"format"`                     // cloud-config、shell
+	Content     string             `gorm:"type:text;not null" json:"content"`          // 模板内容
+	Variables   []UserDataVariable `gorm:"type:text;serializer:json" json:"variables"` // 模板变量定义
+	Status      int                `gorm:"default:1" json:"status"`                    // 1:启用 2:停用
+	Description string             `gorm:"type:text" json:"description"`               // 描述
+	CreatedAt   time.Time          `json:"created_at"`
+	UpdatedAt   time.Time          `json:"updated_at"`
+	DeletedAt   gorm.DeletedAt     `gorm:"index" json:"-"`
+}
+
+// UserDataVariable 模板变量定义
+type UserDataVariable struct {
+	Name        string `json:"name"`        // 变量名，模板中写作 {{name}}
+	Description string `json:"description"` // 说明
+	Default     string `json:"default"`     // 默认值
+	Required    bool   `json:"required"`    // 是否必填，必填且无默认值时购买须提供
+}
+
+// TableName 指定表名
+func (UserDataTemplate) TableName() string {
+	return "user_data_templates"
+}
@@ -7,6 +7,7 @@ import (
 	"cloudbp-backend/internal/model"
 	"gorm.io/gorm"
 	"github.com/redis/go-redis/v9"
+	"github.com/shopspring/decimal"
 )
 
 // AdminService 管理员服务
@@ -25,14 +26,14 @@ func NewAdminService(db *gorm.DB, rdb *redis.Client) *AdminService {
 
 // DashboardData 仪表板数据
 type DashboardData struct {
-	UserCount       int64   `json:"user_count"`       // 用户总数
-	ServerCount     int64   `json:"server_count"`     // 服务器总数
-	OrderCount      int64   `json:"order_count"`      // 订单总数
-	TodayOrderCount int64   `json:"today_order_count"`// 今日订单数
-	TotalRevenue    float64 `json:"total_revenue"`    // 总收入
-	TodayRevenue    float64 `json:"today_revenue"`    // 今日收入
-	ActiveServers   int64   `json:"active_servers"`   // 运行中服务器数
-	PendingOrders   int64   `json:"pending_orders"`   // 待处理订单数
+	UserCount       int64           `json:"user_count"`        // 用户总数
+	ServerCount     int64           `json:"server_count"`      // 服务器总数
+	OrderCount      int64           `json:"order_count"`       // 订单总数
+	TodayOrderCount int64           `json:"today_order_count"` // 今日订单数
+	TotalRevenue    decimal.Decimal `json:"total_revenue"`     // 总收入（本位币）
+	TodayRevenue    decimal.Decimal `json:"today_revenue"`     // 今日收入（本位币）
+	ActiveServers   int64           `json:"active_servers"`    // 运行中服务器数
+	PendingOrders   int64           `json:"pending_orders"`    // 待处理订单数
 }
 
 // GetDashboard 获取仪表板数据
@@ -61,21 +62,21 @@ func (s *AdminService) GetDashboard(ctx context.Context) (*DashboardData, error)
 		return nil, fmt.Errorf("获取今日订单数失败: %w", err)
 	}
 
-	// 获取总收入
-	var totalRevenue float64
+	// 获取总收入，多币种订单按下单时锁定的汇率折合本位币
+	var totalRevenue decimal.Decimal
 	if err := s.db.Model(&model.Order{}).
 		Where("status = ?", model.OrderStatusSuccess).
-		Select("SUM(pay_amount)").
+		Select("COALESCE(SUM(base_pay_amount), 0)").
 		Scan(&totalRevenue).Error; err != nil {
 		return nil, fmt.Errorf("获取总收入失败: %w", err)
 	}
 	data.TotalRevenue = totalRevenue
 
 	// 获取今日收入
-	var todayRevenue float64
+	var todayRevenue decimal.Decimal
 	if err := s.db.Model(&model.Order{}).
 		Where("status = ? AND DATE(created_at) = CURRENT_DATE", model.OrderStatusSuccess).
-		Select("SUM(pay_amount)").
+		Select("COALESCE(SUM(base_pay_amount), 0)").
 		Scan(&todayRevenue).Error; err != nil {
 		return nil, fmt.Errorf("获取今日收入失败: %w", err)
 	}
@@ -192,6 +193,9 @@ func (s *AdminService) GetOrders(ctx context.Context, req *GetOrdersRequest) (*G
 	if err := query.Offset(offset).Limit(req.Size).Order("created_at DESC").Find(&orders).Error; err != nil {
 		return nil, fmt.Errorf("获取订单列表失败: %w", err)
 	}
+	for i := range orders {
+		redactOrderConfig(&orders[i])
+	}
 
 	return &GetOrdersResponse{
 		Orders:     orders,
@@ -0,0 +1,327 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"cloudbp-backend/internal/config"
+	"cloudbp-backend/internal/model"
+	"cloudbp-backend/pkg/logger"
+	"cloudbp-backend/pkg/provider"
+
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// CatalogService 产品目录同步服务：从厂商导入规格为待上架产品，检测下线的规格，
+// 同步镜像目录并缓存地域供购买页使用
+type CatalogService struct {
+	db                 *gorm.DB
+	rdb                *redis.Client
+	config             config.CatalogConfig
+	providerService    *ProviderService
+	pricingRuleService *PricingRuleService
+	imageService       *ImageService
+}
+
+// NewCatalogService 创建目录同步服务
+func NewCatalogService(db *gorm.DB, rdb *redis.Client, cfg config.CatalogConfig, providerService *ProviderService, pricingRuleService *PricingRuleService, imageService *ImageService) *CatalogService {
+	return &CatalogService{
+		db:                 db,
+		rdb:                rdb,
+		config:             cfg,
+		providerService:    providerService,
+		pricingRuleService: pricingRuleService,
+		imageService:       imageService,
+	}
+}
+
+// CatalogSyncResult 单个厂商的目录同步结果
+type CatalogSyncResult struct {
+	Provider  string   `json:"provider"`
+	Regions   int      `json:"regions"`   // 同步的地域数
+	Images    int      `json:"images"`    // 同步的镜像数
+	Created   int      `json:"created"`   // 新导入的待上架产品数
+	Updated   int      `json:"updated"`   // 成本价或配置变化的产品数
+	Restored  int      `json:"restored"`  // 重新出现的规格数
+	Repricing int      `json:"repricing"` // 成本价变化后按规则售价需调整的已上架产品数，预览确认后发布
+	Removed   []string `json:"removed"`   // 厂商已下线的规格，格式为“地域/规格”
+	Errors    []string `json:"errors"`    // 同步失败的地域
+}
+
+// SyncCatalog 同步所有启用厂商的产品目录
+func (s *CatalogService) SyncCatalog(ctx context.Context) ([]CatalogSyncResult, error) {
+	var providers []model.Provider
+	if err := s.db.WithContext(ctx).Where("status = ?", model.ProviderStatusActive).Find(&providers).Error; err != nil {
+		return nil, fmt.Errorf("获取云厂商列表失败: %w", err)
+	}
+
+	results := make([]CatalogSyncResult, 0, len(providers))
+	for i := range providers {
+		result, err := s.syncProvider(ctx, &providers[i])
+		if err != nil {
+			logger.Log.Error("同步厂商目录失败", zap.String("provider", providers[i].Code), zap.Error(err))
+			result = &CatalogSyncResult{Provider: providers[i].Code, Errors: []string{err.Error()}}
+		}
+		results = append(results, *result)
+	}
+	return results, nil
+}
+
+// RunScheduledSync 定时同步产品目录
+func (s *CatalogService) RunScheduledSync(ctx context.Context) error {
+	results, err := s.SyncCatalog(ctx)
+	if err != nil {
+		return err
+	}
+	for _, result := range results {
+		logger.Log.Info("产品目录同步完成", zap.String("provider", result.Provider),
+			zap.Int("created", result.Created), zap.Int("updated", result.Updated),
+			zap.Int("removed", len(result.Removed)), zap.Int("errors", len(result.Errors)))
+	}
+	return nil
+}
+
+// syncProvider 同步单个厂商：按地域导入规格和镜像，最后标记未再出现的规格为已下线
+func (s *CatalogService) syncProvider(ctx context.Context, p *model.Provider) (*CatalogSyncResult, error) {
+	cloudProvider, err := s.providerService.GetProvider(p.Code)
+	if err != nil {
+		return nil, err
+	}
+
+	regionsResp, err := cloudProvider.GetRegions(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("获取地域列表失败: %w", err)
+	}
+	if err := s.setCache(ctx, regionsCacheKey(p.Code), regionsResp.Regions); err != nil {
+		logger.Log.Warn("缓存地域列表失败", zap.String("provider", p.Code), zap.Error(err))
+	}
+
+	var products []model.Product
+	if err := s.db.Where("provider_id = ?", p.ID).Find(&products).Error; err != nil {
+		return nil, fmt.Errorf("获取产品列表失败: %w", err)
+	}
+	rules, err := s.pricingRuleService.LoadRules("")
+	if err != nil {
+		return nil, err
+	}
+	existing := make(map[string][]*model.Product)
+	for i := range products {
+		key := products[i].Region + "/" + products[i].Code
+		existing[key] = append(existing[key], &products[i])
+	}
+
+	result := &CatalogSyncResult{Provider: p.Code, Regions: len(regionsResp.Regions), Removed: []string{}, Errors: []string{}}
+	seen := make(map[string]bool)
+	failedRegions := make(map[string]bool)
+
+	for _, region := range regionsResp.Regions {
+		typesResp, err := cloudProvider.GetInstanceTypes(ctx, &provider.GetInstanceTypesRequest{Region: region.RegionID})
+		if err != nil {
+			failedRegions[region.RegionID] = true
+			result.Errors = append(result.Errors, fmt.Sprintf("%s: 获取规格失败: %v", region.RegionID, err))
+			continue
+		}
+
+		for _, instanceType := range typesResp.InstanceTypes {
+			key := region.RegionID + "/" + instanceType.InstanceType
+			seen[key] = true
+			if err := s.upsertProduct(p, region.RegionID, &instanceType, existing[key], rules, result); err != nil {
+				result.Errors = append(result.Errors, fmt.Sprintf("%s: %v", key, err))
+			}
+		}
+
+		imagesResp, err := cloudProvider.GetImages(ctx, &provider.GetImagesRequest{Region: region.RegionID})
+		if err != nil {
+			result.Errors = append(result.Errors, fmt.Sprintf("%s: 获取镜像失败: %v", region.RegionID, err))
+			continue
+		}
+		if err := s.imageService.SyncImages(p.ID, region.RegionID, imagesResp.Images); err != nil {
+			result.Errors = append(result.Errors, fmt.Sprintf("%s: 同步镜像失败: %v", region.RegionID, err))
+			continue
+		}
+		result.Images += len(imagesResp.Images)
+	}
+
+	// 检测下线的规格：地域已不再提供，或地域同步成功但规格不在列表中
+	now := time.Now()
+	for key, items := range existing {
+		if seen[key] {
+			continue
+		}
+		for _, product := range items {
+			if product.RemovedAt != nil || failedRegions[product.Region] {
+				continue
+			}
+			updates := map[string]interface{}{"removed_at": &now}
+			if product.Status == model.ProductStatusOnline {
+				updates["status"] = model.ProductStatusOffline
+			}
+			if err := s.db.Model(product).Updates(updates).Error; err != nil {
+				result.Errors = append(result.Errors, fmt.Sprintf("%s: 标记下线失败: %v", key, err))
+				continue
+			}
+			result.Removed = append(result.Removed, key)
+			logger.Log.Warn("厂商规格已下线，产品已下架", zap.String("provider", p.Code),
+				zap.Uint("product_id", product.ID), zap.String("plan", key))
+		}
+	}
+
+	return result, nil
+}
+
+// upsertProduct 导入或更新单个规格：新规格创建为待上架产品并按定价规则定价；
+// 已有产品更新成本价和配置，待上架产品随成本重新定价，已上架产品的售价需预览后发布
+func (s *CatalogService) upsertProduct(p *model.Provider, region string, instanceType *provider.InstanceType, items []*model.Product, rules *PriceRules, result *CatalogSyncResult) error {
+	// 厂商接口返回的成本价为浮点数，入库前转为两位小数的定点金额
+	costPrice := toAmount(instanceType.Price)
+	if len(items) == 0 {
+		name := instanceType.Description
+		if name == "" {
+			name = instanceType.InstanceType
+		}
+		productType := instanceType.Family
+		if productType == "" {
+			productType = "cvm"
+		}
+
+		product := model.Product{
+			ProviderID: p.ID,
+			Name:       name,
+			Code:       instanceType.InstanceType,
+			Type:       productType,
+			Region:     region,
+			CPU:        instanceType.CPU,
+			Memory:     instanceType.Memory,
+			Storage:    instanceType.Storage,
+			Bandwidth:  instanceType.Bandwidth,
+			Traffic:    instanceType.Traffic,
+			CostPrice:  costPrice,
+			Price:      costPrice,
+			Status:     model.ProductStatusDraft,
+			SoldOut:    instanceType.SoldOut,
+		}
+		if price, _, ok := rules.Evaluate(&product); ok {
+			product.Price = price
+		}
+		if err := s.db.Create(&product).Error; err != nil {
+			return fmt.Errorf("导入产品失败: %w", err)
+		}
+		result.Created++
+		return nil
+	}
+
+	for _, product := range items {
+		updates := map[string]interface{}{}
+		if !product.CostPrice.Equal(costPrice) {
+			updates["cost_price"] = costPrice
+			product.CostPrice = costPrice
+			if price, _, ok := rules.Evaluate(product); ok && !price.Equal(product.Price) {
+				if product.Status == model.ProductStatusDraft {
+					updates["price"] = price
+				} else if product.Status == model.ProductStatusOnline {
+					result.Repricing++
+				}
+			}
+		}
+		if product.CPU != instanceType.CPU || product.Memory != instanceType.Memory ||
+			product.Storage != instanceType.Storage || product.Bandwidth != instanceType.Bandwidth ||
+			product.Traffic != instanceType.Traffic {
+			updates["cpu"] = instanceType.CPU
+			updates["memory"] = instanceType.Memory
+			updates["storage"] = instanceType.Storage
+			updates["bandwidth"] = instanceType.Bandwidth
+			updates["traffic"] = instanceType.Traffic
+		}
+		if len(updates) > 0 {
+			result.Updated++
+		}
+		if product.RemovedAt != nil {
+			updates["removed_at"] = nil
+			result.Restored++
+		}
+		if len(updates) == 0 {
+			continue
+		}
+
+		if err := s.db.Model(product).Updates(updates).Error; err != nil {
+			return fmt.Errorf("更新产品失败: %w", err)
+		}
+	}
+	return nil
+}
+
+// GetRegions 获取厂商可用地域，优先读取缓存，缓存失效时实时查询
+func (s *CatalogService) GetRegions(ctx context.Context, providerID uint) ([]provider.Region, error) {
+	p, cloudProvider, err := s.cloudProvider(providerID)
+	if err != nil {
+		return nil, err
+	}
+
+	var regions []provider.Region
+	if s.getCache(ctx, regionsCacheKey(p.Code), &regions) {
+		return regions, nil
+	}
+
+	resp, err := cloudProvider.GetRegions(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("获取地域列表失败: %w", err)
+	}
+	if err := s.setCache(ctx, regionsCacheKey(p.Code), resp.Regions); err != nil {
+		logger.Log.Warn("缓存地域列表失败", zap.String("provider", p.Code), zap.Error(err))
+	}
+	return resp.Regions, nil
+}
+
+// cloudProvider 获取启用的厂商及其适配器
+func (s *CatalogService) cloudProvider(providerID uint) (*model.Provider, provider.CloudProvider, error) {
+	var p model.Provider
+	if err := s.db.Where("id = ? AND status = ?", providerID, model.ProviderStatusActive).First(&p).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, errors.New("厂商不存在")
+		}
+		return nil, nil, fmt.Errorf("获取厂商信息失败: %w", err)
+	}
+
+	cloudProvider, err := s.providerService.GetProvider(p.Code)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &p, cloudProvider, nil
+}
+
+// setCache 写入目录缓存，未配置 Redis 时不缓存
+func (s *CatalogService) setCache(ctx context.Context, key string, value interface{}) error {
+	if s.rdb == nil {
+		return nil
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return s.rdb.Set(ctx, key, data, time.Duration(s.config.CacheTTL)*time.Minute).Err()
+}
+
+// getCache 读取目录缓存，未命中或解析失败时返回false
+func (s *CatalogService) getCache(ctx context.Context, key string, dest interface{}) bool {
+	if s.rdb == nil {
+		return false
+	}
+	data, err := s.rdb.Get(ctx, key).Bytes()
+	if err != nil {
+		if !errors.Is(err, redis.Nil) {
+			logger.Log.Warn("读取目录缓存失败", zap.String("key", key), zap.Error(err))
+		}
+		return false
+	}
+	return json.Unmarshal(data, dest) == nil
+}
+
+// regionsCacheKey 地域缓存键
+func regionsCacheKey(code string) string {
+	return fmt.Sprintf("catalog:regions:%s", code)
+}
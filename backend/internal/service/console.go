@@ -0,0 +1,204 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"cloudbp-backend/internal/config"
+	"cloudbp-backend/internal/model"
+	"cloudbp-backend/pkg/logger"
+	"cloudbp-backend/pkg/provider"
+
+	"github.com/gorilla/websocket"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+const (
+	// consoleLockKey 服务器控制台会话锁，同一台服务器同时只允许一个控制台会话
+	consoleLockKey = "console:server:%d"
+	// consoleDialTimeout 连接厂商控制台的超时时间
+	consoleDialTimeout = 10 * time.Second
+	// consoleCloseTimeout 发送关闭帧的超时时间
+	consoleCloseTimeout = time.Second
+)
+
+// ConsoleService 网页控制台服务：获取厂商控制台地址，WebSocket类型的控制台由平台代理，
+// 会话限制最长时长，打开和关闭均记录操作日志
+type ConsoleService struct {
+	db              *gorm.DB
+	rdb             *redis.Client
+	config          config.ConsoleConfig
+	providerService *ProviderService
+}
+
+// NewConsoleService 创建网页控制台服务
+func NewConsoleService(db *gorm.DB, rdb *redis.Client, cfg config.ConsoleConfig, providerService *ProviderService) *ConsoleService {
+	return &ConsoleService{
+		db:              db,
+		rdb:             rdb,
+		config:          cfg,
+		providerService: providerService,
+	}
+}
+
+// OpenConsoleRequest 打开控制台请求
+type OpenConsoleRequest struct {
+	ServerID  uint
+	UserID    uint
+	Username  string
+	IP        string
+	UserAgent string
+}
+
+// ConsoleSession 控制台会话
+type ConsoleSession struct {
+	Server  *model.Server
+	Console *provider.GetConsoleResponse
+	request *OpenConsoleRequest
+}
+
+// MaxDuration 单次会话最长时长
+func (s *ConsoleService) MaxDuration() time.Duration {
+	return time.Duration(s.config.MaxDuration) * time.Minute
+}
+
+// CheckOrigin 校验WebSocket连接的来源，未配置允许来源时不限制
+func (s *ConsoleService) CheckOrigin(origin string) bool {
+	if len(s.config.AllowedOrigins) == 0 {
+		return true
+	}
+	for _, allowed := range s.config.AllowedOrigins {
+		if origin == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+// OpenConsole 获取厂商控制台地址，服务器须属于该用户且正在运行；
+// 厂商返回网页地址时直接记录访问日志，WebSocket地址由 ProxyConsole 代理并记录
+func (s *ConsoleService) OpenConsole(ctx context.Context, req *OpenConsoleRequest) (*ConsoleSession, error) {
+	var server model.Server
+	if err := s.db.Preload("Provider").Where("id = ? AND user_id = ?", req.ServerID, req.UserID).First(&server).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("服务器不存在")
+		}
+		return nil, fmt.Errorf("获取服务器信息失败: %w", err)
+	}
+	if server.Status != model.ServerStatusRunning {
+		return nil, errors.New("服务器未运行，无法打开控制台")
+	}
+
+	cloudProvider, err := s.providerService.GetProvider(server.Provider.Code)
+	if err != nil {
+		return nil, err
+	}
+	consoleProvider, ok := cloudProvider.(provider.ConsoleProvider)
+	if !ok {
+		return nil, errors.New("该厂商不支持网页控制台")
+	}
+
+	console, err := consoleProvider.GetConsole(ctx, &provider.GetConsoleRequest{InstanceID: server.InstanceID})
+	if err != nil {
+		s.audit(req, fmt.Sprintf("打开服务器 %s 控制台失败: %v", server.Name, err), model.OperationLogStatusFailed)
+		return nil, fmt.Errorf("获取控制台地址失败: %w", err)
+	}
+
+	session := &ConsoleSession{Server: &server, Console: console, request: req}
+	if console.Type == provider.ConsoleTypeURL {
+		s.audit(req, fmt.Sprintf("获取服务器 %s 控制台地址", server.Name), model.OperationLogStatusSuccess)
+	}
+	return session, nil
+}
+
+// ProxyConsole 在用户和厂商控制台之间转发WebSocket消息，任一端断开或达到最长时长时结束会话
+func (s *ConsoleService) ProxyConsole(ctx context.Context, session *ConsoleSession, client *websocket.Conn) error {
+	defer client.Close()
+
+	server := session.Server
+	lockKey := fmt.Sprintf(consoleLockKey, server.ID)
+	maxDuration := s.MaxDuration()
+	acquired, err := s.rdb.SetNX(ctx, lockKey, session.request.UserID, maxDuration+time.Minute).Result()
+	if err != nil {
+		closeConsole(client, websocket.CloseInternalServerErr, "打开控制台失败")
+		return fmt.Errorf("获取控制台会话锁失败: %w", err)
+	}
+	if !acquired {
+		closeConsole(client, websocket.ClosePolicyViolation, "该服务器已有控制台会话")
+		return errors.New("该服务器已有控制台会话")
+	}
+	defer s.rdb.Del(context.Background(), lockKey)
+
+	ctx, cancel := context.WithTimeout(ctx, maxDuration)
+	defer cancel()
+
+	dialCtx, dialCancel := context.WithTimeout(ctx, consoleDialTimeout)
+	vendor, _, err := websocket.DefaultDialer.DialContext(dialCtx, session.Console.URL, nil)
+	dialCancel()
+	if err != nil {
+		closeConsole(client, websocket.CloseInternalServerErr, "连接厂商控制台失败")
+		s.audit(session.request, fmt.Sprintf("打开服务器 %s 控制台失败: %v", server.Name, err), model.OperationLogStatusFailed)
+		return fmt.Errorf("连接厂商控制台失败: %w", err)
+	}
+	defer vendor.Close()
+
+	start := time.Now()
+	s.audit(session.request, fmt.Sprintf("打开服务器 %s 控制台", server.Name), model.OperationLogStatusSuccess)
+
+	// 每个连接只有一个转发协程写入数据，关闭帧通过可并发调用的 WriteControl 发送
+	errc := make(chan error, 2)
+	go func() { errc <- pumpConsole(vendor, client) }()
+	go func() { errc <- pumpConsole(client, vendor) }()
+
+	select {
+	case <-ctx.Done():
+		closeConsole(client, websocket.CloseNormalClosure, "控制台会话已达到最长时长")
+	case <-errc:
+		closeConsole(client, websocket.CloseNormalClosure, "")
+	}
+	closeConsole(vendor, websocket.CloseNormalClosure, "")
+
+	duration := time.Since(start).Round(time.Second)
+	s.audit(session.request, fmt.Sprintf("关闭服务器 %s 控制台，时长 %s", server.Name, duration), model.OperationLogStatusSuccess)
+	logger.Log.Info("控制台会话结束", zap.Uint("server_id", server.ID), zap.Uint("user_id", session.request.UserID), zap.Duration("duration", duration))
+	return nil
+}
+
+// audit 记录控制台访问日志，写入失败只记录错误
+func (s *ConsoleService) audit(req *OpenConsoleRequest, content string, status int) {
+	log := model.OperationLog{
+		UserID:    req.UserID,
+		Username:  req.Username,
+		Module:    "server",
+		Action:    "console",
+		Content:   content,
+		IP:        req.IP,
+		UserAgent: req.UserAgent,
+		Status:    status,
+	}
+	if err := s.db.Create(&log).Error; err != nil {
+		logger.Log.Error("记录控制台访问日志失败", zap.Uint("server_id", req.ServerID), zap.Error(err))
+	}
+}
+
+// pumpConsole 将src收到的消息原样转发到dst，直到任一端出错
+func pumpConsole(dst, src *websocket.Conn) error {
+	for {
+		messageType, data, err := src.ReadMessage()
+		if err != nil {
+			return err
+		}
+		if err := dst.WriteMessage(messageType, data); err != nil {
+			return err
+		}
+	}
+}
+
+// closeConsole 向连接发送关闭帧
+func closeConsole(conn *websocket.Conn, code int, reason string) {
+	_ = conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason), time.Now().Add(consoleCloseTimeout))
+}
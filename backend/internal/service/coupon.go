@@ -0,0 +1,551 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"cloudbp-backend/internal/model"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// CouponService 优惠券和促销活动服务
+type CouponService struct {
+	db  *gorm.DB
+	rdb *redis.Client
+}
+
+// NewCouponService 创建优惠券服务
+func NewCouponService(db *gorm.DB, rdb *redis.Client) *CouponService {
+	return &CouponService{
+		db:  db,
+		rdb: rdb,
+	}
+}
+
+// DiscountRequest 优惠计算请求
+type DiscountRequest struct {
+	UserID     uint            `json:"user_id"`
+	ProductID  uint            `json:"product_id"`
+	ProviderID uint            `json:"provider_id"`
+	OrderType  string          `json:"order_type"`
+	Period     int             `json:"period"`
+	Amount     decimal.Decimal `json:"amount"` // 优惠前金额
+	CouponCode string          `json:"coupon_code"`
+}
+
+// DiscountResult 优惠计算结果，促销活动先于优惠券计算，两者可叠加
+type DiscountResult struct {
+	PromotionID       *uint           `json:"promotion_id"`
+	PromotionName     string          `json:"promotion_name"`
+	PromotionDiscount decimal.Decimal `json:"promotion_discount"`
+	CouponID          *uint           `json:"coupon_id"`
+	CouponCode        string          `json:"coupon_code"`
+	CouponDiscount    decimal.Decimal `json:"coupon_discount"`
+	CouponBase        decimal.Decimal `json:"-"`               // 核销优惠券时的订单金额，下单占用时复核门槛
+	DiscountAmount    decimal.Decimal `json:"discount_amount"` // 优惠合计
+}
+
+// ApplyDiscounts 计算订单优惠：自动匹配优惠力度最大的促销活动，再按剩余金额核销优惠券
+func (s *CouponService) ApplyDiscounts(tx *gorm.DB, req *DiscountRequest) (*DiscountResult, error) {
+	result := &DiscountResult{}
+	now := time.Now()
+
+	firstPurchase, err := isFirstPurchase(tx, req.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	// 促销活动
+	var promotions []model.Promotion
+	if err := tx.Where("status = ?", model.CouponStatusActive).
+		Where("(start_time IS NULL OR start_time <= ?) AND (end_time IS NULL OR end_time > ?)", now, now).
+		Find(&promotions).Error; err != nil {
+		return nil, fmt.Errorf("获取促销活动失败: %w", err)
+	}
+
+	for i := range promotions {
+		promotion := &promotions[i]
+		if promotion.FirstPurchaseOnly && !firstPurchase {
+			continue
+		}
+		if req.Period < promotion.MinPeriod || req.Amount.LessThan(promotion.MinAmount) {
+			continue
+		}
+		if !discountScopeMatches(req, promotion.ProductIDs, promotion.ProviderIDs, promotion.OrderTypes) {
+			continue
+		}
+
+		discount := calculateDiscount(promotion.Type, promotion.Value, promotion.MaxDiscount, req.Amount)
+		if discount.GreaterThan(result.PromotionDiscount) {
+			result.PromotionID = &promotion.ID
+			result.PromotionName = promotion.Name
+			result.PromotionDiscount = discount
+		}
+	}
+
+	remaining := req.Amount.Sub(result.PromotionDiscount)
+
+	// 优惠券
+	if code := strings.TrimSpace(req.CouponCode); code != "" {
+		var coupon model.Coupon
+		if err := tx.Where("code = ?", strings.ToUpper(code)).First(&coupon).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, errors.New("优惠券不存在")
+			}
+			return nil, fmt.Errorf("获取优惠券失败: %w", err)
+		}
+
+		if err := checkCoupon(tx, &coupon, req, remaining, firstPurchase); err != nil {
+			return nil, err
+		}
+
+		result.CouponID = &coupon.ID
+		result.CouponCode = coupon.Code
+		result.CouponBase = remaining
+		result.CouponDiscount = calculateDiscount(coupon.Type, coupon.Value, coupon.MaxDiscount, remaining)
+	}
+
+	result.DiscountAmount = result.PromotionDiscount.Add(result.CouponDiscount)
+	return result, nil
+}
+
+// checkCoupon 校验优惠券是否可用于当前订单
+func checkCoupon(tx *gorm.DB, coupon *model.Coupon, req *DiscountRequest, amount decimal.Decimal, firstPurchase bool) error {
+	now := time.Now()
+	if coupon.Status != model.CouponStatusActive {
+		return errors.New("优惠券已停用")
+	}
+	if coupon.StartTime != nil && now.Before(*coupon.StartTime) {
+		return errors.New("优惠券尚未生效")
+	}
+	if coupon.EndTime != nil && !now.Before(*coupon.EndTime) {
+		return errors.New("优惠券已过期")
+	}
+	if coupon.FirstPurchaseOnly && !firstPurchase {
+		return errors.New("优惠券仅限首次购买使用")
+	}
+	if !discountScopeMatches(req, coupon.ProductIDs, coupon.ProviderIDs, coupon.OrderTypes) {
+		return errors.New("优惠券不适用于当前订单")
+	}
+	if amount.LessThan(coupon.MinAmount) {
+		return fmt.Errorf("订单金额未达到优惠券最低消费 %s 元", coupon.MinAmount.StringFixed(2))
+	}
+	if coupon.TotalLimit > 0 && coupon.UsedCount >= coupon.TotalLimit {
+		return errors.New("优惠券已被领完")
+	}
+
+	if coupon.PerUserLimit > 0 {
+		var used int64
+		if err := tx.Model(&model.CouponUsage{}).
+			Where("coupon_id = ? AND user_id = ?", coupon.ID, req.UserID).
+			Count(&used).Error; err != nil {
+			return fmt.Errorf("获取优惠券使用记录失败: %w", err)
+		}
+		if int(used) >= coupon.PerUserLimit {
+			return errors.New("已达到该优惠券使用次数上限")
+		}
+	}
+
+	return nil
+}
+
+// reserveCoupon 下单时占用优惠券，在优惠券行锁内复核使用次数
+func reserveCoupon(tx *gorm.DB, discount *DiscountResult, order *model.Order) error {
+	if discount == nil || discount.CouponID == nil {
+		return nil
+	}
+
+	var coupon model.Coupon
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&coupon, *discount.CouponID).Error; err != nil {
+		return fmt.Errorf("获取优惠券失败: %w", err)
+	}
+
+	req := &DiscountRequest{
+		UserID:     order.UserID,
+		ProductID:  order.ProductID,
+		ProviderID: order.ProviderID,
+		OrderType:  order.Type,
+		Period:     order.Period,
+	}
+	if err := checkCoupon(tx, &coupon, req, discount.CouponBase, true); err != nil {
+		return err
+	}
+
+	if err := tx.Model(&coupon).Update("used_count", gorm.Expr("used_count + 1")).Error; err != nil {
+		return fmt.Errorf("更新优惠券使用次数失败: %w", err)
+	}
+
+	usage := model.CouponUsage{
+		CouponID:       coupon.ID,
+		UserID:         order.UserID,
+		OrderID:        order.ID,
+		DiscountAmount: discount.CouponDiscount,
+	}
+	if err := tx.Create(&usage).Error; err != nil {
+		return fmt.Errorf("创建优惠券使用记录失败: %w", err)
+	}
+	return nil
+}
+
+// releaseCoupon 订单关闭时退回占用的优惠券
+func releaseCoupon(tx *gorm.DB, orderID uint) error {
+	var usage model.CouponUsage
+	if err := tx.Where("order_id = ?", orderID).First(&usage).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return fmt.Errorf("获取优惠券使用记录失败: %w", err)
+	}
+
+	if err := tx.Delete(&usage).Error; err != nil {
+		return fmt.Errorf("删除优惠券使用记录失败: %w", err)
+	}
+	return tx.Model(&model.Coupon{}).
+		Where("id = ? AND used_count > 0", usage.CouponID).
+		Update("used_count", gorm.Expr("used_count - 1")).Error
+}
+
+// isFirstPurchase 用户是否从未成功购买过服务器
+func isFirstPurchase(tx *gorm.DB, userID uint) (bool, error) {
+	var count int64
+	if err := tx.Model(&model.Order{}).
+		Where("user_id = ? AND type = ? AND status IN ?", userID, model.OrderTypeNew,
+			[]string{model.OrderStatusPaid, model.OrderStatusProcessing, model.OrderStatusSuccess}).
+		Count(&count).Error; err != nil {
+		return false, fmt.Errorf("获取历史订单失败: %w", err)
+	}
+	return count == 0, nil
+}
+
+// discountScopeMatches 检查订单是否在适用范围内，范围为空表示不限
+func discountScopeMatches(req *DiscountRequest, productIDs, providerIDs []uint, orderTypes []string) bool {
+	if len(productIDs) > 0 && !containsUint(productIDs, req.ProductID) {
+		return false
+	}
+	if len(providerIDs) > 0 && !containsUint(providerIDs, req.ProviderID) {
+		return false
+	}
+	if len(orderTypes) > 0 {
+		for _, orderType := range orderTypes {
+			if orderType == req.OrderType {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// calculateDiscount 计算优惠金额，不超过订单金额
+func calculateDiscount(discountType string, value, maxDiscount, amount decimal.Decimal) decimal.Decimal {
+	discount := decimal.Zero
+	switch discountType {
+	case model.DiscountTypeFixed:
+		discount = value
+	case model.DiscountTypePercent:
+		discount = amount.Mul(value).Div(decimal.NewFromInt(100))
+		if maxDiscount.IsPositive() {
+			discount = decimal.Min(discount, maxDiscount)
+		}
+	}
+	return roundAmount(decimal.Max(decimal.Zero, decimal.Min(discount, amount)))
+}
+
+// containsUint 切片是否包含指定值
+func containsUint(values []uint, target uint) bool {
+	for _, value := range values {
+		if value == target {
+			return true
+		}
+	}
+	return false
+}
+
+// SaveCouponRequest 创建或更新优惠券请求
+type SaveCouponRequest struct {
+	Code              string          `json:"code" binding:"required"`
+	Name              string          `json:"name" binding:"required"`
+	Type              string          `json:"type" binding:"required,oneof=fixed percent"`
+	Value             decimal.Decimal `json:"value" binding:"required"`
+	MaxDiscount       decimal.Decimal `json:"max_discount"`
+	MinAmount         decimal.Decimal `json:"min_amount"`
+	ProductIDs        []uint          `json:"product_ids"`
+	ProviderIDs       []uint          `json:"provider_ids"`
+	OrderTypes        []string        `json:"order_types"`
+	FirstPurchaseOnly bool            `json:"first_purchase_only"`
+	TotalLimit        int             `json:"total_limit"`
+	PerUserLimit      int             `json:"per_user_limit"`
+	StartTime         *time.Time      `json:"start_time"`
+	EndTime           *time.Time      `json:"end_time"`
+	Status            int             `json:"status"`
+	Description       string          `json:"description"`
+}
+
+// GetCouponsRequest 获取优惠券列表请求
+type GetCouponsRequest struct {
+	Page    int    `json:"page"`
+	Size    int    `json:"size"`
+	Keyword string `json:"keyword"`
+	Status  int    `json:"status"`
+}
+
+// GetCouponsResponse 获取优惠券列表响应
+type GetCouponsResponse struct {
+	Coupons    []model.Coupon `json:"coupons"`
+	TotalCount int64          `json:"total_count"`
+	Page       int            `json:"page"`
+	Size       int            `json:"size"`
+}
+
+// GetCoupons 获取优惠券列表
+func (s *CouponService) GetCoupons(ctx context.Context, req *GetCouponsRequest) (*GetCouponsResponse, error) {
+	var coupons []model.Coupon
+	var totalCount int64
+
+	query := s.db.Model(&model.Coupon{})
+	if req.Keyword != "" {
+		query = query.Where("code LIKE ? OR name LIKE ?", "%"+req.Keyword+"%", "%"+req.Keyword+"%")
+	}
+	if req.Status != 0 {
+		query = query.Where("status = ?", req.Status)
+	}
+
+	// 获取总数
+	if err := query.Count(&totalCount).Error; err != nil {
+		return nil, fmt.Errorf("获取优惠券总数失败: %w", err)
+	}
+
+	// 获取分页数据
+	offset := (req.Page - 1) * req.Size
+	if err := query.Offset(offset).Limit(req.Size).Order("created_at DESC").Find(&coupons).Error; err != nil {
+		return nil, fmt.Errorf("获取优惠券列表失败: %w", err)
+	}
+
+	return &GetCouponsResponse{
+		Coupons:    coupons,
+		TotalCount: totalCount,
+		Page:       req.Page,
+		Size:       req.Size,
+	}, nil
+}
+
+// CreateCoupon 创建优惠券
+func (s *CouponService) CreateCoupon(ctx context.Context, req *SaveCouponRequest) (*model.Coupon, error) {
+	coupon := model.Coupon{}
+	if err := applyCouponRequest(&coupon, req); err != nil {
+		return nil, err
+	}
+
+	var count int64
+	if err := s.db.Model(&model.Coupon{}).Where("code = ?", coupon.Code).Count(&count).Error; err != nil {
+		return nil, fmt.Errorf("检查券码失败: %w", err)
+	}
+	if count > 0 {
+		return nil, errors.New("券码已存在")
+	}
+
+	if err := s.db.Create(&coupon).Error; err != nil {
+		return nil, fmt.Errorf("创建优惠券失败: %w", err)
+	}
+	return &coupon, nil
+}
+
+// UpdateCoupon 更新优惠券，已使用次数不受影响
+func (s *CouponService) UpdateCoupon(ctx context.Context, id uint, req *SaveCouponRequest) (*model.Coupon, error) {
+	var coupon model.Coupon
+	if err := s.db.First(&coupon, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("优惠券不存在")
+		}
+		return nil, fmt.Errorf("获取优惠券失败: %w", err)
+	}
+
+	if err := applyCouponRequest(&coupon, req); err != nil {
+		return nil, err
+	}
+
+	var count int64
+	if err := s.db.Model(&model.Coupon{}).Where("code = ? AND id <> ?", coupon.Code, id).Count(&count).Error; err != nil {
+		return nil, fmt.Errorf("检查券码失败: %w", err)
+	}
+	if count > 0 {
+		return nil, errors.New("券码已存在")
+	}
+
+	if err := s.db.Model(&coupon).Select("*").Omit("id", "used_count", "created_at").Updates(&coupon).Error; err != nil {
+		return nil, fmt.Errorf("更新优惠券失败: %w", err)
+	}
+	return &coupon, nil
+}
+
+// DeleteCoupon 删除优惠券
+func (s *CouponService) DeleteCoupon(ctx context.Context, id uint) error {
+	result := s.db.Delete(&model.Coupon{}, id)
+	if result.Error != nil {
+		return fmt.Errorf("删除优惠券失败: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("优惠券不存在")
+	}
+	return nil
+}
+
+// applyCouponRequest 校验并写入优惠券字段
+func applyCouponRequest(coupon *model.Coupon, req *SaveCouponRequest) error {
+	if err := validateDiscountRule(req.Type, req.Value, req.MaxDiscount, req.MinAmount, req.StartTime, req.EndTime); err != nil {
+		return err
+	}
+	if req.TotalLimit < 0 || req.PerUserLimit < 0 {
+		return errors.New("使用次数限制不能为负数")
+	}
+
+	coupon.Code = strings.ToUpper(strings.TrimSpace(req.Code))
+	coupon.Name = req.Name
+	coupon.Type = req.Type
+	coupon.Value = req.Value
+	coupon.MaxDiscount = req.MaxDiscount
+	coupon.MinAmount = req.MinAmount
+	coupon.ProductIDs = req.ProductIDs
+	coupon.ProviderIDs = req.ProviderIDs
+	coupon.OrderTypes = req.OrderTypes
+	coupon.FirstPurchaseOnly = req.FirstPurchaseOnly
+	coupon.TotalLimit = req.TotalLimit
+	coupon.PerUserLimit = req.PerUserLimit
+	coupon.StartTime = req.StartTime
+	coupon.EndTime = req.EndTime
+	coupon.Status = req.Status
+	coupon.Description = req.Description
+	if coupon.Status == 0 {
+		coupon.Status = model.CouponStatusActive
+	}
+	return nil
+}
+
+// SavePromotionRequest 创建或更新促销活动请求
+type SavePromotionRequest struct {
+	Name              string          `json:"name" binding:"required"`
+	Type              string          `json:"type" binding:"required,oneof=fixed percent"`
+	Value             decimal.Decimal `json:"value" binding:"required"`
+	MaxDiscount       decimal.Decimal `json:"max_discount"`
+	MinAmount         decimal.Decimal `json:"min_amount"`
+	MinPeriod         int             `json:"min_period"`
+	ProductIDs        []uint          `json:"product_ids"`
+	ProviderIDs       []uint          `json:"provider_ids"`
+	OrderTypes        []string        `json:"order_types"`
+	FirstPurchaseOnly bool            `json:"first_purchase_only"`
+	StartTime         *time.Time      `json:"start_time"`
+	EndTime           *time.Time      `json:"end_time"`
+	Status            int             `json:"status"`
+	Description       string          `json:"description"`
+}
+
+// GetPromotions 获取促销活动列表
+func (s *CouponService) GetPromotions(ctx context.Context, status int) ([]model.Promotion, error) {
+	var promotions []model.Promotion
+
+	query := s.db.Model(&model.Promotion{})
+	if status != 0 {
+		query = query.Where("status = ?", status)
+	}
+	if err := query.Order("created_at DESC").Find(&promotions).Error; err != nil {
+		return nil, fmt.Errorf("获取促销活动失败: %w", err)
+	}
+	return promotions, nil
+}
+
+// CreatePromotion 创建促销活动
+func (s *CouponService) CreatePromotion(ctx context.Context, req *SavePromotionRequest) (*model.Promotion, error) {
+	promotion := model.Promotion{}
+	if err := applyPromotionRequest(&promotion, req); err != nil {
+		return nil, err
+	}
+
+	if err := s.db.Create(&promotion).Error; err != nil {
+		return nil, fmt.Errorf("创建促销活动失败: %w", err)
+	}
+	return &promotion, nil
+}
+
+// UpdatePromotion 更新促销活动
+func (s *CouponService) UpdatePromotion(ctx context.Context, id uint, req *SavePromotionRequest) (*model.Promotion, error) {
+	var promotion model.Promotion
+	if err := s.db.First(&promotion, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("促销活动不存在")
+		}
+		return nil, fmt.Errorf("获取促销活动失败: %w", err)
+	}
+
+	if err := applyPromotionRequest(&promotion, req); err != nil {
+		return nil, err
+	}
+
+	if err := s.db.Model(&promotion).Select("*").Omit("id", "created_at").Updates(&promotion).Error; err != nil {
+		return nil, fmt.Errorf("更新促销活动失败: %w", err)
+	}
+	return &promotion, nil
+}
+
+// DeletePromotion 删除促销活动
+func (s *CouponService) DeletePromotion(ctx context.Context, id uint) error {
+	result := s.db.Delete(&model.Promotion{}, id)
+	if result.Error != nil {
+		return fmt.Errorf("删除促销活动失败: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("促销活动不存在")
+	}
+	return nil
+}
+
+// applyPromotionRequest 校验并写入促销活动字段
+func applyPromotionRequest(promotion *model.Promotion, req *SavePromotionRequest) error {
+	if err := validateDiscountRule(req.Type, req.Value, req.MaxDiscount, req.MinAmount, req.StartTime, req.EndTime); err != nil {
+		return err
+	}
+
+	promotion.Name = req.Name
+	promotion.Type = req.Type
+	promotion.Value = req.Value
+	promotion.MaxDiscount = req.MaxDiscount
+	promotion.MinAmount = req.MinAmount
+	promotion.MinPeriod = req.MinPeriod
+	promotion.ProductIDs = req.ProductIDs
+	promotion.ProviderIDs = req.ProviderIDs
+	promotion.OrderTypes = req.OrderTypes
+	promotion.FirstPurchaseOnly = req.FirstPurchaseOnly
+	promotion.StartTime = req.StartTime
+	promotion.EndTime = req.EndTime
+	promotion.Status = req.Status
+	promotion.Description = req.Description
+	if promotion.Status == 0 {
+		promotion.Status = model.CouponStatusActive
+	}
+	return nil
+}
+
+// validateDiscountRule 校验优惠规则
+func validateDiscountRule(discountType string, value, maxDiscount, minAmount decimal.Decimal, startTime, endTime *time.Time) error {
+	if !value.IsPositive() {
+		return errors.New("优惠金额或折扣比例必须大于0")
+	}
+	for _, amount := range []decimal.Decimal{value, maxDiscount, minAmount} {
+		if amount.IsNegative() || !amount.Round(2).Equal(amount) {
+			return errors.New("金额不能为负数且最多两位小数")
+		}
+	}
+	if discountType == model.DiscountTypePercent && value.GreaterThan(decimal.NewFromInt(100)) {
+		return errors.New("折扣比例不能超过100")
+	}
+	if startTime != nil && endTime != nil && !endTime.After(*startTime) {
+		return errors.New("结束时间必须晚于开始时间")
+	}
+	return nil
+}
@@ -0,0 +1,261 @@
+package service
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"cloudbp-backend/internal/config"
+	"cloudbp-backend/internal/model"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// currencyPattern ISO 4217 币种代码
+var currencyPattern = regexp.MustCompile(`^[A-Z]{3}$`)
+
+// CurrencyService 币种和汇率服务，钱包账本和报表以本位币计，订单按下单时锁定的汇率折算
+type CurrencyService struct {
+	db     *gorm.DB
+	rdb    *redis.Client
+	config config.CurrencyConfig
+}
+
+// NewCurrencyService 创建币种服务
+func NewCurrencyService(db *gorm.DB, rdb *redis.Client, cfg config.CurrencyConfig) *CurrencyService {
+	if cfg.Base == "" {
+		cfg.Base = "CNY"
+	}
+	return &CurrencyService{
+		db:     db,
+		rdb:    rdb,
+		config: cfg,
+	}
+}
+
+// BaseCurrency 本位币
+func (s *CurrencyService) BaseCurrency() string {
+	return s.config.Base
+}
+
+// Rate 获取币种汇率：1单位该币种折合本位币
+func (s *CurrencyService) Rate(currency string) (decimal.Decimal, error) {
+	currency = normalizeCurrency(currency)
+	if currency == "" || currency == s.config.Base {
+		return decimal.NewFromInt(1), nil
+	}
+
+	var rate model.ExchangeRate
+	if err := s.db.Where("currency = ?", currency).First(&rate).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return decimal.Zero, fmt.Errorf("不支持的币种: %s", currency)
+		}
+		return decimal.Zero, fmt.Errorf("获取汇率失败: %w", err)
+	}
+	return rate.Rate, nil
+}
+
+// Convert 币种换算，产品标价换算为结算币种的定点金额（保留两位小数）
+func (s *CurrencyService) Convert(amount decimal.Decimal, from, to string) (decimal.Decimal, error) {
+	from, to = normalizeCurrency(from), normalizeCurrency(to)
+	if from == to {
+		return roundAmount(amount), nil
+	}
+
+	fromRate, err := s.Rate(from)
+	if err != nil {
+		return decimal.Zero, err
+	}
+	toRate, err := s.Rate(to)
+	if err != nil {
+		return decimal.Zero, err
+	}
+
+	return amount.Mul(fromRate).Div(toRate).Round(2), nil
+}
+
+// CurrencyInfo 可用币种
+type CurrencyInfo struct {
+	Currency string          `json:"currency"`
+	Rate     decimal.Decimal `json:"rate"` // 1单位该币种折合本位币
+	Base     bool            `json:"base"` // 是否本位币
+}
+
+// GetCurrencies 获取可用币种：本位币及已配置汇率的外币
+func (s *CurrencyService) GetCurrencies(ctx context.Context) ([]CurrencyInfo, error) {
+	rates, err := s.GetExchangeRates(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	currencies := []CurrencyInfo{{Currency: s.config.Base, Rate: decimal.NewFromInt(1), Base: true}}
+	for _, rate := range rates {
+		currencies = append(currencies, CurrencyInfo{Currency: rate.Currency, Rate: rate.Rate})
+	}
+	return currencies, nil
+}
+
+// GetExchangeRates 获取汇率列表
+func (s *CurrencyService) GetExchangeRates(ctx context.Context) ([]model.ExchangeRate, error) {
+	var rates []model.ExchangeRate
+	if err := s.db.Order("currency").Find(&rates).Error; err != nil {
+		return nil, fmt.Errorf("获取汇率列表失败: %w", err)
+	}
+	return rates, nil
+}
+
+// SaveExchangeRateRequest 设置汇率请求
+type SaveExchangeRateRequest struct {
+	Currency   string          `json:"currency"`
+	Rate       decimal.Decimal `json:"rate" binding:"required"` // 1单位该币种折合本位币
+	OperatorID uint            `json:"operator_id"`
+}
+
+// SaveExchangeRate 新增或更新汇率
+func (s *CurrencyService) SaveExchangeRate(ctx context.Context, req *SaveExchangeRateRequest) (*model.ExchangeRate, error) {
+	rate, err := s.validateRate(req.Currency, req.Rate)
+	if err != nil {
+		return nil, err
+	}
+	rate.Source = model.ExchangeRateSourceManual
+	rate.OperatorID = &req.OperatorID
+
+	if err := upsertExchangeRate(s.db, rate); err != nil {
+		return nil, err
+	}
+	return rate, nil
+}
+
+// DeleteExchangeRate 删除汇率，删除后该币种不可再下单，已下单的订单不受影响
+func (s *CurrencyService) DeleteExchangeRate(ctx context.Context, currency string) error {
+	currency = normalizeCurrency(currency)
+
+	var count int64
+	if err := s.db.Model(&model.Product{}).Where("currency = ?", currency).Count(&count).Error; err != nil {
+		return fmt.Errorf("检查产品币种失败: %w", err)
+	}
+	if count > 0 {
+		return errors.New("仍有产品以该币种标价，无法删除")
+	}
+
+	result := s.db.Where("currency = ?", currency).Delete(&model.ExchangeRate{})
+	if result.Error != nil {
+		return fmt.Errorf("删除汇率失败: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("汇率不存在")
+	}
+	return nil
+}
+
+// ImportExchangeRates 从CSV文件批量导入汇率，每行格式为“币种,汇率”，首行可为表头；
+// 任一行不合法时全部不导入
+func (s *CurrencyService) ImportExchangeRates(ctx context.Context, r io.Reader, operatorID uint) ([]model.ExchangeRate, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = 2
+	reader.TrimLeadingSpace = true
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("解析汇率文件失败: %w", err)
+	}
+
+	var rates []model.ExchangeRate
+	for i, record := range records {
+		// 跳过表头
+		if i == 0 && strings.EqualFold(strings.TrimSpace(record[0]), "currency") {
+			continue
+		}
+
+		value, err := decimal.NewFromString(strings.TrimSpace(record[1]))
+		if err != nil {
+			return nil, fmt.Errorf("第%d行汇率格式错误", i+1)
+		}
+		rate, err := s.validateRate(record[0], value)
+		if err != nil {
+			return nil, fmt.Errorf("第%d行: %w", i+1, err)
+		}
+		rate.Source = model.ExchangeRateSourceFile
+		rate.OperatorID = &operatorID
+		rates = append(rates, *rate)
+	}
+	if len(rates) == 0 {
+		return nil, errors.New("汇率文件为空")
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		for i := range rates {
+			if err := upsertExchangeRate(tx, &rates[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return rates, nil
+}
+
+// SetUserCurrency 设置用户结算币种，之后的报价和订单均以该币种计价
+func (s *CurrencyService) SetUserCurrency(ctx context.Context, userID uint, currency string) error {
+	currency = normalizeCurrency(currency)
+	if _, err := s.Rate(currency); err != nil {
+		return err
+	}
+
+	if err := s.db.Model(&model.User{}).Where("id = ?", userID).Update("currency", currency).Error; err != nil {
+		return fmt.Errorf("更新结算币种失败: %w", err)
+	}
+	return nil
+}
+
+// validateRate 校验币种代码和汇率
+func (s *CurrencyService) validateRate(currency string, rate decimal.Decimal) (*model.ExchangeRate, error) {
+	currency = normalizeCurrency(currency)
+	if !currencyPattern.MatchString(currency) {
+		return nil, errors.New("币种代码格式错误")
+	}
+	if currency == s.config.Base {
+		return nil, errors.New("本位币无需设置汇率")
+	}
+	if !rate.IsPositive() {
+		return nil, errors.New("汇率必须大于0")
+	}
+	return &model.ExchangeRate{Currency: currency, Rate: rate}, nil
+}
+
+// upsertExchangeRate 按币种新增或覆盖汇率
+func upsertExchangeRate(tx *gorm.DB, rate *model.ExchangeRate) error {
+	if err := tx.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "currency"}},
+		DoUpdates: clause.AssignmentColumns([]string{"rate", "source", "operator_id", "updated_at"}),
+	}).Create(rate).Error; err != nil {
+		return fmt.Errorf("保存汇率失败: %w", err)
+	}
+	return nil
+}
+
+// normalizeCurrency 币种代码统一为大写
+func normalizeCurrency(currency string) string {
+	return strings.ToUpper(strings.TrimSpace(currency))
+}
+
+// toBaseAmount 按订单锁定的汇率将订单币种金额折合为本位币，全额时直接取下单时的折算结果避免尾差
+func toBaseAmount(order *model.Order, amount decimal.Decimal) decimal.Decimal {
+	if amount.Equal(order.PayAmount) && !order.BasePayAmount.IsZero() {
+		return order.BasePayAmount
+	}
+	rate := order.ExchangeRate
+	if !rate.IsPositive() {
+		rate = decimal.NewFromInt(1)
+	}
+	return roundAmount(amount.Mul(rate))
+}
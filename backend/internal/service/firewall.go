@@ -0,0 +1,421 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/netip"
+	"strconv"
+	"strings"
+
+	"cloudbp-backend/internal/model"
+	"cloudbp-backend/pkg/provider"
+
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+)
+
+const (
+	maxFirewallRulesPerServer = 50 // 每台服务器最多的防火墙规则数
+	maxFirewallPortItems      = 15 // 单条规则最多的端口项数
+)
+
+// FirewallPreset 防火墙规则预设模板
+type FirewallPreset struct {
+	Code        string                `json:"code"`
+	Name        string                `json:"name"`
+	Description string                `json:"description"`
+	Replace     bool                  `json:"replace"` // 应用时是否替换服务器现有规则
+	Rules       []FirewallRuleRequest `json:"rules"`
+}
+
+// firewallPresets 内置的防火墙预设
+var firewallPresets = []FirewallPreset{
+	{
+		Code:        "web",
+		Name:        "Web服务",
+		Description: "开放HTTP和HTTPS端口",
+		Rules: []FirewallRuleRequest{
+			{Protocol: model.FirewallProtocolTCP, Port: "80", CIDR: "0.0.0.0/0", Description: "HTTP"},
+			{Protocol: model.FirewallProtocolTCP, Port: "443", CIDR: "0.0.0.0/0", Description: "HTTPS"},
+		},
+	},
+	{
+		Code:        "ssh_only",
+		Name:        "仅SSH",
+		Description: "移除现有规则，只开放SSH端口和Ping",
+		Replace:     true,
+		Rules: []FirewallRuleRequest{
+			{Protocol: model.FirewallProtocolTCP, Port: "22", CIDR: "0.0.0.0/0", Description: "SSH"},
+			{Protocol: model.FirewallProtocolICMP, Port: "ALL", CIDR: "0.0.0.0/0", Description: "Ping"},
+		},
+	},
+	{
+		Code:        "database",
+		Name:        "数据库",
+		Description: "开放MySQL、PostgreSQL、Redis和MongoDB端口，建议指定来源网段",
+		Rules: []FirewallRuleRequest{
+			{Protocol: model.FirewallProtocolTCP, Port: "3306", CIDR: "10.0.0.0/8", Description: "MySQL"},
+			{Protocol: model.FirewallProtocolTCP, Port: "5432", CIDR: "10.0.0.0/8", Description: "PostgreSQL"},
+			{Protocol: model.FirewallProtocolTCP, Port: "6379", CIDR: "10.0.0.0/8", Description: "Redis"},
+			{Protocol: model.FirewallProtocolTCP, Port: "27017", CIDR: "10.0.0.0/8", Description: "MongoDB"},
+		},
+	},
+}
+
+// FirewallService 服务器防火墙服务：规则保存在本地并同步到厂商，厂商须支持防火墙能力
+type FirewallService struct {
+	db              *gorm.DB
+	rdb             *redis.Client
+	providerService *ProviderService
+}
+
+// NewFirewallService 创建防火墙服务
+func NewFirewallService(db *gorm.DB, rdb *redis.Client, providerService *ProviderService) *FirewallService {
+	return &FirewallService{
+		db:              db,
+		rdb:             rdb,
+		providerService: providerService,
+	}
+}
+
+// FirewallRuleRequest 防火墙规则
+type FirewallRuleRequest struct {
+	Protocol    string `json:"protocol" binding:"required"` // TCP、UDP、ICMP、ALL
+	Port        string `json:"port"`                        // 端口，如 22、80,443、8000-9000，ICMP和ALL协议可不填
+	CIDR        string `json:"cidr"`                        // 来源网段或IP，默认0.0.0.0/0
+	Action      string `json:"action"`                      // ACCEPT、DROP，默认ACCEPT
+	Description string `json:"description"`
+}
+
+// AddFirewallRulesRequest 添加防火墙规则请求，指定预设时按预设添加，CIDR可覆盖预设的来源网段
+type AddFirewallRulesRequest struct {
+	ServerID uint                  `json:"server_id"`
+	UserID   uint                  `json:"user_id"`
+	Preset   string                `json:"preset"` // 预设：web、ssh_only、database
+	CIDR     string                `json:"cidr"`   // 应用预设时的来源网段
+	Rules    []FirewallRuleRequest `json:"rules"`
+}
+
+// GetPresets 获取防火墙预设
+func (s *FirewallService) GetPresets() []FirewallPreset {
+	return firewallPresets
+}
+
+// GetFirewallRules 获取服务器防火墙规则，本地尚无规则时从厂商导入
+func (s *FirewallService) GetFirewallRules(ctx context.Context, userID, serverID uint) ([]model.FirewallRule, error) {
+	server, firewall, err := s.firewallServer(userID, serverID)
+	if err != nil {
+		return nil, err
+	}
+
+	var rules []model.FirewallRule
+	if err := s.db.Where("server_id = ?", server.ID).Order("id").Find(&rules).Error; err != nil {
+		return nil, fmt.Errorf("获取防火墙规则失败: %w", err)
+	}
+	if len(rules) > 0 {
+		return rules, nil
+	}
+
+	resp, err := firewall.GetFirewallRules(ctx, &provider.GetFirewallRulesRequest{InstanceID: server.InstanceID})
+	if err != nil {
+		return nil, fmt.Errorf("获取厂商防火墙规则失败: %w", err)
+	}
+	rules = make([]model.FirewallRule, 0, len(resp.Rules))
+	for _, rule := range resp.Rules {
+		rules = append(rules, model.FirewallRule{
+			ServerID:    server.ID,
+			Protocol:    rule.Protocol,
+			Port:        rule.Port,
+			CIDR:        rule.CIDR,
+			Action:      rule.Action,
+			Description: rule.Description,
+		})
+	}
+	if len(rules) > 0 {
+		if err := s.db.Create(&rules).Error; err != nil {
+			return nil, fmt.Errorf("保存防火墙规则失败: %w", err)
+		}
+	}
+	return rules, nil
+}
+
+// AddFirewallRules 添加防火墙规则，与现有规则重复的跳过；预设要求替换时先删除现有规则
+func (s *FirewallService) AddFirewallRules(ctx context.Context, req *AddFirewallRulesRequest) ([]model.FirewallRule, error) {
+	requests := req.Rules
+	replace := false
+	if req.Preset != "" {
+		preset := findFirewallPreset(req.Preset)
+		if preset == nil {
+			return nil, errors.New("防火墙预设不存在")
+		}
+		if len(req.Rules) > 0 {
+			return nil, errors.New("预设和自定义规则只能选择一种")
+		}
+		requests = make([]FirewallRuleRequest, 0, len(preset.Rules))
+		for _, rule := range preset.Rules {
+			if req.CIDR != "" {
+				rule.CIDR = req.CIDR
+			}
+			requests = append(requests, rule)
+		}
+		replace = preset.Replace
+	}
+	if len(requests) == 0 {
+		return nil, errors.New("请至少添加一条规则")
+	}
+
+	// 先读取现有规则，确保已从厂商导入
+	existing, err := s.GetFirewallRules(ctx, req.UserID, req.ServerID)
+	if err != nil {
+		return nil, err
+	}
+	server, firewall, err := s.firewallServer(req.UserID, req.ServerID)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool, len(existing)+len(requests))
+	if !replace {
+		for _, rule := range existing {
+			seen[firewallRuleKey(rule.Protocol, rule.Port, rule.CIDR, rule.Action)] = true
+		}
+	}
+	rules := make([]model.FirewallRule, 0, len(requests))
+	for i := range requests {
+		rule, err := normalizeFirewallRule(&requests[i])
+		if err != nil {
+			return nil, fmt.Errorf("第%d条规则: %w", i+1, err)
+		}
+		key := firewallRuleKey(rule.Protocol, rule.Port, rule.CIDR, rule.Action)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		rule.ServerID = server.ID
+		rules = append(rules, *rule)
+	}
+
+	total := len(existing) + len(rules)
+	if replace {
+		total = len(rules)
+	}
+	if total > maxFirewallRulesPerServer {
+		return nil, fmt.Errorf("每台服务器最多%d条防火墙规则", maxFirewallRulesPerServer)
+	}
+
+	if replace && len(existing) > 0 {
+		if err := firewall.DeleteFirewallRules(ctx, &provider.DeleteFirewallRulesRequest{
+			InstanceID: server.InstanceID,
+			Rules:      toProviderFirewallRules(existing),
+		}); err != nil {
+			return nil, fmt.Errorf("删除厂商防火墙规则失败: %w", err)
+		}
+		if err := s.db.Where("server_id = ?", server.ID).Delete(&model.FirewallRule{}).Error; err != nil {
+			return nil, fmt.Errorf("删除防火墙规则失败: %w", err)
+		}
+	}
+	if len(rules) == 0 {
+		return rules, nil
+	}
+
+	if err := firewall.CreateFirewallRules(ctx, &provider.CreateFirewallRulesRequest{
+		InstanceID: server.InstanceID,
+		Rules:      toProviderFirewallRules(rules),
+	}); err != nil {
+		return nil, fmt.Errorf("添加厂商防火墙规则失败: %w", err)
+	}
+	if err := s.db.Create(&rules).Error; err != nil {
+		return nil, fmt.Errorf("保存防火墙规则失败: %w", err)
+	}
+	return rules, nil
+}
+
+// DeleteFirewallRule 删除防火墙规则
+func (s *FirewallService) DeleteFirewallRule(ctx context.Context, userID, serverID, ruleID uint) error {
+	server, firewall, err := s.firewallServer(userID, serverID)
+	if err != nil {
+		return err
+	}
+
+	var rule model.FirewallRule
+	if err := s.db.Where("id = ? AND server_id = ?", ruleID, server.ID).First(&rule).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.New("防火墙规则不存在")
+		}
+		return fmt.Errorf("获取防火墙规则失败: %w", err)
+	}
+
+	if err := firewall.DeleteFirewallRules(ctx, &provider.DeleteFirewallRulesRequest{
+		InstanceID: server.InstanceID,
+		Rules:      toProviderFirewallRules([]model.FirewallRule{rule}),
+	}); err != nil {
+		return fmt.Errorf("删除厂商防火墙规则失败: %w", err)
+	}
+	if err := s.db.Delete(&rule).Error; err != nil {
+		return fmt.Errorf("删除防火墙规则失败: %w", err)
+	}
+	return nil
+}
+
+// firewallServer 获取用户的服务器及其厂商的防火墙能力
+func (s *FirewallService) firewallServer(userID, serverID uint) (*model.Server, provider.FirewallManager, error) {
+	var server model.Server
+	if err := s.db.Preload("Provider").Where("id = ? AND user_id = ?", serverID, userID).First(&server).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, errors.New("服务器不存在")
+		}
+		return nil, nil, fmt.Errorf("获取服务器信息失败: %w", err)
+	}
+	if server.Status != model.ServerStatusRunning && server.Status != model.ServerStatusStopped {
+		return nil, nil, errors.New("当前状态的服务器不能管理防火墙")
+	}
+
+	cloudProvider, err := s.providerService.GetProvider(server.Provider.Code)
+	if err != nil {
+		return nil, nil, err
+	}
+	firewall, ok := cloudProvider.(provider.FirewallManager)
+	if !ok {
+		return nil, nil, errors.New("该厂商不支持防火墙")
+	}
+	return &server, firewall, nil
+}
+
+// findFirewallPreset 查找防火墙预设
+func findFirewallPreset(code string) *FirewallPreset {
+	for i := range firewallPresets {
+		if firewallPresets[i].Code == code {
+			return &firewallPresets[i]
+		}
+	}
+	return nil
+}
+
+// normalizeFirewallRule 校验并规范化规则：协议和策略转为大写，端口去除空格，来源IP转为网段
+func normalizeFirewallRule(req *FirewallRuleRequest) (*model.FirewallRule, error) {
+	protocol := strings.ToUpper(strings.TrimSpace(req.Protocol))
+	action := strings.ToUpper(strings.TrimSpace(req.Action))
+	if action == "" {
+		action = model.FirewallActionAccept
+	}
+	if action != model.FirewallActionAccept && action != model.FirewallActionDrop {
+		return nil, errors.New("策略只能是ACCEPT或DROP")
+	}
+	if len([]rune(req.Description)) > 64 {
+		return nil, errors.New("备注不能超过64个字符")
+	}
+
+	var port string
+	switch protocol {
+	case model.FirewallProtocolTCP, model.FirewallProtocolUDP:
+		var err error
+		if port, err = normalizeFirewallPort(req.Port); err != nil {
+			return nil, err
+		}
+	case model.FirewallProtocolICMP, model.FirewallProtocolALL:
+		port = strings.ToUpper(strings.TrimSpace(req.Port))
+		if port != "" && port != "ALL" {
+			return nil, fmt.Errorf("%s协议不能指定端口", protocol)
+		}
+		port = "ALL"
+	default:
+		return nil, errors.New("协议只能是TCP、UDP、ICMP或ALL")
+	}
+
+	cidr, err := normalizeFirewallCIDR(req.CIDR)
+	if err != nil {
+		return nil, err
+	}
+
+	return &model.FirewallRule{
+		Protocol:    protocol,
+		Port:        port,
+		CIDR:        cidr,
+		Action:      action,
+		Description: strings.TrimSpace(req.Description),
+	}, nil
+}
+
+// normalizeFirewallPort 校验端口：ALL、单个端口、端口范围或逗号分隔的多个端口
+func normalizeFirewallPort(port string) (string, error) {
+	port = strings.ToUpper(strings.ReplaceAll(port, " ", ""))
+	if port == "" {
+		return "", errors.New("请填写端口")
+	}
+	if port == "ALL" {
+		return port, nil
+	}
+
+	items := strings.Split(port, ",")
+	if len(items) > maxFirewallPortItems {
+		return "", fmt.Errorf("单条规则最多%d个端口", maxFirewallPortItems)
+	}
+	for _, item := range items {
+		from, to, isRange := strings.Cut(item, "-")
+		start, err := parseFirewallPort(from)
+		if err != nil {
+			return "", err
+		}
+		if !isRange {
+			continue
+		}
+		end, err := parseFirewallPort(to)
+		if err != nil {
+			return "", err
+		}
+		if start >= end {
+			return "", fmt.Errorf("端口范围 %s 无效", item)
+		}
+	}
+	return port, nil
+}
+
+// parseFirewallPort 解析单个端口
+func parseFirewallPort(text string) (int, error) {
+	port, err := strconv.Atoi(text)
+	if err != nil || port < 1 || port > 65535 {
+		return 0, fmt.Errorf("端口 %s 无效，须为1-65535", text)
+	}
+	return port, nil
+}
+
+// normalizeFirewallCIDR 校验来源网段，单个IP转为/32或/128网段，网段按掩码规范化
+func normalizeFirewallCIDR(cidr string) (string, error) {
+	cidr = strings.TrimSpace(cidr)
+	if cidr == "" {
+		return "0.0.0.0/0", nil
+	}
+	if !strings.Contains(cidr, "/") {
+		addr, err := netip.ParseAddr(cidr)
+		if err != nil {
+			return "", fmt.Errorf("来源 %s 不是有效的IP或网段", cidr)
+		}
+		return netip.PrefixFrom(addr, addr.BitLen()).String(), nil
+	}
+	prefix, err := netip.ParsePrefix(cidr)
+	if err != nil {
+		return "", fmt.Errorf("来源 %s 不是有效的IP或网段", cidr)
+	}
+	return prefix.Masked().String(), nil
+}
+
+// firewallRuleKey 规则去重键
+func firewallRuleKey(protocol, port, cidr, action string) string {
+	return strings.Join([]string{protocol, port, cidr, action}, "|")
+}
+
+// toProviderFirewallRules 转换为厂商防火墙规则
+func toProviderFirewallRules(rules []model.FirewallRule) []provider.FirewallRule {
+	result := make([]provider.FirewallRule, 0, len(rules))
+	for _, rule := range rules {
+		result = append(result, provider.FirewallRule{
+			Protocol:    rule.Protocol,
+			Port:        rule.Port,
+			CIDR:        rule.CIDR,
+			Action:      rule.Action,
+			Description: rule.Description,
+		})
+	}
+	return result
+}
@@ -0,0 +1,358 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"cloudbp-backend/internal/model"
+	"cloudbp-backend/pkg/provider"
+
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+)
+
+// ImageService 镜像目录服务：系统镜像由目录同步导入，应用镜像由管理员维护
+type ImageService struct {
+	db  *gorm.DB
+	rdb *redis.Client
+}
+
+// NewImageService 创建镜像目录服务
+func NewImageService(db *gorm.DB, rdb *redis.Client) *ImageService {
+	return &ImageService{
+		db:  db,
+		rdb: rdb,
+	}
+}
+
+// GetAvailableImagesRequest 获取可选镜像请求
+type GetAvailableImagesRequest struct {
+	ProviderID uint   `json:"provider_id"`
+	Region     string `json:"region"`
+	ProductID  uint   `json:"product_id"` // 指定时按产品的厂商和配置过滤，未指定地域时使用产品所在地域
+	Category   string `json:"category"`   // os、app，为空表示全部
+}
+
+// GetAvailableImages 获取购买时可选的镜像
+func (s *ImageService) GetAvailableImages(ctx context.Context, req *GetAvailableImagesRequest) ([]model.Image, error) {
+	query := s.db.WithContext(ctx).Model(&model.Image{}).
+		Where("status = ? AND removed_at IS NULL", model.ImageStatusActive)
+
+	if req.ProductID != 0 {
+		var product model.Product
+		if err := s.db.First(&product, req.ProductID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, errors.New("产品不存在")
+			}
+			return nil, fmt.Errorf("获取产品信息失败: %w", err)
+		}
+		region := req.Region
+		if region == "" {
+			region = product.Region
+		}
+		query = query.Where("provider_id = ? AND region = ?", product.ProviderID, region).
+			Where("min_storage <= ? AND min_memory <= ?", product.Storage, product.Memory)
+	} else {
+		if req.ProviderID == 0 || req.Region == "" {
+			return nil, errors.New("请指定产品或厂商和地域")
+		}
+		query = query.Where("provider_id = ? AND region = ?", req.ProviderID, req.Region)
+	}
+	if req.Category != "" {
+		query = query.Where("category = ?", req.Category)
+	}
+
+	var images []model.Image
+	if err := query.Order("sort_order, category, name").Find(&images).Error; err != nil {
+		return nil, fmt.Errorf("获取镜像列表失败: %w", err)
+	}
+	return images, nil
+}
+
+// SyncImages 同步厂商地域下的系统镜像：新镜像直接启用，已有镜像只更新厂商属性，
+// 管理员调整的名称、分类和状态保留；不再出现的同步镜像标记为已下线
+func (s *ImageService) SyncImages(providerID uint, region string, images []provider.Image) error {
+	var existing []model.Image
+	if err := s.db.Where("provider_id = ? AND region = ?", providerID, region).Find(&existing).Error; err != nil {
+		return fmt.Errorf("获取镜像目录失败: %w", err)
+	}
+	byImageID := make(map[string]*model.Image, len(existing))
+	for i := range existing {
+		byImageID[existing[i].ImageID] = &existing[i]
+	}
+
+	seen := make(map[string]bool, len(images))
+	for _, image := range images {
+		seen[image.ImageID] = true
+
+		current, ok := byImageID[image.ImageID]
+		if !ok {
+			name := image.ImageName
+			if name == "" {
+				name = image.OSName
+			}
+			if err := s.db.Create(&model.Image{
+				ProviderID:  providerID,
+				Region:      region,
+				ImageID:     image.ImageID,
+				Name:        name,
+				OSType:      image.OSType,
+				OSName:      image.OSName,
+				Category:    model.ImageCategoryOS,
+				ImageType:   image.ImageType,
+				MinStorage:  image.ImageSize,
+				Source:      model.ImageSourceSync,
+				Status:      model.ImageStatusActive,
+				Description: image.Description,
+			}).Error; err != nil {
+				return fmt.Errorf("导入镜像失败: %w", err)
+			}
+			continue
+		}
+
+		if err := s.db.Model(current).Updates(map[string]interface{}{
+			"os_type":     image.OSType,
+			"os_name":     image.OSName,
+			"image_type":  image.ImageType,
+			"min_storage": image.ImageSize,
+			"removed_at":  nil,
+		}).Error; err != nil {
+			return fmt.Errorf("更新镜像失败: %w", err)
+		}
+	}
+
+	// 管理员添加的镜像不在厂商公共镜像列表中，不做下线检测
+	now := time.Now()
+	for _, image := range existing {
+		if seen[image.ImageID] || image.Source != model.ImageSourceSync || image.RemovedAt != nil {
+			continue
+		}
+		if err := s.db.Model(&model.Image{}).Where("id = ?", image.ID).Update("removed_at", &now).Error; err != nil {
+			return fmt.Errorf("标记镜像下线失败: %w", err)
+		}
+	}
+	return nil
+}
+
+// GetImageCatalogRequest 获取镜像目录请求
+type GetImageCatalogRequest struct {
+	Page       int    `json:"page"`
+	Size       int    `json:"size"`
+	ProviderID uint   `json:"provider_id"`
+	Region     string `json:"region"`
+	Category   string `json:"category"`
+	Status     int    `json:"status"`
+	Keyword    string `json:"keyword"`
+}
+
+// GetImageCatalogResponse 获取镜像目录响应
+type GetImageCatalogResponse struct {
+	Images     []model.Image `json:"images"`
+	TotalCount int64         `json:"total_count"`
+	Page       int           `json:"page"`
+	Size       int           `json:"size"`
+}
+
+// GetImageCatalog 获取镜像目录（管理员）
+func (s *ImageService) GetImageCatalog(ctx context.Context, req *GetImageCatalogRequest) (*GetImageCatalogResponse, error) {
+	var images []model.Image
+	var totalCount int64
+
+	query := s.db.Model(&model.Image{})
+	if req.ProviderID != 0 {
+		query = query.Where("provider_id = ?", req.ProviderID)
+	}
+	if req.Region != "" {
+		query = query.Where("region = ?", req.Region)
+	}
+	if req.Category != "" {
+		query = query.Where("category = ?", req.Category)
+	}
+	if req.Status != 0 {
+		query = query.Where("status = ?", req.Status)
+	}
+	if req.Keyword != "" {
+		query = query.Where("name LIKE ? OR image_id LIKE ? OR app_name LIKE ?",
+			"%"+req.Keyword+"%", "%"+req.Keyword+"%", "%"+req.Keyword+"%")
+	}
+
+	// 获取总数
+	if err := query.Count(&totalCount).Error; err != nil {
+		return nil, fmt.Errorf("获取镜像总数失败: %w", err)
+	}
+
+	// 获取分页数据
+	offset := (req.Page - 1) * req.Size
+	if err := query.Offset(offset).Limit(req.Size).Order("provider_id, region, sort_order, id").Find(&images).Error; err != nil {
+		return nil, fmt.Errorf("获取镜像目录失败: %w", err)
+	}
+
+	return &GetImageCatalogResponse{
+		Images:     images,
+		TotalCount: totalCount,
+		Page:       req.Page,
+		Size:       req.Size,
+	}, nil
+}
+
+// SaveImageRequest 添加或更新镜像请求，同步镜像只能修改展示属性
+type SaveImageRequest struct {
+	ProviderID  uint   `json:"provider_id"`
+	Region      string `json:"region"`
+	ImageID     string `json:"image_id"`
+	Name        string `json:"name" binding:"required"`
+	OSType      string `json:"os_type"`
+	OSName      string `json:"os_name"`
+	Category    string `json:"category" binding:"omitempty,oneof=os app"`
+	AppName     string `json:"app_name"`
+	MinStorage  int    `json:"min_storage"`
+	MinMemory   int    `json:"min_memory"`
+	Status      int    `json:"status"`
+	SortOrder   int    `json:"sort_order"`
+	Description string `json:"description"`
+}
+
+// CreateImage 添加镜像，用于厂商公共镜像之外的应用镜像和自定义镜像
+func (s *ImageService) CreateImage(ctx context.Context, req *SaveImageRequest) (*model.Image, error) {
+	req.Region = strings.TrimSpace(req.Region)
+	req.ImageID = strings.TrimSpace(req.ImageID)
+	if req.ProviderID == 0 || req.Region == "" || req.ImageID == "" {
+		return nil, errors.New("厂商、地域和镜像ID不能为空")
+	}
+
+	var count int64
+	if err := s.db.Model(&model.Provider{}).Where("id = ?", req.ProviderID).Count(&count).Error; err != nil {
+		return nil, fmt.Errorf("获取厂商信息失败: %w", err)
+	}
+	if count == 0 {
+		return nil, errors.New("厂商不存在")
+	}
+	if err := s.db.Model(&model.Image{}).
+		Where("provider_id = ? AND region = ? AND image_id = ?", req.ProviderID, req.Region, req.ImageID).
+		Count(&count).Error; err != nil {
+		return nil, fmt.Errorf("检查镜像失败: %w", err)
+	}
+	if count > 0 {
+		return nil, errors.New("该地域下镜像已存在")
+	}
+
+	image := model.Image{
+		ProviderID: req.ProviderID,
+		Region:     req.Region,
+		ImageID:    req.ImageID,
+		OSType:     req.OSType,
+		OSName:     req.OSName,
+		Source:     model.ImageSourceManual,
+	}
+	if err := applyImageRequest(&image, req); err != nil {
+		return nil, err
+	}
+
+	if err := s.db.Create(&image).Error; err != nil {
+		return nil, fmt.Errorf("添加镜像失败: %w", err)
+	}
+	return &image, nil
+}
+
+// UpdateImage 更新镜像展示属性和状态
+func (s *ImageService) UpdateImage(ctx context.Context, id uint, req *SaveImageRequest) (*model.Image, error) {
+	var image model.Image
+	if err := s.db.First(&image, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("镜像不存在")
+		}
+		return nil, fmt.Errorf("获取镜像失败: %w", err)
+	}
+
+	// 同步镜像的操作系统信息和系统盘要求以厂商为准
+	if image.Source == model.ImageSourceManual {
+		image.OSType = req.OSType
+		image.OSName = req.OSName
+	}
+	if err := applyImageRequest(&image, req); err != nil {
+		return nil, err
+	}
+
+	if err := s.db.Model(&image).Select("*").Omit("id", "provider_id", "region", "image_id", "source", "created_at").
+		Updates(&image).Error; err != nil {
+		return nil, fmt.Errorf("更新镜像失败: %w", err)
+	}
+	return &image, nil
+}
+
+// DeleteImage 删除管理员添加的镜像，同步镜像只能禁用
+func (s *ImageService) DeleteImage(ctx context.Context, id uint) error {
+	var image model.Image
+	if err := s.db.First(&image, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.New("镜像不存在")
+		}
+		return fmt.Errorf("获取镜像失败: %w", err)
+	}
+	if image.Source != model.ImageSourceManual {
+		return errors.New("厂商同步的镜像不能删除，请禁用")
+	}
+
+	if err := s.db.Delete(&image).Error; err != nil {
+		return fmt.Errorf("删除镜像失败: %w", err)
+	}
+	return nil
+}
+
+// applyImageRequest 校验并写入镜像展示属性
+func applyImageRequest(image *model.Image, req *SaveImageRequest) error {
+	if req.MinStorage < 0 || req.MinMemory < 0 {
+		return errors.New("最低配置不能为负数")
+	}
+	if req.Category == model.ImageCategoryApp && strings.TrimSpace(req.AppName) == "" {
+		return errors.New("应用镜像需填写应用名称")
+	}
+
+	image.Name = req.Name
+	image.Category = req.Category
+	image.AppName = strings.TrimSpace(req.AppName)
+	image.MinMemory = req.MinMemory
+	image.Status = req.Status
+	image.SortOrder = req.SortOrder
+	image.Description = req.Description
+	if image.Source == model.ImageSourceManual {
+		image.MinStorage = req.MinStorage
+	}
+	if image.Category == "" {
+		image.Category = model.ImageCategoryOS
+	}
+	if image.Status == 0 {
+		image.Status = model.ImageStatusActive
+	}
+	return nil
+}
+
+// purchaseImage 校验购买时选择的镜像：须为产品所在厂商和地域下启用的镜像，且产品配置满足镜像要求
+func purchaseImage(db *gorm.DB, product *model.Product, imageID string) (*model.Image, error) {
+	if imageID == "" {
+		return nil, errors.New("请选择镜像")
+	}
+
+	var image model.Image
+	if err := db.Where("provider_id = ? AND region = ? AND image_id = ?", product.ProviderID, product.Region, imageID).
+		First(&image).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("镜像不存在或不适用于该产品所在地域")
+		}
+		return nil, fmt.Errorf("获取镜像信息失败: %w", err)
+	}
+
+	if image.Status != model.ImageStatusActive || image.RemovedAt != nil {
+		return nil, errors.New("镜像已下线")
+	}
+	if image.MinStorage > product.Storage {
+		return nil, fmt.Errorf("该镜像要求系统盘至少%dGB", image.MinStorage)
+	}
+	if image.MinMemory > product.Memory {
+		return nil, fmt.Errorf("该镜像要求内存至少%dGB", image.MinMemory)
+	}
+	return &image, nil
+}
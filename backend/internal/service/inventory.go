@@ -0,0 +1,212 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"cloudbp-backend/internal/model"
+	"cloudbp-backend/pkg/logger"
+	"cloudbp-backend/pkg/provider"
+
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrOutOfStock 库存不足
+var ErrOutOfStock = errors.New("库存不足")
+
+// InventoryService 产品库存服务：库存和限购由管理员设置，售罄状态从厂商规格同步
+type InventoryService struct {
+	db              *gorm.DB
+	rdb             *redis.Client
+	providerService *ProviderService
+}
+
+// NewInventoryService 创建库存服务
+func NewInventoryService(db *gorm.DB, rdb *redis.Client, providerService *ProviderService) *InventoryService {
+	return &InventoryService{
+		db:              db,
+		rdb:             rdb,
+		providerService: providerService,
+	}
+}
+
+// UpdateInventoryRequest 设置产品库存请求
+type UpdateInventoryRequest struct {
+	ProductID     uint `json:"product_id"`
+	Stock         *int `json:"stock"`          // 剩余可售库存，-1表示不限
+	PurchaseLimit *int `json:"purchase_limit"` // 每个用户最多持有台数，0表示不限
+}
+
+// UpdateInventory 设置产品库存和限购数量
+func (s *InventoryService) UpdateInventory(ctx context.Context, req *UpdateInventoryRequest) (*model.Product, error) {
+	updates := map[string]interface{}{}
+	if req.Stock != nil {
+		if *req.Stock < -1 {
+			return nil, errors.New("库存不能小于-1")
+		}
+		updates["stock"] = *req.Stock
+	}
+	if req.PurchaseLimit != nil {
+		if *req.PurchaseLimit < 0 {
+			return nil, errors.New("限购数量不能小于0")
+		}
+		updates["purchase_limit"] = *req.PurchaseLimit
+	}
+	if len(updates) == 0 {
+		return nil, errors.New("没有需要更新的内容")
+	}
+
+	var product model.Product
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&product, req.ProductID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errors.New("产品不存在")
+			}
+			return fmt.Errorf("获取产品信息失败: %w", err)
+		}
+		return tx.Model(&product).Updates(updates).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &product, nil
+}
+
+// SyncAvailability 按厂商返回的可售规格同步产品售罄状态，规格不在列表中或标记售罄即视为售罄；
+// 厂商接口调用失败时保持原状态
+func (s *InventoryService) SyncAvailability(ctx context.Context) error {
+	var products []model.Product
+	if err := s.db.WithContext(ctx).Preload("Provider").
+		Where("status = ?", model.ProductStatusOnline).
+		Find(&products).Error; err != nil {
+		return fmt.Errorf("获取产品列表失败: %w", err)
+	}
+
+	// 每个厂商地域只查询一次
+	available := make(map[string]map[string]bool)
+	for _, product := range products {
+		key := product.Provider.Code + "/" + product.Region
+		if _, ok := available[key]; !ok {
+			types, err := s.instanceTypes(ctx, product.Provider.Code, product.Region)
+			if err != nil {
+				logger.Log.Warn("获取厂商规格失败", zap.String("provider", product.Provider.Code),
+					zap.String("region", product.Region), zap.Error(err))
+			}
+			available[key] = types
+		}
+
+		types := available[key]
+		if types == nil {
+			continue
+		}
+		soldOut := !types[product.Code]
+		if soldOut == product.SoldOut {
+			continue
+		}
+
+		if err := s.db.Model(&model.Product{}).Where("id = ?", product.ID).
+			Update("sold_out", soldOut).Error; err != nil {
+			logger.Log.Error("更新产品售罄状态失败", zap.Uint("product_id", product.ID), zap.Error(err))
+			continue
+		}
+		logger.Log.Info("产品售罄状态变更", zap.Uint("product_id", product.ID),
+			zap.String("code", product.Code), zap.Bool("sold_out", soldOut))
+	}
+	return nil
+}
+
+// instanceTypes 获取厂商地域下可售的规格集合
+func (s *InventoryService) instanceTypes(ctx context.Context, code, region string) (map[string]bool, error) {
+	cloudProvider, err := s.providerService.GetProvider(code)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := cloudProvider.GetInstanceTypes(ctx, &provider.GetInstanceTypesRequest{Region: region})
+	if err != nil {
+		return nil, err
+	}
+
+	types := make(map[string]bool, len(resp.InstanceTypes))
+	for _, instanceType := range resp.InstanceTypes {
+		if !instanceType.SoldOut {
+			types[instanceType.InstanceType] = true
+		}
+	}
+	return types, nil
+}
+
+// reserveStock 新购订单创建时占用产品库存并校验限购，需在订单创建的事务内调用；
+// 产品行锁保证并发下单不会超卖
+func reserveStock(tx *gorm.DB, order *model.Order) error {
+	var product model.Product
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&product, order.ProductID).Error; err != nil {
+		return fmt.Errorf("获取产品信息失败: %w", err)
+	}
+
+	if product.SoldOut {
+		return errors.New("该产品已售罄")
+	}
+	if product.Stock >= 0 && product.Stock-product.Reserved < order.Quantity {
+		return fmt.Errorf("%w，剩余 %d 台", ErrOutOfStock, max(product.Stock-product.Reserved, 0))
+	}
+
+	if product.PurchaseLimit > 0 {
+		// 已持有的服务器（含开通中）加上待支付订单中的台数
+		var owned int64
+		if err := tx.Model(&model.Server{}).
+			Where("user_id = ? AND product_id = ? AND status <> ?", order.UserID, product.ID, model.ServerStatusTerminated).
+			Count(&owned).Error; err != nil {
+			return fmt.Errorf("统计已购数量失败: %w", err)
+		}
+		var pending int64
+		if err := tx.Model(&model.Order{}).
+			Where("user_id = ? AND product_id = ? AND type = ? AND status = ? AND id <> ?",
+				order.UserID, product.ID, model.OrderTypeNew, model.OrderStatusPending, order.ID).
+			Select("COALESCE(SUM(quantity), 0)").Scan(&pending).Error; err != nil {
+			return fmt.Errorf("统计待支付数量失败: %w", err)
+		}
+		if int(owned+pending)+order.Quantity > product.PurchaseLimit {
+			return fmt.Errorf("该产品每个用户限购 %d 台", product.PurchaseLimit)
+		}
+	}
+
+	if product.Stock < 0 {
+		return nil
+	}
+	if err := tx.Model(&product).Update("reserved", gorm.Expr("reserved + ?", order.Quantity)).Error; err != nil {
+		return fmt.Errorf("占用库存失败: %w", err)
+	}
+	if err := tx.Model(order).Update("reserved_stock", order.Quantity).Error; err != nil {
+		return fmt.Errorf("更新订单库存占用失败: %w", err)
+	}
+	order.ReservedStock = order.Quantity
+	return nil
+}
+
+// settleStock 归还订单占用的库存，delivered 为实际开通的台数，从剩余库存中扣除；
+// 订单关闭时 delivered 为0，全部归还。订单占用清零后重复调用无副作用
+func settleStock(tx *gorm.DB, orderID uint, delivered int) error {
+	var order model.Order
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&order, orderID).Error; err != nil {
+		return fmt.Errorf("获取订单失败: %w", err)
+	}
+	if order.ReservedStock <= 0 {
+		return nil
+	}
+
+	if err := tx.Model(&model.Product{}).Where("id = ?", order.ProductID).Updates(map[string]interface{}{
+		"reserved": gorm.Expr("GREATEST(reserved - ?, 0)", order.ReservedStock),
+		"stock":    gorm.Expr("CASE WHEN stock >= 0 THEN GREATEST(stock - ?, 0) ELSE stock END", delivered),
+	}).Error; err != nil {
+		return fmt.Errorf("归还库存失败: %w", err)
+	}
+	if err := tx.Model(&order).Update("reserved_stock", 0).Error; err != nil {
+		return fmt.Errorf("更新订单库存占用失败: %w", err)
+	}
+	return nil
+}
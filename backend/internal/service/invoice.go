@@ -0,0 +1,431 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"cloudbp-backend/internal/config"
+	"cloudbp-backend/internal/model"
+	"cloudbp-backend/pkg/invoice"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// taxNoPattern 统一社会信用代码或纳税人识别号，15至20位字母数字
+var taxNoPattern = regexp.MustCompile(`^[0-9A-Z]{15,20}$`)
+
+// InvoiceService 发票服务
+type InvoiceService struct {
+	db           *gorm.DB
+	rdb          *redis.Client
+	config       config.InvoiceConfig
+	baseCurrency string
+	renderer     *invoice.Renderer
+}
+
+// NewInvoiceService 创建发票服务，充值单以本位币开票
+func NewInvoiceService(db *gorm.DB, rdb *redis.Client, cfg config.InvoiceConfig, baseCurrency string) *InvoiceService {
+	return &InvoiceService{
+		db:           db,
+		rdb:          rdb,
+		config:       cfg,
+		baseCurrency: baseCurrency,
+		renderer:     invoice.NewRenderer(cfg.FontPath),
+	}
+}
+
+// InvoiceProfile 用户开票信息
+type InvoiceProfile struct {
+	BuyerType   string `json:"buyer_type" binding:"required,oneof=personal company"`
+	Title       string `json:"title" binding:"required"`
+	TaxNo       string `json:"tax_no"`
+	Address     string `json:"address"`
+	Phone       string `json:"phone"`
+	BankName    string `json:"bank_name"`
+	BankAccount string `json:"bank_account"`
+	Email       string `json:"email" binding:"omitempty,email"`
+}
+
+// GetInvoiceProfile 获取用户开票信息
+func (s *InvoiceService) GetInvoiceProfile(ctx context.Context, userID uint) (*InvoiceProfile, error) {
+	var user model.User
+	if err := s.db.First(&user, userID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("用户不存在")
+		}
+		return nil, fmt.Errorf("获取用户信息失败: %w", err)
+	}
+
+	return &InvoiceProfile{
+		BuyerType:   user.InvoiceBuyerType,
+		Title:       user.InvoiceTitle,
+		TaxNo:       user.InvoiceTaxNo,
+		Address:     user.InvoiceAddress,
+		Phone:       user.InvoicePhone,
+		BankName:    user.InvoiceBankName,
+		BankAccount: user.InvoiceBankAccount,
+		Email:       user.InvoiceEmail,
+	}, nil
+}
+
+// UpdateInvoiceProfile 更新用户开票信息，企业抬头必须填写纳税人识别号
+func (s *InvoiceService) UpdateInvoiceProfile(ctx context.Context, userID uint, req *InvoiceProfile) error {
+	req.Title = strings.TrimSpace(req.Title)
+	req.TaxNo = strings.ToUpper(strings.TrimSpace(req.TaxNo))
+
+	if req.BuyerType == model.InvoiceBuyerCompany && !taxNoPattern.MatchString(req.TaxNo) {
+		return errors.New("企业抬头须填写正确的纳税人识别号")
+	}
+	if req.BuyerType == model.InvoiceBuyerPersonal {
+		req.TaxNo = ""
+	}
+
+	if err := s.db.Model(&model.User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+		"invoice_buyer_type":   req.BuyerType,
+		"invoice_title":        req.Title,
+		"invoice_tax_no":       req.TaxNo,
+		"invoice_address":      req.Address,
+		"invoice_phone":        req.Phone,
+		"invoice_bank_name":    req.BankName,
+		"invoice_bank_account": req.BankAccount,
+		"invoice_email":        req.Email,
+	}).Error; err != nil {
+		return fmt.Errorf("更新开票信息失败: %w", err)
+	}
+	return nil
+}
+
+// CreateInvoiceRequest 申请开票请求，订单和充值单二选一
+type CreateInvoiceRequest struct {
+	UserID     uint   `json:"user_id"`
+	Type       string `json:"type" binding:"omitempty,oneof=normal special"` // 默认normal
+	OrderID    uint   `json:"order_id"`
+	RechargeID uint   `json:"recharge_id"`
+	Remark     string `json:"remark"`
+}
+
+// CreateInvoice 申请开票：按用户当前开票信息开具，每笔订单或充值单只能开具一张有效发票
+func (s *InvoiceService) CreateInvoice(ctx context.Context, req *CreateInvoiceRequest) (*model.Invoice, error) {
+	if req.Type == "" {
+		req.Type = model.InvoiceTypeNormal
+	}
+	if (req.OrderID == 0) == (req.RechargeID == 0) {
+		return nil, errors.New("请选择一笔订单或充值单开票")
+	}
+
+	profile, err := s.GetInvoiceProfile(ctx, req.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if profile.BuyerType == "" || profile.Title == "" {
+		return nil, errors.New("请先完善开票信息")
+	}
+	if req.Type == model.InvoiceTypeSpecial {
+		if profile.BuyerType != model.InvoiceBuyerCompany {
+			return nil, errors.New("增值税专用发票仅限企业抬头")
+		}
+		if profile.Address == "" || profile.Phone == "" || profile.BankName == "" || profile.BankAccount == "" {
+			return nil, errors.New("开具专用发票须填写注册地址、电话、开户行及账号")
+		}
+	}
+
+	inv := &model.Invoice{
+		UserID:     req.UserID,
+		Type:       req.Type,
+		ItemName:   s.config.ItemName,
+		BuyerType:  profile.BuyerType,
+		BuyerName:  profile.Title,
+		BuyerTaxNo: profile.TaxNo,
+		BuyerAddr:  strings.TrimSpace(profile.Address + " " + profile.Phone),
+		BuyerBank:  strings.TrimSpace(profile.BankName + " " + profile.BankAccount),
+		Email:      profile.Email,
+		Status:     model.InvoiceStatusIssued,
+		Remark:     req.Remark,
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if req.OrderID != 0 {
+			err = s.fillOrderInvoice(tx, inv, req.OrderID)
+		} else {
+			err = s.fillRechargeInvoice(tx, inv, req.RechargeID)
+		}
+		if err != nil {
+			return err
+		}
+
+		invoiceNo, err := s.nextInvoiceNo(tx)
+		if err != nil {
+			return err
+		}
+		inv.InvoiceNo = invoiceNo
+		inv.IssuedAt = time.Now()
+
+		if err := tx.Create(inv).Error; err != nil {
+			return fmt.Errorf("创建发票失败: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return inv, nil
+}
+
+// fillOrderInvoice 订单开票：金额为实付金额扣除已退款部分，税额按比例折算
+func (s *InvoiceService) fillOrderInvoice(tx *gorm.DB, inv *model.Invoice, orderID uint) error {
+	// 锁定订单，防止并发重复开票
+	var order model.Order
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id = ? AND user_id = ?", orderID, inv.UserID).
+		First(&order).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.New("订单不存在")
+		}
+		return fmt.Errorf("获取订单信息失败: %w", err)
+	}
+
+	if order.Status != model.OrderStatusSuccess && order.Status != model.OrderStatusPaid {
+		return errors.New("仅已完成的订单可以开票")
+	}
+	if !order.PayAmount.IsPositive() {
+		return errors.New("订单无实付金额")
+	}
+	// 余额支付的金额已在充值时开票，避免重复开票
+	if order.PayMethod == model.PaymentMethodBalance {
+		return errors.New("余额支付的订单请对充值单开票")
+	}
+	if err := checkInvoiced(tx, "order_id", order.ID); err != nil {
+		return err
+	}
+
+	var refunded decimal.Decimal
+	if err := tx.Model(&model.Refund{}).
+		Where("order_id = ? AND status = ?", order.ID, model.RefundStatusSuccess).
+		Select("COALESCE(SUM(amount), 0)").
+		Scan(&refunded).Error; err != nil {
+		return fmt.Errorf("统计退款金额失败: %w", err)
+	}
+
+	amount := order.PayAmount.Sub(refunded)
+	if !amount.IsPositive() {
+		return errors.New("订单已全额退款")
+	}
+
+	inv.SourceType = model.InvoiceSourceOrder
+	inv.OrderID = &order.ID
+	inv.SourceNo = order.OrderNo
+	inv.Amount = amount
+	inv.Currency = order.Currency
+	inv.TaxAmount = roundAmount(order.TaxAmount.Mul(amount).Div(order.PayAmount))
+	if net := order.PayAmount.Sub(order.TaxAmount); order.TaxAmount.IsPositive() && net.IsPositive() {
+		inv.TaxRate = order.TaxAmount.Div(net).Round(2)
+	}
+	return nil
+}
+
+// fillRechargeInvoice 充值开票：充值金额为含税价，按配置税率价内计税
+func (s *InvoiceService) fillRechargeInvoice(tx *gorm.DB, inv *model.Invoice, rechargeID uint) error {
+	var recharge model.Recharge
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id = ? AND user_id = ?", rechargeID, inv.UserID).
+		First(&recharge).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.New("充值单不存在")
+		}
+		return fmt.Errorf("获取充值单失败: %w", err)
+	}
+
+	if recharge.Status != model.RechargeStatusSuccess {
+		return errors.New("仅已到账的充值单可以开票")
+	}
+	if err := checkInvoiced(tx, "recharge_id", recharge.ID); err != nil {
+		return err
+	}
+
+	taxRate := decimal.NewFromFloat(s.config.TaxRate)
+	inv.SourceType = model.InvoiceSourceRecharge
+	inv.RechargeID = &recharge.ID
+	inv.SourceNo = recharge.RechargeNo
+	inv.Amount = recharge.Amount
+	inv.Currency = s.baseCurrency
+	inv.TaxRate = taxRate
+	inv.TaxAmount = roundAmount(recharge.Amount.Mul(taxRate).Div(taxRate.Add(decimal.NewFromInt(1))))
+	return nil
+}
+
+// checkInvoiced 检查订单或充值单是否已开具有效发票
+func checkInvoiced(tx *gorm.DB, column string, id uint) error {
+	var count int64
+	if err := tx.Model(&model.Invoice{}).
+		Where(column+" = ? AND status = ?", id, model.InvoiceStatusIssued).
+		Count(&count).Error; err != nil {
+		return fmt.Errorf("查询开票记录失败: %w", err)
+	}
+	if count > 0 {
+		return errors.New("该笔交易已开具发票")
+	}
+	return nil
+}
+
+// nextInvoiceNo 按年取下一个连续发票号码，在序列行锁内分配，事务回滚时号码一并回滚不会跳号
+func (s *InvoiceService) nextInvoiceNo(tx *gorm.DB) (string, error) {
+	year := time.Now().Year()
+
+	// 当年首次开票时初始化序列
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&model.InvoiceSequence{Year: year}).Error; err != nil {
+		return "", fmt.Errorf("初始化发票序列失败: %w", err)
+	}
+
+	var seq model.InvoiceSequence
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&seq, "year = ?", year).Error; err != nil {
+		return "", fmt.Errorf("获取发票序列失败: %w", err)
+	}
+
+	seq.LastNo++
+	if err := tx.Model(&seq).Where("year = ?", year).Update("last_no", seq.LastNo).Error; err != nil {
+		return "", fmt.Errorf("更新发票序列失败: %w", err)
+	}
+
+	return fmt.Sprintf("%s%d%08d", s.config.Prefix, year, seq.LastNo), nil
+}
+
+// GetInvoicesRequest 获取发票列表请求
+type GetInvoicesRequest struct {
+	UserID uint   `json:"user_id"` // 为0时查询全部用户
+	Status string `json:"status"`
+	Page   int    `json:"page"`
+	Size   int    `json:"size"`
+}
+
+// GetInvoicesResponse 获取发票列表响应
+type GetInvoicesResponse struct {
+	Invoices   []model.Invoice `json:"invoices"`
+	TotalCount int64           `json:"total_count"`
+	Page       int             `json:"page"`
+	Size       int             `json:"size"`
+}
+
+// GetInvoices 获取发票列表
+func (s *InvoiceService) GetInvoices(ctx context.Context, req *GetInvoicesRequest) (*GetInvoicesResponse, error) {
+	var invoices []model.Invoice
+	var totalCount int64
+
+	query := s.db.Model(&model.Invoice{})
+	if req.UserID != 0 {
+		query = query.Where("user_id = ?", req.UserID)
+	}
+	if req.Status != "" {
+		query = query.Where("status = ?", req.Status)
+	}
+
+	// 获取总数
+	if err := query.Count(&totalCount).Error; err != nil {
+		return nil, fmt.Errorf("获取发票总数失败: %w", err)
+	}
+
+	// 获取分页数据
+	offset := (req.Page - 1) * req.Size
+	if err := query.Offset(offset).Limit(req.Size).Order("id DESC").Find(&invoices).Error; err != nil {
+		return nil, fmt.Errorf("获取发票列表失败: %w", err)
+	}
+
+	return &GetInvoicesResponse{
+		Invoices:   invoices,
+		TotalCount: totalCount,
+		Page:       req.Page,
+		Size:       req.Size,
+	}, nil
+}
+
+// GetInvoice 获取发票详情，userID为0时不校验归属
+func (s *InvoiceService) GetInvoice(ctx context.Context, userID, invoiceID uint) (*model.Invoice, error) {
+	query := s.db.Where("id = ?", invoiceID)
+	if userID != 0 {
+		query = query.Where("user_id = ?", userID)
+	}
+
+	var inv model.Invoice
+	if err := query.First(&inv).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("发票不存在")
+		}
+		return nil, fmt.Errorf("获取发票失败: %w", err)
+	}
+	return &inv, nil
+}
+
+// RenderInvoicePDF 生成PDF发票
+func (s *InvoiceService) RenderInvoicePDF(ctx context.Context, userID, invoiceID uint) (*model.Invoice, []byte, error) {
+	inv, err := s.GetInvoice(ctx, userID, invoiceID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	data, err := s.renderer.Render(&invoice.Document{
+		Type:      inv.Type,
+		InvoiceNo: inv.InvoiceNo,
+		IssuedAt:  inv.IssuedAt,
+		Buyer: invoice.Party{
+			Name:  inv.BuyerName,
+			TaxNo: inv.BuyerTaxNo,
+			Addr:  inv.BuyerAddr,
+			Bank:  inv.BuyerBank,
+		},
+		Seller: invoice.Party{
+			Name:  s.config.SellerName,
+			TaxNo: s.config.SellerTaxNo,
+			Addr:  s.config.SellerAddr,
+			Bank:  s.config.SellerBank,
+		},
+		ItemName:  inv.ItemName,
+		Amount:    inv.Amount,
+		Currency:  inv.Currency,
+		TaxRate:   inv.TaxRate,
+		TaxAmount: inv.TaxAmount,
+		SourceNo:  inv.SourceNo,
+		Remark:    inv.Remark,
+		Voided:    inv.Status == model.InvoiceStatusVoided,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return inv, data, nil
+}
+
+// VoidInvoiceRequest 作废发票请求
+type VoidInvoiceRequest struct {
+	InvoiceID  uint   `json:"invoice_id"`
+	OperatorID uint   `json:"operator_id"`
+	Reason     string `json:"reason"`
+}
+
+// VoidInvoice 作废发票，作废后该笔交易可重新开票，号码不回收
+func (s *InvoiceService) VoidInvoice(ctx context.Context, req *VoidInvoiceRequest) (*model.Invoice, error) {
+	now := time.Now()
+	result := s.db.Model(&model.Invoice{}).
+		Where("id = ? AND status = ?", req.InvoiceID, model.InvoiceStatusIssued).
+		Updates(map[string]interface{}{
+			"status":      model.InvoiceStatusVoided,
+			"voided_at":   now,
+			"operator_id": req.OperatorID,
+			"remark":      req.Reason,
+		})
+	if result.Error != nil {
+		return nil, fmt.Errorf("作废发票失败: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil, errors.New("发票不存在或已作废")
+	}
+
+	return s.GetInvoice(ctx, 0, req.InvoiceID)
+}
@@ -0,0 +1,581 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"cloudbp-backend/internal/model"
+
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrInsufficientBalance 钱包余额不足
+var ErrInsufficientBalance = errors.New("余额不足")
+
+// systemAccountNames 平台科目账户名称
+var systemAccountNames = map[string]string{
+	model.LedgerAccountRevenue:    "销售收入",
+	model.LedgerAccountGateway:    "第三方支付通道",
+	model.LedgerAccountAdjustment: "人工调账",
+}
+
+// LedgerService 账本服务，所有余额变动都通过复式记账完成
+type LedgerService struct {
+	db *gorm.DB
+}
+
+// NewLedgerService 创建账本服务
+func NewLedgerService(db *gorm.DB) *LedgerService {
+	return &LedgerService{
+		db: db,
+	}
+}
+
+// WalletPostingRequest 钱包记账请求
+type WalletPostingRequest struct {
+	UserID      uint            `json:"user_id"`
+	Amount      decimal.Decimal `json:"amount"`      // 变动金额（正数）
+	Type        string          `json:"type"`        // 交易类型
+	Counterpart string          `json:"counterpart"` // 对方科目编码，默认为销售收入
+	OrderID     *uint           `json:"order_id"`
+	Description string          `json:"description"`
+	OperatorID  uint            `json:"operator_id"`
+}
+
+// ledgerPosting 单条分录
+type ledgerPosting struct {
+	account *model.LedgerAccount
+	amount  decimal.Decimal
+}
+
+// DebitWallet 从用户钱包扣款并计入对方科目，须在调用方事务中执行
+func (s *LedgerService) DebitWallet(tx *gorm.DB, req *WalletPostingRequest) (*model.LedgerTransaction, error) {
+	return s.postWallet(tx, req, req.Amount.Neg())
+}
+
+// CreditWallet 从对方科目向用户钱包入账，须在调用方事务中执行
+func (s *LedgerService) CreditWallet(tx *gorm.DB, req *WalletPostingRequest) (*model.LedgerTransaction, error) {
+	return s.postWallet(tx, req, req.Amount)
+}
+
+// postWallet 记一笔钱包与对方科目之间的交易
+func (s *LedgerService) postWallet(tx *gorm.DB, req *WalletPostingRequest, walletAmount decimal.Decimal) (*model.LedgerTransaction, error) {
+	if !req.Amount.IsPositive() {
+		return nil, errors.New("记账金额必须大于0")
+	}
+
+	counterpart := req.Counterpart
+	if counterpart == "" {
+		counterpart = model.LedgerAccountRevenue
+	}
+
+	wallet, err := s.walletAccount(tx, req.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	other, err := s.systemAccount(tx, counterpart)
+	if err != nil {
+		return nil, err
+	}
+
+	header := &model.LedgerTransaction{
+		TxNo:        generateNo("LTX"),
+		Type:        req.Type,
+		UserID:      req.UserID,
+		OrderID:     req.OrderID,
+		Amount:      req.Amount,
+		Description: req.Description,
+		OperatorID:  req.OperatorID,
+	}
+
+	return s.post(tx, header, []ledgerPosting{
+		{account: wallet, amount: walletAmount},
+		{account: other, amount: walletAmount.Neg()},
+	})
+}
+
+// post 写入交易和分录并更新账户余额，分录金额之和必须为零
+//
+// 涉及的账户按ID顺序加行锁后再读取余额，钱包扣款使用 balance >= 金额 的条件更新，
+// 并发扣款不会把钱包扣成负数。
+func (s *LedgerService) post(tx *gorm.DB, header *model.LedgerTransaction, postings []ledgerPosting) (*model.LedgerTransaction, error) {
+	if len(postings) < 2 {
+		return nil, errors.New("一笔交易至少需要两条分录")
+	}
+
+	sum := decimal.Zero
+	for _, p := range postings {
+		sum = sum.Add(p.amount)
+	}
+	if !sum.IsZero() {
+		return nil, fmt.Errorf("交易借贷不平衡: %s", sum.String())
+	}
+
+	// 按账户ID顺序加锁，避免并发交易互相等待造成死锁
+	locked := make([]ledgerPosting, len(postings))
+	copy(locked, postings)
+	sort.Slice(locked, func(i, j int) bool {
+		return locked[i].account.ID < locked[j].account.ID
+	})
+	for _, p := range locked {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(p.account, p.account.ID).Error; err != nil {
+			return nil, fmt.Errorf("锁定账户失败: %w", err)
+		}
+	}
+
+	if err := tx.Create(header).Error; err != nil {
+		return nil, fmt.Errorf("创建账本交易失败: %w", err)
+	}
+
+	for _, p := range postings {
+		update := tx.Model(&model.LedgerAccount{}).Where("id = ?", p.account.ID)
+		if p.account.Type == model.LedgerAccountTypeWallet && p.amount.IsNegative() {
+			update = update.Where("balance >= ?", p.amount.Neg())
+		}
+
+		result := update.Update("balance", gorm.Expr("balance + ?", p.amount))
+		if result.Error != nil {
+			return nil, fmt.Errorf("更新账户余额失败: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return nil, ErrInsufficientBalance
+		}
+		p.account.Balance = p.account.Balance.Add(p.amount)
+
+		entry := model.LedgerEntry{
+			TransactionID: header.ID,
+			AccountID:     p.account.ID,
+			Amount:        p.amount,
+			BalanceAfter:  p.account.Balance,
+		}
+		if err := tx.Create(&entry).Error; err != nil {
+			return nil, fmt.Errorf("创建账本分录失败: %w", err)
+		}
+		header.Entries = append(header.Entries, entry)
+
+		// 同步用户表中的余额
+		if p.account.UserID != nil {
+			if err := tx.Model(&model.User{}).Where("id = ?", *p.account.UserID).Update("balance", p.account.Balance).Error; err != nil {
+				return nil, fmt.Errorf("同步用户余额失败: %w", err)
+			}
+		}
+	}
+
+	return header, nil
+}
+
+// walletAccount 获取用户钱包账户，不存在时创建并把用户表中的历史余额记为期初
+func (s *LedgerService) walletAccount(tx *gorm.DB, userID uint) (*model.LedgerAccount, error) {
+	var account model.LedgerAccount
+	err := tx.Where("code = ?", walletCode(userID)).First(&account).Error
+	if err == nil {
+		return &account, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("获取钱包账户失败: %w", err)
+	}
+
+	var user model.User
+	if err := tx.First(&user, userID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("用户不存在")
+		}
+		return nil, fmt.Errorf("获取用户信息失败: %w", err)
+	}
+
+	account = model.LedgerAccount{
+		Code:   walletCode(userID),
+		Type:   model.LedgerAccountTypeWallet,
+		UserID: &user.ID,
+		Name:   fmt.Sprintf("%s的钱包", user.Username),
+	}
+	result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&account)
+	if result.Error != nil {
+		return nil, fmt.Errorf("创建钱包账户失败: %w", result.Error)
+	}
+
+	// 并发请求已先创建了钱包，期初余额由对方记账
+	if result.RowsAffected == 0 {
+		account = model.LedgerAccount{}
+		if err := tx.Where("code = ?", walletCode(userID)).First(&account).Error; err != nil {
+			return nil, fmt.Errorf("获取钱包账户失败: %w", err)
+		}
+		return &account, nil
+	}
+
+	// 接入账本前的余额记为期初余额
+	if !user.Balance.IsZero() {
+		adjustment, err := s.systemAccount(tx, model.LedgerAccountAdjustment)
+		if err != nil {
+			return nil, err
+		}
+
+		header := &model.LedgerTransaction{
+			TxNo:        fmt.Sprintf("OPEN%d", user.ID),
+			Type:        model.LedgerTxTypeOpening,
+			UserID:      user.ID,
+			Amount:      user.Balance.Abs(),
+			Description: "期初余额",
+		}
+		if _, err := s.post(tx, header, []ledgerPosting{
+			{account: &account, amount: user.Balance},
+			{account: adjustment, amount: user.Balance.Neg()},
+		}); err != nil {
+			return nil, err
+		}
+	}
+
+	return &account, nil
+}
+
+// systemAccount 获取平台科目账户，不存在时创建
+func (s *LedgerService) systemAccount(tx *gorm.DB, code string) (*model.LedgerAccount, error) {
+	name, ok := systemAccountNames[code]
+	if !ok {
+		return nil, fmt.Errorf("未知的平台科目: %s", code)
+	}
+
+	var account model.LedgerAccount
+	err := tx.Where("code = ?", code).First(&account).Error
+	if err == nil {
+		return &account, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("获取平台科目失败: %w", err)
+	}
+
+	account = model.LedgerAccount{
+		Code: code,
+		Type: model.LedgerAccountTypeSystem,
+		Name: name,
+	}
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&account).Error; err != nil {
+		return nil, fmt.Errorf("创建平台科目失败: %w", err)
+	}
+
+	// 并发请求已先创建了该科目
+	if account.ID == 0 {
+		if err := tx.Where("code = ?", code).First(&account).Error; err != nil {
+			return nil, fmt.Errorf("获取平台科目失败: %w", err)
+		}
+	}
+
+	return &account, nil
+}
+
+// walletCode 用户钱包账户编码
+func walletCode(userID uint) string {
+	return fmt.Sprintf("wallet:%d", userID)
+}
+
+// toAmount 将请求参数、产品价格等浮点金额转换为两位小数的定点金额
+func toAmount(amount float64) decimal.Decimal {
+	return decimal.NewFromFloat(amount).Round(2)
+}
+
+// WalletResponse 钱包信息
+type WalletResponse struct {
+	AccountID uint            `json:"account_id"`
+	Code      string          `json:"code"`
+	Balance   decimal.Decimal `json:"balance"`
+}
+
+// GetWallet 获取用户钱包
+func (s *LedgerService) GetWallet(ctx context.Context, userID uint) (*WalletResponse, error) {
+	var account *model.LedgerAccount
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		account, err = s.walletAccount(tx, userID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &WalletResponse{
+		AccountID: account.ID,
+		Code:      account.Code,
+		Balance:   account.Balance,
+	}, nil
+}
+
+// GetWalletStatementRequest 获取钱包对账单请求
+type GetWalletStatementRequest struct {
+	UserID    uint       `json:"user_id"`
+	Page      int        `json:"page"`
+	Size      int        `json:"size"`
+	Type      string     `json:"type"`
+	StartTime *time.Time `json:"start_time"`
+	EndTime   *time.Time `json:"end_time"`
+}
+
+// WalletStatementItem 对账单明细
+type WalletStatementItem struct {
+	EntryID      uint            `json:"entry_id"`
+	TxNo         string          `json:"tx_no"`
+	Type         string          `json:"type"`
+	OrderID      *uint           `json:"order_id"`
+	Description  string          `json:"description"`
+	Amount       decimal.Decimal `json:"amount"`
+	BalanceAfter decimal.Decimal `json:"balance_after"`
+	CreatedAt    time.Time       `json:"created_at"`
+}
+
+// GetWalletStatementResponse 获取钱包对账单响应
+type GetWalletStatementResponse struct {
+	Balance        decimal.Decimal       `json:"balance"`         // 当前余额
+	OpeningBalance decimal.Decimal       `json:"opening_balance"` // 期初余额
+	ClosingBalance decimal.Decimal       `json:"closing_balance"` // 期末余额
+	TotalIn        decimal.Decimal       `json:"total_in"`        // 期间收入
+	TotalOut       decimal.Decimal       `json:"total_out"`       // 期间支出
+	Items          []WalletStatementItem `json:"items"`
+	TotalCount     int64                 `json:"total_count"`
+	Page           int                   `json:"page"`
+	Size           int                   `json:"size"`
+}
+
+// GetWalletStatement 获取钱包对账单
+func (s *LedgerService) GetWalletStatement(ctx context.Context, req *GetWalletStatementRequest) (*GetWalletStatementResponse, error) {
+	wallet, err := s.GetWallet(ctx, req.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	query := s.db.Table("ledger_entries AS e").
+		Joins("JOIN ledger_transactions AS t ON t.id = e.transaction_id").
+		Where("e.account_id = ?", wallet.AccountID)
+
+	if req.Type != "" {
+		query = query.Where("t.type = ?", req.Type)
+	}
+	if req.StartTime != nil {
+		query = query.Where("e.created_at >= ?", *req.StartTime)
+	}
+	if req.EndTime != nil {
+		query = query.Where("e.created_at < ?", *req.EndTime)
+	}
+
+	resp := &GetWalletStatementResponse{
+		Balance: wallet.Balance,
+		Page:    req.Page,
+		Size:    req.Size,
+	}
+
+	// 获取总数
+	if err := query.Session(&gorm.Session{}).Count(&resp.TotalCount).Error; err != nil {
+		return nil, fmt.Errorf("获取对账单总数失败: %w", err)
+	}
+
+	// 期间收支合计
+	var totals struct {
+		TotalIn  decimal.Decimal
+		TotalOut decimal.Decimal
+	}
+	if err := query.Session(&gorm.Session{}).
+		Select("COALESCE(SUM(CASE WHEN e.amount > 0 THEN e.amount ELSE 0 END), 0) AS total_in, " +
+			"COALESCE(SUM(CASE WHEN e.amount < 0 THEN -e.amount ELSE 0 END), 0) AS total_out").
+		Scan(&totals).Error; err != nil {
+		return nil, fmt.Errorf("统计对账单收支失败: %w", err)
+	}
+	resp.TotalIn = totals.TotalIn
+	resp.TotalOut = totals.TotalOut
+
+	// 期初余额为开始时间之前最后一条分录的余额
+	if req.StartTime != nil {
+		if err := s.lastBalance(wallet.AccountID, "created_at < ?", *req.StartTime, &resp.OpeningBalance); err != nil {
+			return nil, err
+		}
+	}
+
+	// 期末余额为结束时间之前最后一条分录的余额
+	resp.ClosingBalance = wallet.Balance
+	if req.EndTime != nil {
+		if err := s.lastBalance(wallet.AccountID, "created_at < ?", *req.EndTime, &resp.ClosingBalance); err != nil {
+			return nil, err
+		}
+	}
+
+	// 获取分页数据
+	offset := (req.Page - 1) * req.Size
+	if err := query.Select("e.id AS entry_id, t.tx_no, t.type, t.order_id, t.description, e.amount, e.balance_after, e.created_at").
+		Order("e.id DESC").
+		Offset(offset).Limit(req.Size).
+		Scan(&resp.Items).Error; err != nil {
+		return nil, fmt.Errorf("获取对账单失败: %w", err)
+	}
+
+	return resp, nil
+}
+
+// lastBalance 获取满足条件的最后一条分录的余额，没有分录时为0
+func (s *LedgerService) lastBalance(accountID uint, cond string, arg interface{}, balance *decimal.Decimal) error {
+	var entry model.LedgerEntry
+	err := s.db.Where("account_id = ?", accountID).Where(cond, arg).Order("id DESC").First(&entry).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			*balance = decimal.Zero
+			return nil
+		}
+		return fmt.Errorf("获取历史余额失败: %w", err)
+	}
+
+	*balance = entry.BalanceAfter
+	return nil
+}
+
+// AdjustBalanceRequest 管理员调账请求
+type AdjustBalanceRequest struct {
+	UserID     uint            `json:"user_id"`
+	OperatorID uint            `json:"operator_id"`
+	Amount     decimal.Decimal `json:"amount" binding:"required"` // 正数加款，负数扣款
+	Remark     string          `json:"remark" binding:"required"`
+}
+
+// AdjustBalance 管理员调整用户余额
+func (s *LedgerService) AdjustBalance(ctx context.Context, req *AdjustBalanceRequest) (*model.LedgerTransaction, error) {
+	if req.Amount.IsZero() {
+		return nil, errors.New("调账金额不能为0")
+	}
+
+	var result *model.LedgerTransaction
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		posting := &WalletPostingRequest{
+			UserID:      req.UserID,
+			Amount:      req.Amount.Abs().Round(2),
+			Type:        model.LedgerTxTypeAdjust,
+			Counterpart: model.LedgerAccountAdjustment,
+			Description: req.Remark,
+			OperatorID:  req.OperatorID,
+		}
+
+		var err error
+		if req.Amount.IsPositive() {
+			result, err = s.CreditWallet(tx, posting)
+		} else {
+			result, err = s.DebitWallet(tx, posting)
+		}
+		return err
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// ReconcileItem 对账差异
+type ReconcileItem struct {
+	AccountID      uint            `json:"account_id"`
+	Code           string          `json:"code"`
+	UserID         *uint           `json:"user_id"`
+	AccountBalance decimal.Decimal `json:"account_balance"` // 账户记录的余额
+	EntrySum       decimal.Decimal `json:"entry_sum"`       // 分录汇总余额
+	UserBalance    decimal.Decimal `json:"user_balance"`    // 用户表中的余额
+}
+
+// ReconcileResponse 对账结果
+type ReconcileResponse struct {
+	CheckedAccounts        int             `json:"checked_accounts"`
+	Mismatches             []ReconcileItem `json:"mismatches"`
+	UnbalancedTransactions []uint          `json:"unbalanced_transactions"` // 分录合计不为零的交易
+	Fixed                  bool            `json:"fixed"`
+}
+
+// Reconcile 以分录为准核对账户余额和用户余额，fix为true时修正差异
+func (s *LedgerService) Reconcile(ctx context.Context, fix bool) (*ReconcileResponse, error) {
+	var accounts []model.LedgerAccount
+	if err := s.db.Order("id").Find(&accounts).Error; err != nil {
+		return nil, fmt.Errorf("获取账户列表失败: %w", err)
+	}
+
+	// 按账户汇总分录
+	var sums []struct {
+		AccountID uint
+		Total     decimal.Decimal
+	}
+	if err := s.db.Model(&model.LedgerEntry{}).
+		Select("account_id, SUM(amount) AS total").
+		Group("account_id").
+		Scan(&sums).Error; err != nil {
+		return nil, fmt.Errorf("汇总账本分录失败: %w", err)
+	}
+	entrySums := make(map[uint]decimal.Decimal, len(sums))
+	for _, sum := range sums {
+		entrySums[sum.AccountID] = sum.Total
+	}
+
+	// 用户表余额
+	var users []model.User
+	if err := s.db.Select("id, balance").Find(&users).Error; err != nil {
+		return nil, fmt.Errorf("获取用户余额失败: %w", err)
+	}
+	userBalances := make(map[uint]decimal.Decimal, len(users))
+	for _, user := range users {
+		userBalances[user.ID] = user.Balance
+	}
+
+	resp := &ReconcileResponse{
+		CheckedAccounts: len(accounts),
+		Mismatches:      []ReconcileItem{},
+		Fixed:           fix,
+	}
+
+	for _, account := range accounts {
+		item := ReconcileItem{
+			AccountID:      account.ID,
+			Code:           account.Code,
+			UserID:         account.UserID,
+			AccountBalance: account.Balance,
+			EntrySum:       entrySums[account.ID],
+		}
+
+		mismatch := !item.AccountBalance.Equal(item.EntrySum)
+		if account.UserID != nil {
+			item.UserBalance = userBalances[*account.UserID]
+			mismatch = mismatch || !item.UserBalance.Equal(item.EntrySum)
+		}
+		if mismatch {
+			resp.Mismatches = append(resp.Mismatches, item)
+		}
+	}
+
+	// 检查借贷不平衡的交易
+	if err := s.db.Model(&model.LedgerEntry{}).
+		Group("transaction_id").
+		Having("SUM(amount) <> 0").
+		Pluck("transaction_id", &resp.UnbalancedTransactions).Error; err != nil {
+		return nil, fmt.Errorf("检查交易平衡失败: %w", err)
+	}
+
+	if !fix || len(resp.Mismatches) == 0 {
+		return resp, nil
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		for _, item := range resp.Mismatches {
+			if err := tx.Model(&model.LedgerAccount{}).Where("id = ?", item.AccountID).
+				Update("balance", item.EntrySum).Error; err != nil {
+				return fmt.Errorf("修正账户余额失败: %w", err)
+			}
+			if item.UserID != nil {
+				if err := tx.Model(&model.User{}).Where("id = ?", *item.UserID).
+					Update("balance", item.EntrySum).Error; err != nil {
+					return fmt.Errorf("修正用户余额失败: %w", err)
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
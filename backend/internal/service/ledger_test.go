@@ -0,0 +1,137 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"cloudbp-backend/internal/model"
+	"cloudbp-backend/pkg/idgen"
+
+	"github.com/shopspring/decimal"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// openTestDB 连接测试数据库，未设置 TEST_DATABASE_DSN 时跳过
+func openTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+
+	dsn := os.Getenv("TEST_DATABASE_DSN")
+	if dsn == "" {
+		t.Skip("未设置 TEST_DATABASE_DSN，跳过数据库测试")
+	}
+
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("连接测试数据库失败: %v", err)
+	}
+	if err := model.AutoMigrate(db); err != nil {
+		t.Fatalf("数据库迁移失败: %v", err)
+	}
+	if err := idgen.SetNode(0); err != nil {
+		t.Fatalf("设置单号生成节点号失败: %v", err)
+	}
+	return db
+}
+
+// TestDebitWalletConcurrent 多个请求同时扣同一个钱包，成功笔数恰好为余额能覆盖的笔数且余额不为负
+func TestDebitWalletConcurrent(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+	ledger := NewLedgerService(db)
+
+	suffix := time.Now().UnixNano()
+	user := model.User{
+		Username: fmt.Sprintf("ledger_test_%d", suffix),
+		Email:    fmt.Sprintf("ledger_test_%d@example.com", suffix),
+		Phone:    fmt.Sprintf("t%d", suffix),
+		Password: "-",
+	}
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatalf("创建测试用户失败: %v", err)
+	}
+
+	balance := decimal.RequireFromString("100.00")
+	price := decimal.RequireFromString("7.00")
+	const workers = 50
+
+	if _, err := ledger.AdjustBalance(ctx, &AdjustBalanceRequest{
+		UserID: user.ID,
+		Amount: balance,
+		Remark: "并发扣款测试充值",
+	}); err != nil {
+		t.Fatalf("充值失败: %v", err)
+	}
+
+	var (
+		wg        sync.WaitGroup
+		mu        sync.Mutex
+		successes int
+		failures  []error
+	)
+	start := make(chan struct{})
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+
+			err := db.Transaction(func(tx *gorm.DB) error {
+				_, err := ledger.DebitWallet(tx, &WalletPostingRequest{
+					UserID:      user.ID,
+					Amount:      price,
+					Type:        model.LedgerTxTypePurchase,
+					Description: "并发扣款测试",
+				})
+				return err
+			})
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err == nil {
+				successes++
+			} else if !errors.Is(err, ErrInsufficientBalance) {
+				failures = append(failures, err)
+			}
+		}()
+	}
+	close(start)
+	wg.Wait()
+
+	for _, err := range failures {
+		t.Errorf("扣款返回了余额不足以外的错误: %v", err)
+	}
+
+	expected := int(balance.Div(price).Floor().IntPart())
+	if successes != expected {
+		t.Errorf("成功扣款 %d 笔，期望 %d 笔", successes, expected)
+	}
+
+	wallet, err := ledger.GetWallet(ctx, user.ID)
+	if err != nil {
+		t.Fatalf("获取钱包失败: %v", err)
+	}
+	if wallet.Balance.IsNegative() {
+		t.Errorf("钱包余额为负: %s", wallet.Balance)
+	}
+	remaining := balance.Sub(price.Mul(decimal.NewFromInt(int64(successes))))
+	if !wallet.Balance.Equal(remaining) {
+		t.Errorf("钱包余额 %s，期望 %s", wallet.Balance, remaining)
+	}
+
+	var stored model.User
+	if err := db.First(&stored, user.ID).Error; err != nil {
+		t.Fatalf("获取用户失败: %v", err)
+	}
+	if !stored.Balance.Equal(wallet.Balance) {
+		t.Errorf("用户表余额 %s 与钱包余额 %s 不一致", stored.Balance, wallet.Balance)
+	}
+}
@@ -0,0 +1,349 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"cloudbp-backend/internal/model"
+	"cloudbp-backend/pkg/logger"
+
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// payExpireDuration 第三方支付有效期，超时未支付的订单和充值单将被关闭
+const payExpireDuration = 30 * time.Minute
+
+// orderConfig 订单的服务器配置，保存在订单Config字段中
+type orderConfig struct {
+	Name            string `json:"name,omitempty"`
+	Region          string `json:"region,omitempty"` // 下单时选择的地域和可用区
+	Zone            string `json:"zone,omitempty"`
+	ImageID         string `json:"image_id,omitempty"`
+	OSType          string `json:"os_type,omitempty"` // 下单时所选镜像的操作系统
+	OSName          string `json:"os_name,omitempty"`
+	Password        string `json:"password,omitempty"`
+	SSHKeyIDs       []uint `json:"ssh_key_ids,omitempty"`      // 注入的公钥
+	DisablePassword bool   `json:"disable_password,omitempty"` // 禁用密码登录
+	UserData        string `json:"user_data,omitempty"`        // 用户数据，内置变量在开通时按服务器替换
+	AutoRenew       bool   `json:"auto_renew,omitempty"`
+	ServerID        uint   `json:"server_id,omitempty"` // 续费的服务器
+}
+
+// orderSecretKeys 订单配置中不返回给前端、开通完成后也不再保留的字段
+var orderSecretKeys = []string{"password", "user_data"}
+
+// redactOrderConfig 填充脱敏后的订单配置用于接口返回，解析失败时不返回配置
+func redactOrderConfig(order *model.Order) {
+	if order.Config == "" {
+		return
+	}
+	var cfg map[string]interface{}
+	if err := json.Unmarshal([]byte(order.Config), &cfg); err != nil {
+		return
+	}
+	for _, key := range orderSecretKeys {
+		delete(cfg, key)
+	}
+	order.RedactedConfig = cfg
+}
+
+// scrubOrderConfig 清除订单配置中的登录密码和用户数据，实例开通后已无需保留
+func scrubOrderConfig(config string) (string, error) {
+	var cfg map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(config), &cfg); err != nil {
+		return "", fmt.Errorf("解析订单配置失败: %w", err)
+	}
+	for _, key := range orderSecretKeys {
+		delete(cfg, key)
+	}
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return "", fmt.Errorf("序列化订单配置失败: %w", err)
+	}
+	return string(data), nil
+}
+
+// OrderService 订单服务
+type OrderService struct {
+	db  *gorm.DB
+	rdb *redis.Client
+}
+
+// NewOrderService 创建订单服务
+func NewOrderService(db *gorm.DB, rdb *redis.Client) *OrderService {
+	return &OrderService{
+		db:  db,
+		rdb: rdb,
+	}
+}
+
+// CloseExpiredOrders 关闭超时未支付的订单和充值单
+func (s *OrderService) CloseExpiredOrders(ctx context.Context) error {
+	now := time.Now()
+
+	var orders []model.Order
+	if err := s.db.WithContext(ctx).
+		Where("status = ? AND pay_expire_at IS NOT NULL AND pay_expire_at < ?", model.OrderStatusPending, now).
+		Find(&orders).Error; err != nil {
+		return fmt.Errorf("获取超时订单失败: %w", err)
+	}
+
+	for _, order := range orders {
+		err := s.db.Transaction(func(tx *gorm.DB) error {
+			_, err := cancelPendingOrder(tx, order.ID, "支付超时自动关闭", "支付超时")
+			return err
+		})
+		if err != nil {
+			logger.Log.Error("关闭超时订单失败", zap.String("order_no", order.OrderNo), zap.Error(err))
+			continue
+		}
+	}
+
+	if err := s.db.WithContext(ctx).Model(&model.Recharge{}).
+		Where("status = ? AND expire_at < ?", model.RechargeStatusPending, now).
+		Updates(map[string]interface{}{
+			"status": model.RechargeStatusClosed,
+			"remark": "支付超时自动关闭",
+		}).Error; err != nil {
+		return fmt.Errorf("关闭超时充值单失败: %w", err)
+	}
+
+	return nil
+}
+
+// cancelPendingOrder 关闭待支付订单，释放占用的优惠券和库存并将未完成的支付记录置为失败；
+// 条件更新避免覆盖并发到达的支付回调，订单已不是待支付状态时返回false
+func cancelPendingOrder(tx *gorm.DB, orderID uint, remark, paymentRemark string) (bool, error) {
+	result := tx.Model(&model.Order{}).
+		Where("id = ? AND status = ?", orderID, model.OrderStatusPending).
+		Updates(map[string]interface{}{
+			"status": model.OrderStatusCancelled,
+			"remark": remark,
+		})
+	if result.Error != nil {
+		return false, fmt.Errorf("关闭订单失败: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return false, nil
+	}
+
+	if err := releaseCoupon(tx, orderID); err != nil {
+		return false, err
+	}
+
+	if err := settleStock(tx, orderID, 0); err != nil {
+		return false, err
+	}
+
+	if err := tx.Model(&model.Payment{}).
+		Where("order_id = ? AND status = ?", orderID, model.PaymentStatusPending).
+		Updates(map[string]interface{}{
+			"status": model.PaymentStatusFailed,
+			"remark": paymentRemark,
+		}).Error; err != nil {
+		return false, fmt.Errorf("更新支付记录失败: %w", err)
+	}
+	return true, nil
+}
+
+// GetUserOrdersRequest 获取用户订单列表请求
+type GetUserOrdersRequest struct {
+	UserID    uint       `json:"user_id"`
+	Status    string     `json:"status"`
+	Type      string     `json:"type"`
+	StartTime *time.Time `json:"start_time"` // 下单时间起（含）
+	EndTime   *time.Time `json:"end_time"`   // 下单时间止（不含）
+	Page      int        `json:"page"`
+	Size      int        `json:"size"`
+}
+
+// GetUserOrdersResponse 获取用户订单列表响应
+type GetUserOrdersResponse struct {
+	Orders     []model.Order `json:"orders"`
+	TotalCount int64         `json:"total_count"`
+	Page       int           `json:"page"`
+	Size       int           `json:"size"`
+}
+
+// GetUserOrders 获取用户订单列表
+func (s *OrderService) GetUserOrders(ctx context.Context, req *GetUserOrdersRequest) (*GetUserOrdersResponse, error) {
+	var orders []model.Order
+	var totalCount int64
+
+	query := s.db.Model(&model.Order{}).Where("user_id = ?", req.UserID)
+	if req.Status != "" {
+		query = query.Where("status = ?", req.Status)
+	}
+	if req.Type != "" {
+		query = query.Where("type = ?", req.Type)
+	}
+	if req.StartTime != nil {
+		query = query.Where("created_at >= ?", *req.StartTime)
+	}
+	if req.EndTime != nil {
+		query = query.Where("created_at < ?", *req.EndTime)
+	}
+
+	// 获取总数
+	if err := query.Count(&totalCount).Error; err != nil {
+		return nil, fmt.Errorf("获取订单总数失败: %w", err)
+	}
+
+	// 获取分页数据
+	offset := (req.Page - 1) * req.Size
+	if err := query.Preload("Product").Preload("Provider").
+		Offset(offset).Limit(req.Size).Order("created_at DESC").Find(&orders).Error; err != nil {
+		return nil, fmt.Errorf("获取订单列表失败: %w", err)
+	}
+	for i := range orders {
+		redactOrderConfig(&orders[i])
+	}
+
+	return &GetUserOrdersResponse{
+		Orders:     orders,
+		TotalCount: totalCount,
+		Page:       req.Page,
+		Size:       req.Size,
+	}, nil
+}
+
+// GetUserOrder 获取用户订单详情，包含支付记录和开通的服务器
+func (s *OrderService) GetUserOrder(ctx context.Context, userID, orderID uint) (*model.Order, error) {
+	var order model.Order
+	if err := s.db.Preload("Product").Preload("Provider").
+		Preload("Payments", func(db *gorm.DB) *gorm.DB {
+			return db.Order("created_at DESC")
+		}).
+		Preload("Servers").
+		Where("id = ? AND user_id = ?", orderID, userID).
+		First(&order).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("订单不存在")
+		}
+		return nil, fmt.Errorf("获取订单失败: %w", err)
+	}
+	redactOrderConfig(&order)
+	return &order, nil
+}
+
+// CancelOrder 用户取消待支付订单
+func (s *OrderService) CancelOrder(ctx context.Context, userID, orderID uint) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		var order model.Order
+		if err := tx.Where("id = ? AND user_id = ?", orderID, userID).First(&order).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errors.New("订单不存在")
+			}
+			return fmt.Errorf("获取订单失败: %w", err)
+		}
+
+		cancelled, err := cancelPendingOrder(tx, order.ID, "用户取消", "订单已取消")
+		if err != nil {
+			return err
+		}
+		if !cancelled {
+			return errors.New("只能取消待支付的订单")
+		}
+
+		logger.Log.Info("用户取消订单", zap.Uint("user_id", userID), zap.String("order_no", order.OrderNo))
+		return nil
+	})
+}
+
+// fulfillOrder 已支付订单履约：新购创建待开通的服务器记录；续费订单保持已支付，
+// 由 ProvisionService 在事务外续费厂商实例后顺延到期时间
+func fulfillOrder(tx *gorm.DB, order *model.Order) error {
+	if order.Type == model.OrderTypeNew {
+		return createOrderServers(tx, order)
+	}
+	return nil
+}
+
+// orderDescription 订单扣款的账本摘要
+func orderDescription(order *model.Order) string {
+	if order.Type == model.OrderTypeRenew {
+		return fmt.Sprintf("续费服务器 %s", order.OrderNo)
+	}
+	return fmt.Sprintf("购买服务器 %s", order.OrderNo)
+}
+
+// createOrderServers 新购订单按购买数量创建服务器记录，实例由 ProvisionService 在事务提交后并行开通
+func createOrderServers(tx *gorm.DB, order *model.Order) error {
+	var product model.Product
+	if err := tx.First(&product, order.ProductID).Error; err != nil {
+		return fmt.Errorf("获取产品信息失败: %w", err)
+	}
+
+	var cfg orderConfig
+	if err := json.Unmarshal([]byte(order.Config), &cfg); err != nil {
+		return fmt.Errorf("解析订单配置失败: %w", err)
+	}
+	osType, osName := cfg.OSType, cfg.OSName
+	if osType == "" {
+		osType = product.OS
+	}
+	region, zone := cfg.Region, cfg.Zone
+	if region == "" {
+		region, zone = product.Region, product.Zone
+	}
+
+	quantity := order.Quantity
+	if quantity <= 0 {
+		quantity = 1
+	}
+
+	// 创建服务器记录
+	expireTime := time.Now().AddDate(0, order.Period, 0)
+	servers := make([]model.Server, 0, quantity)
+	for n := 1; n <= quantity; n++ {
+		servers = append(servers, model.Server{
+			UserID:          order.UserID,
+			OrderID:         order.ID,
+			ProviderID:      order.ProviderID,
+			ProductID:       order.ProductID,
+			Name:            serverName(cfg.Name, n, quantity),
+			InstanceID:      fmt.Sprintf("pending-%s-%d", order.OrderNo, n), // 开通前的占位实例ID
+			Region:          region,
+			Zone:            zone,
+			Status:          model.ServerStatusCreating,
+			ExpireTime:      expireTime,
+			AutoRenew:       cfg.AutoRenew,
+			Password:        cfg.Password,
+			SSHKeyIDs:       cfg.SSHKeyIDs,
+			DisablePassword: cfg.DisablePassword,
+			ImageID:         cfg.ImageID,
+			OSType:          osType,
+			OSName:          osName,
+			CPU:             product.CPU,
+			Memory:          product.Memory,
+			Storage:         product.Storage,
+			Bandwidth:       product.Bandwidth,
+			Traffic:         product.Traffic,
+		})
+	}
+
+	if err := tx.Create(&servers).Error; err != nil {
+		return fmt.Errorf("创建服务器记录失败: %w", err)
+	}
+
+	return nil
+}
+
+// serverName 按名称模板生成第n台服务器的名称，模板中的{n}替换为序号，
+// 购买多台且模板中没有{n}时在名称后追加"-序号"
+func serverName(template string, n, quantity int) string {
+	if strings.Contains(template, "{n}") {
+		return strings.ReplaceAll(template, "{n}", strconv.Itoa(n))
+	}
+	if quantity > 1 {
+		return fmt.Sprintf("%s-%d", template, n)
+	}
+	return template
+}
@@ -0,0 +1,667 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	"cloudbp-backend/internal/config"
+	"cloudbp-backend/internal/model"
+	"cloudbp-backend/pkg/logger"
+	"cloudbp-backend/pkg/payment"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/shopspring/decimal"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 充值金额限制
+var (
+	minRechargeAmount = decimal.NewFromInt(1)
+	maxRechargeAmount = decimal.NewFromInt(50000)
+)
+
+// PaymentService 支付服务
+type PaymentService struct {
+	db            *gorm.DB
+	rdb           *redis.Client
+	config        config.PaymentConfig
+	gateways      *payment.Manager
+	ledgerService *LedgerService
+}
+
+// NewPaymentService 创建支付服务
+func NewPaymentService(db *gorm.DB, rdb *redis.Client, cfg config.PaymentConfig) *PaymentService {
+	return &PaymentService{
+		db:            db,
+		rdb:           rdb,
+		config:        cfg,
+		gateways:      payment.NewManager(),
+		ledgerService: NewLedgerService(db),
+	}
+}
+
+// InitGateways 注册配置中启用的支付通道，单个通道失败不影响其他通道
+func (s *PaymentService) InitGateways() error {
+	var errs []error
+
+	if s.config.Wechat.Enabled {
+		gateway, err := payment.NewWechatPay(s.config.Wechat, s.notifyURL(model.PaymentMethodWechat))
+		if err != nil {
+			errs = append(errs, fmt.Errorf("初始化微信支付失败: %w", err))
+		} else {
+			s.gateways.RegisterGateway(gateway)
+		}
+	}
+
+	if s.config.Alipay.Enabled {
+		gateway, err := payment.NewAlipay(s.config.Alipay, s.notifyURL(model.PaymentMethodAlipay))
+		if err != nil {
+			errs = append(errs, fmt.Errorf("初始化支付宝失败: %w", err))
+		} else {
+			s.gateways.RegisterGateway(gateway)
+		}
+	}
+
+	if s.config.Mock.Enabled {
+		gateway, err := payment.NewMockPay(s.config.Mock, strings.TrimRight(s.config.NotifyBaseURL, "/")+"/payment/mock/pay")
+		if err != nil {
+			errs = append(errs, fmt.Errorf("初始化模拟支付失败: %w", err))
+		} else {
+			s.gateways.RegisterGateway(gateway)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// notifyURL 支付通道异步通知地址
+func (s *PaymentService) notifyURL(method string) string {
+	return strings.TrimRight(s.config.NotifyBaseURL, "/") + "/payment/notify/" + method
+}
+
+// GetGateway 获取支付通道
+func (s *PaymentService) GetGateway(method string) (payment.Gateway, error) {
+	gateway, exists := s.gateways.GetGateway(method)
+	if !exists {
+		return nil, fmt.Errorf("支付方式 %s 不存在或未启用", method)
+	}
+	return gateway, nil
+}
+
+// PaymentMethod 可用支付方式
+type PaymentMethod struct {
+	Code string `json:"code"`
+	Name string `json:"name"`
+}
+
+// GetPaymentMethods 获取可用的第三方支付方式
+func (s *PaymentService) GetPaymentMethods(ctx context.Context) []PaymentMethod {
+	gateways := s.gateways.GetAllGateways()
+	methods := make([]PaymentMethod, 0, len(gateways))
+	for _, gateway := range gateways {
+		methods = append(methods, PaymentMethod{
+			Code: gateway.GetCode(),
+			Name: gateway.GetName(),
+		})
+	}
+	return methods
+}
+
+// CreateRechargeRequest 创建充值请求
+type CreateRechargeRequest struct {
+	UserID   uint            `json:"user_id"`
+	Amount   decimal.Decimal `json:"amount" binding:"required"`
+	Method   string          `json:"method" binding:"required"` // wechat、alipay
+	PayType  string          `json:"pay_type"`                  // qrcode、redirect，默认qrcode
+	ClientIP string          `json:"client_ip"`
+}
+
+// CreateRechargeResponse 创建充值响应
+type CreateRechargeResponse struct {
+	RechargeNo string          `json:"recharge_no"`
+	Amount     decimal.Decimal `json:"amount"`
+	Method     string          `json:"method"`
+	ExpireAt   time.Time       `json:"expire_at"`
+	PayType    string          `json:"pay_type"`
+	CodeURL    string          `json:"code_url"`
+	PayURL     string          `json:"pay_url"`
+}
+
+// CreateRecharge 创建充值单并向支付通道下单
+func (s *PaymentService) CreateRecharge(ctx context.Context, req *CreateRechargeRequest) (*CreateRechargeResponse, error) {
+	// 校验金额
+	if !req.Amount.Round(2).Equal(req.Amount) {
+		return nil, errors.New("充值金额最多两位小数")
+	}
+	if req.Amount.LessThan(minRechargeAmount) || req.Amount.GreaterThan(maxRechargeAmount) {
+		return nil, fmt.Errorf("充值金额需在 %s 到 %s 元之间", minRechargeAmount, maxRechargeAmount)
+	}
+
+	gateway, err := s.GetGateway(req.Method)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.PayType == "" {
+		req.PayType = payment.PayTypeQRCode
+	}
+
+	recharge := model.Recharge{
+		RechargeNo: generateNo("RCH"),
+		UserID:     req.UserID,
+		Method:     req.Method,
+		Amount:     req.Amount,
+		Status:     model.RechargeStatusPending,
+		ExpireAt:   time.Now().Add(payExpireDuration),
+		ClientIP:   req.ClientIP,
+	}
+	if err := s.db.Create(&recharge).Error; err != nil {
+		return nil, fmt.Errorf("创建充值单失败: %w", err)
+	}
+
+	payResp, err := gateway.CreatePayment(ctx, &payment.CreatePaymentRequest{
+		TradeNo:   recharge.RechargeNo,
+		Subject:   "账户余额充值",
+		Amount:    recharge.Amount,
+		PayType:   req.PayType,
+		ClientIP:  req.ClientIP,
+		ReturnURL: s.config.ReturnURL,
+		ExpireAt:  recharge.ExpireAt,
+	})
+	if err != nil {
+		// 下单失败的充值单直接关闭
+		s.db.Model(&recharge).Updates(map[string]interface{}{
+			"status": model.RechargeStatusClosed,
+			"remark": err.Error(),
+		})
+		return nil, fmt.Errorf("创建支付失败: %w", err)
+	}
+
+	return &CreateRechargeResponse{
+		RechargeNo: recharge.RechargeNo,
+		Amount:     recharge.Amount,
+		Method:     recharge.Method,
+		ExpireAt:   recharge.ExpireAt,
+		PayType:    payResp.PayType,
+		CodeURL:    payResp.CodeURL,
+		PayURL:     payResp.PayURL,
+	}, nil
+}
+
+// HandleNotify 处理支付通道异步通知，返回应写回给通道的应答
+func (s *PaymentService) HandleNotify(ctx context.Context, method string, r *http.Request) (*payment.NotifyResponse, error) {
+	gateway, err := s.GetGateway(method)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := gateway.ParseNotify(ctx, r)
+	if err != nil {
+		return gateway.NotifyResponse(false), err
+	}
+
+	// 未支付成功的通知无需处理
+	if !result.Paid {
+		return gateway.NotifyResponse(true), nil
+	}
+
+	if err := s.completeTrade(ctx, method, result); err != nil {
+		return gateway.NotifyResponse(false), err
+	}
+
+	return gateway.NotifyResponse(true), nil
+}
+
+// completeTrade 根据商户交易号完成对应的业务单据，订单支付单优先，其次为充值单
+func (s *PaymentService) completeTrade(ctx context.Context, method string, result *payment.NotifyResult) error {
+	var count int64
+	if err := s.db.Model(&model.Payment{}).Where("payment_no = ?", result.TradeNo).Count(&count).Error; err != nil {
+		return fmt.Errorf("获取支付记录失败: %w", err)
+	}
+	if count > 0 {
+		return s.completeOrderPayment(ctx, method, result)
+	}
+	return s.completeRecharge(ctx, method, result)
+}
+
+// CreateOrderPayment 为待支付订单向支付通道下单，下单失败时关闭订单
+func (s *PaymentService) CreateOrderPayment(ctx context.Context, order *model.Order, record *model.Payment, payType, clientIP string) (*payment.CreatePaymentResponse, error) {
+	gateway, err := s.GetGateway(record.Method)
+	if err != nil {
+		return nil, err
+	}
+
+	if payType == "" {
+		payType = payment.PayTypeQRCode
+	}
+
+	expireAt := time.Now().Add(payExpireDuration)
+	if order.PayExpireAt != nil {
+		expireAt = *order.PayExpireAt
+	}
+
+	payResp, err := gateway.CreatePayment(ctx, &payment.CreatePaymentRequest{
+		TradeNo:   record.PaymentNo,
+		Subject:   fmt.Sprintf("云服务器订单 %s", order.OrderNo),
+		Amount:    record.Amount,
+		Currency:  record.Currency,
+		PayType:   payType,
+		ClientIP:  clientIP,
+		ReturnURL: s.config.ReturnURL,
+		ExpireAt:  expireAt,
+	})
+	if err != nil {
+		// 下单失败的订单直接关闭
+		s.db.Transaction(func(tx *gorm.DB) error {
+			_, cancelErr := cancelPendingOrder(tx, order.ID, err.Error(), err.Error())
+			return cancelErr
+		})
+		return nil, fmt.Errorf("创建支付失败: %w", err)
+	}
+
+	return payResp, nil
+}
+
+// PayOrderRequest 重新支付订单请求
+type PayOrderRequest struct {
+	OrderID   uint   `json:"order_id"`
+	UserID    uint   `json:"user_id"`
+	PayMethod string `json:"pay_method"` // balance、wechat、alipay，默认沿用订单的支付方式
+	PayType   string `json:"pay_type"`   // 第三方支付方式：qrcode、redirect
+	ClientIP  string `json:"client_ip"`
+}
+
+// PayOrder 重新支付待支付订单，可更换支付方式；金额、优惠和汇率沿用下单时锁定的结果，
+// 支付截止时间不变。之前未完成的支付记录置为失败，其迟到的付款按已关闭订单转入余额
+func (s *PaymentService) PayOrder(ctx context.Context, req *PayOrderRequest) (*CheckoutResponse, error) {
+	var order model.Order
+	var record model.Payment
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ? AND user_id = ?", req.OrderID, req.UserID).
+			First(&order).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errors.New("订单不存在")
+			}
+			return fmt.Errorf("获取订单失败: %w", err)
+		}
+
+		if order.Status != model.OrderStatusPending {
+			return errors.New("只能支付待支付的订单")
+		}
+		if order.PayExpireAt != nil && order.PayExpireAt.Before(time.Now()) {
+			return errors.New("订单已超过支付期限")
+		}
+
+		method := req.PayMethod
+		if method == "" {
+			method = order.PayMethod
+		}
+		if method != model.PaymentMethodBalance {
+			if _, err := s.GetGateway(method); err != nil {
+				return err
+			}
+		}
+
+		// 作废之前未完成的支付记录
+		if err := tx.Model(&model.Payment{}).
+			Where("order_id = ? AND status = ?", order.ID, model.PaymentStatusPending).
+			Updates(map[string]interface{}{
+				"status": model.PaymentStatusFailed,
+				"remark": "已重新发起支付",
+			}).Error; err != nil {
+			return fmt.Errorf("更新支付记录失败: %w", err)
+		}
+
+		record = model.Payment{
+			OrderID:   order.ID,
+			UserID:    order.UserID,
+			PaymentNo: generateNo("PAY"),
+			Method:    method,
+			Amount:    order.PayAmount,
+			Currency:  order.Currency,
+			Status:    model.PaymentStatusPending,
+		}
+
+		if method != model.PaymentMethodBalance {
+			if err := tx.Create(&record).Error; err != nil {
+				return fmt.Errorf("创建支付记录失败: %w", err)
+			}
+			if err := tx.Model(&order).Update("pay_method", method).Error; err != nil {
+				return fmt.Errorf("更新订单支付方式失败: %w", err)
+			}
+			order.PayMethod = method
+			return nil
+		}
+
+		// 余额支付立即扣款并履约
+		if order.BasePayAmount.IsPositive() {
+			if _, err := s.ledgerService.DebitWallet(tx, &WalletPostingRequest{
+				UserID:      order.UserID,
+				Amount:      order.BasePayAmount,
+				Type:        orderLedgerTxType(order.Type),
+				OrderID:     &order.ID,
+				Description: orderDescription(&order),
+			}); err != nil {
+				if errors.Is(err, ErrInsufficientBalance) {
+					return err
+				}
+				return fmt.Errorf("扣除余额失败: %w", err)
+			}
+		}
+
+		now := time.Now()
+		record.Status = model.PaymentStatusSuccess
+		record.PayTime = &now
+		if err := tx.Create(&record).Error; err != nil {
+			return fmt.Errorf("创建支付记录失败: %w", err)
+		}
+
+		if err := tx.Model(&order).Updates(map[string]interface{}{
+			"status":     model.OrderStatusPaid,
+			"pay_method": method,
+			"pay_time":   &now,
+		}).Error; err != nil {
+			return fmt.Errorf("更新订单状态失败: %w", err)
+		}
+		order.Status = model.OrderStatusPaid
+		order.PayMethod = method
+		order.PayTime = &now
+
+		return fulfillOrder(tx, &order)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result := &CheckoutResponse{
+		OrderID:        order.ID,
+		OrderNo:        order.OrderNo,
+		Status:         order.Status,
+		Currency:       order.Currency,
+		Amount:         order.Amount,
+		DiscountAmount: order.DiscountAmount,
+		TaxAmount:      order.TaxAmount,
+		PayAmount:      order.PayAmount,
+		PayMethod:      order.PayMethod,
+		PaymentNo:      record.PaymentNo,
+	}
+
+	if order.PayMethod == model.PaymentMethodBalance {
+		logger.Log.Info("订单余额支付成功", zap.String("order_no", order.OrderNo), zap.String("payment_no", record.PaymentNo))
+		return result, nil
+	}
+
+	// 向支付通道下单
+	payResp, err := s.CreateOrderPayment(ctx, &order, &record, req.PayType, req.ClientIP)
+	if err != nil {
+		return nil, err
+	}
+
+	result.PayType = payResp.PayType
+	result.CodeURL = payResp.CodeURL
+	result.PayURL = payResp.PayURL
+	result.PayExpireAt = order.PayExpireAt
+	return result, nil
+}
+
+// completeOrderPayment 订单支付到账，先充入钱包再从钱包扣款，保持账本收支一致；
+// 订单已关闭时款项保留在余额中
+func (s *PaymentService) completeOrderPayment(ctx context.Context, method string, result *payment.NotifyResult) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		var record model.Payment
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("payment_no = ?", result.TradeNo).
+			First(&record).Error; err != nil {
+			return fmt.Errorf("获取支付记录失败: %w", err)
+		}
+
+		// 已到账的支付记录直接应答成功
+		if record.Status == model.PaymentStatusSuccess {
+			return nil
+		}
+
+		if record.Method != method {
+			return fmt.Errorf("支付单 %s 支付方式不一致", record.PaymentNo)
+		}
+		if !result.Amount.Equal(record.Amount) {
+			return fmt.Errorf("支付单 %s 支付金额不一致: %s", record.PaymentNo, result.Amount)
+		}
+
+		var order model.Order
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&order, record.OrderID).Error; err != nil {
+			return fmt.Errorf("获取订单失败: %w", err)
+		}
+
+		payTime := result.PaidAt
+		if payTime.IsZero() {
+			payTime = time.Now()
+		}
+		if err := tx.Model(&record).Updates(map[string]interface{}{
+			"status":         model.PaymentStatusSuccess,
+			"transaction_id": result.TransactionID,
+			"pay_time":       &payTime,
+		}).Error; err != nil {
+			return fmt.Errorf("更新支付记录失败: %w", err)
+		}
+
+		// 通道按订单币种收款，钱包按下单时锁定的汇率折合本位币入账
+		if _, err := s.ledgerService.CreditWallet(tx, &WalletPostingRequest{
+			UserID:      record.UserID,
+			Amount:      toBaseAmount(&order, record.Amount),
+			Type:        model.LedgerTxTypeTopUp,
+			Counterpart: model.LedgerAccountGateway,
+			OrderID:     &order.ID,
+			Description: fmt.Sprintf("订单支付 %s", record.PaymentNo),
+		}); err != nil {
+			return fmt.Errorf("支付入账失败: %w", err)
+		}
+
+		// 订单已超时关闭，款项转入余额
+		if order.Status != model.OrderStatusPending {
+			logger.Log.Warn("已关闭的订单收到付款，款项转入余额",
+				zap.String("order_no", order.OrderNo), zap.String("payment_no", record.PaymentNo))
+			return tx.Model(&record).Update("remark", "订单已关闭，款项已转入余额").Error
+		}
+
+		if _, err := s.ledgerService.DebitWallet(tx, &WalletPostingRequest{
+			UserID:      order.UserID,
+			Amount:      order.BasePayAmount,
+			Type:        orderLedgerTxType(order.Type),
+			OrderID:     &order.ID,
+			Description: orderDescription(&order),
+		}); err != nil {
+			return fmt.Errorf("订单扣款失败: %w", err)
+		}
+
+		if err := tx.Model(&order).Updates(map[string]interface{}{
+			"status":   model.OrderStatusPaid,
+			"pay_time": &payTime,
+		}).Error; err != nil {
+			return fmt.Errorf("更新订单状态失败: %w", err)
+		}
+
+		if err := fulfillOrder(tx, &order); err != nil {
+			return err
+		}
+
+		logger.Log.Info("订单支付到账", zap.String("order_no", order.OrderNo), zap.String("payment_no", record.PaymentNo))
+		return nil
+	})
+}
+
+// orderLedgerTxType 订单类型对应的账本交易类型
+func orderLedgerTxType(orderType string) string {
+	switch orderType {
+	case model.OrderTypeRenew:
+		return model.LedgerTxTypeRenew
+	case model.OrderTypeUpgrade:
+		return model.LedgerTxTypeUpgrade
+	default:
+		return model.LedgerTxTypePurchase
+	}
+}
+
+// completeRecharge 充值到账，重复通知不会重复入账
+func (s *PaymentService) completeRecharge(ctx context.Context, method string, result *payment.NotifyResult) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		var recharge model.Recharge
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("recharge_no = ?", result.TradeNo).
+			First(&recharge).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return fmt.Errorf("充值单 %s 不存在", result.TradeNo)
+			}
+			return fmt.Errorf("获取充值单失败: %w", err)
+		}
+
+		// 已到账的充值单直接应答成功
+		if recharge.Status == model.RechargeStatusSuccess {
+			return nil
+		}
+
+		if recharge.Method != method {
+			return fmt.Errorf("充值单 %s 支付方式不一致", recharge.RechargeNo)
+		}
+		if !result.Amount.Equal(recharge.Amount) {
+			return fmt.Errorf("充值单 %s 支付金额不一致: %s", recharge.RechargeNo, result.Amount)
+		}
+
+		// 已关闭的充值单收到付款同样入账，避免资金滞留
+		if recharge.Status == model.RechargeStatusClosed {
+			logger.Log.Warn("已关闭的充值单收到付款", zap.String("recharge_no", recharge.RechargeNo))
+		}
+
+		payTime := result.PaidAt
+		if payTime.IsZero() {
+			payTime = time.Now()
+		}
+		if err := tx.Model(&recharge).Updates(map[string]interface{}{
+			"status":         model.RechargeStatusSuccess,
+			"transaction_id": result.TransactionID,
+			"pay_time":       &payTime,
+		}).Error; err != nil {
+			return fmt.Errorf("更新充值单失败: %w", err)
+		}
+
+		if _, err := s.ledgerService.CreditWallet(tx, &WalletPostingRequest{
+			UserID:      recharge.UserID,
+			Amount:      recharge.Amount,
+			Type:        model.LedgerTxTypeTopUp,
+			Counterpart: model.LedgerAccountGateway,
+			Description: fmt.Sprintf("余额充值 %s", recharge.RechargeNo),
+		}); err != nil {
+			return fmt.Errorf("充值入账失败: %w", err)
+		}
+
+		logger.Log.Info("充值到账", zap.String("recharge_no", recharge.RechargeNo), zap.String("amount", recharge.Amount.String()))
+		return nil
+	})
+}
+
+// MockPay 模拟第三方支付成功并回调，仅在启用模拟支付时可用
+func (s *PaymentService) MockPay(ctx context.Context, tradeNo string) error {
+	gateway, err := s.GetGateway(model.PaymentMethodMock)
+	if err != nil {
+		return err
+	}
+	mock, ok := gateway.(*payment.MockPay)
+	if !ok {
+		return errors.New("模拟支付不可用")
+	}
+
+	amount, err := s.tradeAmount(tradeNo)
+	if err != nil {
+		return err
+	}
+
+	form := mock.BuildNotify(tradeNo, amount)
+	notifyReq := httptest.NewRequest(http.MethodPost, s.notifyURL(model.PaymentMethodMock), strings.NewReader(form.Encode()))
+	notifyReq.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	_, err = s.HandleNotify(ctx, model.PaymentMethodMock, notifyReq)
+	return err
+}
+
+// tradeAmount 获取商户交易号对应的待支付金额
+func (s *PaymentService) tradeAmount(tradeNo string) (decimal.Decimal, error) {
+	var record model.Payment
+	if err := s.db.Where("payment_no = ?", tradeNo).First(&record).Error; err == nil {
+		return record.Amount, nil
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return decimal.Zero, fmt.Errorf("获取支付记录失败: %w", err)
+	}
+
+	var recharge model.Recharge
+	if err := s.db.Where("recharge_no = ?", tradeNo).First(&recharge).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return decimal.Zero, errors.New("交易不存在")
+		}
+		return decimal.Zero, fmt.Errorf("获取充值单失败: %w", err)
+	}
+	return recharge.Amount, nil
+}
+
+// GetRechargesRequest 获取充值记录请求
+type GetRechargesRequest struct {
+	UserID uint   `json:"user_id"`
+	Page   int    `json:"page"`
+	Size   int    `json:"size"`
+	Status string `json:"status"`
+}
+
+// GetRechargesResponse 获取充值记录响应
+type GetRechargesResponse struct {
+	Recharges  []model.Recharge `json:"recharges"`
+	TotalCount int64            `json:"total_count"`
+	Page       int              `json:"page"`
+	Size       int              `json:"size"`
+}
+
+// GetRecharges 获取用户充值记录
+func (s *PaymentService) GetRecharges(ctx context.Context, req *GetRechargesRequest) (*GetRechargesResponse, error) {
+	var recharges []model.Recharge
+	var totalCount int64
+
+	query := s.db.Model(&model.Recharge{}).Where("user_id = ?", req.UserID)
+	if req.Status != "" {
+		query = query.Where("status = ?", req.Status)
+	}
+
+	// 获取总数
+	if err := query.Count(&totalCount).Error; err != nil {
+		return nil, fmt.Errorf("获取充值记录总数失败: %w", err)
+	}
+
+	// 获取分页数据
+	offset := (req.Page - 1) * req.Size
+	if err := query.Offset(offset).Limit(req.Size).Order("created_at DESC").Find(&recharges).Error; err != nil {
+		return nil, fmt.Errorf("获取充值记录失败: %w", err)
+	}
+
+	return &GetRechargesResponse{
+		Recharges:  recharges,
+		TotalCount: totalCount,
+		Page:       req.Page,
+		Size:       req.Size,
+	}, nil
+}
+
+// GetRecharge 获取充值单详情，用于前端轮询支付结果
+func (s *PaymentService) GetRecharge(ctx context.Context, userID uint, rechargeNo string) (*model.Recharge, error) {
+	var recharge model.Recharge
+	if err := s.db.Where("recharge_no = ? AND user_id = ?", rechargeNo, userID).First(&recharge).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("充值单不存在")
+		}
+		return nil, fmt.Errorf("获取充值单失败: %w", err)
+	}
+	return &recharge, nil
+}
@@ -0,0 +1,447 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"cloudbp-backend/internal/config"
+	"cloudbp-backend/internal/model"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+// 报价明细类型
+const (
+	QuoteItemBase           = "base"            // 原价
+	QuoteItemPeriodDiscount = "period_discount" // 长周期折扣
+	QuoteItemPromotion      = "promotion"       // 促销活动
+	QuoteItemCoupon         = "coupon"          // 优惠券
+	QuoteItemTax            = "tax"             // 税费
+)
+
+// maxOrderQuantity 单笔订单最多购买的服务器数量
+const maxOrderQuantity = 20
+
+// PricingService 订单计价服务，新购、续费、升降配统一在此计算应付金额
+type PricingService struct {
+	db                 *gorm.DB
+	rdb                *redis.Client
+	config             config.PricingConfig
+	couponService      *CouponService
+	currencyService    *CurrencyService
+	pricingRuleService *PricingRuleService
+	regionService      *RegionService
+}
+
+// NewPricingService 创建计价服务
+func NewPricingService(db *gorm.DB, rdb *redis.Client, cfg config.PricingConfig, currencyService *CurrencyService, pricingRuleService *PricingRuleService, regionService *RegionService) *PricingService {
+	return &PricingService{
+		db:                 db,
+		rdb:                rdb,
+		config:             cfg,
+		couponService:      NewCouponService(db, rdb),
+		currencyService:    currencyService,
+		pricingRuleService: pricingRuleService,
+		regionService:      regionService,
+	}
+}
+
+// QuoteRequest 订单报价请求
+type QuoteRequest struct {
+	UserID     uint   `json:"user_id"`
+	Type       string `json:"type"`       // new、renew、upgrade，默认new
+	ProductID  uint   `json:"product_id"` // 新购、升降配时必填，升降配为目标套餐
+	ServerID   uint   `json:"server_id"`  // 续费、升降配时必填
+	Period     int    `json:"period"`     // 新购、续费时必填
+	Quantity   int    `json:"quantity"`
+	Region     string `json:"region"` // 新购地域，默认产品所在地域
+	Zone       string `json:"zone"`   // 新购可用区，默认由厂商分配
+	CouponCode string `json:"coupon_code"`
+	Currency   string `json:"currency"` // 结算币种，默认用户设置的币种
+}
+
+// QuoteItem 报价明细，优惠类明细金额为负数
+type QuoteItem struct {
+	Type   string          `json:"type"` // base、period_discount、promotion、coupon、tax
+	Name   string          `json:"name"`
+	Amount decimal.Decimal `json:"amount"`
+}
+
+// QuoteResponse 订单报价
+type QuoteResponse struct {
+	Type           string          `json:"type"` // new、renew、upgrade、downgrade
+	ProductID      uint            `json:"product_id"`
+	ProviderID     uint            `json:"provider_id"`
+	ServerID       uint            `json:"server_id,omitempty"`
+	FromProductID  uint            `json:"from_product_id,omitempty"` // 升降配前的套餐
+	Region         string          `json:"region"`                    // 地域，按地域售价计价
+	Zone           string          `json:"zone,omitempty"`            // 新购选择的可用区
+	Period         int             `json:"period"`
+	Quantity       int             `json:"quantity"`
+	RemainingDays  int             `json:"remaining_days,omitempty"` // 升降配按剩余天数折算
+	Currency       string          `json:"currency"`                 // 结算币种，以下金额均以该币种计
+	ExchangeRate   decimal.Decimal `json:"exchange_rate"`            // 1单位结算币种折合本位币，下单时锁定
+	UnitPrice      decimal.Decimal `json:"unit_price"`               // 月单价
+	Amount         decimal.Decimal `json:"amount"`                   // 优惠前金额
+	Items          []QuoteItem     `json:"items"`                    // 计价明细
+	Discount       *DiscountResult `json:"-"`                        // 促销活动和优惠券（本位币），下单时用于占用优惠券
+	DiscountAmount decimal.Decimal `json:"discount_amount"`          // 优惠合计
+	TaxAmount      decimal.Decimal `json:"tax_amount"`               // 税费
+	PayAmount      decimal.Decimal `json:"pay_amount"`               // 应付金额，降配时为负数表示退还
+	BasePayAmount  decimal.Decimal `json:"base_pay_amount"`          // 应付金额折合本位币
+}
+
+// Quote 计算订单报价，依次计算原价、长周期折扣、促销活动、优惠券和税费，不占用优惠券；
+// 产品标价按当前汇率换算为结算币种
+func (s *PricingService) Quote(ctx context.Context, req *QuoteRequest) (*QuoteResponse, error) {
+	// 设置默认值
+	if req.Type == "" {
+		req.Type = model.OrderTypeNew
+	}
+	if req.Quantity == 0 {
+		req.Quantity = 1
+	}
+	if req.Currency == "" {
+		var user model.User
+		if err := s.db.Select("id", "currency").First(&user, req.UserID).Error; err != nil {
+			return nil, fmt.Errorf("获取用户信息失败: %w", err)
+		}
+		req.Currency = user.Currency
+	}
+	req.Currency = normalizeCurrency(req.Currency)
+	if req.Currency == "" {
+		req.Currency = s.currencyService.BaseCurrency()
+	}
+
+	rate, err := s.currencyService.Rate(req.Currency)
+	if err != nil {
+		return nil, err
+	}
+
+	var quote *QuoteResponse
+	switch req.Type {
+	case model.OrderTypeNew, model.OrderTypeRenew:
+		quote, err = s.quotePeriod(ctx, req)
+	case model.OrderTypeUpgrade, model.OrderTypeDowngrade:
+		quote, err = s.quoteChangePlan(req)
+	default:
+		return nil, errors.New("不支持的订单类型")
+	}
+	if err != nil {
+		return nil, err
+	}
+	quote.Currency = req.Currency
+	quote.ExchangeRate = rate
+
+	// 降配退还差价，不参与优惠和计税
+	if !quote.Amount.IsPositive() {
+		quote.Discount = &DiscountResult{}
+		quote.PayAmount = quote.Amount
+		quote.BasePayAmount = s.toBase(quote.Amount, rate)
+		return quote, nil
+	}
+
+	// 长周期折扣
+	amount := quote.Amount
+	discountAmount := decimal.Zero
+	if periodRate := s.periodDiscountRate(quote.Period); periodRate > 0 {
+		periodDiscount := roundAmount(amount.Mul(decimal.NewFromFloat(periodRate)))
+		if periodDiscount.IsPositive() {
+			quote.Items = append(quote.Items, QuoteItem{
+				Type:   QuoteItemPeriodDiscount,
+				Name:   fmt.Sprintf("购买%d个月折扣", quote.Period),
+				Amount: periodDiscount.Neg(),
+			})
+			discountAmount = discountAmount.Add(periodDiscount)
+		}
+	}
+
+	// 促销活动和优惠券以本位币定义，按长周期折扣后的金额折合本位币计算，再换算回结算币种
+	var product model.Product
+	if err := s.db.Select("id", "provider_id").First(&product, quote.ProductID).Error; err != nil {
+		return nil, fmt.Errorf("获取产品信息失败: %w", err)
+	}
+	discount, err := s.couponService.ApplyDiscounts(s.db, &DiscountRequest{
+		UserID:     req.UserID,
+		ProductID:  product.ID,
+		ProviderID: product.ProviderID,
+		OrderType:  quote.Type,
+		Period:     quote.Period,
+		Amount:     s.toBase(amount.Sub(discountAmount), rate),
+		CouponCode: req.CouponCode,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if promotionDiscount := s.fromBase(discount.PromotionDiscount, rate); promotionDiscount.IsPositive() {
+		quote.Items = append(quote.Items, QuoteItem{
+			Type:   QuoteItemPromotion,
+			Name:   discount.PromotionName,
+			Amount: promotionDiscount.Neg(),
+		})
+		discountAmount = discountAmount.Add(promotionDiscount)
+	}
+	if couponDiscount := s.fromBase(discount.CouponDiscount, rate); couponDiscount.IsPositive() {
+		quote.Items = append(quote.Items, QuoteItem{
+			Type:   QuoteItemCoupon,
+			Name:   fmt.Sprintf("优惠券 %s", discount.CouponCode),
+			Amount: couponDiscount.Neg(),
+		})
+		discountAmount = discountAmount.Add(couponDiscount)
+	}
+	discountAmount = decimal.Min(discountAmount, amount)
+
+	// 税费按优惠后金额计算
+	taxable := amount.Sub(discountAmount)
+	taxAmount := roundAmount(taxable.Mul(decimal.NewFromFloat(s.config.TaxRate)))
+	if taxAmount.IsPositive() {
+		quote.Items = append(quote.Items, QuoteItem{
+			Type:   QuoteItemTax,
+			Name:   fmt.Sprintf("税费(%g%%)", s.config.TaxRate*100),
+			Amount: taxAmount,
+		})
+	}
+
+	quote.Discount = discount
+	quote.DiscountAmount = discountAmount
+	quote.TaxAmount = taxAmount
+	quote.PayAmount = taxable.Add(taxAmount)
+	quote.BasePayAmount = s.toBase(quote.PayAmount, rate)
+	return quote, nil
+}
+
+// toBase 结算币种金额折合本位币
+func (s *PricingService) toBase(amount, rate decimal.Decimal) decimal.Decimal {
+	return roundAmount(amount.Mul(rate))
+}
+
+// fromBase 本位币金额换算为结算币种
+func (s *PricingService) fromBase(amount, rate decimal.Decimal) decimal.Decimal {
+	return roundAmount(amount.Div(rate))
+}
+
+// quotePeriod 新购、续费原价：地域月单价 × 周期 × 数量
+func (s *PricingService) quotePeriod(ctx context.Context, req *QuoteRequest) (*QuoteResponse, error) {
+	if req.Period <= 0 || req.Quantity < 0 {
+		return nil, errors.New("购买周期和数量必须大于0")
+	}
+	if req.Quantity > maxOrderQuantity {
+		return nil, fmt.Errorf("单笔订单最多购买%d台", maxOrderQuantity)
+	}
+
+	var product model.Product
+	if req.Type == model.OrderTypeNew {
+		// 获取产品信息
+		if err := s.db.Preload("Provider").First(&product, req.ProductID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, errors.New("产品不存在")
+			}
+			return nil, fmt.Errorf("获取产品信息失败: %w", err)
+		}
+
+		// 检查产品状态
+		if product.Status != model.ProductStatusOnline {
+			return nil, errors.New("产品已下架")
+		}
+		if product.SoldOut {
+			return nil, errors.New("该产品已售罄")
+		}
+
+		// 校验地域和可用区，按地域售价计价
+		selection, err := s.regionService.ResolveRegion(ctx, &product, req.Region, req.Zone)
+		if err != nil {
+			return nil, err
+		}
+		product.Region = selection.Region
+		product.Price = selection.Price
+		req.Region = selection.Region
+		req.Zone = selection.Zone
+	} else {
+		var server model.Server
+		if err := s.db.Preload("Product.Provider").
+			Where("id = ? AND user_id = ?", req.ServerID, req.UserID).
+			First(&server).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, errors.New("服务器不存在")
+			}
+			return nil, fmt.Errorf("获取服务器信息失败: %w", err)
+		}
+
+		// 检查服务器状态
+		if server.Status == model.ServerStatusCreating || server.Status == model.ServerStatusTerminated {
+			return nil, errors.New("当前服务器状态不允许续费")
+		}
+
+		product = server.Product
+		price, err := regionPrice(s.db, &product, server.Region)
+		if err != nil {
+			return nil, err
+		}
+		product.Region = server.Region
+		product.Price = price
+		req.Quantity = 1
+		req.Region = server.Region
+		req.Zone = ""
+	}
+
+	// 检查云厂商状态
+	if product.Provider.Status != model.ProviderStatusActive {
+		return nil, errors.New("云厂商暂不可用")
+	}
+
+	// 用户所在定价分组有专属价时按专属价计
+	listPrice, err := s.pricingRuleService.UserPrice(req.UserID, &product)
+	if err != nil {
+		return nil, err
+	}
+	unitPrice, err := s.currencyService.Convert(listPrice, product.Currency, req.Currency)
+	if err != nil {
+		return nil, err
+	}
+
+	amount := unitPrice.Mul(decimal.NewFromInt(int64(req.Period * req.Quantity)))
+	return &QuoteResponse{
+		Type:       req.Type,
+		ProductID:  product.ID,
+		ProviderID: product.ProviderID,
+		ServerID:   req.ServerID,
+		Region:     req.Region,
+		Zone:       req.Zone,
+		Period:     req.Period,
+		Quantity:   req.Quantity,
+		UnitPrice:  unitPrice,
+		Amount:     amount,
+		Items: []QuoteItem{{
+			Type:   QuoteItemBase,
+			Name:   fmt.Sprintf("%s × %d个月 × %d台", product.Name, req.Period, req.Quantity),
+			Amount: amount,
+		}},
+	}, nil
+}
+
+// quoteChangePlan 升降配差价：按剩余天数折算新旧套餐月单价之差（每月按30天计）
+func (s *PricingService) quoteChangePlan(req *QuoteRequest) (*QuoteResponse, error) {
+	// 获取服务器信息
+	var server model.Server
+	if err := s.db.Preload("Product").
+		Where("id = ? AND user_id = ?", req.ServerID, req.UserID).
+		First(&server).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("服务器不存在")
+		}
+		return nil, fmt.Errorf("获取服务器信息失败: %w", err)
+	}
+
+	// 检查服务器状态
+	if server.Status != model.ServerStatusRunning && server.Status != model.ServerStatusStopped {
+		return nil, errors.New("当前服务器状态不允许变更套餐")
+	}
+	if server.ProductID == req.ProductID {
+		return nil, errors.New("目标套餐与当前套餐相同")
+	}
+
+	remaining := time.Until(server.ExpireTime)
+	if remaining <= 0 {
+		return nil, errors.New("服务器已到期，请先续费")
+	}
+
+	// 获取目标产品信息
+	var product model.Product
+	if err := s.db.Preload("Provider").First(&product, req.ProductID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("产品不存在")
+		}
+		return nil, fmt.Errorf("获取产品信息失败: %w", err)
+	}
+
+	// 检查产品状态
+	if product.Status != model.ProductStatusOnline {
+		return nil, errors.New("产品已下架")
+	}
+	if product.Provider.Status != model.ProviderStatusActive {
+		return nil, errors.New("云厂商暂不可用")
+	}
+
+	// 只允许在同一厂商、同一产品类型之间变更
+	if product.ProviderID != server.ProviderID || product.Type != server.Product.Type {
+		return nil, errors.New("只能变更为同一厂商同类型的套餐")
+	}
+
+	// 服务器不能迁移地域，目标套餐须在服务器所在地域销售，新旧套餐均按该地域售价计
+	offer, err := productRegion(s.db, &product, server.Region)
+	if err != nil {
+		return nil, err
+	}
+	product.Price = offerPrice(&product, offer)
+	product.Region = server.Region
+	oldProduct := server.Product
+	if oldProduct.Price, err = regionPrice(s.db, &oldProduct, server.Region); err != nil {
+		return nil, err
+	}
+	oldProduct.Region = server.Region
+
+	newListPrice, err := s.pricingRuleService.UserPrice(req.UserID, &product)
+	if err != nil {
+		return nil, err
+	}
+	oldListPrice, err := s.pricingRuleService.UserPrice(req.UserID, &oldProduct)
+	if err != nil {
+		return nil, err
+	}
+	newPrice, err := s.currencyService.Convert(newListPrice, product.Currency, req.Currency)
+	if err != nil {
+		return nil, err
+	}
+	oldPrice, err := s.currencyService.Convert(oldListPrice, server.Product.Currency, req.Currency)
+	if err != nil {
+		return nil, err
+	}
+
+	remainingDays := int(math.Ceil(remaining.Hours() / 24))
+	amount := roundAmount(newPrice.Sub(oldPrice).Mul(decimal.NewFromInt(int64(remainingDays))).Div(decimal.NewFromInt(30)))
+
+	orderType := model.OrderTypeUpgrade
+	if amount.IsNegative() {
+		orderType = model.OrderTypeDowngrade
+	}
+
+	return &QuoteResponse{
+		Type:          orderType,
+		ProductID:     product.ID,
+		ProviderID:    product.ProviderID,
+		ServerID:      server.ID,
+		FromProductID: server.ProductID,
+		Region:        server.Region,
+		Quantity:      1,
+		RemainingDays: remainingDays,
+		UnitPrice:     newPrice,
+		Amount:        amount,
+		Items: []QuoteItem{{
+			Type:   QuoteItemBase,
+			Name:   fmt.Sprintf("%s → %s 剩余%d天差价", server.Product.Name, product.Name, remainingDays),
+			Amount: amount,
+		}},
+	}, nil
+}
+
+// periodDiscountRate 取购买周期适用的最高一档长周期折扣
+func (s *PricingService) periodDiscountRate(period int) float64 {
+	tiers := make([]config.PeriodDiscount, len(s.config.PeriodDiscounts))
+	copy(tiers, s.config.PeriodDiscounts)
+	sort.Slice(tiers, func(i, j int) bool {
+		return tiers[i].MinPeriod > tiers[j].MinPeriod
+	})
+
+	for _, tier := range tiers {
+		if period >= tier.MinPeriod && tier.Rate > 0 && tier.Rate < 1 {
+			return tier.Rate
+		}
+	}
+	return 0
+}
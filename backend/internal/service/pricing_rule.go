@@ -0,0 +1,441 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"cloudbp-backend/internal/model"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+// PricingRuleService 定价规则服务：按规则在成本价上加价计算标准售价和用户分组专属价，
+// 标准售价经预览确认后发布到产品
+type PricingRuleService struct {
+	db                *gorm.DB
+	rdb               *redis.Client
+	defaultMarkupRate float64
+}
+
+// NewPricingRuleService 创建定价规则服务，defaultMarkupRate 为未匹配任何标准售价规则时的加价比例
+func NewPricingRuleService(db *gorm.DB, rdb *redis.Client, defaultMarkupRate float64) *PricingRuleService {
+	return &PricingRuleService{
+		db:                db,
+		rdb:               rdb,
+		defaultMarkupRate: defaultMarkupRate,
+	}
+}
+
+// PriceRules 某个用户分组的启用规则，已按优先级排序
+type PriceRules struct {
+	userGroup         string
+	rules             []model.PricingRule
+	defaultMarkupRate float64
+}
+
+// LoadRules 加载用户分组的启用规则，userGroup 为空时加载标准售价规则
+func (s *PricingRuleService) LoadRules(userGroup string) (*PriceRules, error) {
+	var rules []model.PricingRule
+	if err := s.db.Where("status = ? AND user_group = ?", model.PricingRuleStatusActive, userGroup).
+		Order("id").Find(&rules).Error; err != nil {
+		return nil, fmt.Errorf("获取定价规则失败: %w", err)
+	}
+
+	// 优先级高的在前，优先级相同时条件越具体越优先
+	sort.SliceStable(rules, func(i, j int) bool {
+		if rules[i].Priority != rules[j].Priority {
+			return rules[i].Priority > rules[j].Priority
+		}
+		return ruleSpecificity(&rules[i]) > ruleSpecificity(&rules[j])
+	})
+
+	return &PriceRules{
+		userGroup:         userGroup,
+		rules:             rules,
+		defaultMarkupRate: s.defaultMarkupRate,
+	}, nil
+}
+
+// Evaluate 按规则计算产品售价，返回命中的规则；产品未设置成本价，或分组规则均不适用时 ok 为false，
+// 此时沿用产品标准售价。标准售价未命中规则时按默认加价比例计算
+func (r *PriceRules) Evaluate(product *model.Product) (price decimal.Decimal, rule *model.PricingRule, ok bool) {
+	if !product.CostPrice.IsPositive() {
+		return decimal.Zero, nil, false
+	}
+
+	for i := range r.rules {
+		if ruleMatches(&r.rules[i], product) {
+			rule = &r.rules[i]
+			return applyPricingRule(rule, product.CostPrice), rule, true
+		}
+	}
+
+	if r.userGroup != "" {
+		return decimal.Zero, nil, false
+	}
+	return product.CostPrice.Mul(decimal.NewFromFloat(1 + r.defaultMarkupRate)).Round(2), nil, true
+}
+
+// UserPrice 计算用户购买产品的月单价，用户所在分组有适用规则时使用专属价，否则使用标准售价
+func (s *PricingRuleService) UserPrice(userID uint, product *model.Product) (decimal.Decimal, error) {
+	var user model.User
+	if err := s.db.Select("id", "price_group").First(&user, userID).Error; err != nil {
+		return decimal.Zero, fmt.Errorf("获取用户信息失败: %w", err)
+	}
+	if user.PriceGroup == "" {
+		return product.Price, nil
+	}
+
+	rules, err := s.LoadRules(user.PriceGroup)
+	if err != nil {
+		return decimal.Zero, err
+	}
+	if price, _, ok := rules.Evaluate(product); ok {
+		return price, nil
+	}
+	return product.Price, nil
+}
+
+// ruleMatches 规则条件是否适用于产品
+func ruleMatches(rule *model.PricingRule, product *model.Product) bool {
+	if rule.ProviderID != nil && *rule.ProviderID != product.ProviderID {
+		return false
+	}
+	if rule.ProductType != "" && rule.ProductType != product.Type {
+		return false
+	}
+	if rule.Region != "" && rule.Region != product.Region {
+		return false
+	}
+	return true
+}
+
+// ruleSpecificity 规则限定的条件数
+func ruleSpecificity(rule *model.PricingRule) int {
+	n := 0
+	if rule.ProviderID != nil {
+		n++
+	}
+	if rule.ProductType != "" {
+		n++
+	}
+	if rule.Region != "" {
+		n++
+	}
+	return n
+}
+
+// applyPricingRule 在成本价上加价并按规则取整
+func applyPricingRule(rule *model.PricingRule, cost decimal.Decimal) decimal.Decimal {
+	price := cost
+	markup := decimal.NewFromFloat(rule.MarkupValue)
+	switch rule.MarkupType {
+	case model.MarkupTypePercent:
+		price = price.Mul(markup.Add(decimal.NewFromInt(100))).Div(decimal.NewFromInt(100))
+	case model.MarkupTypeFixed:
+		price = price.Add(markup)
+	}
+	return roundPrice(price, rule.Rounding, rule.RoundingUnit, rule.PriceEnding)
+}
+
+// roundPrice 按取整单位取整后减去尾数，结果不低于0.01
+func roundPrice(price decimal.Decimal, rounding string, unit, ending float64) decimal.Decimal {
+	if unit > 0 {
+		// 先保留两位小数再取整，避免除不尽的尾数导致多进一个单位
+		step := decimal.NewFromFloat(unit)
+		units := price.Div(step).Round(2)
+		switch rounding {
+		case model.PriceRoundingUp:
+			price = units.Ceil().Mul(step)
+		case model.PriceRoundingDown:
+			price = units.Floor().Mul(step)
+		case model.PriceRoundingNearest:
+			price = units.Round(0).Mul(step)
+		}
+	}
+	price = roundAmount(price.Sub(decimal.NewFromFloat(ending)))
+	return decimal.Max(price, decimal.New(1, -2))
+}
+
+// SavePricingRuleRequest 创建或更新定价规则请求
+type SavePricingRuleRequest struct {
+	Name         string  `json:"name" binding:"required"`
+	Priority     int     `json:"priority"`
+	ProviderID   *uint   `json:"provider_id"`
+	ProductType  string  `json:"product_type"`
+	Region       string  `json:"region"`
+	UserGroup    string  `json:"user_group"`
+	MarkupType   string  `json:"markup_type" binding:"required,oneof=percent fixed"`
+	MarkupValue  float64 `json:"markup_value"`
+	Rounding     string  `json:"rounding"`
+	RoundingUnit float64 `json:"rounding_unit"`
+	PriceEnding  float64 `json:"price_ending"`
+	Status       int     `json:"status"`
+	Description  string  `json:"description"`
+}
+
+// GetPricingRules 获取定价规则列表，userGroup 为nil时返回全部分组
+func (s *PricingRuleService) GetPricingRules(ctx context.Context, userGroup *string, status int) ([]model.PricingRule, error) {
+	var rules []model.PricingRule
+	query := s.db.Model(&model.PricingRule{})
+	if userGroup != nil {
+		query = query.Where("user_group = ?", *userGroup)
+	}
+	if status != 0 {
+		query = query.Where("status = ?", status)
+	}
+	if err := query.Order("user_group, priority DESC, id").Find(&rules).Error; err != nil {
+		return nil, fmt.Errorf("获取定价规则失败: %w", err)
+	}
+	return rules, nil
+}
+
+// CreatePricingRule 创建定价规则
+func (s *PricingRuleService) CreatePricingRule(ctx context.Context, req *SavePricingRuleRequest) (*model.PricingRule, error) {
+	rule := model.PricingRule{}
+	if err := applyPricingRuleRequest(&rule, req); err != nil {
+		return nil, err
+	}
+
+	if err := s.db.Create(&rule).Error; err != nil {
+		return nil, fmt.Errorf("创建定价规则失败: %w", err)
+	}
+	return &rule, nil
+}
+
+// UpdatePricingRule 更新定价规则，已发布的标准售价需重新预览发布后才会变更
+func (s *PricingRuleService) UpdatePricingRule(ctx context.Context, id uint, req *SavePricingRuleRequest) (*model.PricingRule, error) {
+	var rule model.PricingRule
+	if err := s.db.First(&rule, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("定价规则不存在")
+		}
+		return nil, fmt.Errorf("获取定价规则失败: %w", err)
+	}
+
+	if err := applyPricingRuleRequest(&rule, req); err != nil {
+		return nil, err
+	}
+
+	if err := s.db.Model(&rule).Select("*").Omit("id", "created_at").Updates(&rule).Error; err != nil {
+		return nil, fmt.Errorf("更新定价规则失败: %w", err)
+	}
+	return &rule, nil
+}
+
+// DeletePricingRule 删除定价规则
+func (s *PricingRuleService) DeletePricingRule(ctx context.Context, id uint) error {
+	result := s.db.Delete(&model.PricingRule{}, id)
+	if result.Error != nil {
+		return fmt.Errorf("删除定价规则失败: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("定价规则不存在")
+	}
+	return nil
+}
+
+// applyPricingRuleRequest 校验并写入定价规则字段
+func applyPricingRuleRequest(rule *model.PricingRule, req *SavePricingRuleRequest) error {
+	if req.MarkupType == model.MarkupTypePercent && req.MarkupValue <= -100 {
+		return errors.New("加价百分比不能小于等于-100")
+	}
+	if req.Rounding == "" {
+		req.Rounding = model.PriceRoundingNone
+	}
+	switch req.Rounding {
+	case model.PriceRoundingNone, model.PriceRoundingUp, model.PriceRoundingDown, model.PriceRoundingNearest:
+	default:
+		return errors.New("不支持的取整方式")
+	}
+	if req.Rounding != model.PriceRoundingNone && req.RoundingUnit <= 0 {
+		return errors.New("取整单位必须大于0")
+	}
+	if req.PriceEnding < 0 || (req.RoundingUnit > 0 && req.PriceEnding >= req.RoundingUnit) {
+		return errors.New("尾数必须大于等于0且小于取整单位")
+	}
+
+	rule.Name = req.Name
+	rule.Priority = req.Priority
+	rule.ProviderID = req.ProviderID
+	rule.ProductType = strings.TrimSpace(req.ProductType)
+	rule.Region = strings.TrimSpace(req.Region)
+	rule.UserGroup = strings.TrimSpace(req.UserGroup)
+	rule.MarkupType = req.MarkupType
+	rule.MarkupValue = req.MarkupValue
+	rule.Rounding = req.Rounding
+	rule.RoundingUnit = req.RoundingUnit
+	rule.PriceEnding = req.PriceEnding
+	rule.Status = req.Status
+	rule.Description = req.Description
+	if rule.Status == 0 {
+		rule.Status = model.PricingRuleStatusActive
+	}
+	return nil
+}
+
+// PreviewPricesRequest 价格表预览请求
+type PreviewPricesRequest struct {
+	ProviderID uint   `json:"provider_id"`
+	Type       string `json:"type"`
+	Region     string `json:"region"`
+	Status     int    `json:"status"`     // 产品状态，0表示上架和待上架
+	UserGroup  string `json:"user_group"` // 为空预览标准售价，否则预览该分组的专属价
+}
+
+// PriceListItem 价格表条目
+type PriceListItem struct {
+	ProductID    uint            `json:"product_id"`
+	Name         string          `json:"name"`
+	Code         string          `json:"code"`
+	ProviderID   uint            `json:"provider_id"`
+	Type         string          `json:"type"`
+	Region       string          `json:"region"`
+	Status       int             `json:"status"`
+	Currency     string          `json:"currency"`
+	CostPrice    decimal.Decimal `json:"cost_price"`
+	CurrentPrice decimal.Decimal `json:"current_price"` // 当前标准售价
+	NewPrice     decimal.Decimal `json:"new_price"`     // 按规则计算的价格，未设置成本价时与当前售价相同
+	Margin       decimal.Decimal `json:"margin"`        // 新价格的毛利
+	RuleID       *uint           `json:"rule_id"`       // 命中的规则，为空表示默认加价或沿用当前售价
+	RuleName     string          `json:"rule_name"`
+	Changed      bool            `json:"changed"` // 新价格与当前售价不同
+}
+
+// PreviewPrices 按当前规则计算价格表，不修改产品
+func (s *PricingRuleService) PreviewPrices(ctx context.Context, req *PreviewPricesRequest) ([]PriceListItem, error) {
+	query := s.db.WithContext(ctx).Model(&model.Product{}).Where("removed_at IS NULL")
+	if req.ProviderID != 0 {
+		query = query.Where("provider_id = ?", req.ProviderID)
+	}
+	if req.Type != "" {
+		query = query.Where("type = ?", req.Type)
+	}
+	if req.Region != "" {
+		query = query.Where("region = ?", req.Region)
+	}
+	if req.Status != 0 {
+		query = query.Where("status = ?", req.Status)
+	} else {
+		query = query.Where("status IN ?", []int{model.ProductStatusOnline, model.ProductStatusDraft})
+	}
+
+	var products []model.Product
+	if err := query.Order("provider_id, region, price").Find(&products).Error; err != nil {
+		return nil, fmt.Errorf("获取产品列表失败: %w", err)
+	}
+
+	rules, err := s.LoadRules(strings.TrimSpace(req.UserGroup))
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]PriceListItem, 0, len(products))
+	for i := range products {
+		items = append(items, priceListItem(rules, &products[i]))
+	}
+	return items, nil
+}
+
+// priceListItem 计算单个产品的价格表条目
+func priceListItem(rules *PriceRules, product *model.Product) PriceListItem {
+	item := PriceListItem{
+		ProductID:    product.ID,
+		Name:         product.Name,
+		Code:         product.Code,
+		ProviderID:   product.ProviderID,
+		Type:         product.Type,
+		Region:       product.Region,
+		Status:       product.Status,
+		Currency:     product.Currency,
+		CostPrice:    product.CostPrice,
+		CurrentPrice: product.Price,
+		NewPrice:     product.Price,
+	}
+
+	price, rule, ok := rules.Evaluate(product)
+	if ok {
+		item.NewPrice = price
+		if rule != nil {
+			item.RuleID = &rule.ID
+			item.RuleName = rule.Name
+		} else {
+			item.RuleName = "默认加价"
+		}
+	}
+	if product.CostPrice.IsPositive() {
+		item.Margin = item.NewPrice.Sub(product.CostPrice)
+	}
+	item.Changed = !item.NewPrice.Equal(item.CurrentPrice)
+	return item
+}
+
+// PublishPricesRequest 发布价格请求
+type PublishPricesRequest struct {
+	ProductIDs []uint `json:"product_ids" binding:"required,min=1"`
+	Online     bool   `json:"online"` // 同时上架其中的待上架产品
+}
+
+// PublishPricesResult 发布价格结果
+type PublishPricesResult struct {
+	Updated int `json:"updated"` // 售价变更的产品数
+	Onlined int `json:"onlined"` // 上架的产品数
+}
+
+// PublishPrices 按当前标准售价规则更新产品售价，可同时上架待上架产品；
+// 厂商已下线的规格不会上架
+func (s *PricingRuleService) PublishPrices(ctx context.Context, req *PublishPricesRequest) (*PublishPricesResult, error) {
+	rules, err := s.LoadRules("")
+	if err != nil {
+		return nil, err
+	}
+
+	result := &PublishPricesResult{}
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		var products []model.Product
+		if err := tx.Where("id IN ?", req.ProductIDs).Find(&products).Error; err != nil {
+			return fmt.Errorf("获取产品列表失败: %w", err)
+		}
+
+		for i := range products {
+			product := &products[i]
+			updates := map[string]interface{}{}
+			if price, _, ok := rules.Evaluate(product); ok && !price.Equal(product.Price) {
+				updates["price"] = price
+				result.Updated++
+			}
+			if req.Online && product.Status == model.ProductStatusDraft && product.RemovedAt == nil {
+				updates["status"] = model.ProductStatusOnline
+				result.Onlined++
+			}
+			if len(updates) == 0 {
+				continue
+			}
+			if err := tx.Model(product).Updates(updates).Error; err != nil {
+				return fmt.Errorf("更新产品售价失败: %w", err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// SetUserPriceGroup 设置用户定价分组，空字符串表示恢复标准售价
+func (s *PricingRuleService) SetUserPriceGroup(ctx context.Context, userID uint, group string) error {
+	result := s.db.Model(&model.User{}).Where("id = ?", userID).Update("price_group", strings.TrimSpace(group))
+	if result.Error != nil {
+		return fmt.Errorf("更新用户定价分组失败: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("用户不存在")
+	}
+	return nil
+}
@@ -73,6 +73,27 @@ func (s *ProviderService) GetProvider(code string) (provider.CloudProvider, erro
 	return p, nil
 }
 
+// GetCapabilities 获取厂商支持的可选能力，前端据此隐藏不支持的操作
+func (s *ProviderService) GetCapabilities(code string) (*provider.Capabilities, error) {
+	cloudProvider, err := s.GetProvider(code)
+	if err != nil {
+		return nil, err
+	}
+	return provider.GetCapabilities(cloudProvider), nil
+}
+
+// ServerCapabilities 获取服务器所属厂商支持的可选能力
+func (s *ProviderService) ServerCapabilities(serverID uint) (*provider.Capabilities, error) {
+	var server model.Server
+	if err := s.db.Preload("Provider").First(&server, serverID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("服务器不存在")
+		}
+		return nil, err
+	}
+	return s.GetCapabilities(server.Provider.Code)
+}
+
 // CreateInstance 创建实例
 func (s *ProviderService) CreateInstance(ctx context.Context, req *CreateInstanceRequest) (*CreateInstanceResponse, error) {
 	// 获取厂商信息
@@ -99,17 +120,35 @@ func (s *ProviderService) CreateInstance(ctx context.Context, req *CreateInstanc
 		return nil, err
 	}
 
+	region, zone := req.Region, req.Zone
+	if region == "" {
+		region, zone = product.Region, product.Zone
+	}
+
+	// 准备公钥注入
+	keys, err := prepareLoginKeys(ctx, s.db, cloudProvider, providerModel.ID, region, req.SSHKeyIDs, req.DisablePassword)
+	if err != nil {
+		return nil, err
+	}
+	userData, err := loginUserData(req.UserData, keys)
+	if err != nil {
+		return nil, err
+	}
+
 	// 调用厂商API创建实例
 	createReq := &provider.CreateInstanceRequest{
-		Name:         req.Name,
-		Region:       product.Region,
-		Zone:         product.Zone,
-		ImageID:      req.ImageID,
-		InstanceType: product.Code,
-		Password:     req.Password,
-		Period:       req.Period,
-		AutoRenew:    req.AutoRenew,
-		UserData:     req.UserData,
+		Name:                 req.Name,
+		Region:               region,
+		Zone:                 zone,
+		ImageID:              req.ImageID,
+		InstanceType:         product.Code,
+		Password:             req.Password,
+		Period:               req.Period,
+		AutoRenew:            req.AutoRenew,
+		UserData:             userData,
+		KeyIDs:               keys.KeyIDs,
+		DisablePasswordLogin: req.DisablePassword,
+		ClientToken:          req.ClientToken,
 	}
 
 	resp, err := cloudProvider.CreateInstance(ctx, createReq)
@@ -196,6 +235,118 @@ func (s *ProviderService) operateInstance(ctx context.Context, serverID uint, op
 	}
 }
 
+// RebuildInstance 重装实例系统，按厂商能力注入公钥
+func (s *ProviderService) RebuildInstance(ctx context.Context, req *RebuildInstanceRequest) error {
+	// 获取服务器信息
+	var server model.Server
+	if err := s.db.Preload("Provider").First(&server, req.ServerID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.New("服务器不存在")
+		}
+		return err
+	}
+
+	// 获取厂商适配器
+	cloudProvider, err := s.GetProvider(server.Provider.Code)
+	if err != nil {
+		return err
+	}
+
+	// 准备公钥注入
+	keys, err := prepareLoginKeys(ctx, s.db, cloudProvider, server.ProviderID, server.Region, req.SSHKeyIDs, req.DisablePassword)
+	if err != nil {
+		return err
+	}
+	userData, err := loginUserData(req.UserData, keys)
+	if err != nil {
+		return err
+	}
+
+	// 调用厂商API重装系统
+	rebuildReq := &provider.RebuildInstanceRequest{
+		InstanceID:           server.InstanceID,
+		ImageID:              req.ImageID,
+		Password:             req.Password,
+		UserData:             userData,
+		KeyIDs:               keys.KeyIDs,
+		DisablePasswordLogin: req.DisablePassword,
+	}
+
+	if err := cloudProvider.RebuildInstance(ctx, rebuildReq); err != nil {
+		return fmt.Errorf("重装实例系统失败: %w", err)
+	}
+
+	return nil
+}
+
+// ResizeInstance 变更实例规格
+func (s *ProviderService) ResizeInstance(ctx context.Context, req *ResizeInstanceRequest) error {
+	// 获取服务器信息
+	var server model.Server
+	if err := s.db.Preload("Provider").First(&server, req.ServerID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.New("服务器不存在")
+		}
+		return err
+	}
+
+	// 获取厂商适配器
+	cloudProvider, err := s.GetProvider(server.Provider.Code)
+	if err != nil {
+		return err
+	}
+
+	resizer, ok := cloudProvider.(provider.Resizer)
+	if !ok {
+		return errors.New("该厂商不支持变更配置")
+	}
+
+	// 调用厂商API变更规格
+	resizeReq := &provider.ResizeInstanceRequest{
+		InstanceID:   server.InstanceID,
+		InstanceType: req.InstanceType,
+	}
+
+	if err := resizer.ResizeInstance(ctx, resizeReq); err != nil {
+		return fmt.Errorf("变更实例规格失败: %w", err)
+	}
+
+	return nil
+}
+
+// RenewInstance 续费厂商实例，厂商未实现续费能力时实例按量计费，无需同步
+func (s *ProviderService) RenewInstance(ctx context.Context, req *RenewInstanceRequest) error {
+	// 获取服务器信息
+	var server model.Server
+	if err := s.db.Preload("Provider").First(&server, req.ServerID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.New("服务器不存在")
+		}
+		return err
+	}
+
+	// 获取厂商适配器
+	cloudProvider, err := s.GetProvider(server.Provider.Code)
+	if err != nil {
+		return err
+	}
+
+	renewer, ok := cloudProvider.(provider.Renewer)
+	if !ok {
+		return nil
+	}
+
+	if err := renewer.RenewInstance(ctx, &provider.RenewInstanceRequest{
+		InstanceID:  server.InstanceID,
+		Period:      req.Period,
+		ClientToken: req.ClientToken,
+	}); err != nil {
+		return fmt.Errorf("续费实例失败: %w", err)
+	}
+
+	return nil
+}
+
 // GetInstanceDetail 获取实例详情
 func (s *ProviderService) GetInstanceDetail(ctx context.Context, req *GetInstanceDetailRequest) (*GetInstanceDetailResponse, error) {
 	// 获取服务器信息
@@ -352,14 +503,19 @@ func (s *ProviderService) GetInstanceTypes(ctx context.Context, req *GetInstance
 
 // 请求和响应结构体
 type CreateInstanceRequest struct {
-	ProviderID uint   `json:"provider_id"`
-	ProductID  uint   `json:"product_id"`
-	Name       string `json:"name"`
-	ImageID    string `json:"image_id"`
-	Password   string `json:"password"`
-	Period     int    `json:"period"`
-	AutoRenew  bool   `json:"auto_renew"`
-	UserData   string `json:"user_data"`
+	ProviderID      uint   `json:"provider_id"`
+	ProductID       uint   `json:"product_id"`
+	Name            string `json:"name"`
+	Region          string `json:"region"` // 为空时使用产品所在地域
+	Zone            string `json:"zone"`
+	ImageID         string `json:"image_id"`
+	Password        string `json:"password"`
+	Period          int    `json:"period"`
+	AutoRenew       bool   `json:"auto_renew"`
+	UserData        string `json:"user_data"`
+	SSHKeyIDs       []uint `json:"ssh_key_ids"`      // 注入的公钥
+	DisablePassword bool   `json:"disable_password"` // 禁用密码登录
+	ClientToken     string `json:"client_token"`     // 厂商幂等令牌
 }
 
 type CreateInstanceResponse struct {
@@ -383,6 +539,26 @@ type RestartInstanceRequest struct {
 	ServerID uint `json:"server_id"`
 }
 
+type RebuildInstanceRequest struct {
+	ServerID        uint   `json:"server_id"`
+	ImageID         string `json:"image_id"`
+	Password        string `json:"password"`
+	UserData        string `json:"user_data"`
+	SSHKeyIDs       []uint `json:"ssh_key_ids"`
+	DisablePassword bool   `json:"disable_password"`
+}
+
+type ResizeInstanceRequest struct {
+	ServerID     uint   `json:"server_id"`
+	InstanceType string `json:"instance_type"`
+}
+
+type RenewInstanceRequest struct {
+	ServerID    uint   `json:"server_id"`
+	Period      int    `json:"period"`
+	ClientToken string `json:"client_token"` // 厂商幂等令牌
+}
+
 type GetInstanceDetailRequest struct {
 	ServerID uint `json:"server_id"`
 }
@@ -0,0 +1,372 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"cloudbp-backend/internal/model"
+	"cloudbp-backend/pkg/logger"
+
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// provisionConcurrency 单个订单同时向云厂商创建实例的数量
+	provisionConcurrency = 5
+	// provisionStaleDuration 开通中的订单超过该时长没有心跳视为进程中断，由后台任务重新接管
+	provisionStaleDuration = 10 * time.Minute
+	// provisionHeartbeatInterval 开通过程中刷新订单更新时间和租约锁的间隔
+	provisionHeartbeatInterval = time.Minute
+)
+
+// releaseLeaseScript 仅当租约仍属于自己时释放
+var releaseLeaseScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0`)
+
+// renewLeaseScript 仅当租约仍属于自己时续期
+var renewLeaseScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0`)
+
+// ProvisionService 服务器开通服务，已支付的新购订单在此向云厂商并行创建实例，
+// 开通失败的服务器按台数退款；已支付的续费订单在此续费厂商实例并顺延到期时间
+type ProvisionService struct {
+	db              *gorm.DB
+	rdb             *redis.Client
+	providerService *ProviderService
+	refundService   *RefundService
+}
+
+// NewProvisionService 创建开通服务
+func NewProvisionService(db *gorm.DB, rdb *redis.Client, providerService *ProviderService, refundService *RefundService) *ProvisionService {
+	return &ProvisionService{
+		db:              db,
+		rdb:             rdb,
+		providerService: providerService,
+		refundService:   refundService,
+	}
+}
+
+// ProvisionPaidOrders 开通所有已支付的新购和续费订单，并接管中断的开通任务；
+// 第三方支付回调到账的订单由该后台任务开通
+func (s *ProvisionService) ProvisionPaidOrders(ctx context.Context) error {
+	var orderIDs []uint
+	if err := s.db.WithContext(ctx).Model(&model.Order{}).
+		Where("type IN ?", []string{model.OrderTypeNew, model.OrderTypeRenew}).
+		Where("status = ? OR (status = ? AND updated_at < ?)",
+			model.OrderStatusPaid, model.OrderStatusProcessing, time.Now().Add(-provisionStaleDuration)).
+		Order("id").
+		Pluck("id", &orderIDs).Error; err != nil {
+		return fmt.Errorf("获取待开通订单失败: %w", err)
+	}
+
+	for _, orderID := range orderIDs {
+		if err := s.ProvisionOrder(ctx, orderID); err != nil {
+			logger.Log.Error("开通订单失败", zap.Uint("order_id", orderID), zap.Error(err))
+		}
+	}
+	return nil
+}
+
+// ProvisionOrder 开通订单下所有待创建的服务器，全部成功订单完成，部分失败按失败台数退款，
+// 全部失败订单标记为失败并全额退款；续费订单续费厂商实例
+func (s *ProvisionService) ProvisionOrder(ctx context.Context, orderID uint) error {
+	// 持有订单租约的实例才能开通，其他实例即使看到订单超时也不会接管
+	leaseKey := fmt.Sprintf("provision:order:%d", orderID)
+	leaseToken := generateNo("LEASE")
+	if s.rdb != nil {
+		acquired, err := s.rdb.SetNX(ctx, leaseKey, leaseToken, provisionStaleDuration).Result()
+		if err != nil {
+			return fmt.Errorf("获取开通租约失败: %w", err)
+		}
+		if !acquired {
+			return nil
+		}
+		defer func() {
+			if err := releaseLeaseScript.Run(context.WithoutCancel(ctx), s.rdb, []string{leaseKey}, leaseToken).Err(); err != nil {
+				logger.Log.Error("释放开通租约失败", zap.Uint("order_id", orderID), zap.Error(err))
+			}
+		}()
+	}
+
+	// 条件更新抢占订单，避免多个实例重复开通
+	result := s.db.Model(&model.Order{}).
+		Where("id = ? AND type IN ?", orderID, []string{model.OrderTypeNew, model.OrderTypeRenew}).
+		Where("status = ? OR (status = ? AND updated_at < ?)",
+			model.OrderStatusPaid, model.OrderStatusProcessing, time.Now().Add(-provisionStaleDuration)).
+		Updates(map[string]interface{}{
+			"status":     model.OrderStatusProcessing,
+			"updated_at": time.Now(),
+		})
+	if result.Error != nil {
+		return fmt.Errorf("更新订单状态失败: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil
+	}
+
+	var order model.Order
+	if err := s.db.First(&order, orderID).Error; err != nil {
+		return fmt.Errorf("获取订单失败: %w", err)
+	}
+
+	var cfg orderConfig
+	if err := json.Unmarshal([]byte(order.Config), &cfg); err != nil {
+		return fmt.Errorf("解析订单配置失败: %w", err)
+	}
+
+	// 开通期间定时心跳，慢速开通不会被误判为中断
+	stop := make(chan struct{})
+	defer close(stop)
+	go s.heartbeat(ctx, order.ID, leaseKey, leaseToken, stop)
+
+	if order.Type == model.OrderTypeRenew {
+		return s.renewOrder(ctx, &order, &cfg)
+	}
+
+	// 只开通仍在创建中的服务器，中断后重新接管时不会重复创建已开通的实例
+	var servers []model.Server
+	if err := s.db.Where("order_id = ? AND status = ?", order.ID, model.ServerStatusCreating).
+		Order("id").Find(&servers).Error; err != nil {
+		return fmt.Errorf("获取待开通服务器失败: %w", err)
+	}
+
+	// 并行开通，开通结果直接写回各服务器记录
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, provisionConcurrency)
+	for i := range servers {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(server *model.Server) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			if err := s.provisionServer(ctx, &order, &cfg, server); err != nil {
+				logger.Log.Error("开通服务器失败", zap.String("order_no", order.OrderNo),
+					zap.Uint("server_id", server.ID), zap.Error(err))
+			}
+		}(&servers[i])
+	}
+	wg.Wait()
+
+	return s.finishOrder(ctx, &order)
+}
+
+// heartbeat 定时刷新开通中订单的更新时间并续期租约，直到开通结束
+func (s *ProvisionService) heartbeat(ctx context.Context, orderID uint, leaseKey, leaseToken string, stop <-chan struct{}) {
+	ticker := time.NewTicker(provisionHeartbeatInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+
+		if err := s.db.Model(&model.Order{}).
+			Where("id = ? AND status = ?", orderID, model.OrderStatusProcessing).
+			Update("updated_at", time.Now()).Error; err != nil {
+			logger.Log.Error("刷新开通心跳失败", zap.Uint("order_id", orderID), zap.Error(err))
+		}
+
+		if s.rdb == nil {
+			continue
+		}
+		renewed, err := renewLeaseScript.Run(context.WithoutCancel(ctx), s.rdb, []string{leaseKey},
+			leaseToken, provisionStaleDuration.Milliseconds()).Int()
+		if err != nil {
+			logger.Log.Error("续期开通租约失败", zap.Uint("order_id", orderID), zap.Error(err))
+		} else if renewed == 0 {
+			logger.Log.Warn("开通租约已丢失", zap.Uint("order_id", orderID))
+		}
+	}
+}
+
+// provisionServer 向云厂商创建单台服务器的实例
+func (s *ProvisionService) provisionServer(ctx context.Context, order *model.Order, cfg *orderConfig, server *model.Server) error {
+	// 创建前重新确认服务器仍待开通，已开通或已取消的不再创建
+	var current model.Server
+	if err := s.db.Select("id", "status", "instance_id").First(&current, server.ID).Error; err != nil {
+		return fmt.Errorf("获取服务器信息失败: %w", err)
+	}
+	if current.Status != model.ServerStatusCreating || !strings.HasPrefix(current.InstanceID, "pending-") {
+		return nil
+	}
+
+	resp, err := s.providerService.CreateInstance(ctx, &CreateInstanceRequest{
+		ProviderID:      server.ProviderID,
+		ProductID:       server.ProductID,
+		Name:            server.Name,
+		Region:          server.Region,
+		Zone:            server.Zone,
+		ImageID:         cfg.ImageID,
+		Password:        cfg.Password,
+		SSHKeyIDs:       cfg.SSHKeyIDs,
+		DisablePassword: cfg.DisablePassword,
+		UserData:        renderServerUserData(cfg.UserData, server),
+		Period:          order.Period,
+		AutoRenew:       cfg.AutoRenew,
+		// 以占位实例ID作为幂等令牌，中断后重新接管时厂商返回上次已创建的实例
+		ClientToken: current.InstanceID,
+	})
+	if err != nil {
+		if updateErr := s.db.Model(server).Update("status", model.ServerStatusError).Error; updateErr != nil {
+			logger.Log.Error("更新服务器状态失败", zap.Uint("server_id", server.ID), zap.Error(updateErr))
+		}
+		return err
+	}
+
+	if err := s.db.Model(server).Updates(map[string]interface{}{
+		"instance_id": resp.InstanceID,
+		"status":      model.ServerStatusRunning,
+	}).Error; err != nil {
+		return fmt.Errorf("更新服务器信息失败: %w", err)
+	}
+	return nil
+}
+
+// renewOrder 续费订单先续费厂商实例，厂商确认后再顺延到期时间，已过期的服务器恢复运行；
+// 厂商续费失败时订单保持开通中，心跳停止后由后台任务超时接管重试，同一订单号作为幂等令牌不会重复续费
+func (s *ProvisionService) renewOrder(ctx context.Context, order *model.Order, cfg *orderConfig) error {
+	if err := s.providerService.RenewInstance(ctx, &RenewInstanceRequest{
+		ServerID:    cfg.ServerID,
+		Period:      order.Period,
+		ClientToken: order.OrderNo,
+	}); err != nil {
+		if updateErr := s.db.Model(order).Update("remark", "续费厂商实例失败，等待重试").Error; updateErr != nil {
+			logger.Log.Error("更新订单备注失败", zap.String("order_no", order.OrderNo), zap.Error(updateErr))
+		}
+		return err
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var server model.Server
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&server, cfg.ServerID).Error; err != nil {
+			return fmt.Errorf("获取服务器信息失败: %w", err)
+		}
+
+		base := server.ExpireTime
+		if base.Before(time.Now()) {
+			base = time.Now()
+		}
+		updates := map[string]interface{}{
+			"expire_time": base.AddDate(0, order.Period, 0),
+		}
+		if server.Status == model.ServerStatusExpired {
+			updates["status"] = model.ServerStatusRunning
+		}
+		if err := tx.Model(&server).Updates(updates).Error; err != nil {
+			return fmt.Errorf("更新服务器到期时间失败: %w", err)
+		}
+
+		result := tx.Model(order).Where("status = ?", model.OrderStatusProcessing).Updates(map[string]interface{}{
+			"status": model.OrderStatusSuccess,
+			"remark": "",
+		})
+		if result.Error != nil {
+			return fmt.Errorf("更新订单状态失败: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return errors.New("订单状态已变更")
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	logger.Log.Info("续费订单完成", zap.String("order_no", order.OrderNo), zap.Uint("server_id", cfg.ServerID))
+	return nil
+}
+
+// finishOrder 按开通结果更新订单状态并结算库存，开通失败的服务器自动退款
+func (s *ProvisionService) finishOrder(ctx context.Context, order *model.Order) error {
+	var servers []model.Server
+	if err := s.db.Where("order_id = ?", order.ID).Find(&servers).Error; err != nil {
+		return fmt.Errorf("获取订单服务器失败: %w", err)
+	}
+
+	var failedIDs []uint
+	delivered := 0
+	for _, server := range servers {
+		switch server.Status {
+		case model.ServerStatusError:
+			failedIDs = append(failedIDs, server.ID)
+		case model.ServerStatusTerminated:
+		default:
+			delivered++
+		}
+	}
+
+	status := model.OrderStatusSuccess
+	remark := order.Remark
+	if len(failedIDs) > 0 {
+		remark = fmt.Sprintf("%d台服务器开通失败", len(failedIDs))
+		if len(failedIDs) == len(servers) {
+			status = model.OrderStatusFailed
+		}
+	}
+
+	// 实例已开通，订单配置不再保留登录密码和用户数据
+	config, err := scrubOrderConfig(order.Config)
+	if err != nil {
+		return err
+	}
+
+	// 更新订单状态，按实际开通台数扣减库存并归还其余占用；开通失败的退款单在同一事务内创建，
+	// 订单完成后退款不会丢失，执行失败时由后台任务重试
+	var refund *model.Refund
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(order).Updates(map[string]interface{}{
+			"status": status,
+			"remark": remark,
+			"config": config,
+		}).Error; err != nil {
+			return fmt.Errorf("更新订单状态失败: %w", err)
+		}
+		if err := settleStock(tx, order.ID, delivered); err != nil {
+			return err
+		}
+		if len(failedIDs) == 0 {
+			return nil
+		}
+		var err error
+		refund, err = s.refundService.PrepareFailedServersRefund(tx, order.ID, failedIDs)
+		if err != nil {
+			return fmt.Errorf("创建开通失败退款单失败: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if len(failedIDs) == 0 {
+		logger.Log.Info("订单开通完成", zap.String("order_no", order.OrderNo), zap.Int("servers", len(servers)))
+		return nil
+	}
+
+	logger.Log.Warn("订单部分服务器开通失败", zap.String("order_no", order.OrderNo),
+		zap.Int("servers", len(servers)), zap.Int("failed", len(failedIDs)))
+	if refund == nil {
+		return nil
+	}
+	if _, err := s.refundService.ExecuteAutoRefund(ctx, refund.ID); err != nil {
+		return fmt.Errorf("开通失败退款失败，等待后台重试: %w", err)
+	}
+	return nil
+}
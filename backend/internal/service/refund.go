@@ -0,0 +1,904 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"cloudbp-backend/internal/config"
+	"cloudbp-backend/internal/model"
+	"cloudbp-backend/pkg/logger"
+	"cloudbp-backend/pkg/payment"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/shopspring/decimal"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// refundStaleDuration 处理中的退款单超过该时长视为上次执行中断，允许重新处理
+const refundStaleDuration = 15 * time.Minute
+
+// RefundService 退款服务
+type RefundService struct {
+	db              *gorm.DB
+	rdb             *redis.Client
+	config          config.RefundConfig
+	paymentService  *PaymentService
+	providerService *ProviderService
+	ledgerService   *LedgerService
+}
+
+// NewRefundService 创建退款服务
+func NewRefundService(db *gorm.DB, rdb *redis.Client, cfg config.RefundConfig, paymentService *PaymentService, providerService *ProviderService) *RefundService {
+	return &RefundService{
+		db:              db,
+		rdb:             rdb,
+		config:          cfg,
+		paymentService:  paymentService,
+		providerService: providerService,
+		ledgerService:   NewLedgerService(db),
+	}
+}
+
+// RefundQuote 提前退订退款试算，剩余时长依次由最近的续费订单和原订单覆盖，按订单分别退款
+type RefundQuote struct {
+	ServerID         uint               `json:"server_id"`
+	RemainingDays    int                `json:"remaining_days"`     // 剩余整天数，不足一天不退
+	FeeRate          float64            `json:"fee_rate"`           // 手续费比例
+	BaseRefundAmount decimal.Decimal    `json:"base_refund_amount"` // 退款合计折合本位币
+	Orders           []RefundQuoteOrder `json:"orders"`             // 各订单退款明细，最近支付的在前
+}
+
+// RefundQuoteOrder 单个订单的退订退款试算
+type RefundQuoteOrder struct {
+	OrderID      uint            `json:"order_id"`
+	OrderNo      string          `json:"order_no"`
+	Type         string          `json:"type"`          // new、renew
+	Currency     string          `json:"currency"`      // 订单币种，以下金额均以该币种计
+	Days         int             `json:"days"`          // 该订单覆盖的剩余天数
+	MonthlyPrice decimal.Decimal `json:"monthly_price"` // 实付月单价
+	GrossAmount  decimal.Decimal `json:"gross_amount"`  // 按覆盖天数折算金额
+	FeeAmount    decimal.Decimal `json:"fee_amount"`    // 手续费
+	RefundAmount decimal.Decimal `json:"refund_amount"` // 实际退款金额，不超过订单剩余可退金额
+	Method       string          `json:"method"`        // 退款去向
+}
+
+// QuoteTermination 试算服务器提前退订可退金额
+func (s *RefundService) QuoteTermination(ctx context.Context, userID, serverID uint) (*RefundQuote, error) {
+	var server model.Server
+	if err := s.db.Where("id = ? AND user_id = ?", serverID, userID).First(&server).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("服务器不存在")
+		}
+		return nil, fmt.Errorf("获取服务器信息失败: %w", err)
+	}
+
+	return s.quoteTermination(s.db, &server)
+}
+
+// quoteTermination 剩余整天数从最近的续费订单往前分摊，各订单按实付月单价折算并扣除手续费，
+// 且不超过该订单剩余可退金额
+func (s *RefundService) quoteTermination(tx *gorm.DB, server *model.Server) (*RefundQuote, error) {
+	if server.Status != model.ServerStatusRunning && server.Status != model.ServerStatusStopped {
+		return nil, errors.New("当前服务器状态不允许退订")
+	}
+
+	orders, err := s.termOrders(tx, server)
+	if err != nil {
+		return nil, err
+	}
+
+	remainingDays := 0
+	if remaining := time.Until(server.ExpireTime); remaining > 0 {
+		remainingDays = int(math.Floor(remaining.Hours() / 24))
+	}
+
+	quote := &RefundQuote{
+		ServerID:      server.ID,
+		RemainingDays: remainingDays,
+		FeeRate:       s.config.TerminationFeeRate,
+	}
+	feeRate := decimal.NewFromFloat(s.config.TerminationFeeRate)
+	daysLeft := remainingDays
+	for i := range orders {
+		order := &orders[i]
+		// 每个订单覆盖购买月数×30天，剩余时长分摊完即停止，剩余不足一天时仅保留最近的订单
+		days := order.Period * 30
+		if days > daysLeft {
+			days = daysLeft
+		}
+		if days == 0 && len(quote.Orders) > 0 {
+			break
+		}
+		daysLeft -= days
+
+		monthlyPrice := order.PayAmount.Div(decimal.NewFromInt(int64(order.Period * order.Quantity)))
+		grossAmount := roundAmount(monthlyPrice.Mul(decimal.NewFromInt(int64(days))).Div(decimal.NewFromInt(30)))
+		feeAmount := roundAmount(grossAmount.Mul(feeRate))
+
+		refundable, err := s.refundableAmount(tx, order)
+		if err != nil {
+			return nil, err
+		}
+		refundAmount := decimal.Max(decimal.Zero, decimal.Min(grossAmount.Sub(feeAmount), refundable))
+
+		method, err := s.refundMethod(tx, order.ID, false)
+		if err != nil {
+			return nil, err
+		}
+
+		quote.Orders = append(quote.Orders, RefundQuoteOrder{
+			OrderID:      order.ID,
+			OrderNo:      order.OrderNo,
+			Type:         order.Type,
+			Currency:     order.Currency,
+			Days:         days,
+			MonthlyPrice: roundAmount(monthlyPrice),
+			GrossAmount:  grossAmount,
+			FeeAmount:    feeAmount,
+			RefundAmount: refundAmount,
+			Method:       method,
+		})
+		quote.BaseRefundAmount = quote.BaseRefundAmount.Add(toBaseAmount(order, refundAmount))
+	}
+
+	return quote, nil
+}
+
+// termOrders 获取为服务器支付过时长的订单：已支付的续费订单按支付时间倒序在前，原订单在最后
+func (s *RefundService) termOrders(tx *gorm.DB, server *model.Server) ([]model.Order, error) {
+	var original model.Order
+	if err := tx.First(&original, server.OrderID).Error; err != nil {
+		return nil, fmt.Errorf("获取订单失败: %w", err)
+	}
+	if original.Period <= 0 || original.Quantity <= 0 || !original.PayAmount.IsPositive() {
+		return nil, errors.New("该订单不支持退款")
+	}
+
+	var renewals []model.Order
+	if err := tx.Where("user_id = ? AND type = ? AND status IN ?", server.UserID, model.OrderTypeRenew,
+		[]string{model.OrderStatusPaid, model.OrderStatusSuccess}).
+		Order("pay_time DESC, id DESC").
+		Find(&renewals).Error; err != nil {
+		return nil, fmt.Errorf("获取续费订单失败: %w", err)
+	}
+
+	orders := make([]model.Order, 0, len(renewals)+1)
+	for _, renewal := range renewals {
+		var cfg orderConfig
+		if err := json.Unmarshal([]byte(renewal.Config), &cfg); err != nil || cfg.ServerID != server.ID {
+			continue
+		}
+		if renewal.Period <= 0 || renewal.Quantity <= 0 || !renewal.PayAmount.IsPositive() {
+			continue
+		}
+		orders = append(orders, renewal)
+	}
+	return append(orders, original), nil
+}
+
+// TerminateServerRequest 提前退订请求
+type TerminateServerRequest struct {
+	ServerID uint   `json:"server_id"`
+	UserID   uint   `json:"user_id"`
+	Reason   string `json:"reason"`
+}
+
+// TerminateServer 申请提前退订服务器，按订单分别创建退款单，审核通过后释放实例并退款
+func (s *RefundService) TerminateServer(ctx context.Context, req *TerminateServerRequest) ([]model.Refund, error) {
+	var refunds []model.Refund
+	var quote *RefundQuote
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var server model.Server
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ? AND user_id = ?", req.ServerID, req.UserID).
+			First(&server).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errors.New("服务器不存在")
+			}
+			return fmt.Errorf("获取服务器信息失败: %w", err)
+		}
+
+		// 同一服务器只允许一笔待审核的退订申请
+		var count int64
+		if err := tx.Model(&model.Refund{}).
+			Where("server_id = ? AND status IN ?", server.ID, []string{model.RefundStatusPending, model.RefundStatusProcessing}).
+			Count(&count).Error; err != nil {
+			return fmt.Errorf("获取退款单失败: %w", err)
+		}
+		if count > 0 {
+			return errors.New("已有待审核的退订申请")
+		}
+
+		var err error
+		quote, err = s.quoteTermination(tx, &server)
+		if err != nil {
+			return err
+		}
+
+		// 无可退金额的订单不建退款单，但至少保留一张用于审核释放实例
+		items := make([]RefundQuoteOrder, 0, len(quote.Orders))
+		for _, item := range quote.Orders {
+			if item.RefundAmount.IsPositive() {
+				items = append(items, item)
+			}
+		}
+		if len(items) == 0 {
+			items = quote.Orders[:1]
+		}
+
+		for _, item := range items {
+			original, err := s.originalPayment(tx, item.OrderID)
+			if err != nil {
+				return err
+			}
+
+			refund := model.Refund{
+				RefundNo:  generateNo("RFD"),
+				OrderID:   item.OrderID,
+				UserID:    req.UserID,
+				ServerID:  &server.ID,
+				PaymentID: original.ID,
+				Type:      model.RefundTypeTermination,
+				Method:    item.Method,
+				Amount:    item.RefundAmount,
+				Currency:  item.Currency,
+				Status:    model.RefundStatusPending,
+				Reason:    req.Reason,
+			}
+			if err := tx.Create(&refund).Error; err != nil {
+				return fmt.Errorf("创建退款单失败: %w", err)
+			}
+			refunds = append(refunds, refund)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// 小额退订自动审核，按退款合计（本位币）判断
+	if s.config.AutoApproveLimit > 0 && quote.BaseRefundAmount.LessThanOrEqual(decimal.NewFromFloat(s.config.AutoApproveLimit)) {
+		for i := range refunds {
+			refund, err := s.executeRefund(ctx, refunds[i].ID, nil, "自动审核通过")
+			if err != nil {
+				return nil, err
+			}
+			refunds[i] = *refund
+		}
+	}
+
+	return refunds, nil
+}
+
+// RefundFailedOrder 开通失败的订单全额退款，无需审核
+func (s *RefundService) RefundFailedOrder(ctx context.Context, orderID uint, operatorID *uint) (*model.Refund, error) {
+	var refund model.Refund
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var order model.Order
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&order, orderID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errors.New("订单不存在")
+			}
+			return fmt.Errorf("获取订单失败: %w", err)
+		}
+		if order.Status != model.OrderStatusFailed {
+			return errors.New("只有开通失败的订单可以全额退款")
+		}
+
+		amount, err := s.refundableAmount(tx, &order)
+		if err != nil {
+			return err
+		}
+		if !amount.IsPositive() {
+			return errors.New("订单无可退金额")
+		}
+
+		original, err := s.originalPayment(tx, order.ID)
+		if err != nil {
+			return err
+		}
+		method, err := s.refundMethod(tx, order.ID, false)
+		if err != nil {
+			return err
+		}
+
+		refund = model.Refund{
+			RefundNo:  generateNo("RFD"),
+			OrderID:   order.ID,
+			UserID:    order.UserID,
+			PaymentID: original.ID,
+			Type:      model.RefundTypeFailed,
+			Method:    method,
+			Amount:    amount,
+			Currency:  order.Currency,
+			Status:    model.RefundStatusPending,
+			Reason:    "服务器开通失败",
+		}
+		if err := tx.Create(&refund).Error; err != nil {
+			return fmt.Errorf("创建退款单失败: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.executeRefund(ctx, refund.ID, operatorID, "开通失败自动退款")
+}
+
+// PrepareFailedServersRefund 多台订单中部分服务器开通失败时按失败台数折算退款，无需审核；
+// 全部失败时退还订单剩余可退金额。失败的服务器标记为已释放。
+// 在完成订单的事务内创建待执行的退款单，订单无可退金额时返回nil，提交后由 ExecuteAutoRefund 执行
+func (s *RefundService) PrepareFailedServersRefund(tx *gorm.DB, orderID uint, serverIDs []uint) (*model.Refund, error) {
+	if len(serverIDs) == 0 {
+		return nil, errors.New("没有开通失败的服务器")
+	}
+
+	var order model.Order
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&order, orderID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("订单不存在")
+		}
+		return nil, fmt.Errorf("获取订单失败: %w", err)
+	}
+
+	if err := tx.Model(&model.Server{}).
+		Where("id IN ? AND order_id = ? AND status = ?", serverIDs, order.ID, model.ServerStatusError).
+		Update("status", model.ServerStatusTerminated).Error; err != nil {
+		return nil, fmt.Errorf("更新服务器状态失败: %w", err)
+	}
+
+	refundable, err := s.refundableAmount(tx, &order)
+	if err != nil {
+		return nil, err
+	}
+
+	quantity := order.Quantity
+	if quantity <= 0 {
+		quantity = 1
+	}
+	amount := refundable
+	if len(serverIDs) < quantity {
+		amount = decimal.Min(roundAmount(order.PayAmount.Mul(decimal.NewFromInt(int64(len(serverIDs)))).Div(decimal.NewFromInt(int64(quantity)))), refundable)
+	}
+	if !amount.IsPositive() {
+		return nil, nil
+	}
+
+	original, err := s.originalPayment(tx, order.ID)
+	if err != nil {
+		return nil, err
+	}
+	method, err := s.refundMethod(tx, order.ID, false)
+	if err != nil {
+		return nil, err
+	}
+
+	refund := model.Refund{
+		RefundNo:  generateNo("RFD"),
+		OrderID:   order.ID,
+		UserID:    order.UserID,
+		PaymentID: original.ID,
+		Type:      model.RefundTypeFailed,
+		Method:    method,
+		Amount:    amount,
+		Currency:  order.Currency,
+		Status:    model.RefundStatusPending,
+		Reason:    fmt.Sprintf("%d台服务器开通失败", len(serverIDs)),
+	}
+	if len(serverIDs) == 1 {
+		refund.ServerID = &serverIDs[0]
+	}
+	if err := tx.Create(&refund).Error; err != nil {
+		return nil, fmt.Errorf("创建退款单失败: %w", err)
+	}
+	return &refund, nil
+}
+
+// ExecuteAutoRefund 执行开通失败的自动退款，失败的退款单由 RetryAutoRefunds 定时重试
+func (s *RefundService) ExecuteAutoRefund(ctx context.Context, refundID uint) (*model.Refund, error) {
+	return s.executeRefund(ctx, refundID, nil, "开通失败自动退款")
+}
+
+// RetryAutoRefunds 重试未完成的开通失败退款，包括待执行、执行失败和处理中断的退款单
+func (s *RefundService) RetryAutoRefunds(ctx context.Context) error {
+	var refundIDs []uint
+	if err := s.db.WithContext(ctx).Model(&model.Refund{}).
+		Where("type = ?", model.RefundTypeFailed).
+		Where("status IN ? OR (status = ? AND updated_at < ?)",
+			[]string{model.RefundStatusPending, model.RefundStatusFailed},
+			model.RefundStatusProcessing, time.Now().Add(-refundStaleDuration)).
+		Order("id").
+		Pluck("id", &refundIDs).Error; err != nil {
+		return fmt.Errorf("获取待重试退款单失败: %w", err)
+	}
+
+	for _, refundID := range refundIDs {
+		// 失败原因已由 executeRefund 记录到退款单
+		s.ExecuteAutoRefund(ctx, refundID)
+	}
+	return nil
+}
+
+// CreateRefundRequest 人工退款请求
+type CreateRefundRequest struct {
+	OrderID    uint            `json:"order_id" binding:"required"`
+	Amount     decimal.Decimal `json:"amount" binding:"required"`
+	Reason     string          `json:"reason" binding:"required"`
+	ToBalance  bool            `json:"to_balance"` // 退回余额而不是原路退回
+	OperatorID uint            `json:"operator_id"`
+}
+
+// CreateRefund 创建人工退款单，需审核通过后执行
+func (s *RefundService) CreateRefund(ctx context.Context, req *CreateRefundRequest) (*model.Refund, error) {
+	if !req.Amount.IsPositive() || !req.Amount.Round(2).Equal(req.Amount) {
+		return nil, errors.New("退款金额必须大于0且最多两位小数")
+	}
+
+	var refund model.Refund
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var order model.Order
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&order, req.OrderID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errors.New("订单不存在")
+			}
+			return fmt.Errorf("获取订单失败: %w", err)
+		}
+
+		refundable, err := s.refundableAmount(tx, &order)
+		if err != nil {
+			return err
+		}
+		if req.Amount.GreaterThan(refundable) {
+			return fmt.Errorf("退款金额超过可退金额 %s", refundable.StringFixed(2))
+		}
+
+		original, err := s.originalPayment(tx, order.ID)
+		if err != nil {
+			return err
+		}
+		method, err := s.refundMethod(tx, order.ID, req.ToBalance)
+		if err != nil {
+			return err
+		}
+
+		refund = model.Refund{
+			RefundNo:   generateNo("RFD"),
+			OrderID:    order.ID,
+			UserID:     order.UserID,
+			PaymentID:  original.ID,
+			Type:       model.RefundTypeManual,
+			Method:     method,
+			Amount:     req.Amount,
+			Currency:   order.Currency,
+			Status:     model.RefundStatusPending,
+			Reason:     req.Reason,
+			OperatorID: &req.OperatorID,
+		}
+		if err := tx.Create(&refund).Error; err != nil {
+			return fmt.Errorf("创建退款单失败: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &refund, nil
+}
+
+// ReviewRefundRequest 审核退款请求
+type ReviewRefundRequest struct {
+	RefundID   uint   `json:"refund_id"`
+	OperatorID uint   `json:"operator_id"`
+	Remark     string `json:"remark"`
+}
+
+// ApproveRefund 审核通过并执行退款，执行失败的退款单可再次审核重试
+func (s *RefundService) ApproveRefund(ctx context.Context, req *ReviewRefundRequest) (*model.Refund, error) {
+	return s.executeRefund(ctx, req.RefundID, &req.OperatorID, req.Remark)
+}
+
+// RejectRefund 驳回退款申请，退订的实例已释放时只能重试退款
+func (s *RefundService) RejectRefund(ctx context.Context, req *ReviewRefundRequest) (*model.Refund, error) {
+	var released int64
+	if err := s.db.Model(&model.Refund{}).
+		Joins("JOIN servers ON servers.id = refunds.server_id").
+		Where("refunds.id = ? AND refunds.type = ? AND servers.status IN ?", req.RefundID, model.RefundTypeTermination,
+			[]string{model.ServerStatusReleasing, model.ServerStatusTerminated}).
+		Count(&released).Error; err != nil {
+		return nil, fmt.Errorf("获取退款单失败: %w", err)
+	}
+	if released > 0 {
+		return nil, errors.New("服务器实例已释放，不能驳回，请重试退款")
+	}
+
+	now := time.Now()
+	result := s.db.Model(&model.Refund{}).
+		Where("id = ? AND status IN ?", req.RefundID, []string{model.RefundStatusPending, model.RefundStatusFailed}).
+		Updates(map[string]interface{}{
+			"status":      model.RefundStatusRejected,
+			"remark":      req.Remark,
+			"operator_id": req.OperatorID,
+			"reviewed_at": &now,
+		})
+	if result.Error != nil {
+		return nil, fmt.Errorf("驳回退款失败: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil, errors.New("退款单不存在或已处理")
+	}
+
+	var refund model.Refund
+	if err := s.db.First(&refund, req.RefundID).Error; err != nil {
+		return nil, fmt.Errorf("获取退款单失败: %w", err)
+	}
+	return &refund, nil
+}
+
+// executeRefund 执行退款：退订时先释放实例，再锁定退款单并调用支付通道，最后入账。
+// 实例释放后立即记录服务器已退订，退款失败重试时跳过；通道退款使用退款单号保证幂等，入账失败后重试不会重复退款
+func (s *RefundService) executeRefund(ctx context.Context, refundID uint, operatorID *uint, remark string) (*model.Refund, error) {
+	var refund model.Refund
+	err := s.releaseServer(ctx, refundID)
+	if err == nil {
+		err = s.claimRefund(&refund, refundID)
+	}
+	if err != nil {
+		s.failRefund(refundID, err, model.RefundStatusPending, model.RefundStatusFailed)
+		return nil, err
+	}
+
+	transactionID, err := s.gatewayRefund(ctx, &refund)
+	if err == nil {
+		err = s.settleRefund(&refund, transactionID, operatorID, remark)
+	}
+	if err != nil {
+		s.failRefund(refundID, err, model.RefundStatusProcessing)
+		return nil, err
+	}
+
+	logger.Log.Info("退款成功", zap.String("refund_no", refund.RefundNo), zap.String("amount", refund.Amount.String()))
+	if err := s.db.First(&refund, refundID).Error; err != nil {
+		return nil, fmt.Errorf("获取退款单失败: %w", err)
+	}
+	return &refund, nil
+}
+
+// failRefund 记录失败原因，便于管理员重试；只更新仍处于指定状态的退款单，避免覆盖其他请求的结果
+func (s *RefundService) failRefund(refundID uint, cause error, statuses ...string) {
+	if err := s.db.Model(&model.Refund{}).
+		Where("id = ? AND status IN ?", refundID, statuses).
+		Updates(map[string]interface{}{
+			"status": model.RefundStatusFailed,
+			"remark": cause.Error(),
+		}).Error; err != nil {
+		logger.Log.Error("更新退款单失败", zap.Uint("refund_id", refundID), zap.Error(err))
+	}
+	logger.Log.Error("执行退款失败", zap.Uint("refund_id", refundID), zap.Error(cause))
+}
+
+// releaseServer 退订退款先释放云厂商实例，释放期间服务器为释放中，释放成功后立即标记为已退订；
+// 实例已释放的服务器直接跳过，释放失败时恢复原状态
+func (s *RefundService) releaseServer(ctx context.Context, refundID uint) error {
+	var refund model.Refund
+	if err := s.db.First(&refund, refundID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.New("退款单不存在")
+		}
+		return fmt.Errorf("获取退款单失败: %w", err)
+	}
+	if refund.Status != model.RefundStatusPending && refund.Status != model.RefundStatusFailed &&
+		refund.Status != model.RefundStatusProcessing {
+		return errors.New("退款单已处理")
+	}
+	if refund.Type != model.RefundTypeTermination || refund.ServerID == nil {
+		return nil
+	}
+
+	var server model.Server
+	if err := s.db.First(&server, *refund.ServerID).Error; err != nil {
+		return fmt.Errorf("获取服务器信息失败: %w", err)
+	}
+	if server.Status == model.ServerStatusTerminated {
+		return nil
+	}
+
+	previous := server.Status
+	if err := s.db.Model(&server).Update("status", model.ServerStatusReleasing).Error; err != nil {
+		return fmt.Errorf("更新服务器状态失败: %w", err)
+	}
+
+	if err := s.providerService.DeleteInstance(ctx, &DeleteInstanceRequest{ServerID: server.ID}); err != nil {
+		if previous != model.ServerStatusReleasing {
+			s.db.Model(&server).Update("status", previous)
+		}
+		return fmt.Errorf("释放实例失败: %w", err)
+	}
+
+	if err := s.db.Model(&server).Update("status", model.ServerStatusTerminated).Error; err != nil {
+		return fmt.Errorf("更新服务器状态失败: %w", err)
+	}
+	return nil
+}
+
+// claimRefund 锁定退款单并标记为处理中，校验剩余可退金额后提交，通道退款在事务外进行；
+// 处理中超时的退款单视为上次执行中断，允许重新处理
+func (s *RefundService) claimRefund(refund *model.Refund, refundID uint) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(refund, refundID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errors.New("退款单不存在")
+			}
+			return fmt.Errorf("获取退款单失败: %w", err)
+		}
+		switch refund.Status {
+		case model.RefundStatusPending, model.RefundStatusFailed:
+		case model.RefundStatusProcessing:
+			if time.Since(refund.UpdatedAt) < refundStaleDuration {
+				return errors.New("退款单处理中，请稍后再试")
+			}
+		default:
+			return errors.New("退款单已处理")
+		}
+
+		var order model.Order
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&order, refund.OrderID).Error; err != nil {
+			return fmt.Errorf("获取订单失败: %w", err)
+		}
+
+		refunded, err := s.refundedAmount(tx, order.ID, model.RefundStatusSuccess)
+		if err != nil {
+			return err
+		}
+		if refund.Amount.GreaterThan(order.PayAmount.Sub(refunded)) {
+			return errors.New("退款金额超过订单剩余可退金额")
+		}
+
+		if err := tx.Model(refund).Updates(map[string]interface{}{
+			"status":     model.RefundStatusProcessing,
+			"updated_at": time.Now(),
+		}).Error; err != nil {
+			return fmt.Errorf("更新退款单失败: %w", err)
+		}
+		return nil
+	})
+}
+
+// gatewayRefund 原路退款时调用支付通道退款，返回第三方退款单号；退回余额的退款无需调用通道
+func (s *RefundService) gatewayRefund(ctx context.Context, refund *model.Refund) (string, error) {
+	if !refund.Amount.IsPositive() || refund.Method == model.PaymentMethodBalance {
+		return "", nil
+	}
+
+	var original model.Payment
+	if err := s.db.First(&original, refund.PaymentID).Error; err != nil {
+		return "", fmt.Errorf("获取原支付记录失败: %w", err)
+	}
+
+	gateway, err := s.paymentService.GetGateway(refund.Method)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := gateway.Refund(ctx, &payment.RefundRequest{
+		TradeNo:     original.PaymentNo,
+		RefundNo:    refund.RefundNo,
+		TotalAmount: original.Amount,
+		Amount:      refund.Amount,
+		Currency:    original.Currency,
+		Reason:      refund.Reason,
+	})
+	if err != nil {
+		return "", fmt.Errorf("支付通道退款失败: %w", err)
+	}
+	return resp.RefundID, nil
+}
+
+// settleRefund 通道退款完成后在事务内入账并更新退款单和订单状态，退款单须仍处于处理中
+func (s *RefundService) settleRefund(refund *model.Refund, transactionID string, operatorID *uint, remark string) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(refund, refund.ID).Error; err != nil {
+			return fmt.Errorf("获取退款单失败: %w", err)
+		}
+		if refund.Status != model.RefundStatusProcessing {
+			return errors.New("退款单已处理")
+		}
+
+		var order model.Order
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&order, refund.OrderID).Error; err != nil {
+			return fmt.Errorf("获取订单失败: %w", err)
+		}
+
+		refunded, err := s.refundedAmount(tx, order.ID, model.RefundStatusSuccess)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		updates := map[string]interface{}{
+			"status":         model.RefundStatusSuccess,
+			"transaction_id": transactionID,
+			"reviewed_at":    &now,
+			"refund_time":    &now,
+		}
+		if operatorID != nil {
+			updates["operator_id"] = *operatorID
+		}
+		if remark != "" {
+			updates["remark"] = remark
+		}
+
+		if refund.Amount.IsPositive() {
+			if err := s.postRefund(tx, &order, refund); err != nil {
+				return err
+			}
+
+			// 记录退款流水
+			record := model.Payment{
+				OrderID:       order.ID,
+				UserID:        refund.UserID,
+				PaymentNo:     refund.RefundNo,
+				Type:          model.PaymentTypeRefund,
+				Method:        refund.Method,
+				Amount:        refund.Amount,
+				Status:        model.PaymentStatusSuccess,
+				TransactionID: transactionID,
+				PayTime:       &now,
+				Remark:        refund.Reason,
+			}
+			if err := tx.Create(&record).Error; err != nil {
+				return fmt.Errorf("创建退款记录失败: %w", err)
+			}
+		}
+
+		if err := tx.Model(refund).Updates(updates).Error; err != nil {
+			return fmt.Errorf("更新退款单失败: %w", err)
+		}
+
+		// 全额退款后订单标记为已退款
+		if refunded.Add(refund.Amount).GreaterThanOrEqual(order.PayAmount) {
+			if err := tx.Model(&order).Update("status", model.OrderStatusRefunded).Error; err != nil {
+				return fmt.Errorf("更新订单状态失败: %w", err)
+			}
+		}
+
+		return nil
+	})
+}
+
+// postRefund 退款入账：从收入退回钱包，原路退款时再从钱包转出到支付通道；
+// 钱包按订单锁定的汇率折合本位币记账，通道按订单币种退款
+func (s *RefundService) postRefund(tx *gorm.DB, order *model.Order, refund *model.Refund) error {
+	baseAmount := toBaseAmount(order, refund.Amount)
+
+	if _, err := s.ledgerService.CreditWallet(tx, &WalletPostingRequest{
+		UserID:      refund.UserID,
+		Amount:      baseAmount,
+		Type:        model.LedgerTxTypeRefund,
+		OrderID:     &refund.OrderID,
+		Description: fmt.Sprintf("订单退款 %s", refund.RefundNo),
+	}); err != nil {
+		return fmt.Errorf("退款入账失败: %w", err)
+	}
+
+	if refund.Method == model.PaymentMethodBalance {
+		return nil
+	}
+
+	if _, err := s.ledgerService.DebitWallet(tx, &WalletPostingRequest{
+		UserID:      refund.UserID,
+		Amount:      baseAmount,
+		Type:        model.LedgerTxTypeRefund,
+		Counterpart: model.LedgerAccountGateway,
+		OrderID:     &refund.OrderID,
+		Description: fmt.Sprintf("原路退款 %s", refund.RefundNo),
+	}); err != nil {
+		return fmt.Errorf("原路退款出账失败: %w", err)
+	}
+	return nil
+}
+
+// originalPayment 获取订单的原支付记录
+func (s *RefundService) originalPayment(tx *gorm.DB, orderID uint) (*model.Payment, error) {
+	var record model.Payment
+	if err := tx.Where("order_id = ? AND type = ? AND status = ?", orderID, model.PaymentTypePay, model.PaymentStatusSuccess).
+		First(&record).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("订单没有已完成的支付记录")
+		}
+		return nil, fmt.Errorf("获取支付记录失败: %w", err)
+	}
+	return &record, nil
+}
+
+// refundMethod 确定退款去向，余额支付退回余额，第三方支付原路退回
+func (s *RefundService) refundMethod(tx *gorm.DB, orderID uint, toBalance bool) (string, error) {
+	record, err := s.originalPayment(tx, orderID)
+	if err != nil {
+		return "", err
+	}
+	if toBalance || record.Method == model.PaymentMethodBalance {
+		return model.PaymentMethodBalance, nil
+	}
+	if _, err := s.paymentService.GetGateway(record.Method); err != nil {
+		return "", fmt.Errorf("原支付方式不可用，请选择退回余额: %w", err)
+	}
+	return record.Method, nil
+}
+
+// refundableAmount 订单剩余可退金额，已退款、处理中和待审核的金额均不可再退
+func (s *RefundService) refundableAmount(tx *gorm.DB, order *model.Order) (decimal.Decimal, error) {
+	refunded, err := s.refundedAmount(tx, order.ID, model.RefundStatusSuccess, model.RefundStatusProcessing,
+		model.RefundStatusPending, model.RefundStatusFailed)
+	if err != nil {
+		return decimal.Zero, err
+	}
+	return decimal.Max(decimal.Zero, order.PayAmount.Sub(refunded)), nil
+}
+
+// refundedAmount 统计订单指定状态的退款金额
+func (s *RefundService) refundedAmount(tx *gorm.DB, orderID uint, statuses ...string) (decimal.Decimal, error) {
+	var amount decimal.Decimal
+	if err := tx.Model(&model.Refund{}).
+		Where("order_id = ? AND status IN ?", orderID, statuses).
+		Select("COALESCE(SUM(amount), 0)").
+		Scan(&amount).Error; err != nil {
+		return decimal.Zero, fmt.Errorf("统计退款金额失败: %w", err)
+	}
+	return amount, nil
+}
+
+// GetRefundsRequest 获取退款单列表请求
+type GetRefundsRequest struct {
+	UserID uint   `json:"user_id"` // 为0时查询全部用户
+	Status string `json:"status"`
+	Page   int    `json:"page"`
+	Size   int    `json:"size"`
+}
+
+// GetRefundsResponse 获取退款单列表响应
+type GetRefundsResponse struct {
+	Refunds    []model.Refund `json:"refunds"`
+	TotalCount int64          `json:"total_count"`
+	Page       int            `json:"page"`
+	Size       int            `json:"size"`
+}
+
+// GetRefunds 获取退款单列表
+func (s *RefundService) GetRefunds(ctx context.Context, req *GetRefundsRequest) (*GetRefundsResponse, error) {
+	var refunds []model.Refund
+	var totalCount int64
+
+	query := s.db.Model(&model.Refund{})
+	if req.UserID != 0 {
+		query = query.Where("user_id = ?", req.UserID)
+	}
+	if req.Status != "" {
+		query = query.Where("status = ?", req.Status)
+	}
+
+	// 获取总数
+	if err := query.Count(&totalCount).Error; err != nil {
+		return nil, fmt.Errorf("获取退款单总数失败: %w", err)
+	}
+
+	// 获取分页数据
+	offset := (req.Page - 1) * req.Size
+	if err := query.Preload("Order").Offset(offset).Limit(req.Size).Order("created_at DESC").Find(&refunds).Error; err != nil {
+		return nil, fmt.Errorf("获取退款单列表失败: %w", err)
+	}
+
+	return &GetRefundsResponse{
+		Refunds:    refunds,
+		TotalCount: totalCount,
+		Page:       req.Page,
+		Size:       req.Size,
+	}, nil
+}
@@ -0,0 +1,322 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"cloudbp-backend/internal/model"
+	"cloudbp-backend/pkg/provider"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+// RegionService 产品可售地域服务：管理员配置套餐的可售地域和地域售价，
+// 用户下单时选择的地域和可用区按厂商地域列表校验
+type RegionService struct {
+	db             *gorm.DB
+	rdb            *redis.Client
+	catalogService *CatalogService
+}
+
+// NewRegionService 创建产品地域服务
+func NewRegionService(db *gorm.DB, rdb *redis.Client, catalogService *CatalogService) *RegionService {
+	return &RegionService{
+		db:             db,
+		rdb:            rdb,
+		catalogService: catalogService,
+	}
+}
+
+// RegionOffer 产品在某个地域的销售信息
+type RegionOffer struct {
+	Region     string          `json:"region"`
+	RegionName string          `json:"region_name"`
+	Zones      []provider.Zone `json:"zones"`    // 可选可用区
+	Price      decimal.Decimal `json:"price"`    // 地域售价/月
+	Currency   string          `json:"currency"` // 标价币种
+}
+
+// RegionSelection 下单时确定的地域、可用区和地域售价
+type RegionSelection struct {
+	Region string
+	Zone   string
+	Price  decimal.Decimal
+}
+
+// GetProductRegions 获取产品可售地域，只返回厂商当前提供的地域和可用区
+func (s *RegionService) GetProductRegions(ctx context.Context, productID uint) ([]RegionOffer, error) {
+	var product model.Product
+	if err := s.db.Where("id = ? AND status = ?", productID, model.ProductStatusOnline).First(&product).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("产品不存在")
+		}
+		return nil, fmt.Errorf("获取产品信息失败: %w", err)
+	}
+
+	offers, err := productRegions(s.db, &product)
+	if err != nil {
+		return nil, err
+	}
+	regions, err := s.catalogService.GetRegions(ctx, product.ProviderID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]RegionOffer, 0, len(offers))
+	for _, offer := range offers {
+		if offer.Status != model.ProductRegionStatusActive {
+			continue
+		}
+		region := findRegion(regions, offer.Region)
+		if region == nil {
+			continue
+		}
+		zones := availableZones(region, offer.Zones)
+		if len(zones) == 0 {
+			continue
+		}
+		result = append(result, RegionOffer{
+			Region:     region.RegionID,
+			RegionName: region.RegionName,
+			Zones:      zones,
+			Price:      offerPrice(&product, &offer),
+			Currency:   product.Currency,
+		})
+	}
+	return result, nil
+}
+
+// ResolveRegion 校验下单选择的地域和可用区并返回地域售价；未选择地域时使用产品默认地域，
+// 未选择可用区时由配置的第一个可用区或厂商分配
+func (s *RegionService) ResolveRegion(ctx context.Context, product *model.Product, region, zone string) (*RegionSelection, error) {
+	region = strings.TrimSpace(region)
+	zone = strings.TrimSpace(zone)
+	if region == "" {
+		region = product.Region
+	}
+
+	offer, err := productRegion(s.db, product, region)
+	if err != nil {
+		return nil, err
+	}
+
+	regions, err := s.catalogService.GetRegions(ctx, product.ProviderID)
+	if err != nil {
+		return nil, err
+	}
+	providerRegion := findRegion(regions, region)
+	if providerRegion == nil {
+		return nil, errors.New("厂商暂不提供该地域")
+	}
+
+	zones := availableZones(providerRegion, offer.Zones)
+	if len(zones) == 0 {
+		return nil, errors.New("该地域暂无可用区")
+	}
+	if zone == "" {
+		if len(offer.Zones) > 0 {
+			zone = zones[0].ZoneID
+		} else if region == product.Region {
+			zone = product.Zone
+		}
+	}
+	if zone != "" && !containsZone(zones, zone) {
+		return nil, errors.New("所选可用区不可用")
+	}
+
+	return &RegionSelection{
+		Region: region,
+		Zone:   zone,
+		Price:  offerPrice(product, offer),
+	}, nil
+}
+
+// SaveProductRegionRequest 产品地域配置
+type SaveProductRegionRequest struct {
+	Region string          `json:"region" binding:"required"`
+	Zones  []string        `json:"zones"`
+	Price  decimal.Decimal `json:"price"`
+	Status int             `json:"status"`
+}
+
+// GetProductRegionSettings 获取产品地域配置（管理员）
+func (s *RegionService) GetProductRegionSettings(ctx context.Context, productID uint) ([]model.ProductRegion, error) {
+	var regions []model.ProductRegion
+	if err := s.db.Where("product_id = ?", productID).Order("id").Find(&regions).Error; err != nil {
+		return nil, fmt.Errorf("获取产品地域失败: %w", err)
+	}
+	return regions, nil
+}
+
+// SetProductRegions 覆盖产品的可售地域配置，地域和可用区须为厂商当前提供的；
+// 传空列表表示只在产品自身的地域销售
+func (s *RegionService) SetProductRegions(ctx context.Context, productID uint, req []SaveProductRegionRequest) ([]model.ProductRegion, error) {
+	var product model.Product
+	if err := s.db.First(&product, productID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("产品不存在")
+		}
+		return nil, fmt.Errorf("获取产品信息失败: %w", err)
+	}
+
+	var regions []provider.Region
+	if len(req) > 0 {
+		var err error
+		if regions, err = s.catalogService.GetRegions(ctx, product.ProviderID); err != nil {
+			return nil, err
+		}
+	}
+
+	items := make([]model.ProductRegion, 0, len(req))
+	seen := make(map[string]bool, len(req))
+	for _, item := range req {
+		regionID := strings.TrimSpace(item.Region)
+		if seen[regionID] {
+			return nil, fmt.Errorf("地域 %s 重复", regionID)
+		}
+		seen[regionID] = true
+
+		providerRegion := findRegion(regions, regionID)
+		if providerRegion == nil {
+			return nil, fmt.Errorf("厂商不提供地域 %s", regionID)
+		}
+		for _, zone := range item.Zones {
+			if !containsZone(providerRegion.Zones, zone) {
+				return nil, fmt.Errorf("地域 %s 下没有可用区 %s", regionID, zone)
+			}
+		}
+		if item.Price.IsNegative() {
+			return nil, errors.New("地域售价不能为负数")
+		}
+
+		status := item.Status
+		if status == 0 {
+			status = model.ProductRegionStatusActive
+		}
+		items = append(items, model.ProductRegion{
+			ProductID: productID,
+			Region:    regionID,
+			Zones:     item.Zones,
+			Price:     item.Price,
+			Status:    status,
+		})
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("product_id = ?", productID).Delete(&model.ProductRegion{}).Error; err != nil {
+			return fmt.Errorf("清除产品地域失败: %w", err)
+		}
+		if len(items) == 0 {
+			return nil
+		}
+		if err := tx.Create(&items).Error; err != nil {
+			return fmt.Errorf("保存产品地域失败: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// productRegions 获取产品的可售地域配置，未配置时以产品自身的地域和可用区作为唯一地域
+func productRegions(db *gorm.DB, product *model.Product) ([]model.ProductRegion, error) {
+	var regions []model.ProductRegion
+	if err := db.Where("product_id = ?", product.ID).Order("id").Find(&regions).Error; err != nil {
+		return nil, fmt.Errorf("获取产品地域失败: %w", err)
+	}
+	if len(regions) > 0 {
+		return regions, nil
+	}
+
+	region := model.ProductRegion{
+		ProductID: product.ID,
+		Region:    product.Region,
+		Status:    model.ProductRegionStatusActive,
+	}
+	if product.Zone != "" {
+		region.Zones = []string{product.Zone}
+	}
+	return []model.ProductRegion{region}, nil
+}
+
+// productRegion 获取产品在指定地域的销售配置，地域未开放或暂停销售时返回错误
+func productRegion(db *gorm.DB, product *model.Product, region string) (*model.ProductRegion, error) {
+	regions, err := productRegions(db, product)
+	if err != nil {
+		return nil, err
+	}
+	for i := range regions {
+		if regions[i].Region != region {
+			continue
+		}
+		if regions[i].Status != model.ProductRegionStatusActive {
+			return nil, errors.New("该产品在所选地域暂停销售")
+		}
+		return &regions[i], nil
+	}
+	return nil, errors.New("该产品不在所选地域销售")
+}
+
+// regionPrice 已购服务器所在地域的套餐售价，用于续费和升降配；地域配置已移除时使用产品售价
+func regionPrice(db *gorm.DB, product *model.Product, region string) (decimal.Decimal, error) {
+	var offer model.ProductRegion
+	err := db.Where("product_id = ? AND region = ?", product.ID, region).First(&offer).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return product.Price, nil
+	}
+	if err != nil {
+		return decimal.Zero, fmt.Errorf("获取产品地域失败: %w", err)
+	}
+	return offerPrice(product, &offer), nil
+}
+
+// offerPrice 地域售价，未单独定价时使用产品售价
+func offerPrice(product *model.Product, offer *model.ProductRegion) decimal.Decimal {
+	if offer.Price.IsPositive() {
+		return offer.Price
+	}
+	return product.Price
+}
+
+// findRegion 在厂商地域列表中查找地域
+func findRegion(regions []provider.Region, regionID string) *provider.Region {
+	for i := range regions {
+		if regions[i].RegionID == regionID {
+			return &regions[i]
+		}
+	}
+	return nil
+}
+
+// availableZones 厂商地域下可选的可用区，allowed 为空表示全部
+func availableZones(region *provider.Region, allowed []string) []provider.Zone {
+	if len(allowed) == 0 {
+		return region.Zones
+	}
+	zones := make([]provider.Zone, 0, len(allowed))
+	for _, zone := range region.Zones {
+		for _, id := range allowed {
+			if zone.ZoneID == id {
+				zones = append(zones, zone)
+				break
+			}
+		}
+	}
+	return zones
+}
+
+// containsZone 可用区列表中是否包含指定可用区
+func containsZone(zones []provider.Zone, zoneID string) bool {
+	for _, zone := range zones {
+		if zone.ZoneID == zoneID {
+			return true
+		}
+	}
+	return false
+}
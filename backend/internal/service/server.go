@@ -2,36 +2,69 @@ package service
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"cloudbp-backend/internal/model"
+	"cloudbp-backend/pkg/idgen"
+	"cloudbp-backend/pkg/logger"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 	"github.com/redis/go-redis/v9"
+	"github.com/shopspring/decimal"
+	"go.uber.org/zap"
 )
 
 // ServerService 服务器服务
 type ServerService struct {
-	db  *gorm.DB
-	rdb *redis.Client
+	db              *gorm.DB
+	rdb             *redis.Client
+	providerService *ProviderService
+	paymentService  *PaymentService
+	pricingService  *PricingService
+	ledgerService   *LedgerService
 }
 
 // NewServerService 创建服务器服务
-func NewServerService(db *gorm.DB, rdb *redis.Client) *ServerService {
+func NewServerService(db *gorm.DB, rdb *redis.Client, providerService *ProviderService, paymentService *PaymentService, pricingService *PricingService) *ServerService {
 	return &ServerService{
-		db:  db,
-		rdb: rdb,
+		db:              db,
+		rdb:             rdb,
+		providerService: providerService,
+		paymentService:  paymentService,
+		pricingService:  pricingService,
+		ledgerService:   NewLedgerService(db),
 	}
 }
 
 // GetUserServersRequest 获取用户服务器列表请求
 type GetUserServersRequest struct {
-	UserID uint `json:"user_id"`
-	Page   int  `json:"page"`
-	Size   int  `json:"size"`
+	UserID     uint     `json:"user_id"`
+	Status     string   `json:"status"`
+	Region     string   `json:"region"`
+	ProviderID uint     `json:"provider_id"`
+	GroupID    uint     `json:"group_id"`
+	Tags       []string `json:"tags"`    // 标签筛选，格式为 键 或 键:值，多个标签须同时满足
+	Keyword    string   `json:"keyword"` // 按名称、公网IP、私网IP或实例ID模糊搜索
+	Sort       string   `json:"sort"`    // 排序字段：created_at、expire_time、name
+	Order      string   `json:"order"`   // 排序方向：asc、desc
+	Page       int      `json:"page"`
+	Size       int      `json:"size"`
 }
 
+// serverSortColumns 服务器列表允许的排序字段
+var serverSortColumns = map[string]string{
+	"created_at":  "created_at",
+	"expire_time": "expire_time",
+	"name":        "name",
+}
+
+// likeEscaper 转义模糊搜索中的通配符
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
 // GetUserServersResponse 获取用户服务器列表响应
 type GetUserServersResponse struct {
 	Servers    []model.Server `json:"servers"`
@@ -45,17 +78,59 @@ func (s *ServerService) GetUserServers(ctx context.Context, req *GetUserServersR
 	var servers []model.Server
 	var totalCount int64
 
+	// 构建查询条件
+	query := s.db.Model(&model.Server{}).Where("user_id = ?", req.UserID)
+	if req.Status != "" {
+		query = query.Where("status = ?", req.Status)
+	}
+	if req.Region != "" {
+		query = query.Where("region = ?", req.Region)
+	}
+	if req.ProviderID > 0 {
+		query = query.Where("provider_id = ?", req.ProviderID)
+	}
+	if req.GroupID > 0 {
+		query = query.Where("group_id = ?", req.GroupID)
+	}
+	for _, tag := range req.Tags {
+		key, value, hasValue := strings.Cut(tag, ":")
+		tagQuery := s.db.Model(&model.ServerTag{}).Select("server_id").Where("key = ?", key)
+		if hasValue {
+			tagQuery = tagQuery.Where("value = ?", value)
+		}
+		query = query.Where("id IN (?)", tagQuery)
+	}
+	if keyword := strings.TrimSpace(req.Keyword); keyword != "" {
+		like := "%" + likeEscaper.Replace(keyword) + "%"
+		query = query.Where("name ILIKE ? OR public_ip LIKE ? OR private_ip LIKE ? OR instance_id LIKE ?", like, like, like, like)
+	}
+
 	// 获取总数
-	if err := s.db.Model(&model.Server{}).Where("user_id = ?", req.UserID).Count(&totalCount).Error; err != nil {
+	if err := query.Count(&totalCount).Error; err != nil {
 		return nil, fmt.Errorf("获取服务器总数失败: %w", err)
 	}
 
+	// 排序，默认按创建时间倒序，按到期时间排序时默认最先到期的在前
+	column, ok := serverSortColumns[req.Sort]
+	if !ok {
+		column = "created_at"
+	}
+	direction := req.Order
+	if direction != "asc" && direction != "desc" {
+		direction = "desc"
+		if column == "expire_time" || column == "name" {
+			direction = "asc"
+		}
+	}
+
 	// 获取分页数据
 	offset := (req.Page - 1) * req.Size
-	if err := s.db.Preload("Provider").Preload("Product").Preload("Order").
-		Where("user_id = ?", req.UserID).
+	if err := query.Preload("Provider").Preload("Product").Preload("Order").Preload("Group").
+		Preload("Tags", func(db *gorm.DB) *gorm.DB {
+			return db.Order("key")
+		}).
 		Offset(offset).Limit(req.Size).
-		Order("created_at DESC").
+		Order(column + " " + direction).Order("id DESC").
 		Find(&servers).Error; err != nil {
 		return nil, fmt.Errorf("获取服务器列表失败: %w", err)
 	}
@@ -92,13 +167,19 @@ func (s *ServerService) GetProducts(ctx context.Context, req *GetProductsRequest
 	}
 
 	// 构建查询条件
-	query := s.db.Preload("Provider").Where("status = ?", model.ProductStatusOnline)
+	query := s.db.Preload("Provider").
+		Preload("Regions", "status = ?", model.ProductRegionStatusActive).
+		Where("status = ?", model.ProductStatusOnline)
 	
 	if req.ProviderID > 0 {
 		query = query.Where("provider_id = ?", req.ProviderID)
 	}
 	if req.Region != "" {
-		query = query.Where("region = ?", req.Region)
+		// 配置了可售地域的产品按配置筛选，未配置的按产品自身的地域筛选
+		query = query.Where("id IN (?) OR (region = ? AND NOT EXISTS (SELECT 1 FROM product_regions pr WHERE pr.product_id = products.id))",
+			s.db.Model(&model.ProductRegion{}).Select("product_id").
+				Where("region = ? AND status = ?", req.Region, model.ProductRegionStatusActive),
+			req.Region)
 	}
 	if req.Type != "" {
 		query = query.Where("type = ?", req.Type)
@@ -117,161 +198,751 @@ func (s *ServerService) GetProducts(ctx context.Context, req *GetProductsRequest
 
 // PurchaseServerRequest 购买服务器请求
 type PurchaseServerRequest struct {
-	UserID    uint   `json:"user_id"`
-	ProductID uint   `json:"product_id" binding:"required"`
-	Name      string `json:"name" binding:"required"`
-	Period    int    `json:"period" binding:"required"`
-	Quantity  int    `json:"quantity"`
-	ImageID   string `json:"image_id"`
-	Password  string `json:"password"`
-	AutoRenew bool   `json:"auto_renew"`
-}
-
-// PurchaseServerResponse 购买服务器响应
-type PurchaseServerResponse struct {
-	OrderID   uint   `json:"order_id"`
-	OrderNo   string `json:"order_no"`
-	PayAmount float64 `json:"pay_amount"`
-}
-
-// PurchaseServer 购买服务器
-func (s *ServerService) PurchaseServer(ctx context.Context, req *PurchaseServerRequest) (*PurchaseServerResponse, error) {
-	// 获取产品信息
+	UserID             uint              `json:"user_id"`
+	ProductID          uint              `json:"product_id" binding:"required"`
+	Name               string            `json:"name" binding:"required"` // 名称模板，{n}替换为序号，如 web-{n}
+	Period             int               `json:"period" binding:"required"`
+	Quantity           int               `json:"quantity"`                    // 购买台数，默认1
+	Region             string            `json:"region"`                      // 地域，默认产品所在地域
+	Zone               string            `json:"zone"`                        // 可用区，默认由厂商分配
+	ImageID            string            `json:"image_id" binding:"required"` // 镜像，须为产品所在地域下可选的镜像
+	Password           string            `json:"password"`
+	SSHKeyIDs          []uint            `json:"ssh_key_ids"`           // 注入的公钥
+	DisablePassword    bool              `json:"disable_password"`      // 禁用密码登录，须至少选择一个公钥
+	UserData           string            `json:"user_data"`             // 自定义用户数据，cloud-config 或 shell 脚本
+	UserDataTemplateID uint              `json:"user_data_template_id"` // 用户数据模板，与自定义用户数据二选一
+	UserDataVars       map[string]string `json:"user_data_vars"`        // 模板变量
+	AutoRenew          bool              `json:"auto_renew"`
+	CouponCode         string            `json:"coupon_code"`
+	Currency           string            `json:"currency"`   // 结算币种，默认用户设置的币种
+	PayMethod          string            `json:"pay_method"` // balance、wechat、alipay，默认balance
+	PayType            string            `json:"pay_type"`   // 第三方支付方式：qrcode、redirect
+	ClientIP           string            `json:"client_ip"`
+}
+
+// CheckoutResponse 下单响应
+type CheckoutResponse struct {
+	OrderID        uint       `json:"order_id"`
+	OrderNo        string     `json:"order_no"`
+	Status         string     `json:"status"`
+	Currency       string     `json:"currency"`
+	Amount         decimal.Decimal `json:"amount"`
+	DiscountAmount decimal.Decimal `json:"discount_amount"`
+	TaxAmount      decimal.Decimal `json:"tax_amount"`
+	PayAmount      decimal.Decimal `json:"pay_amount"`
+	PayMethod      string     `json:"pay_method"`
+	PaymentNo      string     `json:"payment_no,omitempty"`
+	PayType        string     `json:"pay_type,omitempty"`
+	CodeURL        string     `json:"code_url,omitempty"`
+	PayURL         string     `json:"pay_url,omitempty"`
+	PayExpireAt    *time.Time `json:"pay_expire_at,omitempty"`
+}
+
+// PurchaseServer 购买服务器，余额支付立即开通，第三方支付在回调到账后开通
+func (s *ServerService) PurchaseServer(ctx context.Context, req *PurchaseServerRequest) (*CheckoutResponse, error) {
+	quote, err := s.pricingService.Quote(ctx, &QuoteRequest{
+		UserID:     req.UserID,
+		Type:       model.OrderTypeNew,
+		ProductID:  req.ProductID,
+		Period:     req.Period,
+		Quantity:   req.Quantity,
+		Region:     req.Region,
+		Zone:       req.Zone,
+		CouponCode: req.CouponCode,
+		Currency:   req.Currency,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// 校验所选地域下的镜像，所选操作系统记录到订单中，开通时写入服务器
 	var product model.Product
-	if err := s.db.Preload("Provider").First(&product, req.ProductID).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, errors.New("产品不存在")
-		}
+	if err := s.db.First(&product, quote.ProductID).Error; err != nil {
 		return nil, fmt.Errorf("获取产品信息失败: %w", err)
 	}
+	product.Region = quote.Region
+	image, err := purchaseImage(s.db, &product, req.ImageID)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateLoginOptions(s.db, req.UserID, image.OSType, req.SSHKeyIDs, req.DisablePassword); err != nil {
+		return nil, err
+	}
+
+	// 用户数据按每台服务器的名称和地域渲染后校验
+	userData, err := resolveUserData(s.db, req.UserID, req.UserData, req.UserDataTemplateID, req.UserDataVars)
+	if err != nil {
+		return nil, err
+	}
+	servers := make([]model.Server, 0, quote.Quantity)
+	for n := 1; n <= quote.Quantity; n++ {
+		servers = append(servers, model.Server{Name: serverName(req.Name, n, quote.Quantity), Region: quote.Region, Zone: quote.Zone})
+	}
+	if err := validateServerUserData(userData, servers); err != nil {
+		return nil, err
+	}
 
-	// 检查产品状态
-	if product.Status != model.ProductStatusOnline {
-		return nil, errors.New("产品已下架")
+	config, err := json.Marshal(orderConfig{
+		Name:            req.Name,
+		Region:          quote.Region,
+		Zone:            quote.Zone,
+		ImageID:         image.ImageID,
+		OSType:          image.OSType,
+		OSName:          image.OSName,
+		Password:        req.Password,
+		SSHKeyIDs:       req.SSHKeyIDs,
+		DisablePassword: req.DisablePassword,
+		UserData:        userData,
+		AutoRenew:       req.AutoRenew,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("序列化订单配置失败: %w", err)
 	}
 
-	// 检查云厂商状态
-	if product.Provider.Status != model.ProviderStatusActive {
-		return nil, errors.New("云厂商暂不可用")
+	order := &model.Order{
+		UserID:    req.UserID,
+		Type:      model.OrderTypeNew,
+		PayMethod: req.PayMethod,
+		Config:    string(config),
+	}
+	return s.checkout(ctx, order, quote, req.PayType, req.ClientIP)
+}
+
+// RenewServerRequest 续费服务器请求
+type RenewServerRequest struct {
+	ServerID   uint   `json:"server_id"`
+	UserID     uint   `json:"user_id"`
+	Period     int    `json:"period" binding:"required,min=1"`
+	CouponCode string `json:"coupon_code"`
+	Currency   string `json:"currency"`   // 结算币种，默认用户设置的币种
+	PayMethod  string `json:"pay_method"` // balance、wechat、alipay，默认balance
+	PayType    string `json:"pay_type"`   // 第三方支付方式：qrcode、redirect
+	ClientIP   string `json:"client_ip"`
+}
+
+// RenewServer 续费服务器，支付完成后由开通服务续费厂商实例，再从当前到期时间顺延
+func (s *ServerService) RenewServer(ctx context.Context, req *RenewServerRequest) (*CheckoutResponse, error) {
+	quote, err := s.pricingService.Quote(ctx, &QuoteRequest{
+		UserID:     req.UserID,
+		Type:       model.OrderTypeRenew,
+		ServerID:   req.ServerID,
+		Period:     req.Period,
+		CouponCode: req.CouponCode,
+		Currency:   req.Currency,
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	// 获取用户信息
-	var user model.User
-	if err := s.db.First(&user, req.UserID).Error; err != nil {
-		return nil, fmt.Errorf("获取用户信息失败: %w", err)
+	config, err := json.Marshal(orderConfig{ServerID: req.ServerID})
+	if err != nil {
+		return nil, fmt.Errorf("序列化订单配置失败: %w", err)
 	}
 
-	// 设置默认值
-	if req.Quantity == 0 {
-		req.Quantity = 1
+	order := &model.Order{
+		UserID:    req.UserID,
+		Type:      model.OrderTypeRenew,
+		PayMethod: req.PayMethod,
+		Config:    string(config),
 	}
+	return s.checkout(ctx, order, quote, req.PayType, req.ClientIP)
+}
 
-	// 计算价格
-	amount := product.Price * float64(req.Period) * float64(req.Quantity)
-	discountAmount := 0.0
-	payAmount := amount - discountAmount
+// checkout 按报价创建订单并支付：余额支付立即扣款并履约，第三方支付向通道下单后等待回调
+func (s *ServerService) checkout(ctx context.Context, order *model.Order, quote *QuoteResponse, payType, clientIP string) (*CheckoutResponse, error) {
+	// 设置默认值，无需支付的订单直接走余额流程
+	if order.PayMethod == "" || !quote.PayAmount.IsPositive() {
+		order.PayMethod = model.PaymentMethodBalance
+	}
 
-	// 检查余额
-	if user.Balance < payAmount {
-		return nil, errors.New("余额不足")
+	// 第三方支付需先确认支付方式可用
+	if order.PayMethod != model.PaymentMethodBalance {
+		if _, err := s.paymentService.GetGateway(order.PayMethod); err != nil {
+			return nil, err
+		}
 	}
 
-	// 生成订单号
-	orderNo := fmt.Sprintf("ORD%d%d", time.Now().Unix(), req.UserID)
+	order.OrderNo = generateNo("ORD")
+	order.ProviderID = quote.ProviderID
+	order.ProductID = quote.ProductID
+	order.Status = model.OrderStatusPending
+	order.Amount = quote.Amount
+	order.DiscountAmount = quote.DiscountAmount
+	order.TaxAmount = quote.TaxAmount
+	order.PayAmount = quote.PayAmount
+	order.Currency = quote.Currency
+	order.ExchangeRate = quote.ExchangeRate
+	order.BasePayAmount = quote.BasePayAmount
+	order.Period = quote.Period
+	order.Quantity = quote.Quantity
+	order.CouponID = quote.Discount.CouponID
+	order.PromotionID = quote.Discount.PromotionID
+
+	// 第三方支付的订单等待回调，超时自动关闭
+	if order.PayMethod != model.PaymentMethodBalance {
+		payExpireAt := time.Now().Add(payExpireDuration)
+		order.PayExpireAt = &payExpireAt
+	}
 
 	// 开始事务
-	var result *PurchaseServerResponse
+	var payment model.Payment
 	err := s.db.Transaction(func(tx *gorm.DB) error {
 		// 创建订单
-		order := model.Order{
-			UserID:         req.UserID,
-			OrderNo:        orderNo,
-			ProviderID:     product.ProviderID,
-			ProductID:      req.ProductID,
-			Type:           model.OrderTypeNew,
-			Status:         model.OrderStatusPending,
-			Amount:         amount,
-			DiscountAmount: discountAmount,
-			PayAmount:      payAmount,
-			PayMethod:      model.PaymentMethodBalance,
-			Period:         req.Period,
-			Quantity:       req.Quantity,
-			Config:         fmt.Sprintf(`{"name": "%s", "image_id": "%s", "password": "%s", "auto_renew": %t}`, req.Name, req.ImageID, req.Password, req.AutoRenew),
+		if err := tx.Create(order).Error; err != nil {
+			return fmt.Errorf("创建订单失败: %w", err)
 		}
 
-		if err := tx.Create(&order).Error; err != nil {
-			return fmt.Errorf("创建订单失败: %w", err)
+		// 占用优惠券
+		if err := reserveCoupon(tx, quote.Discount, order); err != nil {
+			return err
 		}
 
-		// 扣除余额
-		if err := tx.Model(&user).Update("balance", gorm.Expr("balance - ?", payAmount)).Error; err != nil {
-			return fmt.Errorf("扣除余额失败: %w", err)
+		// 新购占用库存
+		if order.Type == model.OrderTypeNew {
+			if err := reserveStock(tx, order); err != nil {
+				return err
+			}
 		}
 
 		// 创建支付记录
-		payment := model.Payment{
-			OrderID:       order.ID,
-			UserID:        req.UserID,
-			PaymentNo:     fmt.Sprintf("PAY%d%d", time.Now().Unix(), req.UserID),
-			Method:        model.PaymentMethodBalance,
-			Amount:        payAmount,
-			Status:        model.PaymentStatusSuccess,
-			PayTime:       &[]time.Time{time.Now()}[0],
+		payment = model.Payment{
+			OrderID:   order.ID,
+			UserID:    order.UserID,
+			PaymentNo: generateNo("PAY"),
+			Method:    order.PayMethod,
+			Amount:    order.PayAmount,
+			Currency:  order.Currency,
+			Status:    model.PaymentStatusPending,
+		}
+
+		if order.PayMethod != model.PaymentMethodBalance {
+			if err := tx.Create(&payment).Error; err != nil {
+				return fmt.Errorf("创建支付记录失败: %w", err)
+			}
+			return nil
+		}
+
+		// 扣除余额（本位币），余额校验在钱包账户行锁内完成
+		if order.BasePayAmount.IsPositive() {
+			if _, err := s.ledgerService.DebitWallet(tx, &WalletPostingRequest{
+				UserID:      order.UserID,
+				Amount:      order.BasePayAmount,
+				Type:        orderLedgerTxType(order.Type),
+				OrderID:     &order.ID,
+				Description: orderDescription(order),
+			}); err != nil {
+				if errors.Is(err, ErrInsufficientBalance) {
+					return err
+				}
+				return fmt.Errorf("扣除余额失败: %w", err)
+			}
 		}
 
+		now := time.Now()
+		payment.Status = model.PaymentStatusSuccess
+		payment.PayTime = &now
 		if err := tx.Create(&payment).Error; err != nil {
 			return fmt.Errorf("创建支付记录失败: %w", err)
 		}
 
 		// 更新订单状态
-		now := time.Now()
-		if err := tx.Model(&order).Updates(map[string]interface{}{
+		if err := tx.Model(order).Updates(map[string]interface{}{
 			"status":   model.OrderStatusPaid,
 			"pay_time": &now,
 		}).Error; err != nil {
 			return fmt.Errorf("更新订单状态失败: %w", err)
 		}
+		order.Status = model.OrderStatusPaid
+		order.PayTime = &now
 
-		// 创建服务器记录
-		expireTime := time.Now().AddDate(0, req.Period, 0)
-		server := model.Server{
-			UserID:     req.UserID,
-			OrderID:    order.ID,
-			ProviderID: product.ProviderID,
-			ProductID:  req.ProductID,
-			Name:       req.Name,
-			InstanceID: fmt.Sprintf("placeholder-%d", time.Now().Unix()),
-			Region:     product.Region,
-			Zone:       product.Zone,
-			Status:     model.ServerStatusCreating,
-			ExpireTime: expireTime,
-			AutoRenew:  req.AutoRenew,
-			Password:   req.Password,
-			OSType:     product.OS,
-			CPU:        product.CPU,
-			Memory:     product.Memory,
-			Storage:    product.Storage,
-			Bandwidth:  product.Bandwidth,
-			Traffic:    product.Traffic,
-		}
-
-		if err := tx.Create(&server).Error; err != nil {
-			return fmt.Errorf("创建服务器记录失败: %w", err)
-		}
-
-		result = &PurchaseServerResponse{
-			OrderID:   order.ID,
-			OrderNo:   orderNo,
-			PayAmount: payAmount,
+		return fulfillOrder(tx, order)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	result := &CheckoutResponse{
+		OrderID:        order.ID,
+		OrderNo:        order.OrderNo,
+		Status:         order.Status,
+		Currency:       order.Currency,
+		Amount:         order.Amount,
+		DiscountAmount: order.DiscountAmount,
+		TaxAmount:      order.TaxAmount,
+		PayAmount:      order.PayAmount,
+		PayMethod:      order.PayMethod,
+		PaymentNo:      payment.PaymentNo,
+	}
+
+	if order.PayMethod == model.PaymentMethodBalance {
+		return result, nil
+	}
+
+	// 向支付通道下单
+	payResp, err := s.paymentService.CreateOrderPayment(ctx, order, &payment, payType, clientIP)
+	if err != nil {
+		return nil, err
+	}
+
+	result.PayType = payResp.PayType
+	result.CodeURL = payResp.CodeURL
+	result.PayURL = payResp.PayURL
+	result.PayExpireAt = order.PayExpireAt
+	return result, nil
+}
+
+// ChangePlanRequest 变更套餐请求
+type ChangePlanRequest struct {
+	ServerID   uint   `json:"server_id"`
+	UserID     uint   `json:"user_id"`
+	ProductID  uint   `json:"product_id" binding:"required"`
+	CouponCode string `json:"coupon_code"` // 仅升配补差价时可用
+}
+
+// ChangePlanResponse 变更套餐响应
+type ChangePlanResponse struct {
+	OrderID        uint            `json:"order_id"`
+	OrderNo        string          `json:"order_no"`
+	Type           string          `json:"type"`           // upgrade、downgrade
+	RemainingDays  int             `json:"remaining_days"` // 剩余天数
+	Currency       string          `json:"currency"`
+	Items          []QuoteItem     `json:"items"` // 计价明细
+	DiscountAmount decimal.Decimal `json:"discount_amount"`
+	TaxAmount      decimal.Decimal `json:"tax_amount"`
+	PayAmount      decimal.Decimal `json:"pay_amount"` // 正数为补缴金额，负数为退还至余额的金额
+}
+
+// changePlanConfig 变更套餐订单配置
+type changePlanConfig struct {
+	ServerID      uint   `json:"server_id"`
+	FromProductID uint   `json:"from_product_id"`
+	ToProductID   uint   `json:"to_product_id"`
+	RemainingDays int    `json:"remaining_days"`
+	ServerStatus  string `json:"server_status"` // 变更前的服务器状态，变更结束后恢复
+}
+
+const (
+	// changePlanTimeout 调用厂商变更规格的超时时间，不随客户端断开而取消
+	changePlanTimeout = 5 * time.Minute
+	// changePlanStaleDuration 处理中的变更套餐订单超过该时长视为中断，由后台任务向厂商核对后完成或退款
+	changePlanStaleDuration = 15 * time.Minute
+)
+
+// errChangePlanSettled 变更套餐订单已被其他流程完成或退款
+var errChangePlanSettled = errors.New("变更套餐订单已处理")
+
+// ChangePlan 变更服务器套餐（升降配），按剩余时长折算差价。
+// 先在服务器行锁内扣除补缴金额并创建处理中的订单，提交后再调用厂商变更规格，
+// 厂商调用不占用数据库事务；变更成功后完成订单并退还降配差价，失败时退回补缴金额。
+// 进程中断遗留的处理中订单由 ReconcileChangePlans 核对厂商规格后处理
+func (s *ServerService) ChangePlan(ctx context.Context, req *ChangePlanRequest) (*ChangePlanResponse, error) {
+	quote, err := s.pricingService.Quote(ctx, &QuoteRequest{
+		UserID:     req.UserID,
+		Type:       model.OrderTypeUpgrade,
+		ProductID:  req.ProductID,
+		ServerID:   req.ServerID,
+		CouponCode: req.CouponCode,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// 厂商不支持变更规格时不创建订单也不扣款
+	caps, err := s.providerService.ServerCapabilities(quote.ServerID)
+	if err != nil {
+		return nil, err
+	}
+	if !caps.Resize {
+		return nil, errors.New("该厂商不支持变更配置")
+	}
+
+	var product model.Product
+	if err := s.db.First(&product, quote.ProductID).Error; err != nil {
+		return nil, fmt.Errorf("获取产品信息失败: %w", err)
+	}
+
+	var server model.Server
+	var order model.Order
+	var cfg changePlanConfig
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		// 锁定服务器，同一台服务器的变更套餐串行执行
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ? AND user_id = ?", quote.ServerID, req.UserID).
+			First(&server).Error; err != nil {
+			return fmt.Errorf("获取服务器信息失败: %w", err)
+		}
+		if server.Status != model.ServerStatusRunning && server.Status != model.ServerStatusStopped {
+			return errors.New("当前服务器状态不允许变更套餐")
+		}
+		// 报价后套餐已被其他请求变更，差价需重新计算
+		if server.ProductID != quote.FromProductID {
+			return errors.New("服务器套餐已变更，请刷新后重试")
+		}
+
+		cfg = changePlanConfig{
+			ServerID:      server.ID,
+			FromProductID: quote.FromProductID,
+			ToProductID:   product.ID,
+			RemainingDays: quote.RemainingDays,
+			ServerStatus:  server.Status,
+		}
+		config, err := json.Marshal(cfg)
+		if err != nil {
+			return fmt.Errorf("序列化订单配置失败: %w", err)
+		}
+
+		now := time.Now()
+
+		// 创建处理中的订单，降配时金额为负数表示退还
+		order = model.Order{
+			UserID:         req.UserID,
+			OrderNo:        generateNo("ORD"),
+			ProviderID:     product.ProviderID,
+			ProductID:      product.ID,
+			Type:           quote.Type,
+			Status:         model.OrderStatusProcessing,
+			Amount:         quote.Amount,
+			DiscountAmount: quote.DiscountAmount,
+			TaxAmount:      quote.TaxAmount,
+			PayAmount:      quote.PayAmount,
+			Currency:       quote.Currency,
+			ExchangeRate:   quote.ExchangeRate,
+			BasePayAmount:  quote.BasePayAmount,
+			CouponID:       quote.Discount.CouponID,
+			PromotionID:    quote.Discount.PromotionID,
+			PayMethod:      model.PaymentMethodBalance,
+			PayTime:        &now,
+			Period:         0,
+			Quantity:       1,
+			Config:         string(config),
+		}
+
+		if err := tx.Create(&order).Error; err != nil {
+			return fmt.Errorf("创建订单失败: %w", err)
+		}
+
+		if err := reserveCoupon(tx, quote.Discount, &order); err != nil {
+			return err
+		}
+
+		// 升配先扣除补差价（本位币），降配的退还差价在变更成功后入账
+		if order.BasePayAmount.IsPositive() {
+			if _, err := s.ledgerService.DebitWallet(tx, &WalletPostingRequest{
+				UserID:      req.UserID,
+				Amount:      order.BasePayAmount,
+				Type:        model.LedgerTxTypeUpgrade,
+				OrderID:     &order.ID,
+				Description: fmt.Sprintf("服务器 %s 升级补差价", server.Name),
+			}); err != nil {
+				if errors.Is(err, ErrInsufficientBalance) {
+					return err
+				}
+				return fmt.Errorf("更新余额失败: %w", err)
+			}
+
+			payment := model.Payment{
+				OrderID:   order.ID,
+				UserID:    req.UserID,
+				PaymentNo: generateNo("PAY"),
+				Method:    model.PaymentMethodBalance,
+				Amount:    order.PayAmount,
+				Currency:  order.Currency,
+				Status:    model.PaymentStatusSuccess,
+				PayTime:   &now,
+			}
+			if err := tx.Create(&payment).Error; err != nil {
+				return fmt.Errorf("创建支付记录失败: %w", err)
+			}
+		}
+
+		// 变更期间服务器不可再次变更套餐或进行其他操作
+		if err := tx.Model(&model.Server{}).Where("id = ?", server.ID).
+			Update("status", model.ServerStatusResizing).Error; err != nil {
+			return fmt.Errorf("更新服务器状态失败: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// 调用厂商变更规格，客户端断开不会中断厂商调用
+	resizeCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), changePlanTimeout)
+	defer cancel()
+	if err := s.providerService.ResizeInstance(resizeCtx, &ResizeInstanceRequest{
+		ServerID:     server.ID,
+		InstanceType: product.Code,
+	}); err != nil {
+		if rollbackErr := s.failChangePlan(&order, &cfg); rollbackErr != nil {
+			// 订单保持处理中，由后台任务核对厂商规格后处理
+			logger.Log.Error("变更套餐失败后退款失败", zap.String("order_no", order.OrderNo), zap.Error(rollbackErr))
+		}
+		return nil, err
+	}
+
+	if err := s.completeChangePlan(&order, &cfg, &product); err != nil {
+		// 厂商已完成变更，订单保持处理中，由后台任务核对厂商规格后完成
+		logger.Log.Error("完成变更套餐订单失败", zap.String("order_no", order.OrderNo), zap.Error(err))
+		return nil, err
+	}
+
+	return &ChangePlanResponse{
+		OrderID:        order.ID,
+		OrderNo:        order.OrderNo,
+		Type:           quote.Type,
+		RemainingDays:  quote.RemainingDays,
+		Currency:       quote.Currency,
+		Items:          quote.Items,
+		DiscountAmount: quote.DiscountAmount,
+		TaxAmount:      quote.TaxAmount,
+		PayAmount:      quote.PayAmount,
+	}, nil
+}
+
+// ReconcileChangePlans 核对中断的变更套餐订单：厂商实例已是目标规格的完成订单，否则退回补缴金额并恢复服务器
+func (s *ServerService) ReconcileChangePlans(ctx context.Context) error {
+	var orders []model.Order
+	if err := s.db.WithContext(ctx).
+		Where("type IN ? AND status = ? AND updated_at < ?",
+			[]string{model.OrderTypeUpgrade, model.OrderTypeDowngrade}, model.OrderStatusProcessing,
+			time.Now().Add(-changePlanStaleDuration)).
+		Order("id").Find(&orders).Error; err != nil {
+		return fmt.Errorf("获取处理中的变更套餐订单失败: %w", err)
+	}
+
+	for i := range orders {
+		if err := s.reconcileChangePlan(ctx, &orders[i]); err != nil {
+			logger.Log.Error("核对变更套餐订单失败", zap.String("order_no", orders[i].OrderNo), zap.Error(err))
+		}
+	}
+	return nil
+}
+
+// reconcileChangePlan 向厂商查询实例规格后完成或退款单个变更套餐订单
+func (s *ServerService) reconcileChangePlan(ctx context.Context, order *model.Order) error {
+	var cfg changePlanConfig
+	if err := json.Unmarshal([]byte(order.Config), &cfg); err != nil {
+		return fmt.Errorf("解析订单配置失败: %w", err)
+	}
+
+	var product model.Product
+	if err := s.db.First(&product, cfg.ToProductID).Error; err != nil {
+		return fmt.Errorf("获取产品信息失败: %w", err)
+	}
+
+	detail, err := s.providerService.GetInstanceDetail(ctx, &GetInstanceDetailRequest{ServerID: cfg.ServerID})
+	if err != nil {
+		return err
+	}
+
+	if detail.InstanceType == product.Code {
+		err = s.completeChangePlan(order, &cfg, &product)
+	} else {
+		err = s.failChangePlan(order, &cfg)
+	}
+	if err != nil && !errors.Is(err, errChangePlanSettled) {
+		return err
+	}
+	logger.Log.Info("变更套餐订单核对完成", zap.String("order_no", order.OrderNo),
+		zap.String("instance_type", detail.InstanceType), zap.String("target", product.Code))
+	return nil
+}
+
+// settleChangePlan 将处理中的变更套餐订单更新为最终状态，已被其他流程处理时返回 errChangePlanSettled
+func settleChangePlan(tx *gorm.DB, order *model.Order, status string) error {
+	result := tx.Model(&model.Order{}).
+		Where("id = ? AND status = ?", order.ID, model.OrderStatusProcessing).
+		Update("status", status)
+	if result.Error != nil {
+		return fmt.Errorf("更新订单状态失败: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return errChangePlanSettled
+	}
+	order.Status = status
+	return nil
+}
+
+// completeChangePlan 厂商变更成功后更新服务器配置、退还降配差价并完成订单
+func (s *ServerService) completeChangePlan(order *model.Order, cfg *changePlanConfig, product *model.Product) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := settleChangePlan(tx, order, model.OrderStatusSuccess); err != nil {
+			return err
+		}
+
+		var server model.Server
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&server, cfg.ServerID).Error; err != nil {
+			return fmt.Errorf("获取服务器信息失败: %w", err)
+		}
+		if err := tx.Model(&server).Updates(map[string]interface{}{
+			"product_id": product.ID,
+			"cpu":        product.CPU,
+			"memory":     product.Memory,
+			"storage":    product.Storage,
+			"bandwidth":  product.Bandwidth,
+			"traffic":    product.Traffic,
+			"status":     cfg.ServerStatus,
+		}).Error; err != nil {
+			return fmt.Errorf("更新服务器配置失败: %w", err)
+		}
+
+		if order.BasePayAmount.IsNegative() {
+			if _, err := s.ledgerService.CreditWallet(tx, &WalletPostingRequest{
+				UserID:      order.UserID,
+				Amount:      order.BasePayAmount.Abs(),
+				Type:        model.LedgerTxTypeDowngrade,
+				OrderID:     &order.ID,
+				Description: fmt.Sprintf("服务器 %s 降级退差价", server.Name),
+			}); err != nil {
+				return fmt.Errorf("更新余额失败: %w", err)
+			}
 		}
 		return nil
 	})
+}
+
+// failChangePlan 厂商变更失败时退回补缴金额、释放优惠券，服务器恢复原状态
+func (s *ServerService) failChangePlan(order *model.Order, cfg *changePlanConfig) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := settleChangePlan(tx, order, model.OrderStatusFailed); err != nil {
+			return err
+		}
+
+		var server model.Server
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&server, cfg.ServerID).Error; err != nil {
+			return fmt.Errorf("获取服务器信息失败: %w", err)
+		}
+		if server.Status == model.ServerStatusResizing {
+			if err := tx.Model(&server).Update("status", cfg.ServerStatus).Error; err != nil {
+				return fmt.Errorf("更新服务器状态失败: %w", err)
+			}
+		}
+
+		if order.BasePayAmount.IsPositive() {
+			if _, err := s.ledgerService.CreditWallet(tx, &WalletPostingRequest{
+				UserID:      order.UserID,
+				Amount:      order.BasePayAmount,
+				Type:        model.LedgerTxTypeRefund,
+				OrderID:     &order.ID,
+				Description: fmt.Sprintf("服务器 %s 变更套餐失败退款", server.Name),
+			}); err != nil {
+				return fmt.Errorf("更新余额失败: %w", err)
+			}
+
+			now := time.Now()
+			record := model.Payment{
+				OrderID:   order.ID,
+				UserID:    order.UserID,
+				PaymentNo: generateNo("RFD"),
+				Type:      model.PaymentTypeRefund,
+				Method:    model.PaymentMethodBalance,
+				Amount:    order.PayAmount,
+				Currency:  order.Currency,
+				Status:    model.PaymentStatusSuccess,
+				PayTime:   &now,
+				Remark:    "变更套餐失败退款",
+			}
+			if err := tx.Create(&record).Error; err != nil {
+				return fmt.Errorf("创建退款记录失败: %w", err)
+			}
+		}
+
+		return releaseCoupon(tx, order.ID)
+	})
+}
+
+// RebuildServerRequest 重装系统请求
+type RebuildServerRequest struct {
+	ServerID           uint              `json:"server_id"`
+	UserID             uint              `json:"user_id"`
+	ImageID            string            `json:"image_id" binding:"required"` // 镜像，须为服务器所在地域下可选的镜像
+	Password           string            `json:"password"`                    // 新登录密码，为空时沿用原密码
+	SSHKeyIDs          []uint            `json:"ssh_key_ids"`                 // 注入的公钥
+	DisablePassword    bool              `json:"disable_password"`            // 禁用密码登录，须至少选择一个公钥
+	UserData           string            `json:"user_data"`                   // 自定义用户数据，cloud-config 或 shell 脚本
+	UserDataTemplateID uint              `json:"user_data_template_id"`       // 用户数据模板，与自定义用户数据二选一
+	UserDataVars       map[string]string `json:"user_data_vars"`              // 模板变量
+}
+
+// RebuildServer 重装服务器系统，可更换镜像并重新选择注入的公钥
+func (s *ServerService) RebuildServer(ctx context.Context, req *RebuildServerRequest) (*model.Server, error) {
+	var server model.Server
+	if err := s.db.Where("id = ? AND user_id = ?", req.ServerID, req.UserID).First(&server).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("服务器不存在")
+		}
+		return nil, fmt.Errorf("获取服务器信息失败: %w", err)
+	}
+	if server.Status != model.ServerStatusRunning && server.Status != model.ServerStatusStopped {
+		return nil, errors.New("当前状态的服务器不能重装系统")
+	}
 
+	// 按服务器所在地域和当前配置校验镜像
+	var product model.Product
+	if err := s.db.First(&product, server.ProductID).Error; err != nil {
+		return nil, fmt.Errorf("获取产品信息失败: %w", err)
+	}
+	product.Region = server.Region
+	product.Storage = server.Storage
+	product.Memory = server.Memory
+	image, err := purchaseImage(s.db, &product, req.ImageID)
 	if err != nil {
 		return nil, err
 	}
+	if err := validateLoginOptions(s.db, req.UserID, image.OSType, req.SSHKeyIDs, req.DisablePassword); err != nil {
+		return nil, err
+	}
 
-	return result, nil
+	password := req.Password
+	if password == "" {
+		password = server.Password
+	}
+
+	userData, err := resolveUserData(s.db, req.UserID, req.UserData, req.UserDataTemplateID, req.UserDataVars)
+	if err != nil {
+		return nil, err
+	}
+	userData = renderServerUserData(userData, &server)
+	if userData != "" {
+		if _, err := validateUserData(userData); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := s.providerService.RebuildInstance(ctx, &RebuildInstanceRequest{
+		ServerID:        server.ID,
+		ImageID:         image.ImageID,
+		Password:        password,
+		SSHKeyIDs:       req.SSHKeyIDs,
+		DisablePassword: req.DisablePassword,
+		UserData:        userData,
+	}); err != nil {
+		return nil, err
+	}
+
+	server.ImageID = image.ImageID
+	server.OSType = image.OSType
+	server.OSName = image.OSName
+	server.Password = password
+	server.SSHKeyIDs = req.SSHKeyIDs
+	server.DisablePassword = req.DisablePassword
+	if err := s.db.Model(&server).Select("image_id", "os_type", "os_name", "password", "ssh_key_ids", "disable_password").
+		Updates(&server).Error; err != nil {
+		return nil, fmt.Errorf("更新服务器信息失败: %w", err)
+	}
+
+	return &server, nil
+}
+
+// generateNo 生成业务单号，同一秒内及多实例间均不会重复
+func generateNo(prefix string) string {
+	return idgen.Next(prefix)
+}
+
+// roundAmount 金额保留两位小数
+func roundAmount(amount decimal.Decimal) decimal.Decimal {
+	return amount.Round(2)
 }
 
 // GetServerDetailRequest 获取服务器详情请求
@@ -283,7 +954,10 @@ type GetServerDetailRequest struct {
 // GetServerDetail 获取服务器详情
 func (s *ServerService) GetServerDetail(ctx context.Context, req *GetServerDetailRequest) (*model.Server, error) {
 	var server model.Server
-	if err := s.db.Preload("Provider").Preload("Product").Preload("Order").
+	if err := s.db.Preload("Provider").Preload("Product").Preload("Order").Preload("Group").
+		Preload("Tags", func(db *gorm.DB) *gorm.DB {
+			return db.Order("key")
+		}).
 		Where("id = ? AND user_id = ?", req.ServerID, req.UserID).
 		First(&server).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
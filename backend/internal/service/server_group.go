@@ -0,0 +1,310 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"cloudbp-backend/internal/model"
+
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	maxServerGroupsPerUser = 50  // 每个用户最多创建的分组数
+	maxTagsPerServer       = 20  // 每台服务器最多的标签数
+	maxTagValueLength      = 128 // 标签值最大长度
+)
+
+// tagKeyPattern 标签键：中英文、数字和 _ . - / @，不能包含冒号，冒号用于按 键:值 筛选
+var tagKeyPattern = regexp.MustCompile(`^[\p{Han}A-Za-z0-9_.\-/@]{1,64}$`)
+
+// ServerGroupService 服务器分组和标签服务
+type ServerGroupService struct {
+	db  *gorm.DB
+	rdb *redis.Client
+}
+
+// NewServerGroupService 创建服务器分组和标签服务
+func NewServerGroupService(db *gorm.DB, rdb *redis.Client) *ServerGroupService {
+	return &ServerGroupService{
+		db:  db,
+		rdb: rdb,
+	}
+}
+
+// ServerGroupRequest 创建或修改分组请求
+type ServerGroupRequest struct {
+	Name        string `json:"name" binding:"required,max=64"`
+	Description string `json:"description" binding:"max=255"`
+}
+
+// SetServerGroupRequest 设置服务器分组请求
+type SetServerGroupRequest struct {
+	GroupID *uint `json:"group_id"` // 为空时移出分组
+}
+
+// ServerTagInput 标签
+type ServerTagInput struct {
+	Key   string `json:"key" binding:"required"`
+	Value string `json:"value"`
+}
+
+// SetServerTagsRequest 设置服务器标签请求，整体替换服务器现有标签
+type SetServerTagsRequest struct {
+	Tags []ServerTagInput `json:"tags"`
+}
+
+// TagKeyValues 标签键及已使用的值，用于筛选
+type TagKeyValues struct {
+	Key    string   `json:"key"`
+	Values []string `json:"values"`
+}
+
+// GetGroups 获取用户分组及各分组的服务器数量
+func (s *ServerGroupService) GetGroups(ctx context.Context, userID uint) ([]model.ServerGroup, error) {
+	var groups []model.ServerGroup
+	if err := s.db.Where("user_id = ?", userID).Order("name").Find(&groups).Error; err != nil {
+		return nil, fmt.Errorf("获取分组列表失败: %w", err)
+	}
+
+	var counts []struct {
+		GroupID uint
+		Count   int64
+	}
+	if err := s.db.Model(&model.Server{}).Select("group_id, COUNT(*) AS count").
+		Where("user_id = ? AND group_id IS NOT NULL", userID).
+		Group("group_id").Scan(&counts).Error; err != nil {
+		return nil, fmt.Errorf("统计分组服务器数量失败: %w", err)
+	}
+	countByGroup := make(map[uint]int64, len(counts))
+	for _, c := range counts {
+		countByGroup[c.GroupID] = c.Count
+	}
+	for i := range groups {
+		groups[i].ServerCount = countByGroup[groups[i].ID]
+	}
+	return groups, nil
+}
+
+// CreateGroup 创建分组
+func (s *ServerGroupService) CreateGroup(ctx context.Context, userID uint, req *ServerGroupRequest) (*model.ServerGroup, error) {
+	group := model.ServerGroup{
+		UserID:      userID,
+		Name:        strings.TrimSpace(req.Name),
+		Description: strings.TrimSpace(req.Description),
+	}
+	if group.Name == "" {
+		return nil, errors.New("分组名称不能为空")
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		// 锁定用户，避免并发创建超过数量上限
+		var user model.User
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&user, userID).Error; err != nil {
+			return fmt.Errorf("获取用户信息失败: %w", err)
+		}
+
+		var count int64
+		if err := tx.Model(&model.ServerGroup{}).Where("user_id = ?", userID).Count(&count).Error; err != nil {
+			return fmt.Errorf("统计分组数量失败: %w", err)
+		}
+		if count >= maxServerGroupsPerUser {
+			return fmt.Errorf("最多只能创建%d个分组", maxServerGroupsPerUser)
+		}
+		if err := checkGroupName(tx, userID, 0, group.Name); err != nil {
+			return err
+		}
+
+		if err := tx.Create(&group).Error; err != nil {
+			return fmt.Errorf("创建分组失败: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &group, nil
+}
+
+// UpdateGroup 修改分组名称和描述
+func (s *ServerGroupService) UpdateGroup(ctx context.Context, userID, groupID uint, req *ServerGroupRequest) (*model.ServerGroup, error) {
+	group, err := s.userGroup(s.db, userID, groupID)
+	if err != nil {
+		return nil, err
+	}
+
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return nil, errors.New("分组名称不能为空")
+	}
+	if err := checkGroupName(s.db, userID, group.ID, name); err != nil {
+		return nil, err
+	}
+
+	group.Name = name
+	group.Description = strings.TrimSpace(req.Description)
+	if err := s.db.Save(group).Error; err != nil {
+		return nil, fmt.Errorf("修改分组失败: %w", err)
+	}
+	return group, nil
+}
+
+// DeleteGroup 删除分组，分组内的服务器移出分组
+func (s *ServerGroupService) DeleteGroup(ctx context.Context, userID, groupID uint) error {
+	group, err := s.userGroup(s.db, userID, groupID)
+	if err != nil {
+		return err
+	}
+
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&model.Server{}).Where("group_id = ?", group.ID).Update("group_id", nil).Error; err != nil {
+			return fmt.Errorf("移出分组服务器失败: %w", err)
+		}
+		if err := tx.Delete(group).Error; err != nil {
+			return fmt.Errorf("删除分组失败: %w", err)
+		}
+		return nil
+	})
+}
+
+// SetServerGroup 设置服务器所属分组，分组须属于同一用户
+func (s *ServerGroupService) SetServerGroup(ctx context.Context, userID, serverID uint, req *SetServerGroupRequest) error {
+	server, err := s.userServer(userID, serverID)
+	if err != nil {
+		return err
+	}
+	if req.GroupID != nil {
+		if _, err := s.userGroup(s.db, userID, *req.GroupID); err != nil {
+			return err
+		}
+	}
+
+	if err := s.db.Model(server).Update("group_id", req.GroupID).Error; err != nil {
+		return fmt.Errorf("设置服务器分组失败: %w", err)
+	}
+	return nil
+}
+
+// SetServerTags 整体替换服务器标签
+func (s *ServerGroupService) SetServerTags(ctx context.Context, userID, serverID uint, req *SetServerTagsRequest) ([]model.ServerTag, error) {
+	server, err := s.userServer(userID, serverID)
+	if err != nil {
+		return nil, err
+	}
+
+	tags, err := normalizeServerTags(server.ID, req.Tags)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("server_id = ?", server.ID).Delete(&model.ServerTag{}).Error; err != nil {
+			return fmt.Errorf("删除服务器标签失败: %w", err)
+		}
+		if len(tags) == 0 {
+			return nil
+		}
+		if err := tx.Create(&tags).Error; err != nil {
+			return fmt.Errorf("保存服务器标签失败: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return tags, nil
+}
+
+// GetTagKeys 获取用户服务器已使用的标签键和值
+func (s *ServerGroupService) GetTagKeys(ctx context.Context, userID uint) ([]TagKeyValues, error) {
+	var tags []model.ServerTag
+	if err := s.db.Model(&model.ServerTag{}).
+		Distinct("server_tags.key", "server_tags.value").
+		Joins("JOIN servers ON servers.id = server_tags.server_id").
+		Where("servers.user_id = ? AND servers.deleted_at IS NULL", userID).
+		Order("server_tags.key, server_tags.value").
+		Find(&tags).Error; err != nil {
+		return nil, fmt.Errorf("获取标签失败: %w", err)
+	}
+
+	result := make([]TagKeyValues, 0)
+	for _, tag := range tags {
+		if n := len(result); n > 0 && result[n-1].Key == tag.Key {
+			result[n-1].Values = append(result[n-1].Values, tag.Value)
+			continue
+		}
+		result = append(result, TagKeyValues{Key: tag.Key, Values: []string{tag.Value}})
+	}
+	return result, nil
+}
+
+// userGroup 获取用户的分组
+func (s *ServerGroupService) userGroup(db *gorm.DB, userID, groupID uint) (*model.ServerGroup, error) {
+	var group model.ServerGroup
+	if err := db.Where("id = ? AND user_id = ?", groupID, userID).First(&group).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("分组不存在")
+		}
+		return nil, fmt.Errorf("获取分组失败: %w", err)
+	}
+	return &group, nil
+}
+
+// userServer 获取用户的服务器
+func (s *ServerGroupService) userServer(userID, serverID uint) (*model.Server, error) {
+	var server model.Server
+	if err := s.db.Where("id = ? AND user_id = ?", serverID, userID).First(&server).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("服务器不存在")
+		}
+		return nil, fmt.Errorf("获取服务器信息失败: %w", err)
+	}
+	return &server, nil
+}
+
+// checkGroupName 检查分组名称在用户下是否重复
+func checkGroupName(db *gorm.DB, userID, excludeID uint, name string) error {
+	var count int64
+	if err := db.Model(&model.ServerGroup{}).Where("user_id = ? AND name = ? AND id <> ?", userID, name, excludeID).
+		Count(&count).Error; err != nil {
+		return fmt.Errorf("检查分组名称失败: %w", err)
+	}
+	if count > 0 {
+		return fmt.Errorf("分组 %s 已存在", name)
+	}
+	return nil
+}
+
+// normalizeServerTags 校验标签并按键排序，键不能重复
+func normalizeServerTags(serverID uint, inputs []ServerTagInput) ([]model.ServerTag, error) {
+	if len(inputs) > maxTagsPerServer {
+		return nil, fmt.Errorf("每台服务器最多设置%d个标签", maxTagsPerServer)
+	}
+
+	tags := make([]model.ServerTag, 0, len(inputs))
+	seen := make(map[string]bool, len(inputs))
+	for _, input := range inputs {
+		key := strings.TrimSpace(input.Key)
+		value := strings.TrimSpace(input.Value)
+		if !tagKeyPattern.MatchString(key) {
+			return nil, fmt.Errorf("标签键 %q 无效，只能包含中英文、数字和 _ . - / @，长度1-64", key)
+		}
+		if utf8.RuneCountInString(value) > maxTagValueLength {
+			return nil, fmt.Errorf("标签 %s 的值不能超过%d个字符", key, maxTagValueLength)
+		}
+		if seen[key] {
+			return nil, fmt.Errorf("标签键 %s 重复", key)
+		}
+		seen[key] = true
+		tags = append(tags, model.ServerTag{ServerID: serverID, Key: key, Value: value})
+	}
+	sort.Slice(tags, func(i, j int) bool { return tags[i].Key < tags[j].Key })
+	return tags, nil
+}
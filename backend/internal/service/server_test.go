@@ -0,0 +1,192 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"cloudbp-backend/internal/config"
+	"cloudbp-backend/internal/model"
+	"cloudbp-backend/pkg/logger"
+	"cloudbp-backend/pkg/provider"
+
+	"github.com/shopspring/decimal"
+	"go.uber.org/zap"
+)
+
+// testCloudProvider 测试用厂商，只提供下单时校验地域所需的地域列表
+type testCloudProvider struct {
+	provider.CloudProvider
+	code   string
+	region provider.Region
+}
+
+func (p *testCloudProvider) GetCode() string {
+	return p.code
+}
+
+func (p *testCloudProvider) GetRegions(ctx context.Context) (*provider.GetRegionsResponse, error) {
+	return &provider.GetRegionsResponse{Regions: []provider.Region{p.region}}, nil
+}
+
+// TestPurchaseServerConcurrent 同一用户并发余额下单，成功笔数恰好为余额能覆盖的笔数且余额不透支
+func TestPurchaseServerConcurrent(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+	if logger.Log == nil {
+		logger.Log = zap.NewNop()
+	}
+
+	suffix := time.Now().UnixNano()
+	cloud := &testCloudProvider{
+		code: fmt.Sprintf("test_%d", suffix),
+		region: provider.Region{
+			RegionID:   "test-region-1",
+			RegionName: "测试地域",
+			Zones:      []provider.Zone{{ZoneID: "test-zone-1", ZoneName: "测试可用区"}},
+		},
+	}
+
+	p := model.Provider{Name: "测试厂商", Code: cloud.code, Status: model.ProviderStatusActive}
+	if err := db.Create(&p).Error; err != nil {
+		t.Fatalf("创建测试厂商失败: %v", err)
+	}
+	product := model.Product{
+		ProviderID: p.ID,
+		Name:       "测试套餐",
+		Code:       "test.small",
+		Type:       "cvm",
+		Region:     cloud.region.RegionID,
+		Zone:       "test-zone-1",
+		CPU:        1,
+		Memory:     1,
+		Storage:    20,
+		Price:      decimal.NewFromInt(7),
+		Currency:   "CNY",
+		Status:     model.ProductStatusOnline,
+		Stock:      -1,
+	}
+	if err := db.Create(&product).Error; err != nil {
+		t.Fatalf("创建测试产品失败: %v", err)
+	}
+	image := model.Image{
+		ProviderID: p.ID,
+		Region:     cloud.region.RegionID,
+		ImageID:    "img-test",
+		Name:       "测试镜像",
+		OSType:     "LINUX",
+		Status:     model.ImageStatusActive,
+	}
+	if err := db.Create(&image).Error; err != nil {
+		t.Fatalf("创建测试镜像失败: %v", err)
+	}
+	user := model.User{
+		Username: fmt.Sprintf("purchase_test_%d", suffix),
+		Email:    fmt.Sprintf("purchase_test_%d@example.com", suffix),
+		Phone:    fmt.Sprintf("p%d", suffix),
+		Password: "-",
+		Currency: "CNY",
+	}
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatalf("创建测试用户失败: %v", err)
+	}
+
+	providerService := NewProviderService(db)
+	providerService.providerManager.RegisterProvider(cloud)
+	currencyService := NewCurrencyService(db, nil, config.CurrencyConfig{Base: "CNY"})
+	pricingRuleService := NewPricingRuleService(db, nil, 0)
+	catalogService := NewCatalogService(db, nil, config.CatalogConfig{}, providerService, pricingRuleService, nil)
+	regionService := NewRegionService(db, nil, catalogService)
+	pricingService := NewPricingService(db, nil, config.PricingConfig{}, currencyService, pricingRuleService, regionService)
+	paymentService := NewPaymentService(db, nil, config.PaymentConfig{})
+	serverService := NewServerService(db, nil, providerService, paymentService, pricingService)
+	ledger := NewLedgerService(db)
+
+	balance := decimal.RequireFromString("100.00")
+	if _, err := ledger.AdjustBalance(ctx, &AdjustBalanceRequest{
+		UserID: user.ID,
+		Amount: balance,
+		Remark: "并发下单测试充值",
+	}); err != nil {
+		t.Fatalf("充值失败: %v", err)
+	}
+
+	quote, err := pricingService.Quote(ctx, &QuoteRequest{UserID: user.ID, ProductID: product.ID, Period: 1})
+	if err != nil {
+		t.Fatalf("报价失败: %v", err)
+	}
+	price := quote.BasePayAmount
+	if !price.IsPositive() {
+		t.Fatalf("报价金额 %s 应大于0", price)
+	}
+
+	const workers = 30
+	var (
+		wg        sync.WaitGroup
+		mu        sync.Mutex
+		successes int
+		failures  []error
+	)
+	start := make(chan struct{})
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+
+			_, err := serverService.PurchaseServer(ctx, &PurchaseServerRequest{
+				UserID:    user.ID,
+				ProductID: product.ID,
+				Name:      "test-{n}",
+				Period:    1,
+				ImageID:   image.ImageID,
+				Password:  "Test@123456",
+				PayMethod: model.PaymentMethodBalance,
+			})
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err == nil {
+				successes++
+			} else if !errors.Is(err, ErrInsufficientBalance) {
+				failures = append(failures, err)
+			}
+		}()
+	}
+	close(start)
+	wg.Wait()
+
+	for _, err := range failures {
+		t.Errorf("下单返回了余额不足以外的错误: %v", err)
+	}
+
+	expected := int(balance.Div(price).Floor().IntPart())
+	if successes != expected {
+		t.Errorf("成功下单 %d 笔，期望 %d 笔", successes, expected)
+	}
+
+	wallet, err := ledger.GetWallet(ctx, user.ID)
+	if err != nil {
+		t.Fatalf("获取钱包失败: %v", err)
+	}
+	if wallet.Balance.IsNegative() {
+		t.Errorf("钱包余额为负: %s", wallet.Balance)
+	}
+	remaining := balance.Sub(price.Mul(decimal.NewFromInt(int64(successes))))
+	if !wallet.Balance.Equal(remaining) {
+		t.Errorf("钱包余额 %s，期望 %s", wallet.Balance, remaining)
+	}
+
+	var paid int64
+	if err := db.Model(&model.Order{}).
+		Where("user_id = ? AND status = ?", user.ID, model.OrderStatusPaid).
+		Count(&paid).Error; err != nil {
+		t.Fatalf("统计已支付订单失败: %v", err)
+	}
+	if int(paid) != successes {
+		t.Errorf("已支付订单 %d 笔，成功下单 %d 笔", paid, successes)
+	}
+}
@@ -0,0 +1,523 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"cloudbp-backend/internal/config"
+	"cloudbp-backend/internal/model"
+	"cloudbp-backend/pkg/logger"
+	"cloudbp-backend/pkg/provider"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/shopspring/decimal"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// backupBatchSize 每次执行的定期备份计划数
+	backupBatchSize = 100
+	// snapshotCreateTimeout 调用厂商创建快照的超时时间
+	snapshotCreateTimeout = 5 * time.Minute
+)
+
+// SnapshotService 快照服务：手动快照、定期备份和快照计费，产品须支持备份且厂商须支持快照；
+// 每台服务器在免费额度内的快照不收费，超出部分按月从余额扣费，余额不足时删除快照
+type SnapshotService struct {
+	db              *gorm.DB
+	rdb             *redis.Client
+	config          config.SnapshotConfig
+	providerService *ProviderService
+	ledgerService   *LedgerService
+}
+
+// NewSnapshotService 创建快照服务
+func NewSnapshotService(db *gorm.DB, rdb *redis.Client, cfg config.SnapshotConfig, providerService *ProviderService) *SnapshotService {
+	return &SnapshotService{
+		db:              db,
+		rdb:             rdb,
+		config:          cfg,
+		providerService: providerService,
+		ledgerService:   NewLedgerService(db),
+	}
+}
+
+// GetSnapshotsResponse 快照列表和额度
+type GetSnapshotsResponse struct {
+	Snapshots    []model.Snapshot `json:"snapshots"`
+	FreeQuota    int              `json:"free_quota"`     // 免费快照数
+	MaxPerServer int              `json:"max_per_server"` // 最多保留的快照数
+	Price        decimal.Decimal  `json:"price"`          // 超出免费额度的快照每月价格（本位币）
+}
+
+// CreateSnapshotRequest 创建快照请求
+type CreateSnapshotRequest struct {
+	ServerID uint   `json:"server_id"`
+	UserID   uint   `json:"user_id"`
+	Name     string `json:"name" binding:"max=60"` // 快照名称，默认按时间生成
+}
+
+// GetSnapshots 获取服务器快照
+func (s *SnapshotService) GetSnapshots(ctx context.Context, userID, serverID uint) (*GetSnapshotsResponse, error) {
+	var count int64
+	if err := s.db.Model(&model.Server{}).Where("id = ? AND user_id = ?", serverID, userID).Count(&count).Error; err != nil {
+		return nil, fmt.Errorf("获取服务器信息失败: %w", err)
+	}
+	if count == 0 {
+		return nil, errors.New("服务器不存在")
+	}
+
+	var snapshots []model.Snapshot
+	if err := s.db.Where("server_id = ?", serverID).Order("id DESC").Find(&snapshots).Error; err != nil {
+		return nil, fmt.Errorf("获取快照列表失败: %w", err)
+	}
+
+	return &GetSnapshotsResponse{
+		Snapshots:    snapshots,
+		FreeQuota:    s.config.FreeQuota,
+		MaxPerServer: s.config.MaxPerServer,
+		Price:        roundAmount(s.config.Price),
+	}, nil
+}
+
+// CreateSnapshot 创建手动快照，超出免费额度时先扣除首月费用
+func (s *SnapshotService) CreateSnapshot(ctx context.Context, req *CreateSnapshotRequest) (*model.Snapshot, error) {
+	server, snapshotter, err := s.snapshotServer(req.UserID, req.ServerID)
+	if err != nil {
+		return nil, err
+	}
+
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		name = fmt.Sprintf("%s-%s", server.Name, time.Now().Format("20060102150405"))
+	}
+	return s.createSnapshot(ctx, server, snapshotter, name, model.SnapshotTypeManual)
+}
+
+// RestoreSnapshot 使用快照回滚服务器，快照之后写入的数据将丢失
+func (s *SnapshotService) RestoreSnapshot(ctx context.Context, userID, serverID, snapshotID uint) error {
+	server, snapshotter, err := s.snapshotServer(userID, serverID)
+	if err != nil {
+		return err
+	}
+
+	var snapshot model.Snapshot
+	if err := s.db.Where("id = ? AND server_id = ?", snapshotID, server.ID).First(&snapshot).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.New("快照不存在")
+		}
+		return fmt.Errorf("获取快照失败: %w", err)
+	}
+	if snapshot.Status == model.SnapshotStatusCreating {
+		return errors.New("快照创建中，请稍后再试")
+	}
+
+	if err := snapshotter.RestoreSnapshot(ctx, &provider.RestoreSnapshotRequest{
+		InstanceID: server.InstanceID,
+		SnapshotID: snapshot.SnapshotID,
+	}); err != nil {
+		return fmt.Errorf("回滚快照失败: %w", err)
+	}
+
+	logger.Log.Info("服务器已回滚快照", zap.Uint("server_id", server.ID), zap.String("snapshot_id", snapshot.SnapshotID))
+	return nil
+}
+
+// DeleteSnapshot 删除快照，已扣除的当月费用不退还
+func (s *SnapshotService) DeleteSnapshot(ctx context.Context, userID, serverID, snapshotID uint) error {
+	var snapshot model.Snapshot
+	if err := s.db.Where("id = ? AND server_id = ? AND user_id = ?", snapshotID, serverID, userID).First(&snapshot).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.New("快照不存在")
+		}
+		return fmt.Errorf("获取快照失败: %w", err)
+	}
+	if snapshot.Status == model.SnapshotStatusCreating {
+		return errors.New("快照创建中，请稍后再试")
+	}
+
+	var server model.Server
+	if err := s.db.Preload("Provider").First(&server, snapshot.ServerID).Error; err != nil {
+		return fmt.Errorf("获取服务器信息失败: %w", err)
+	}
+	snapshotter, err := s.snapshotter(server.Provider.Code)
+	if err != nil {
+		return err
+	}
+	return s.deleteSnapshot(ctx, snapshotter, &snapshot)
+}
+
+// SaveBackupScheduleRequest 设置定期备份请求
+type SaveBackupScheduleRequest struct {
+	ServerID  uint   `json:"server_id"`
+	UserID    uint   `json:"user_id"`
+	Enabled   bool   `json:"enabled"`
+	Frequency string `json:"frequency" binding:"required,oneof=daily weekly"`
+	Weekday   int    `json:"weekday" binding:"min=0,max=6"` // 每周备份的星期，0为周日
+	Hour      int    `json:"hour" binding:"min=0,max=23"`   // 备份时刻
+	Retention int    `json:"retention" binding:"required,min=1"`
+}
+
+// GetBackupSchedule 获取服务器定期备份计划，未设置时返回nil
+func (s *SnapshotService) GetBackupSchedule(ctx context.Context, userID, serverID uint) (*model.BackupSchedule, error) {
+	var schedule model.BackupSchedule
+	err := s.db.Where("server_id = ? AND user_id = ?", serverID, userID).First(&schedule).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("获取备份计划失败: %w", err)
+	}
+	return &schedule, nil
+}
+
+// SaveBackupSchedule 设置服务器定期备份计划，保留数不能超过每台服务器的快照上限
+func (s *SnapshotService) SaveBackupSchedule(ctx context.Context, req *SaveBackupScheduleRequest) (*model.BackupSchedule, error) {
+	if _, _, err := s.snapshotServer(req.UserID, req.ServerID); err != nil {
+		return nil, err
+	}
+	if req.Retention > s.config.MaxPerServer {
+		return nil, fmt.Errorf("保留数不能超过%d", s.config.MaxPerServer)
+	}
+
+	var schedule model.BackupSchedule
+	err := s.db.Where("server_id = ?", req.ServerID).First(&schedule).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("获取备份计划失败: %w", err)
+	}
+
+	schedule.ServerID = req.ServerID
+	schedule.UserID = req.UserID
+	schedule.Enabled = req.Enabled
+	schedule.Frequency = req.Frequency
+	schedule.Weekday = req.Weekday
+	schedule.Hour = req.Hour
+	schedule.Retention = req.Retention
+	schedule.NextRunAt = nextBackupTime(&schedule, time.Now())
+	schedule.LastError = ""
+
+	if err := s.db.Save(&schedule).Error; err != nil {
+		return nil, fmt.Errorf("保存备份计划失败: %w", err)
+	}
+	return &schedule, nil
+}
+
+// DeleteBackupSchedule 删除服务器定期备份计划，已创建的定期快照保留
+func (s *SnapshotService) DeleteBackupSchedule(ctx context.Context, userID, serverID uint) error {
+	result := s.db.Where("server_id = ? AND user_id = ?", serverID, userID).Delete(&model.BackupSchedule{})
+	if result.Error != nil {
+		return fmt.Errorf("删除备份计划失败: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("备份计划不存在")
+	}
+	return nil
+}
+
+// RunBackupSchedules 执行到期的定期备份，单台失败只记录原因，不影响其他服务器
+func (s *SnapshotService) RunBackupSchedules(ctx context.Context) error {
+	var schedules []model.BackupSchedule
+	if err := s.db.WithContext(ctx).Where("enabled = ? AND next_run_at <= ?", true, time.Now()).
+		Order("next_run_at").Limit(backupBatchSize).Find(&schedules).Error; err != nil {
+		return fmt.Errorf("获取备份计划失败: %w", err)
+	}
+
+	for i := range schedules {
+		schedule := &schedules[i]
+		now := time.Now()
+		updates := map[string]interface{}{
+			"last_run_at": &now,
+			"last_error":  "",
+			"next_run_at": nextBackupTime(schedule, now),
+		}
+		if err := s.runBackup(ctx, schedule); err != nil {
+			logger.Log.Warn("定期备份失败", zap.Uint("server_id", schedule.ServerID), zap.Error(err))
+			updates["last_error"] = err.Error()
+		}
+		if err := s.db.Model(schedule).Updates(updates).Error; err != nil {
+			logger.Log.Error("更新备份计划失败", zap.Uint("schedule_id", schedule.ID), zap.Error(err))
+		}
+	}
+	return nil
+}
+
+// runBackup 执行一次定期备份：快照已满时先删除最早的定期快照，创建后只保留最近的定期快照
+func (s *SnapshotService) runBackup(ctx context.Context, schedule *model.BackupSchedule) error {
+	server, snapshotter, err := s.snapshotServer(schedule.UserID, schedule.ServerID)
+	if err != nil {
+		return err
+	}
+
+	var scheduled []model.Snapshot
+	if err := s.db.Where("server_id = ? AND type = ? AND status = ?", server.ID, model.SnapshotTypeScheduled, model.SnapshotStatusAvailable).
+		Order("created_at, id").Find(&scheduled).Error; err != nil {
+		return fmt.Errorf("获取定期快照失败: %w", err)
+	}
+	var total int64
+	if err := s.db.Model(&model.Snapshot{}).Where("server_id = ?", server.ID).Count(&total).Error; err != nil {
+		return fmt.Errorf("统计快照数量失败: %w", err)
+	}
+	if int(total) >= s.config.MaxPerServer && len(scheduled) > 0 {
+		if err := s.deleteSnapshot(ctx, snapshotter, &scheduled[0]); err != nil {
+			return err
+		}
+		scheduled = scheduled[1:]
+	}
+
+	name := fmt.Sprintf("%s-auto-%s", server.Name, time.Now().Format("20060102150405"))
+	snapshot, err := s.createSnapshot(ctx, server, snapshotter, name, model.SnapshotTypeScheduled)
+	if err != nil {
+		return err
+	}
+	scheduled = append(scheduled, *snapshot)
+
+	for len(scheduled) > schedule.Retention {
+		if err := s.deleteSnapshot(ctx, snapshotter, &scheduled[0]); err != nil {
+			return err
+		}
+		scheduled = scheduled[1:]
+	}
+	return nil
+}
+
+// BillSnapshots 续扣到期的快照月费，余额不足或服务器已退订时删除快照
+func (s *SnapshotService) BillSnapshots(ctx context.Context) error {
+	var snapshots []model.Snapshot
+	if err := s.db.WithContext(ctx).Where("chargeable = ? AND status = ? AND billed_until <= ?", true, model.SnapshotStatusAvailable, time.Now()).
+		Order("billed_until").Find(&snapshots).Error; err != nil {
+		return fmt.Errorf("获取待扣费快照失败: %w", err)
+	}
+
+	for i := range snapshots {
+		snapshot := &snapshots[i]
+		var server model.Server
+		if err := s.db.Unscoped().Preload("Provider").First(&server, snapshot.ServerID).Error; err != nil {
+			logger.Log.Error("获取快照所属服务器失败", zap.Uint("snapshot_id", snapshot.ID), zap.Error(err))
+			continue
+		}
+		snapshotter, err := s.snapshotter(server.Provider.Code)
+		if err != nil {
+			logger.Log.Error("获取厂商快照能力失败", zap.Uint("snapshot_id", snapshot.ID), zap.Error(err))
+			continue
+		}
+
+		if server.DeletedAt.Valid || server.Status == model.ServerStatusTerminated {
+			if err := s.deleteSnapshot(ctx, snapshotter, snapshot); err != nil {
+				logger.Log.Error("删除已退订服务器的快照失败", zap.Uint("snapshot_id", snapshot.ID), zap.Error(err))
+			}
+			continue
+		}
+
+		err = s.db.Transaction(func(tx *gorm.DB) error {
+			if _, err := s.ledgerService.DebitWallet(tx, &WalletPostingRequest{
+				UserID:      snapshot.UserID,
+				Amount:      snapshot.Price,
+				Type:        model.LedgerTxTypeSnapshot,
+				Description: fmt.Sprintf("快照 %s 月费", snapshot.Name),
+			}); err != nil {
+				return err
+			}
+			return tx.Model(snapshot).Update("billed_until", snapshot.BilledUntil.AddDate(0, 1, 0)).Error
+		})
+		if errors.Is(err, ErrInsufficientBalance) {
+			logger.Log.Warn("余额不足，删除快照", zap.Uint("user_id", snapshot.UserID), zap.Uint("snapshot_id", snapshot.ID))
+			if err := s.deleteSnapshot(ctx, snapshotter, snapshot); err != nil {
+				logger.Log.Error("删除欠费快照失败", zap.Uint("snapshot_id", snapshot.ID), zap.Error(err))
+			}
+			continue
+		}
+		if err != nil {
+			logger.Log.Error("快照扣费失败", zap.Uint("snapshot_id", snapshot.ID), zap.Error(err))
+		}
+	}
+	return nil
+}
+
+// createSnapshot 创建快照：先在服务器行锁内校验额度、扣除首月费用并保存创建中的快照，
+// 提交后再调用厂商创建，成功后补全厂商快照ID，失败时退回费用并删除记录
+func (s *SnapshotService) createSnapshot(ctx context.Context, server *model.Server, snapshotter provider.Snapshotter, name, snapshotType string) (*model.Snapshot, error) {
+	var snapshot model.Snapshot
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		// 锁定服务器，同一台服务器的快照额度校验串行执行
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&model.Server{}, server.ID).Error; err != nil {
+			return fmt.Errorf("获取服务器信息失败: %w", err)
+		}
+
+		var count int64
+		if err := tx.Model(&model.Snapshot{}).Where("server_id = ?", server.ID).Count(&count).Error; err != nil {
+			return fmt.Errorf("统计快照数量失败: %w", err)
+		}
+		if int(count) >= s.config.MaxPerServer {
+			return fmt.Errorf("每台服务器最多保留%d个快照", s.config.MaxPerServer)
+		}
+
+		snapshot = model.Snapshot{
+			UserID:     server.UserID,
+			ServerID:   server.ID,
+			SnapshotID: "pending-" + generateNo("SNP"),
+			Name:       name,
+			Type:       snapshotType,
+			Size:       server.Storage,
+			Status:     model.SnapshotStatusCreating,
+		}
+		if int(count) >= s.config.FreeQuota && s.config.Price.IsPositive() {
+			billedUntil := time.Now().AddDate(0, 1, 0)
+			snapshot.Chargeable = true
+			snapshot.Price = roundAmount(s.config.Price)
+			snapshot.BilledUntil = &billedUntil
+			if _, err := s.ledgerService.DebitWallet(tx, &WalletPostingRequest{
+				UserID:      server.UserID,
+				Amount:      snapshot.Price,
+				Type:        model.LedgerTxTypeSnapshot,
+				Description: fmt.Sprintf("快照 %s 月费", name),
+			}); err != nil {
+				if errors.Is(err, ErrInsufficientBalance) {
+					return err
+				}
+				return fmt.Errorf("扣除快照费用失败: %w", err)
+			}
+		}
+
+		if err := tx.Create(&snapshot).Error; err != nil {
+			return fmt.Errorf("保存快照失败: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// 调用厂商创建快照，不占用数据库事务，客户端断开也不会中断
+	createCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), snapshotCreateTimeout)
+	defer cancel()
+	resp, err := snapshotter.CreateSnapshot(createCtx, &provider.CreateSnapshotRequest{
+		InstanceID: server.InstanceID,
+		Name:       name,
+	})
+	if err != nil {
+		if rollbackErr := s.cancelSnapshot(&snapshot); rollbackErr != nil {
+			logger.Log.Error("创建快照失败后退款失败", zap.Uint("snapshot_id", snapshot.ID), zap.Error(rollbackErr))
+		}
+		return nil, fmt.Errorf("创建快照失败: %w", err)
+	}
+
+	updates := map[string]interface{}{
+		"snapshot_id": resp.SnapshotID,
+		"status":      model.SnapshotStatusAvailable,
+	}
+	if resp.Size > 0 {
+		updates["size"] = resp.Size
+	}
+	if err := s.db.Model(&snapshot).Updates(updates).Error; err != nil {
+		// 厂商快照已创建，记录保持创建中，便于按厂商快照ID人工核对
+		logger.Log.Error("保存厂商快照ID失败", zap.Uint("snapshot_id", snapshot.ID),
+			zap.String("vendor_snapshot_id", resp.SnapshotID), zap.Error(err))
+		return nil, fmt.Errorf("保存快照失败: %w", err)
+	}
+	return &snapshot, nil
+}
+
+// cancelSnapshot 厂商创建快照失败时退回已扣的首月费用并删除创建中的记录
+func (s *SnapshotService) cancelSnapshot(snapshot *model.Snapshot) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("id = ? AND status = ?", snapshot.ID, model.SnapshotStatusCreating).Delete(&model.Snapshot{})
+		if result.Error != nil {
+			return fmt.Errorf("删除快照失败: %w", result.Error)
+		}
+		if result.RowsAffected == 0 || !snapshot.Chargeable {
+			return nil
+		}
+
+		if _, err := s.ledgerService.CreditWallet(tx, &WalletPostingRequest{
+			UserID:      snapshot.UserID,
+			Amount:      snapshot.Price,
+			Type:        model.LedgerTxTypeRefund,
+			Description: fmt.Sprintf("快照 %s 创建失败退款", snapshot.Name),
+		}); err != nil {
+			return fmt.Errorf("退回快照费用失败: %w", err)
+		}
+		return nil
+	})
+}
+
+// deleteSnapshot 删除厂商快照和本地记录
+func (s *SnapshotService) deleteSnapshot(ctx context.Context, snapshotter provider.Snapshotter, snapshot *model.Snapshot) error {
+	if err := snapshotter.DeleteSnapshot(ctx, &provider.DeleteSnapshotRequest{SnapshotID: snapshot.SnapshotID}); err != nil {
+		return fmt.Errorf("删除厂商快照失败: %w", err)
+	}
+	if err := s.db.Delete(snapshot).Error; err != nil {
+		return fmt.Errorf("删除快照失败: %w", err)
+	}
+	return nil
+}
+
+// snapshotServer 获取可管理快照的服务器：须为用户的运行中或已关机服务器，产品支持备份且厂商支持快照
+func (s *SnapshotService) snapshotServer(userID, serverID uint) (*model.Server, provider.Snapshotter, error) {
+	var server model.Server
+	if err := s.db.Preload("Provider").Preload("Product").Where("id = ? AND user_id = ?", serverID, userID).First(&server).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, errors.New("服务器不存在")
+		}
+		return nil, nil, fmt.Errorf("获取服务器信息失败: %w", err)
+	}
+	if server.Status != model.ServerStatusRunning && server.Status != model.ServerStatusStopped {
+		return nil, nil, errors.New("当前状态的服务器不能管理快照")
+	}
+	if !productSupportsBackup(&server.Product) {
+		return nil, nil, errors.New("该套餐不支持快照备份")
+	}
+
+	snapshotter, err := s.snapshotter(server.Provider.Code)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &server, snapshotter, nil
+}
+
+// snapshotter 获取厂商的快照能力
+func (s *SnapshotService) snapshotter(code string) (provider.Snapshotter, error) {
+	cloudProvider, err := s.providerService.GetProvider(code)
+	if err != nil {
+		return nil, err
+	}
+	snapshotter, ok := cloudProvider.(provider.Snapshotter)
+	if !ok {
+		return nil, errors.New("该厂商不支持快照")
+	}
+	return snapshotter, nil
+}
+
+// productSupportsBackup 产品特性中是否声明支持备份
+func productSupportsBackup(product *model.Product) bool {
+	if product.Features == "" {
+		return false
+	}
+	var features map[string]interface{}
+	if err := json.Unmarshal([]byte(product.Features), &features); err != nil {
+		return false
+	}
+	backup, _ := features["backup"].(bool)
+	return backup
+}
+
+// nextBackupTime 计算下次备份时间：每天或每周指定星期的整点，须晚于after
+func nextBackupTime(schedule *model.BackupSchedule, after time.Time) time.Time {
+	next := time.Date(after.Year(), after.Month(), after.Day(), schedule.Hour, 0, 0, 0, after.Location())
+	if schedule.Frequency == model.BackupFrequencyWeekly {
+		next = next.AddDate(0, 0, (schedule.Weekday-int(next.Weekday())+7)%7)
+		if !next.After(after) {
+			next = next.AddDate(0, 0, 7)
+		}
+		return next
+	}
+	if !next.After(after) {
+		next = next.AddDate(0, 0, 1)
+	}
+	return next
+}
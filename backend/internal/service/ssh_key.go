@@ -0,0 +1,307 @@
+package service
+
+import (
+	"context"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"strings"
+
+	"cloudbp-backend/internal/model"
+	"cloudbp-backend/pkg/logger"
+	"cloudbp-backend/pkg/provider"
+
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+	"golang.org/x/crypto/ssh"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	maxSSHKeysPerUser = 20   // 每个用户最多保存的公钥数
+	minRSAKeyBits     = 2048 // RSA公钥最小长度
+)
+
+// SSHKeyService SSH公钥服务：管理用户公钥，创建和重装服务器时通过厂商密钥对或cloud-init注入
+type SSHKeyService struct {
+	db              *gorm.DB
+	rdb             *redis.Client
+	providerService *ProviderService
+}
+
+// NewSSHKeyService 创建SSH公钥服务
+func NewSSHKeyService(db *gorm.DB, rdb *redis.Client, providerService *ProviderService) *SSHKeyService {
+	return &SSHKeyService{
+		db:              db,
+		rdb:             rdb,
+		providerService: providerService,
+	}
+}
+
+// CreateSSHKeyRequest 添加公钥请求
+type CreateSSHKeyRequest struct {
+	Name      string `json:"name" binding:"required,max=64"`
+	PublicKey string `json:"public_key" binding:"required"`
+}
+
+// GetSSHKeys 获取用户公钥列表
+func (s *SSHKeyService) GetSSHKeys(ctx context.Context, userID uint) ([]model.SSHKey, error) {
+	var keys []model.SSHKey
+	if err := s.db.Where("user_id = ?", userID).Order("id DESC").Find(&keys).Error; err != nil {
+		return nil, fmt.Errorf("获取公钥列表失败: %w", err)
+	}
+	return keys, nil
+}
+
+// CreateSSHKey 添加公钥，校验格式和强度并计算指纹，同一用户下指纹不能重复
+func (s *SSHKeyService) CreateSSHKey(ctx context.Context, userID uint, req *CreateSSHKeyRequest) (*model.SSHKey, error) {
+	publicKey, comment, err := parsePublicKey(req.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	key := model.SSHKey{
+		UserID:      userID,
+		Name:        strings.TrimSpace(req.Name),
+		PublicKey:   strings.TrimSpace(strings.TrimSuffix(string(ssh.MarshalAuthorizedKey(publicKey)), "\n") + " " + comment),
+		Fingerprint: ssh.FingerprintSHA256(publicKey),
+		KeyType:     publicKey.Type(),
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		// 锁定用户，避免并发添加超过数量上限
+		var user model.User
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&user, userID).Error; err != nil {
+			return fmt.Errorf("获取用户信息失败: %w", err)
+		}
+
+		var count int64
+		if err := tx.Model(&model.SSHKey{}).Where("user_id = ?", userID).Count(&count).Error; err != nil {
+			return fmt.Errorf("统计公钥数量失败: %w", err)
+		}
+		if count >= maxSSHKeysPerUser {
+			return fmt.Errorf("最多只能添加%d个公钥", maxSSHKeysPerUser)
+		}
+
+		var existing model.SSHKey
+		err := tx.Where("user_id = ? AND fingerprint = ?", userID, key.Fingerprint).First(&existing).Error
+		if err == nil {
+			return fmt.Errorf("该公钥已添加为 %s", existing.Name)
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return fmt.Errorf("检查公钥失败: %w", err)
+		}
+
+		if err := tx.Create(&key).Error; err != nil {
+			return fmt.Errorf("保存公钥失败: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+// DeleteSSHKey 删除公钥，已导入厂商的密钥对一并删除；已注入服务器的公钥不受影响
+func (s *SSHKeyService) DeleteSSHKey(ctx context.Context, userID, keyID uint) error {
+	var key model.SSHKey
+	if err := s.db.Where("id = ? AND user_id = ?", keyID, userID).First(&key).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.New("公钥不存在")
+		}
+		return fmt.Errorf("获取公钥失败: %w", err)
+	}
+
+	var bindings []model.SSHKeyBinding
+	if err := s.db.Where("ssh_key_id = ?", key.ID).Find(&bindings).Error; err != nil {
+		return fmt.Errorf("获取厂商密钥对失败: %w", err)
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("ssh_key_id = ?", key.ID).Delete(&model.SSHKeyBinding{}).Error; err != nil {
+			return fmt.Errorf("删除厂商密钥对记录失败: %w", err)
+		}
+		if err := tx.Delete(&key).Error; err != nil {
+			return fmt.Errorf("删除公钥失败: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// 厂商侧密钥对删除失败不影响本地删除，仅记录日志
+	for _, binding := range bindings {
+		if err := s.deleteProviderKeyPair(ctx, &binding); err != nil {
+			logger.Log.Warn("删除厂商密钥对失败", zap.Uint("ssh_key_id", key.ID),
+				zap.Uint("provider_id", binding.ProviderID), zap.String("key_id", binding.ProviderKeyID), zap.Error(err))
+		}
+	}
+	return nil
+}
+
+// deleteProviderKeyPair 删除厂商侧的密钥对
+func (s *SSHKeyService) deleteProviderKeyPair(ctx context.Context, binding *model.SSHKeyBinding) error {
+	var p model.Provider
+	if err := s.db.First(&p, binding.ProviderID).Error; err != nil {
+		return fmt.Errorf("获取厂商信息失败: %w", err)
+	}
+	cloudProvider, err := s.providerService.GetProvider(p.Code)
+	if err != nil {
+		return err
+	}
+	keyPairManager, ok := cloudProvider.(provider.KeyPairManager)
+	if !ok {
+		return nil
+	}
+	return keyPairManager.DeleteKeyPair(ctx, &provider.DeleteKeyPairRequest{
+		Region: binding.Region,
+		KeyID:  binding.ProviderKeyID,
+	})
+}
+
+// parsePublicKey 解析OpenSSH格式公钥，拒绝DSA和长度不足的RSA公钥
+func parsePublicKey(text string) (ssh.PublicKey, string, error) {
+	text = strings.TrimSpace(text)
+	if strings.Contains(text, "\n") {
+		return nil, "", errors.New("一次只能添加一个公钥")
+	}
+	publicKey, comment, options, _, err := ssh.ParseAuthorizedKey([]byte(text))
+	if err != nil {
+		return nil, "", errors.New("公钥格式无效，请粘贴OpenSSH格式的公钥")
+	}
+	if len(options) > 0 {
+		return nil, "", errors.New("公钥不能包含选项")
+	}
+
+	switch publicKey.Type() {
+	case ssh.KeyAlgoDSA:
+		return nil, "", errors.New("不支持DSA公钥，请使用Ed25519或RSA公钥")
+	case ssh.KeyAlgoRSA:
+		cryptoKey, ok := publicKey.(ssh.CryptoPublicKey)
+		if !ok {
+			return nil, "", errors.New("公钥格式无效")
+		}
+		if rsaKey, ok := cryptoKey.CryptoPublicKey().(*rsa.PublicKey); ok && rsaKey.N.BitLen() < minRSAKeyBits {
+			return nil, "", fmt.Errorf("RSA公钥长度不能小于%d位", minRSAKeyBits)
+		}
+	}
+	return publicKey, comment, nil
+}
+
+// userSSHKeys 获取用户选择的公钥，公钥须属于该用户
+func userSSHKeys(db *gorm.DB, userID uint, keyIDs []uint) ([]model.SSHKey, error) {
+	if len(keyIDs) == 0 {
+		return nil, nil
+	}
+	var keys []model.SSHKey
+	if err := db.Where("id IN ? AND user_id = ?", keyIDs, userID).Order("id").Find(&keys).Error; err != nil {
+		return nil, fmt.Errorf("获取公钥失败: %w", err)
+	}
+	seen := make(map[uint]bool, len(keyIDs))
+	for _, id := range keyIDs {
+		seen[id] = true
+	}
+	if len(keys) != len(seen) {
+		return nil, errors.New("所选公钥不存在")
+	}
+	return keys, nil
+}
+
+// validateLoginOptions 校验登录方式：禁用密码登录时至少选择一个公钥，Windows镜像不支持公钥登录
+func validateLoginOptions(db *gorm.DB, userID uint, osType string, keyIDs []uint, disablePassword bool) error {
+	if disablePassword && len(keyIDs) == 0 {
+		return errors.New("禁用密码登录时至少需要选择一个公钥")
+	}
+	if len(keyIDs) > 0 && strings.EqualFold(osType, "WINDOWS") {
+		return errors.New("Windows镜像不支持SSH公钥登录")
+	}
+	_, err := userSSHKeys(db, userID, keyIDs)
+	return err
+}
+
+// loginKeys 注入服务器的公钥：厂商支持密钥对时为厂商密钥对ID，否则为cloud-init用户数据
+type loginKeys struct {
+	KeyIDs   []string
+	UserData string
+}
+
+// prepareLoginKeys 按厂商能力准备公钥注入方式，已导入过的密钥对直接复用
+func prepareLoginKeys(ctx context.Context, db *gorm.DB, cloudProvider provider.CloudProvider, providerID uint, region string, keyIDs []uint, disablePassword bool) (*loginKeys, error) {
+	result := &loginKeys{}
+	if len(keyIDs) == 0 {
+		return result, nil
+	}
+
+	var keys []model.SSHKey
+	if err := db.Where("id IN ?", keyIDs).Order("id").Find(&keys).Error; err != nil {
+		return nil, fmt.Errorf("获取公钥失败: %w", err)
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("所选公钥已被删除")
+	}
+
+	keyPairManager, ok := cloudProvider.(provider.KeyPairManager)
+	if !ok {
+		result.UserData = sshKeysCloudConfig(keys, disablePassword)
+		return result, nil
+	}
+
+	for _, key := range keys {
+		var binding model.SSHKeyBinding
+		err := db.Where("ssh_key_id = ? AND provider_id = ? AND region = ?", key.ID, providerID, region).First(&binding).Error
+		if err == nil {
+			result.KeyIDs = append(result.KeyIDs, binding.ProviderKeyID)
+			continue
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("获取厂商密钥对失败: %w", err)
+		}
+
+		resp, err := keyPairManager.ImportKeyPair(ctx, &provider.ImportKeyPairRequest{
+			Region:    region,
+			Name:      fmt.Sprintf("cloudbp-%d-%d", key.UserID, key.ID),
+			PublicKey: key.PublicKey,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("导入密钥对失败: %w", err)
+		}
+		binding = model.SSHKeyBinding{
+			SSHKeyID:      key.ID,
+			ProviderID:    providerID,
+			Region:        region,
+			ProviderKeyID: resp.KeyID,
+		}
+		if err := db.Create(&binding).Error; err != nil {
+			return nil, fmt.Errorf("保存厂商密钥对失败: %w", err)
+		}
+		result.KeyIDs = append(result.KeyIDs, resp.KeyID)
+	}
+	return result, nil
+}
+
+// sshKeysCloudConfig 生成注入公钥的cloud-config
+func sshKeysCloudConfig(keys []model.SSHKey, disablePassword bool) string {
+	var b strings.Builder
+	b.WriteString("#cloud-config\nssh_authorized_keys:\n")
+	for _, key := range keys {
+		fmt.Fprintf(&b, "  - %q\n", key.PublicKey)
+	}
+	if disablePassword {
+		b.WriteString("ssh_pwauth: false\n")
+	}
+	return b.String()
+}
+
+// loginUserData 合并用户数据与公钥注入的cloud-config，两者都有时使用cloud-init多段格式
+func loginUserData(userData string, keys *loginKeys) (string, error) {
+	if keys.UserData == "" {
+		return userData, nil
+	}
+	if strings.TrimSpace(userData) == "" {
+		return keys.UserData, nil
+	}
+	return combineUserData(keys.UserData, userData)
+}
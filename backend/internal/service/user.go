@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/redis/go-redis/v9"
+	"github.com/shopspring/decimal"
 	"gorm.io/gorm"
 )
 
@@ -164,7 +165,7 @@ func (s *UserService) Register(req *RegisterRequest) error {
 		RealName: req.RealName,
 		Status:   model.UserStatusActive,
 		Role:     model.UserRoleUser,
-		Balance:  0,
+		Balance:  decimal.Zero,
 	}
 	
 	if err := s.db.Create(&user).Error; err != nil {
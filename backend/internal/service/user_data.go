@@ -0,0 +1,360 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"mime/multipart"
+	"net/textproto"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"cloudbp-backend/internal/model"
+
+	"github.com/redis/go-redis/v9"
+	"gopkg.in/yaml.v3"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	maxUserDataSize             = 16 * 1024 // 用户数据渲染后的最大字节数
+	maxUserDataTemplatesPerUser = 50        // 每个用户最多保存的模板数
+	maxUserDataVariables        = 20        // 每个模板最多定义的变量数
+)
+
+// userDataVarPattern 模板变量占位符 {{name}}
+var userDataVarPattern = regexp.MustCompile(`\{\{\s*([A-Za-z_][A-Za-z0-9_]*)\s*\}\}`)
+
+// userDataVarName 变量名格式
+var userDataVarName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// builtinUserDataVars 内置变量，开通或重装时按服务器替换，无需在模板中定义
+var builtinUserDataVars = map[string]bool{
+	"server_name": true, // 服务器名称
+	"region":      true, // 地域
+	"zone":        true, // 可用区
+}
+
+// UserDataService 用户数据模板服务：用户维护自己的模板，管理员发布全局模板，
+// 购买和重装时选择模板并填写变量
+type UserDataService struct {
+	db  *gorm.DB
+	rdb *redis.Client
+}
+
+// NewUserDataService 创建用户数据模板服务
+func NewUserDataService(db *gorm.DB, rdb *redis.Client) *UserDataService {
+	return &UserDataService{
+		db:  db,
+		rdb: rdb,
+	}
+}
+
+// SaveUserDataTemplateRequest 添加或更新模板请求
+type SaveUserDataTemplateRequest struct {
+	Name        string                   `json:"name" binding:"required,max=64"`
+	Format      string                   `json:"format" binding:"required,oneof=cloud-config shell"`
+	Content     string                   `json:"content" binding:"required"`
+	Variables   []model.UserDataVariable `json:"variables"`
+	Status      int                      `json:"status"`
+	Description string                   `json:"description"`
+}
+
+// GetTemplates 获取用户可选的模板：自己的模板和启用的全局模板
+func (s *UserDataService) GetTemplates(ctx context.Context, userID uint) ([]model.UserDataTemplate, error) {
+	var templates []model.UserDataTemplate
+	if err := s.db.Where("user_id = ? OR (user_id IS NULL AND status = ?)", userID, model.UserDataTemplateStatusActive).
+		Order("user_id NULLS FIRST, id DESC").Find(&templates).Error; err != nil {
+		return nil, fmt.Errorf("获取用户数据模板失败: %w", err)
+	}
+	return templates, nil
+}
+
+// GetGlobalTemplates 获取全局模板（管理员）
+func (s *UserDataService) GetGlobalTemplates(ctx context.Context) ([]model.UserDataTemplate, error) {
+	var templates []model.UserDataTemplate
+	if err := s.db.Where("user_id IS NULL").Order("id DESC").Find(&templates).Error; err != nil {
+		return nil, fmt.Errorf("获取用户数据模板失败: %w", err)
+	}
+	return templates, nil
+}
+
+// CreateTemplate 添加模板，userID为空时添加全局模板
+func (s *UserDataService) CreateTemplate(ctx context.Context, userID *uint, req *SaveUserDataTemplateRequest) (*model.UserDataTemplate, error) {
+	template := model.UserDataTemplate{UserID: userID}
+	if err := applyUserDataTemplateRequest(&template, req); err != nil {
+		return nil, err
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if userID != nil {
+			// 锁定用户，避免并发添加超过数量上限
+			var user model.User
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&user, *userID).Error; err != nil {
+				return fmt.Errorf("获取用户信息失败: %w", err)
+			}
+
+			var count int64
+			if err := tx.Model(&model.UserDataTemplate{}).Where("user_id = ?", *userID).Count(&count).Error; err != nil {
+				return fmt.Errorf("统计模板数量失败: %w", err)
+			}
+			if count >= maxUserDataTemplatesPerUser {
+				return fmt.Errorf("最多只能保存%d个模板", maxUserDataTemplatesPerUser)
+			}
+		}
+
+		if err := tx.Create(&template).Error; err != nil {
+			return fmt.Errorf("添加模板失败: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &template, nil
+}
+
+// UpdateTemplate 更新模板，userID为空时更新全局模板
+func (s *UserDataService) UpdateTemplate(ctx context.Context, userID *uint, id uint, req *SaveUserDataTemplateRequest) (*model.UserDataTemplate, error) {
+	var template model.UserDataTemplate
+	if err := templateScope(s.db, userID).First(&template, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("模板不存在")
+		}
+		return nil, fmt.Errorf("获取模板失败: %w", err)
+	}
+
+	if err := applyUserDataTemplateRequest(&template, req); err != nil {
+		return nil, err
+	}
+
+	if err := s.db.Model(&template).Select("*").Omit("id", "user_id", "created_at", "deleted_at").
+		Updates(&template).Error; err != nil {
+		return nil, fmt.Errorf("更新模板失败: %w", err)
+	}
+	return &template, nil
+}
+
+// DeleteTemplate 删除模板，userID为空时删除全局模板
+func (s *UserDataService) DeleteTemplate(ctx context.Context, userID *uint, id uint) error {
+	result := templateScope(s.db, userID).Delete(&model.UserDataTemplate{}, id)
+	if result.Error != nil {
+		return fmt.Errorf("删除模板失败: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("模板不存在")
+	}
+	return nil
+}
+
+// templateScope 按所属用户限定模板范围，userID为空表示全局模板
+func templateScope(db *gorm.DB, userID *uint) *gorm.DB {
+	if userID == nil {
+		return db.Where("user_id IS NULL")
+	}
+	return db.Where("user_id = ?", *userID)
+}
+
+// applyUserDataTemplateRequest 校验并写入模板：变量名唯一且不与内置变量重名，
+// 内容只能引用已定义变量和内置变量，按默认值渲染后须符合声明的格式
+func applyUserDataTemplateRequest(template *model.UserDataTemplate, req *SaveUserDataTemplateRequest) error {
+	if len(req.Variables) > maxUserDataVariables {
+		return fmt.Errorf("模板最多定义%d个变量", maxUserDataVariables)
+	}
+
+	sample := make(map[string]string, len(req.Variables))
+	for i := range req.Variables {
+		variable := &req.Variables[i]
+		variable.Name = strings.TrimSpace(variable.Name)
+		if !userDataVarName.MatchString(variable.Name) {
+			return fmt.Errorf("变量名 %s 无效，只能包含字母、数字和下划线且不能以数字开头", variable.Name)
+		}
+		if builtinUserDataVars[variable.Name] {
+			return fmt.Errorf("变量 %s 为内置变量，无需定义", variable.Name)
+		}
+		if _, exists := sample[variable.Name]; exists {
+			return fmt.Errorf("变量 %s 重复", variable.Name)
+		}
+		sample[variable.Name] = variable.Default
+		if sample[variable.Name] == "" {
+			sample[variable.Name] = "value"
+		}
+	}
+
+	for _, match := range userDataVarPattern.FindAllStringSubmatch(req.Content, -1) {
+		if _, defined := sample[match[1]]; !defined && !builtinUserDataVars[match[1]] {
+			return fmt.Errorf("模板引用了未定义的变量 %s", match[1])
+		}
+	}
+
+	content := renderServerUserData(substituteUserDataVars(req.Content, sample), &model.Server{Name: "server", Region: "region", Zone: "zone"})
+	format, err := validateUserData(content)
+	if err != nil {
+		return err
+	}
+	if format != req.Format {
+		return errors.New("模板内容与声明的格式不一致")
+	}
+
+	template.Name = strings.TrimSpace(req.Name)
+	template.Format = req.Format
+	template.Content = req.Content
+	template.Variables = req.Variables
+	template.Status = req.Status
+	template.Description = req.Description
+	if template.Status == 0 {
+		template.Status = model.UserDataTemplateStatusActive
+	}
+	return nil
+}
+
+// resolveUserData 确定购买或重装使用的用户数据：选择模板时按填写的变量渲染，否则使用自定义内容；
+// 返回内容中的内置变量保留，开通时按服务器替换
+func resolveUserData(db *gorm.DB, userID uint, userData string, templateID uint, vars map[string]string) (string, error) {
+	if templateID == 0 {
+		if len(vars) > 0 {
+			return "", errors.New("未选择用户数据模板时不能填写模板变量")
+		}
+		return userData, nil
+	}
+	if strings.TrimSpace(userData) != "" {
+		return "", errors.New("用户数据模板和自定义用户数据只能选择一种")
+	}
+
+	var template model.UserDataTemplate
+	if err := db.Where("id = ? AND (user_id = ? OR (user_id IS NULL AND status = ?))", templateID, userID, model.UserDataTemplateStatusActive).
+		First(&template).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", errors.New("用户数据模板不存在")
+		}
+		return "", fmt.Errorf("获取用户数据模板失败: %w", err)
+	}
+	if template.Status != model.UserDataTemplateStatusActive {
+		return "", errors.New("用户数据模板已停用")
+	}
+
+	values := make(map[string]string, len(template.Variables))
+	for _, variable := range template.Variables {
+		value, ok := vars[variable.Name]
+		if !ok || value == "" {
+			value = variable.Default
+		}
+		if value == "" && variable.Required {
+			return "", fmt.Errorf("请填写变量 %s", variable.Name)
+		}
+		values[variable.Name] = value
+	}
+	for name := range vars {
+		if _, defined := values[name]; !defined {
+			return "", fmt.Errorf("模板未定义变量 %s", name)
+		}
+	}
+
+	return substituteUserDataVars(template.Content, values), nil
+}
+
+// validateServerUserData 按每台服务器替换内置变量后校验用户数据的格式和大小
+func validateServerUserData(userData string, servers []model.Server) error {
+	if userData == "" {
+		return nil
+	}
+	for i := range servers {
+		if _, err := validateUserData(renderServerUserData(userData, &servers[i])); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// substituteUserDataVars 替换模板变量，未提供的变量保留原样
+func substituteUserDataVars(content string, values map[string]string) string {
+	return userDataVarPattern.ReplaceAllStringFunc(content, func(placeholder string) string {
+		name := userDataVarPattern.FindStringSubmatch(placeholder)[1]
+		if value, ok := values[name]; ok {
+			return value
+		}
+		return placeholder
+	})
+}
+
+// renderServerUserData 按服务器替换内置变量
+func renderServerUserData(content string, server *model.Server) string {
+	if content == "" {
+		return ""
+	}
+	return substituteUserDataVars(content, map[string]string{
+		"server_name": server.Name,
+		"region":      server.Region,
+		"zone":        server.Zone,
+	})
+}
+
+// validateUserData 校验用户数据的大小和格式，返回识别出的格式：
+// cloud-config 须以 #cloud-config 开头且为合法的YAML映射，shell 脚本须以 #! 开头
+func validateUserData(content string) (string, error) {
+	if len(content) > maxUserDataSize {
+		return "", fmt.Errorf("用户数据不能超过%dKB", maxUserDataSize/1024)
+	}
+	if !utf8.ValidString(content) || strings.ContainsRune(content, 0) {
+		return "", errors.New("用户数据包含无效字符")
+	}
+
+	switch {
+	case strings.HasPrefix(content, "#cloud-config"):
+		var config map[string]interface{}
+		if err := yaml.Unmarshal([]byte(content), &config); err != nil {
+			return "", fmt.Errorf("cloud-config 格式错误: %v", err)
+		}
+		return model.UserDataFormatCloudConfig, nil
+	case strings.HasPrefix(content, "#!"):
+		return model.UserDataFormatShell, nil
+	default:
+		return "", errors.New("用户数据须以 #cloud-config 或 #! 开头")
+	}
+}
+
+// combineUserData 将公钥注入的cloud-config和用户数据合并为cloud-init多段MIME格式，
+// 公钥段的列表与用户cloud-config中的同名列表追加合并
+func combineUserData(keysConfig, userData string) (string, error) {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+
+	parts := []struct {
+		content   string
+		mergeType string
+	}{
+		{keysConfig, "list(append)+dict(recurse_array)+str()"},
+		{userData, ""},
+	}
+	for _, part := range parts {
+		header := textproto.MIMEHeader{}
+		header.Set("Content-Type", userDataMIMEType(part.content)+`; charset="utf-8"`)
+		header.Set("MIME-Version", "1.0")
+		if part.mergeType != "" {
+			header.Set("Merge-Type", part.mergeType)
+		}
+		w, err := writer.CreatePart(header)
+		if err != nil {
+			return "", fmt.Errorf("生成用户数据失败: %w", err)
+		}
+		if _, err := w.Write([]byte(part.content)); err != nil {
+			return "", fmt.Errorf("生成用户数据失败: %w", err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return "", fmt.Errorf("生成用户数据失败: %w", err)
+	}
+
+	return fmt.Sprintf("Content-Type: multipart/mixed; boundary=\"%s\"\nMIME-Version: 1.0\n\n%s", writer.Boundary(), body.String()), nil
+}
+
+// userDataMIMEType cloud-init 多段格式中各段的内容类型
+func userDataMIMEType(content string) string {
+	if strings.HasPrefix(content, "#!") {
+		return "text/x-shellscript"
+	}
+	return "text/cloud-config"
+}
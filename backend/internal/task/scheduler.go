@@ -0,0 +1,104 @@
+package task
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"cloudbp-backend/pkg/logger"
+
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+// JobFunc 后台任务函数
+type JobFunc func(ctx context.Context) error
+
+// job 定时任务
+type job struct {
+	name     string
+	interval time.Duration
+	fn       JobFunc
+}
+
+// Scheduler 后台定时任务调度器，多实例部署时通过Redis锁保证同一周期内任务只执行一次
+type Scheduler struct {
+	rdb    *redis.Client
+	jobs   []job
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewScheduler 创建调度器
+func NewScheduler(rdb *redis.Client) *Scheduler {
+	return &Scheduler{rdb: rdb}
+}
+
+// Register 注册定时任务，需在Start之前调用
+func (s *Scheduler) Register(name string, interval time.Duration, fn JobFunc) {
+	s.jobs = append(s.jobs, job{name: name, interval: interval, fn: fn})
+}
+
+// Start 启动所有任务
+func (s *Scheduler) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+
+	for _, j := range s.jobs {
+		s.wg.Add(1)
+		go s.run(ctx, j)
+	}
+}
+
+// Stop 停止所有任务并等待正在执行的任务结束
+func (s *Scheduler) Stop() {
+	if s.cancel != nil {
+		s.cancel()
+	}
+	s.wg.Wait()
+}
+
+// run 按间隔循环执行任务
+func (s *Scheduler) run(ctx context.Context, j job) {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.execute(ctx, j)
+		}
+	}
+}
+
+// execute 获取任务锁后执行一次任务
+func (s *Scheduler) execute(ctx context.Context, j job) {
+	defer func() {
+		if r := recover(); r != nil {
+			logger.Log.Error("后台任务异常", zap.String("job", j.name), zap.Any("panic", r))
+		}
+	}()
+
+	if s.rdb != nil {
+		// 锁在周期结束前自然过期，不主动释放
+		ok, err := s.rdb.SetNX(ctx, "task:lock:"+j.name, time.Now().Unix(), j.interval-time.Second).Result()
+		if err != nil {
+			logger.Log.Error("获取任务锁失败", zap.String("job", j.name), zap.Error(err))
+			return
+		}
+		if !ok {
+			return
+		}
+	}
+
+	start := time.Now()
+	if err := j.fn(ctx); err != nil {
+		logger.Log.Error("后台任务执行失败", zap.String("job", j.name), zap.Error(err))
+		return
+	}
+	logger.Log.Debug("后台任务执行完成", zap.String("job", j.name), zap.Duration("duration", time.Since(start)))
+}
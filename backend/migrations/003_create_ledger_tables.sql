@@ -0,0 +1,51 @@
+-- 迁移: create_ledger_tables
+-- 版本: 003
+-- 创建时间: 2026-10-19 10:00:00
+
+-- 用户余额改为由钱包账本同步
+ALTER TABLE users ALTER COLUMN balance TYPE DECIMAL(12,2);
+
+-- 创建账本账户表
+CREATE TABLE IF NOT EXISTS ledger_accounts (
+    id SERIAL PRIMARY KEY,
+    code VARCHAR(100) UNIQUE NOT NULL,
+    type VARCHAR(50) NOT NULL,
+    user_id INTEGER REFERENCES users(id),
+    name VARCHAR(255),
+    balance DECIMAL(12,2) DEFAULT 0.00,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- 创建账本交易表
+CREATE TABLE IF NOT EXISTS ledger_transactions (
+    id SERIAL PRIMARY KEY,
+    tx_no VARCHAR(100) UNIQUE NOT NULL,
+    type VARCHAR(50) NOT NULL,
+    user_id INTEGER,
+    order_id INTEGER REFERENCES orders(id),
+    amount DECIMAL(12,2) NOT NULL,
+    description TEXT,
+    operator_id INTEGER,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- 创建账本分录表
+CREATE TABLE IF NOT EXISTS ledger_entries (
+    id SERIAL PRIMARY KEY,
+    transaction_id INTEGER NOT NULL REFERENCES ledger_transactions(id),
+    account_id INTEGER NOT NULL REFERENCES ledger_accounts(id),
+    amount DECIMAL(12,2) NOT NULL,
+    balance_after DECIMAL(12,2) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- 创建索引
+CREATE INDEX IF NOT EXISTS idx_ledger_accounts_user_id ON ledger_accounts(user_id);
+
+CREATE INDEX IF NOT EXISTS idx_ledger_transactions_type ON ledger_transactions(type);
+CREATE INDEX IF NOT EXISTS idx_ledger_transactions_user_id ON ledger_transactions(user_id);
+CREATE INDEX IF NOT EXISTS idx_ledger_transactions_order_id ON ledger_transactions(order_id);
+
+CREATE INDEX IF NOT EXISTS idx_ledger_entries_transaction_id ON ledger_entries(transaction_id);
+CREATE INDEX IF NOT EXISTS idx_ledger_entries_account_id ON ledger_entries(account_id);
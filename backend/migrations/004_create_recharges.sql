@@ -0,0 +1,25 @@
+-- 迁移: create_recharges
+-- 版本: 004
+-- 创建时间: 2026-10-19 11:00:00
+
+-- 创建充值单表
+CREATE TABLE IF NOT EXISTS recharges (
+    id SERIAL PRIMARY KEY,
+    recharge_no VARCHAR(100) UNIQUE NOT NULL,
+    user_id INTEGER NOT NULL REFERENCES users(id),
+    method VARCHAR(50) NOT NULL,
+    amount DECIMAL(12,2) NOT NULL,
+    status VARCHAR(50) DEFAULT 'pending',
+    transaction_id VARCHAR(255),
+    pay_time TIMESTAMP WITH TIME ZONE,
+    expire_at TIMESTAMP WITH TIME ZONE,
+    client_ip VARCHAR(64),
+    remark TEXT,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX IF NOT EXISTS idx_recharges_user_id ON recharges(user_id);
+CREATE INDEX IF NOT EXISTS idx_recharges_status ON recharges(status);
+CREATE INDEX IF NOT EXISTS idx_recharges_deleted_at ON recharges(deleted_at);
@@ -0,0 +1,8 @@
+-- 迁移: add_order_pay_expire
+-- 版本: 005
+-- 创建时间: 2026-10-19 12:00:00
+
+-- 订单支付截止时间，第三方支付超时未支付自动关闭
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS pay_expire_at TIMESTAMP WITH TIME ZONE;
+
+CREATE INDEX IF NOT EXISTS idx_orders_status_pay_expire_at ON orders(status, pay_expire_at);
@@ -0,0 +1,34 @@
+-- 迁移: create_refunds
+-- 版本: 006
+-- 创建时间: 2026-10-19 13:00:00
+
+-- 支付记录区分支付和退款
+ALTER TABLE payments ADD COLUMN IF NOT EXISTS type VARCHAR(50) DEFAULT 'pay';
+
+-- 创建退款单表
+CREATE TABLE IF NOT EXISTS refunds (
+    id SERIAL PRIMARY KEY,
+    refund_no VARCHAR(100) UNIQUE NOT NULL,
+    order_id INTEGER NOT NULL REFERENCES orders(id),
+    user_id INTEGER NOT NULL REFERENCES users(id),
+    server_id INTEGER REFERENCES servers(id),
+    payment_id INTEGER NOT NULL REFERENCES payments(id),
+    type VARCHAR(50) NOT NULL,
+    method VARCHAR(50) NOT NULL,
+    amount DECIMAL(10,2) NOT NULL,
+    status VARCHAR(50) DEFAULT 'pending',
+    reason TEXT,
+    remark TEXT,
+    transaction_id VARCHAR(255),
+    operator_id INTEGER,
+    reviewed_at TIMESTAMP WITH TIME ZONE,
+    refund_time TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX IF NOT EXISTS idx_refunds_order_id ON refunds(order_id);
+CREATE INDEX IF NOT EXISTS idx_refunds_user_id ON refunds(user_id);
+CREATE INDEX IF NOT EXISTS idx_refunds_status ON refunds(status);
+CREATE INDEX IF NOT EXISTS idx_refunds_deleted_at ON refunds(deleted_at);
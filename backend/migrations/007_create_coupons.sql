@@ -0,0 +1,70 @@
+-- 迁移: create_coupons
+-- 版本: 007
+-- 创建时间: 2026-10-19 14:00:00
+
+-- 订单记录使用的优惠券和促销活动
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS coupon_id INTEGER;
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS promotion_id INTEGER;
+
+-- 创建优惠券表
+CREATE TABLE IF NOT EXISTS coupons (
+    id SERIAL PRIMARY KEY,
+    code VARCHAR(100) UNIQUE NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    type VARCHAR(50) NOT NULL,
+    value DECIMAL(10,2) NOT NULL,
+    max_discount DECIMAL(10,2) DEFAULT 0.00,
+    min_amount DECIMAL(10,2) DEFAULT 0.00,
+    product_ids TEXT,
+    provider_ids TEXT,
+    order_types TEXT,
+    first_purchase_only BOOLEAN DEFAULT FALSE,
+    total_limit INTEGER DEFAULT 0,
+    per_user_limit INTEGER DEFAULT 1,
+    used_count INTEGER DEFAULT 0,
+    start_time TIMESTAMP WITH TIME ZONE,
+    end_time TIMESTAMP WITH TIME ZONE,
+    status INTEGER DEFAULT 1,
+    description TEXT,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP WITH TIME ZONE
+);
+
+-- 创建优惠券使用记录表
+CREATE TABLE IF NOT EXISTS coupon_usages (
+    id SERIAL PRIMARY KEY,
+    coupon_id INTEGER NOT NULL REFERENCES coupons(id),
+    user_id INTEGER NOT NULL REFERENCES users(id),
+    order_id INTEGER NOT NULL REFERENCES orders(id),
+    discount_amount DECIMAL(10,2) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- 创建促销活动表
+CREATE TABLE IF NOT EXISTS promotions (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    type VARCHAR(50) NOT NULL,
+    value DECIMAL(10,2) NOT NULL,
+    max_discount DECIMAL(10,2) DEFAULT 0.00,
+    min_amount DECIMAL(10,2) DEFAULT 0.00,
+    min_period INTEGER DEFAULT 0,
+    product_ids TEXT,
+    provider_ids TEXT,
+    order_types TEXT,
+    first_purchase_only BOOLEAN DEFAULT FALSE,
+    start_time TIMESTAMP WITH TIME ZONE,
+    end_time TIMESTAMP WITH TIME ZONE,
+    status INTEGER DEFAULT 1,
+    description TEXT,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX IF NOT EXISTS idx_coupons_deleted_at ON coupons(deleted_at);
+CREATE INDEX IF NOT EXISTS idx_coupon_usages_coupon_id ON coupon_usages(coupon_id);
+CREATE INDEX IF NOT EXISTS idx_coupon_usages_user_id ON coupon_usages(user_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_coupon_usages_order_id ON coupon_usages(order_id);
+CREATE INDEX IF NOT EXISTS idx_promotions_deleted_at ON promotions(deleted_at);
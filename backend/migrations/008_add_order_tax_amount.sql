@@ -0,0 +1,6 @@
+-- 迁移: add_order_tax_amount
+-- 版本: 008
+-- 创建时间: 2026-10-19 15:00:00
+
+-- 订单税费，按优惠后金额计算
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS tax_amount DECIMAL(10,2) DEFAULT 0;
@@ -0,0 +1,57 @@
+-- 迁移: create_invoices
+-- 版本: 009
+-- 创建时间: 2026-10-19 16:00:00
+
+-- 用户开票信息
+ALTER TABLE users ADD COLUMN IF NOT EXISTS invoice_buyer_type VARCHAR(20);
+ALTER TABLE users ADD COLUMN IF NOT EXISTS invoice_title VARCHAR(200);
+ALTER TABLE users ADD COLUMN IF NOT EXISTS invoice_tax_no VARCHAR(20);
+ALTER TABLE users ADD COLUMN IF NOT EXISTS invoice_address VARCHAR(255);
+ALTER TABLE users ADD COLUMN IF NOT EXISTS invoice_phone VARCHAR(50);
+ALTER TABLE users ADD COLUMN IF NOT EXISTS invoice_bank_name VARCHAR(100);
+ALTER TABLE users ADD COLUMN IF NOT EXISTS invoice_bank_account VARCHAR(50);
+ALTER TABLE users ADD COLUMN IF NOT EXISTS invoice_email VARCHAR(100);
+
+-- 创建发票表
+CREATE TABLE IF NOT EXISTS invoices (
+    id SERIAL PRIMARY KEY,
+    invoice_no VARCHAR(50) UNIQUE NOT NULL,
+    user_id INTEGER NOT NULL REFERENCES users(id),
+    type VARCHAR(20) NOT NULL,
+    source_type VARCHAR(20) NOT NULL,
+    order_id INTEGER REFERENCES orders(id),
+    recharge_id INTEGER REFERENCES recharges(id),
+    source_no VARCHAR(50),
+    item_name VARCHAR(100) NOT NULL,
+    amount DECIMAL(10,2) NOT NULL,
+    tax_rate DECIMAL(5,4) DEFAULT 0,
+    tax_amount DECIMAL(10,2) DEFAULT 0,
+    buyer_type VARCHAR(20) NOT NULL,
+    buyer_name VARCHAR(200) NOT NULL,
+    buyer_tax_no VARCHAR(20),
+    buyer_addr VARCHAR(255),
+    buyer_bank VARCHAR(255),
+    email VARCHAR(100),
+    status VARCHAR(20) DEFAULT 'issued',
+    issued_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    voided_at TIMESTAMP WITH TIME ZONE,
+    operator_id INTEGER REFERENCES users(id),
+    remark TEXT,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX IF NOT EXISTS idx_invoices_user_id ON invoices(user_id);
+CREATE INDEX IF NOT EXISTS idx_invoices_status ON invoices(status);
+CREATE INDEX IF NOT EXISTS idx_invoices_deleted_at ON invoices(deleted_at);
+
+-- 每笔订单、充值单只能有一张有效发票
+CREATE UNIQUE INDEX IF NOT EXISTS idx_invoices_order_issued ON invoices(order_id) WHERE status = 'issued' AND deleted_at IS NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_invoices_recharge_issued ON invoices(recharge_id) WHERE status = 'issued' AND deleted_at IS NULL;
+
+-- 创建发票号码序列表
+CREATE TABLE IF NOT EXISTS invoice_sequences (
+    year INTEGER PRIMARY KEY,
+    last_no BIGINT NOT NULL DEFAULT 0
+);
@@ -0,0 +1,28 @@
+-- 迁移: add_multi_currency
+-- 版本: 010
+-- 创建时间: 2026-10-19 17:00:00
+
+-- 产品标价币种、用户结算币种
+ALTER TABLE products ADD COLUMN IF NOT EXISTS currency VARCHAR(3) DEFAULT 'CNY';
+ALTER TABLE users ADD COLUMN IF NOT EXISTS currency VARCHAR(3) DEFAULT 'CNY';
+
+-- 订单币种和下单时锁定的汇率，base_pay_amount 为折合本位币的实付金额
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS currency VARCHAR(3) DEFAULT 'CNY';
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS exchange_rate DECIMAL(18,8) DEFAULT 1;
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS base_pay_amount DECIMAL(10,2) DEFAULT 0;
+UPDATE orders SET base_pay_amount = pay_amount WHERE base_pay_amount = 0;
+
+ALTER TABLE payments ADD COLUMN IF NOT EXISTS currency VARCHAR(3) DEFAULT 'CNY';
+ALTER TABLE refunds ADD COLUMN IF NOT EXISTS currency VARCHAR(3) DEFAULT 'CNY';
+ALTER TABLE invoices ADD COLUMN IF NOT EXISTS currency VARCHAR(3) DEFAULT 'CNY';
+
+-- 创建汇率表
+CREATE TABLE IF NOT EXISTS exchange_rates (
+    id SERIAL PRIMARY KEY,
+    currency VARCHAR(3) UNIQUE NOT NULL,
+    rate DECIMAL(18,8) NOT NULL,
+    source VARCHAR(20) DEFAULT 'manual',
+    operator_id INTEGER REFERENCES users(id),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
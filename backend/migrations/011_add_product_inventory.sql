@@ -0,0 +1,12 @@
+-- 迁移: add_product_inventory
+-- 版本: 011
+-- 创建时间: 2026-10-19 18:00:00
+
+-- 产品库存：stock 为剩余可售库存（-1不限），reserved 为未完成订单占用的库存
+ALTER TABLE products ADD COLUMN IF NOT EXISTS stock INTEGER DEFAULT -1;
+ALTER TABLE products ADD COLUMN IF NOT EXISTS reserved INTEGER DEFAULT 0;
+ALTER TABLE products ADD COLUMN IF NOT EXISTS purchase_limit INTEGER DEFAULT 0;
+ALTER TABLE products ADD COLUMN IF NOT EXISTS sold_out BOOLEAN DEFAULT FALSE;
+
+-- 订单占用的库存，开通完成或订单关闭时归还
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS reserved_stock INTEGER DEFAULT 0;
@@ -0,0 +1,7 @@
+-- 迁移: add_product_catalog_sync
+-- 版本: 012
+-- 创建时间: 2026-10-19 19:00:00
+
+-- 目录同步：cost_price 为厂商成本价，removed_at 为厂商下线该规格的时间
+ALTER TABLE products ADD COLUMN IF NOT EXISTS cost_price DECIMAL(10,2) DEFAULT 0;
+ALTER TABLE products ADD COLUMN IF NOT EXISTS removed_at TIMESTAMP WITH TIME ZONE;
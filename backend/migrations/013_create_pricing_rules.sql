@@ -0,0 +1,31 @@
+-- 迁移: create_pricing_rules
+-- 版本: 013
+-- 创建时间: 2026-10-19 20:00:00
+
+-- 定价规则：在成本价上加价得到售价，user_group 为空的规则计算标准售价
+CREATE TABLE IF NOT EXISTS pricing_rules (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    priority INTEGER DEFAULT 0,
+    provider_id INTEGER REFERENCES providers(id),
+    product_type VARCHAR(50),
+    region VARCHAR(50),
+    user_group VARCHAR(50) DEFAULT '',
+    markup_type VARCHAR(20) NOT NULL,
+    markup_value DECIMAL(10,2) NOT NULL,
+    rounding VARCHAR(20) DEFAULT 'none',
+    rounding_unit DECIMAL(10,2) DEFAULT 0,
+    price_ending DECIMAL(10,2) DEFAULT 0,
+    status INTEGER DEFAULT 1,
+    description TEXT,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX IF NOT EXISTS idx_pricing_rules_provider_id ON pricing_rules(provider_id);
+CREATE INDEX IF NOT EXISTS idx_pricing_rules_user_group ON pricing_rules(user_group);
+CREATE INDEX IF NOT EXISTS idx_pricing_rules_deleted_at ON pricing_rules(deleted_at);
+
+-- 用户定价分组，按分组定价规则享受专属价
+ALTER TABLE users ADD COLUMN IF NOT EXISTS price_group VARCHAR(50) DEFAULT '';
@@ -0,0 +1,31 @@
+-- 迁移: create_images
+-- 版本: 014
+-- 创建时间: 2026-10-19 21:00:00
+
+-- 镜像目录：系统镜像从厂商同步，应用镜像由管理员维护
+CREATE TABLE IF NOT EXISTS images (
+    id SERIAL PRIMARY KEY,
+    provider_id INTEGER NOT NULL REFERENCES providers(id),
+    region VARCHAR(50) NOT NULL,
+    image_id VARCHAR(100) NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    os_type VARCHAR(50),
+    os_name VARCHAR(255),
+    category VARCHAR(20) DEFAULT 'os',
+    app_name VARCHAR(100),
+    image_type VARCHAR(50),
+    min_storage INTEGER DEFAULT 0,
+    min_memory INTEGER DEFAULT 0,
+    source VARCHAR(20) DEFAULT 'sync',
+    status INTEGER DEFAULT 1,
+    sort_order INTEGER DEFAULT 0,
+    description TEXT,
+    removed_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_images_provider_region_image ON images(provider_id, region, image_id);
+
+-- 服务器记录购买或重装时选择的镜像
+ALTER TABLE servers ADD COLUMN IF NOT EXISTS image_id VARCHAR(100);
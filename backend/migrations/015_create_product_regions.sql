@@ -0,0 +1,18 @@
+-- 迁移: create_product_regions
+-- 版本: 015
+-- 创建时间: 2026-10-19 22:00:00
+
+-- 产品可售地域：同一套餐可在多个地域销售，price 为0表示使用产品售价；
+-- 未配置的产品只在产品自身的地域销售
+CREATE TABLE IF NOT EXISTS product_regions (
+    id SERIAL PRIMARY KEY,
+    product_id INTEGER NOT NULL REFERENCES products(id),
+    region VARCHAR(50) NOT NULL,
+    zones TEXT,
+    price DECIMAL(10,2) DEFAULT 0,
+    status INTEGER DEFAULT 1,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_product_regions_product_region ON product_regions(product_id, region);
@@ -0,0 +1,33 @@
+-- 迁移: create_ssh_keys
+-- 版本: 016
+-- 创建时间: 2026-10-19 23:00:00
+
+-- 用户SSH公钥，同一用户下按SHA256指纹去重
+CREATE TABLE IF NOT EXISTS ssh_keys (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id),
+    name VARCHAR(64) NOT NULL,
+    public_key TEXT NOT NULL,
+    fingerprint VARCHAR(100) NOT NULL,
+    key_type VARCHAR(50),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_ssh_keys_user_fingerprint ON ssh_keys(user_id, fingerprint);
+
+-- 公钥在厂商侧导入的密钥对，按厂商和地域复用
+CREATE TABLE IF NOT EXISTS ssh_key_bindings (
+    id SERIAL PRIMARY KEY,
+    ssh_key_id INTEGER NOT NULL REFERENCES ssh_keys(id),
+    provider_id INTEGER NOT NULL REFERENCES providers(id),
+    region VARCHAR(50) NOT NULL,
+    provider_key_id VARCHAR(100) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_ssh_key_bindings_key_provider_region ON ssh_key_bindings(ssh_key_id, provider_id, region);
+
+-- 服务器注入的公钥和登录方式
+ALTER TABLE servers ADD COLUMN IF NOT EXISTS ssh_key_ids TEXT;
+ALTER TABLE servers ADD COLUMN IF NOT EXISTS disable_password BOOLEAN DEFAULT FALSE;
@@ -0,0 +1,21 @@
+-- 迁移: create_user_data_templates
+-- 版本: 017
+-- 创建时间: 2026-10-20 00:00:00
+
+-- 用户数据（cloud-init）模板，user_id 为空的是管理员发布的全局模板
+CREATE TABLE IF NOT EXISTS user_data_templates (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER REFERENCES users(id),
+    name VARCHAR(64) NOT NULL,
+    format VARCHAR(20) NOT NULL,
+    content TEXT NOT NULL,
+    variables TEXT,
+    status INTEGER DEFAULT 1,
+    description TEXT,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_data_templates_user_id ON user_data_templates(user_id);
+CREATE INDEX IF NOT EXISTS idx_user_data_templates_deleted_at ON user_data_templates(deleted_at);
@@ -0,0 +1,18 @@
+-- 迁移: create_firewall_rules
+-- 版本: 018
+-- 创建时间: 2026-10-20 01:00:00
+
+-- 服务器防火墙规则，与厂商侧规则保持一致
+CREATE TABLE IF NOT EXISTS firewall_rules (
+    id SERIAL PRIMARY KEY,
+    server_id INTEGER NOT NULL REFERENCES servers(id),
+    protocol VARCHAR(10) NOT NULL,
+    port VARCHAR(200) NOT NULL,
+    cidr VARCHAR(50) NOT NULL,
+    action VARCHAR(10) NOT NULL,
+    description VARCHAR(100),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_firewall_rules_server_id ON firewall_rules(server_id);
@@ -0,0 +1,43 @@
+-- 迁移: create_snapshots
+-- 版本: 019
+-- 创建时间: 2026-10-20 02:00:00
+
+-- 服务器快照，超出免费额度的快照按月从余额扣费
+CREATE TABLE IF NOT EXISTS snapshots (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id),
+    server_id INTEGER NOT NULL REFERENCES servers(id),
+    snapshot_id VARCHAR(100) NOT NULL UNIQUE,
+    name VARCHAR(100) NOT NULL,
+    type VARCHAR(20) NOT NULL,
+    size INTEGER DEFAULT 0,
+    chargeable BOOLEAN DEFAULT FALSE,
+    price DECIMAL(10,2) DEFAULT 0,
+    billed_until TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_snapshots_user_id ON snapshots(user_id);
+CREATE INDEX IF NOT EXISTS idx_snapshots_server_id ON snapshots(server_id);
+CREATE INDEX IF NOT EXISTS idx_snapshots_billed_until ON snapshots(billed_until) WHERE chargeable;
+
+-- 服务器定期备份计划
+CREATE TABLE IF NOT EXISTS backup_schedules (
+    id SERIAL PRIMARY KEY,
+    server_id INTEGER NOT NULL UNIQUE REFERENCES servers(id),
+    user_id INTEGER NOT NULL REFERENCES users(id),
+    enabled BOOLEAN DEFAULT FALSE,
+    frequency VARCHAR(10) NOT NULL,
+    weekday INTEGER DEFAULT 0,
+    hour INTEGER DEFAULT 0,
+    retention INTEGER NOT NULL,
+    next_run_at TIMESTAMP WITH TIME ZONE,
+    last_run_at TIMESTAMP WITH TIME ZONE,
+    last_error TEXT,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_backup_schedules_user_id ON backup_schedules(user_id);
+CREATE INDEX IF NOT EXISTS idx_backup_schedules_next_run_at ON backup_schedules(next_run_at) WHERE enabled;
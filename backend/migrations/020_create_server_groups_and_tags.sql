@@ -0,0 +1,31 @@
+-- 迁移: create_server_groups_and_tags
+-- 版本: 020
+-- 创建时间: 2026-10-20 04:00:00
+
+-- 服务器分组（项目）
+CREATE TABLE IF NOT EXISTS server_groups (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id),
+    name VARCHAR(64) NOT NULL,
+    description VARCHAR(255),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_server_groups_user_name ON server_groups(user_id, name);
+
+ALTER TABLE servers ADD COLUMN IF NOT EXISTS group_id INTEGER REFERENCES server_groups(id);
+CREATE INDEX IF NOT EXISTS idx_servers_group_id ON servers(group_id);
+
+-- 服务器键值标签
+CREATE TABLE IF NOT EXISTS server_tags (
+    id SERIAL PRIMARY KEY,
+    server_id INTEGER NOT NULL REFERENCES servers(id),
+    key VARCHAR(64) NOT NULL,
+    value VARCHAR(128) DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_server_tags_server_key ON server_tags(server_id, key);
+CREATE INDEX IF NOT EXISTS idx_server_tags_key ON server_tags(key);
@@ -0,0 +1,6 @@
+-- 迁移: complete_renew_orders
+-- 版本: 021
+-- 创建时间: 2026-10-20 05:00:00
+
+-- 此前的续费订单在支付时已顺延到期时间，停留在已支付状态的标记为完成，避免开通任务再次续费
+UPDATE orders SET status = 'success' WHERE type = 'renew' AND status = 'paid';
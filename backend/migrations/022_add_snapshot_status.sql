@@ -0,0 +1,6 @@
+-- 迁移: add_snapshot_status
+-- 版本: 022
+-- 创建时间: 2026-10-20 06:00:00
+
+-- 快照先以创建中状态占用额度并扣费，厂商创建成功后改为可用
+ALTER TABLE snapshots ADD COLUMN IF NOT EXISTS status VARCHAR(20) DEFAULT 'available';
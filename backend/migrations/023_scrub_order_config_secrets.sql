@@ -0,0 +1,10 @@
+-- 迁移: scrub_order_config_secrets
+-- 版本: 023
+-- 创建时间: 2026-10-20 07:00:00
+
+-- 已结束的订单不会再开通实例，清除配置中保存的登录密码和用户数据
+UPDATE orders
+SET config = (config::jsonb - 'password' - 'user_data')::text
+WHERE status IN ('success', 'failed', 'cancelled', 'refunded')
+  AND config IS NOT NULL
+  AND config <> '';
@@ -2,6 +2,7 @@ package database
 
 import (
 	"cloudbp-backend/internal/model"
+	"github.com/shopspring/decimal"
 	"gorm.io/gorm"
 )
 
@@ -57,8 +58,8 @@ func InitData(db *gorm.DB) error {
 			Bandwidth:     3,
 			Traffic:       100,
 			OS:            "Ubuntu 20.04",
-			Price:         24.00,
-			OriginalPrice: 24.00,
+			Price:         decimal.NewFromInt(24),
+			OriginalPrice: decimal.NewFromInt(24),
 			Status:        model.ProductStatusOnline,
 			Description:   "适合个人开发者和小型应用",
 			Features:      `{"support_docker": true, "support_ssh": true, "backup": true}`,
@@ -77,8 +78,8 @@ func InitData(db *gorm.DB) error {
 			Bandwidth:     5,
 			Traffic:       200,
 			OS:            "Ubuntu 20.04",
-			Price:         54.00,
-			OriginalPrice: 54.00,
+			Price:         decimal.NewFromInt(54),
+			OriginalPrice: decimal.NewFromInt(54),
 			Status:        model.ProductStatusOnline,
 			Description:   "适合中小型应用和网站",
 			Features:      `{"support_docker": true, "support_ssh": true, "backup": true}`,
@@ -97,8 +98,8 @@ func InitData(db *gorm.DB) error {
 			Bandwidth:     6,
 			Traffic:       300,
 			OS:            "Ubuntu 20.04",
-			Price:         108.00,
-			OriginalPrice: 108.00,
+			Price:         decimal.NewFromInt(108),
+			OriginalPrice: decimal.NewFromInt(108),
 			Status:        model.ProductStatusOnline,
 			Description:   "适合大型应用和数据库服务",
 			Features:      `{"support_docker": true, "support_ssh": true, "backup": true}`,
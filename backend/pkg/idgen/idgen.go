@@ -0,0 +1,122 @@
+package idgen
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// MaxNode 节点号上限，多实例部署时每个实例配置不同的节点号
+	MaxNode = 999
+	// maxSeq 单节点每秒可生成的单号数量上限
+	maxSeq = 99999
+	// nodeCounterKey 自动分配节点号的Redis计数器
+	nodeCounterKey = "idgen:node_counter"
+)
+
+// Generator 业务单号生成器
+// 单号格式为：前缀 + 秒级时间(yyyyMMddHHmmss) + 3位节点号 + 5位秒内序号，
+// 同一节点内由互斥锁保证序号递增，不同节点由节点号区分，因此不会重复
+type Generator struct {
+	mu   sync.Mutex
+	node int
+	last int64 // 上次生成单号的秒级时间戳
+	seq  int
+}
+
+// NewGenerator 创建单号生成器，node 取值 0-999
+//
+// 进程重启后，启动所在这一秒的序号可能已被上一个进程用过，因此视为已用尽，
+// 从下一秒开始生成单号
+func NewGenerator(node int) (*Generator, error) {
+	if node < 0 || node > MaxNode {
+		return nil, fmt.Errorf("节点号必须在0-%d之间", MaxNode)
+	}
+	return &Generator{
+		node: node,
+		last: time.Now().Unix(),
+		seq:  maxSeq,
+	}, nil
+}
+
+// Next 生成下一个单号
+func (g *Generator) Next(prefix string) string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	now := time.Now().Unix()
+	// 时钟回拨时沿用上次的时间继续递增，保证单号不重复
+	if now < g.last {
+		now = g.last
+	}
+
+	if now == g.last {
+		g.seq++
+		if g.seq > maxSeq {
+			// 当前秒序号用尽，等待进入下一秒
+			for now <= g.last {
+				time.Sleep(time.Millisecond)
+				now = time.Now().Unix()
+			}
+			g.seq = 0
+		}
+	} else {
+		g.seq = 0
+	}
+	g.last = now
+
+	return fmt.Sprintf("%s%s%03d%05d", prefix, time.Unix(now, 0).Format("20060102150405"), g.node, g.seq)
+}
+
+var (
+	defaultMu        sync.RWMutex
+	defaultGenerator *Generator
+)
+
+// SetNode 设置默认生成器的节点号，启动时必须先设置节点号或通过 AssignNode 分配
+func SetNode(node int) error {
+	generator, err := NewGenerator(node)
+	if err != nil {
+		return err
+	}
+
+	defaultMu.Lock()
+	defaultGenerator = generator
+	defaultMu.Unlock()
+	return nil
+}
+
+// AssignNode 通过Redis计数器为本实例分配节点号并设置默认生成器，
+// 计数器循环使用0-999，同时运行的实例不超过1000个时节点号不会重复
+func AssignNode(ctx context.Context, rdb *redis.Client) (int, error) {
+	if rdb == nil {
+		return 0, errors.New("未配置Redis，无法自动分配节点号")
+	}
+
+	n, err := rdb.Incr(ctx, nodeCounterKey).Result()
+	if err != nil {
+		return 0, fmt.Errorf("分配节点号失败: %w", err)
+	}
+
+	node := int((n - 1) % (MaxNode + 1))
+	if err := SetNode(node); err != nil {
+		return 0, err
+	}
+	return node, nil
+}
+
+// Next 使用默认生成器生成单号，未设置节点号时直接panic，避免不同实例生成重复单号
+func Next(prefix string) string {
+	defaultMu.RLock()
+	generator := defaultGenerator
+	defaultMu.RUnlock()
+	if generator == nil {
+		panic("idgen: 未设置单号生成节点号")
+	}
+	return generator.Next(prefix)
+}
@@ -0,0 +1,159 @@
+package invoice
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+
+	"github.com/jung-kurt/gofpdf"
+	"github.com/shopspring/decimal"
+)
+
+// Party 购买方或销售方信息
+type Party struct {
+	Name  string `json:"name"`   // 名称
+	TaxNo string `json:"tax_no"` // 纳税人识别号
+	Addr  string `json:"addr"`   // 地址、电话
+	Bank  string `json:"bank"`   // 开户行及账号
+}
+
+// Document 发票版式数据
+type Document struct {
+	Type      string          `json:"type"`       // normal:增值税普通发票 special:增值税专用发票
+	InvoiceNo string          `json:"invoice_no"` // 发票号码
+	IssuedAt  time.Time       `json:"issued_at"`  // 开票日期
+	Buyer     Party           `json:"buyer"`
+	Seller    Party           `json:"seller"`
+	ItemName  string          `json:"item_name"`  // 货物或应税劳务名称
+	Amount    decimal.Decimal `json:"amount"`     // 价税合计
+	Currency  string          `json:"currency"`   // 币种
+	TaxRate   decimal.Decimal `json:"tax_rate"`   // 税率
+	TaxAmount decimal.Decimal `json:"tax_amount"` // 税额
+	SourceNo  string          `json:"source_no"`  // 订单号或充值单号
+	Remark    string          `json:"remark"`     // 备注
+	Voided    bool            `json:"voided"`     // 已作废
+}
+
+// Renderer PDF发票渲染器
+type Renderer struct {
+	fontPath string
+}
+
+// NewRenderer 创建PDF发票渲染器，fontPath为中文TrueType字体，为空时使用内置西文字体和英文标签
+func NewRenderer(fontPath string) *Renderer {
+	return &Renderer{fontPath: fontPath}
+}
+
+// labels 版面标签，依次为中文和英文
+var labels = map[string][2]string{
+	"normal":     {"增值税普通发票", "VAT Invoice"},
+	"special":    {"增值税专用发票", "Special VAT Invoice"},
+	"invoice_no": {"发票号码", "Invoice No."},
+	"issued_at":  {"开票日期", "Issue Date"},
+	"buyer":      {"购买方", "Buyer"},
+	"seller":     {"销售方", "Seller"},
+	"name":       {"名称", "Name"},
+	"tax_no":     {"纳税人识别号", "Tax ID"},
+	"addr":       {"地址、电话", "Address/Phone"},
+	"bank":       {"开户行及账号", "Bank/Account"},
+	"item":       {"项目名称", "Item"},
+	"net":        {"金额", "Net"},
+	"tax_rate":   {"税率", "Tax Rate"},
+	"tax":        {"税额", "Tax"},
+	"total":      {"价税合计", "Total"},
+	"source_no":  {"业务单号", "Reference"},
+	"remark":     {"备注", "Remark"},
+	"voided":     {"已作废", "VOID"},
+}
+
+// Render 渲染PDF发票
+func (r *Renderer) Render(doc *Document) ([]byte, error) {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetTitle(doc.InvoiceNo, true)
+	pdf.SetCreator("CloudBP", true)
+
+	family, lang := "Helvetica", 1
+	translate := pdf.UnicodeTranslatorFromDescriptor("")
+	if r.fontPath != "" {
+		pdf.AddUTF8Font("invoice", "", r.fontPath)
+		family, lang = "invoice", 0
+		translate = func(s string) string { return s }
+	}
+	label := func(key string) string {
+		return labels[key][lang]
+	}
+
+	pdf.AddPage()
+	pdf.SetFont(family, "", 18)
+	pdf.CellFormat(0, 12, translate(label(doc.Type)), "", 1, "C", false, 0, "")
+
+	pdf.SetFont(family, "", 10)
+	pdf.CellFormat(0, 6, translate(fmt.Sprintf("%s: %s", label("invoice_no"), doc.InvoiceNo)), "", 1, "R", false, 0, "")
+	pdf.CellFormat(0, 6, translate(fmt.Sprintf("%s: %s", label("issued_at"), doc.IssuedAt.Format("2006-01-02"))), "", 1, "R", false, 0, "")
+	pdf.Ln(4)
+
+	// 购买方、销售方
+	party := func(title string, p Party) {
+		pdf.SetFont(family, "", 11)
+		pdf.CellFormat(0, 8, translate(title), "B", 1, "L", false, 0, "")
+		pdf.SetFont(family, "", 10)
+		for _, row := range [][2]string{
+			{label("name"), p.Name},
+			{label("tax_no"), p.TaxNo},
+			{label("addr"), p.Addr},
+			{label("bank"), p.Bank},
+		} {
+			pdf.CellFormat(40, 7, translate(row[0]), "", 0, "L", false, 0, "")
+			pdf.CellFormat(0, 7, translate(row[1]), "", 1, "L", false, 0, "")
+		}
+		pdf.Ln(3)
+	}
+	party(label("buyer"), doc.Buyer)
+	party(label("seller"), doc.Seller)
+
+	// 明细
+	widths := []float64{80, 35, 25, 35}
+	pdf.SetFont(family, "", 10)
+	for i, key := range []string{"item", "net", "tax_rate", "tax"} {
+		pdf.CellFormat(widths[i], 8, translate(label(key)), "1", 0, "C", false, 0, "")
+	}
+	pdf.Ln(-1)
+	net := doc.Amount.Sub(doc.TaxAmount)
+	for i, text := range []string{
+		doc.ItemName,
+		net.StringFixed(2),
+		doc.TaxRate.Mul(decimal.NewFromInt(100)).String() + "%",
+		doc.TaxAmount.StringFixed(2),
+	} {
+		align := "R"
+		if i == 0 {
+			align = "L"
+		}
+		pdf.CellFormat(widths[i], 8, translate(text), "1", 0, align, false, 0, "")
+	}
+	pdf.Ln(-1)
+	pdf.CellFormat(widths[0], 8, translate(label("total")), "1", 0, "L", false, 0, "")
+	pdf.CellFormat(widths[1]+widths[2]+widths[3], 8, fmt.Sprintf("%s %s", doc.Currency, doc.Amount.StringFixed(2)), "1", 1, "R", false, 0, "")
+	pdf.Ln(4)
+
+	pdf.CellFormat(40, 7, translate(label("source_no")), "", 0, "L", false, 0, "")
+	pdf.CellFormat(0, 7, translate(doc.SourceNo), "", 1, "L", false, 0, "")
+	if doc.Remark != "" {
+		pdf.CellFormat(40, 7, translate(label("remark")), "", 0, "L", false, 0, "")
+		pdf.MultiCell(0, 7, translate(doc.Remark), "", "L", false)
+	}
+
+	// 作废发票加盖标记
+	if doc.Voided {
+		pdf.SetFont(family, "", 36)
+		pdf.SetTextColor(200, 0, 0)
+		pdf.SetXY(10, 120)
+		pdf.CellFormat(0, 20, translate(label("voided")), "", 1, "C", false, 0, "")
+	}
+
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		return nil, fmt.Errorf("生成PDF失败: %w", err)
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,291 @@
+package payment
+
+import (
+	"context"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"cloudbp-backend/internal/config"
+
+	"github.com/shopspring/decimal"
+)
+
+// Alipay 支付宝适配器，扫码使用当面付预下单，跳转使用电脑网站支付
+type Alipay struct {
+	config    config.AlipayConfig
+	notifyURL string
+	appKey    *rsa.PrivateKey
+	alipayKey *rsa.PublicKey
+	client    *http.Client
+}
+
+// NewAlipay 创建支付宝适配器
+func NewAlipay(cfg config.AlipayConfig, notifyURL string) (*Alipay, error) {
+	// 验证配置
+	if cfg.AppID == "" || cfg.GatewayURL == "" {
+		return nil, errors.New("支付宝配置不完整")
+	}
+
+	appKey, err := loadPrivateKey(cfg.PrivateKeyPath)
+	if err != nil {
+		return nil, err
+	}
+
+	alipayKey, err := loadPublicKey(cfg.AlipayPublicKeyPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Alipay{
+		config:    cfg,
+		notifyURL: notifyURL,
+		appKey:    appKey,
+		alipayKey: alipayKey,
+		client:    &http.Client{Timeout: 10 * time.Second},
+	}, nil
+}
+
+// GetName 获取通道名称
+func (a *Alipay) GetName() string {
+	return "支付宝"
+}
+
+// GetCode 获取通道代码
+func (a *Alipay) GetCode() string {
+	return "alipay"
+}
+
+// CreatePayment 创建支付，qrcode返回二维码内容，redirect返回收银台地址
+func (a *Alipay) CreatePayment(ctx context.Context, req *CreatePaymentRequest) (*CreatePaymentResponse, error) {
+	if err := requireCNY(a, req.Currency); err != nil {
+		return nil, err
+	}
+
+	bizContent := map[string]interface{}{
+		"out_trade_no": req.TradeNo,
+		"total_amount": req.Amount.StringFixed(2),
+		"subject":      req.Subject,
+	}
+	if !req.ExpireAt.IsZero() {
+		bizContent["time_expire"] = req.ExpireAt.Format("2006-01-02 15:04:05")
+	}
+
+	if req.PayType == PayTypeRedirect {
+		bizContent["product_code"] = "FAST_INSTANT_TRADE_PAY"
+		params, err := a.signedParams("alipay.trade.page.pay", bizContent, req.ReturnURL)
+		if err != nil {
+			return nil, err
+		}
+
+		return &CreatePaymentResponse{
+			TradeNo: req.TradeNo,
+			PayType: PayTypeRedirect,
+			PayURL:  a.config.GatewayURL + "?" + params.Encode(),
+		}, nil
+	}
+
+	var resp struct {
+		QRCode string `json:"qr_code"`
+	}
+	if err := a.do(ctx, "alipay.trade.precreate", bizContent, &resp); err != nil {
+		return nil, err
+	}
+
+	return &CreatePaymentResponse{
+		TradeNo: req.TradeNo,
+		PayType: PayTypeQRCode,
+		CodeURL: resp.QRCode,
+	}, nil
+}
+
+// ParseNotify 验签并解析支付结果通知
+func (a *Alipay) ParseNotify(ctx context.Context, r *http.Request) (*NotifyResult, error) {
+	if err := r.ParseForm(); err != nil {
+		return nil, fmt.Errorf("解析通知内容失败: %w", err)
+	}
+	form := r.PostForm
+
+	signature := form.Get("sign")
+	if signature == "" {
+		return nil, errors.New("缺少支付宝签名")
+	}
+	if form.Get("app_id") != a.config.AppID {
+		return nil, errors.New("通知应用ID不匹配")
+	}
+	if err := verifySHA256WithRSA(a.alipayKey, signContent(form, "sign", "sign_type"), signature); err != nil {
+		return nil, err
+	}
+
+	amount, err := decimal.NewFromString(form.Get("total_amount"))
+	if err != nil {
+		return nil, errors.New("通知金额格式错误")
+	}
+
+	status := form.Get("trade_status")
+	result := &NotifyResult{
+		TradeNo:       form.Get("out_trade_no"),
+		TransactionID: form.Get("trade_no"),
+		Amount:        amount,
+		Paid:          status == "TRADE_SUCCESS" || status == "TRADE_FINISHED",
+	}
+	if paidAt, err := time.ParseInLocation("2006-01-02 15:04:05", form.Get("gmt_payment"), time.Local); err == nil {
+		result.PaidAt = paidAt
+	}
+
+	return result, nil
+}
+
+// NotifyResponse 异步通知应答
+func (a *Alipay) NotifyResponse(success bool) *NotifyResponse {
+	body := "failure"
+	if success {
+		body = "success"
+	}
+	return &NotifyResponse{
+		StatusCode:  http.StatusOK,
+		ContentType: "text/plain",
+		Body:        body,
+	}
+}
+
+// Refund 申请退款，out_request_no 保证同一退款单重复请求幂等
+func (a *Alipay) Refund(ctx context.Context, req *RefundRequest) (*RefundResponse, error) {
+	if err := requireCNY(a, req.Currency); err != nil {
+		return nil, err
+	}
+
+	bizContent := map[string]interface{}{
+		"out_trade_no":   req.TradeNo,
+		"out_request_no": req.RefundNo,
+		"refund_amount":  req.Amount.StringFixed(2),
+		"refund_reason":  req.Reason,
+	}
+
+	var resp struct {
+		TradeNo    string `json:"trade_no"`
+		FundChange string `json:"fund_change"`
+	}
+	if err := a.do(ctx, "alipay.trade.refund", bizContent, &resp); err != nil {
+		return nil, err
+	}
+
+	return &RefundResponse{
+		RefundNo: req.RefundNo,
+		RefundID: resp.TradeNo,
+	}, nil
+}
+
+// do 调用支付宝开放平台接口，result 为 <method>_response 节点内容
+func (a *Alipay) do(ctx context.Context, method string, bizContent map[string]interface{}, result interface{}) error {
+	params, err := a.signedParams(method, bizContent, "")
+	if err != nil {
+		return err
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, a.config.GatewayURL, strings.NewReader(params.Encode()))
+	if err != nil {
+		return fmt.Errorf("创建请求失败: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/x-www-form-urlencoded;charset=utf-8")
+
+	httpResp, err := a.client.Do(httpReq)
+	if err != nil {
+		return fmt.Errorf("请求支付宝失败: %w", err)
+	}
+	defer httpResp.Body.Close()
+
+	body, err := io.ReadAll(httpResp.Body)
+	if err != nil {
+		return fmt.Errorf("读取支付宝响应失败: %w", err)
+	}
+
+	var envelope map[string]json.RawMessage
+	if err := json.Unmarshal(body, &envelope); err != nil {
+		return fmt.Errorf("解析支付宝响应失败: %w", err)
+	}
+
+	node, ok := envelope[strings.ReplaceAll(method, ".", "_")+"_response"]
+	if !ok {
+		return errors.New("支付宝响应格式错误")
+	}
+
+	var status struct {
+		Code    string `json:"code"`
+		Msg     string `json:"msg"`
+		SubCode string `json:"sub_code"`
+		SubMsg  string `json:"sub_msg"`
+	}
+	if err := json.Unmarshal(node, &status); err != nil {
+		return fmt.Errorf("解析支付宝响应失败: %w", err)
+	}
+	if status.Code != "10000" {
+		return fmt.Errorf("支付宝返回错误: %s %s", status.SubCode, status.SubMsg)
+	}
+
+	if result != nil {
+		if err := json.Unmarshal(node, result); err != nil {
+			return fmt.Errorf("解析支付宝响应失败: %w", err)
+		}
+	}
+	return nil
+}
+
+// signedParams 组装公共请求参数并使用RSA2签名
+func (a *Alipay) signedParams(method string, bizContent map[string]interface{}, returnURL string) (url.Values, error) {
+	content, err := json.Marshal(bizContent)
+	if err != nil {
+		return nil, fmt.Errorf("序列化业务参数失败: %w", err)
+	}
+
+	params := url.Values{}
+	params.Set("app_id", a.config.AppID)
+	params.Set("method", method)
+	params.Set("format", "JSON")
+	params.Set("charset", "utf-8")
+	params.Set("sign_type", "RSA2")
+	params.Set("timestamp", time.Now().Format("2006-01-02 15:04:05"))
+	params.Set("version", "1.0")
+	params.Set("notify_url", a.notifyURL)
+	params.Set("biz_content", string(content))
+	if returnURL != "" {
+		params.Set("return_url", returnURL)
+	}
+
+	signature, err := signSHA256WithRSA(a.appKey, signContent(params, "sign"))
+	if err != nil {
+		return nil, err
+	}
+	params.Set("sign", signature)
+
+	return params, nil
+}
+
+// signContent 按参数名排序拼接待签名字符串，跳过空值和排除的参数
+func signContent(params url.Values, excludes ...string) string {
+	skip := make(map[string]bool, len(excludes))
+	for _, key := range excludes {
+		skip[key] = true
+	}
+
+	keys := make([]string, 0, len(params))
+	for key := range params {
+		if !skip[key] && params.Get(key) != "" {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, 0, len(keys))
+	for _, key := range keys {
+		pairs = append(pairs, key+"="+params.Get(key))
+	}
+	return strings.Join(pairs, "&")
+}
@@ -0,0 +1,124 @@
+package payment
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// loadPrivateKey 读取PEM格式的RSA私钥，支持PKCS#8和PKCS#1
+func loadPrivateKey(path string) (*rsa.PrivateKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取私钥文件失败: %w", err)
+	}
+	return parsePrivateKey(data)
+}
+
+// parsePrivateKey 解析RSA私钥，兼容支付宝导出的不带PEM头的Base64私钥
+func parsePrivateKey(data []byte) (*rsa.PrivateKey, error) {
+	der, err := pemOrBase64(data)
+	if err != nil {
+		return nil, err
+	}
+
+	if key, err := x509.ParsePKCS8PrivateKey(der); err == nil {
+		rsaKey, ok := key.(*rsa.PrivateKey)
+		if !ok {
+			return nil, errors.New("私钥不是RSA类型")
+		}
+		return rsaKey, nil
+	}
+
+	key, err := x509.ParsePKCS1PrivateKey(der)
+	if err != nil {
+		return nil, fmt.Errorf("解析私钥失败: %w", err)
+	}
+	return key, nil
+}
+
+// loadPublicKey 读取RSA公钥，支持公钥和X.509证书两种格式
+func loadPublicKey(path string) (*rsa.PublicKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取公钥文件失败: %w", err)
+	}
+
+	der, err := pemOrBase64(data)
+	if err != nil {
+		return nil, err
+	}
+
+	if cert, err := x509.ParseCertificate(der); err == nil {
+		rsaKey, ok := cert.PublicKey.(*rsa.PublicKey)
+		if !ok {
+			return nil, errors.New("证书公钥不是RSA类型")
+		}
+		return rsaKey, nil
+	}
+
+	key, err := x509.ParsePKIXPublicKey(der)
+	if err != nil {
+		return nil, fmt.Errorf("解析公钥失败: %w", err)
+	}
+	rsaKey, ok := key.(*rsa.PublicKey)
+	if !ok {
+		return nil, errors.New("公钥不是RSA类型")
+	}
+	return rsaKey, nil
+}
+
+// pemOrBase64 取出PEM块内容，不是PEM格式时按Base64解码
+func pemOrBase64(data []byte) ([]byte, error) {
+	if block, _ := pem.Decode(data); block != nil {
+		return block.Bytes, nil
+	}
+
+	der, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil {
+		return nil, errors.New("密钥格式错误")
+	}
+	return der, nil
+}
+
+// signSHA256WithRSA 使用SHA256withRSA签名并返回Base64结果
+func signSHA256WithRSA(key *rsa.PrivateKey, message string) (string, error) {
+	hashed := sha256.Sum256([]byte(message))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, hashed[:])
+	if err != nil {
+		return "", fmt.Errorf("签名失败: %w", err)
+	}
+	return base64.StdEncoding.EncodeToString(signature), nil
+}
+
+// verifySHA256WithRSA 校验SHA256withRSA签名
+func verifySHA256WithRSA(key *rsa.PublicKey, message, signature string) error {
+	sig, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return errors.New("签名格式错误")
+	}
+
+	hashed := sha256.Sum256([]byte(message))
+	if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, hashed[:], sig); err != nil {
+		return errors.New("签名校验失败")
+	}
+	return nil
+}
+
+// nonceStr 生成随机字符串
+func nonceStr() string {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(buf)
+}
@@ -0,0 +1,136 @@
+package payment
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// CurrencyCNY 人民币，未指定币种时的默认值
+const CurrencyCNY = "CNY"
+
+// 支付方式
+const (
+	PayTypeQRCode   = "qrcode"   // 扫码支付，前端根据 CodeURL 生成二维码
+	PayTypeRedirect = "redirect" // 跳转支付，前端跳转到 PayURL
+)
+
+// Gateway 支付通道接口
+type Gateway interface {
+	// 获取通道名称
+	GetName() string
+
+	// 获取通道代码
+	GetCode() string
+
+	// 创建支付
+	CreatePayment(ctx context.Context, req *CreatePaymentRequest) (*CreatePaymentResponse, error)
+
+	// 解析并验签异步通知
+	ParseNotify(ctx context.Context, r *http.Request) (*NotifyResult, error)
+
+	// 异步通知应答
+	NotifyResponse(success bool) *NotifyResponse
+
+	// 原路退款
+	Refund(ctx context.Context, req *RefundRequest) (*RefundResponse, error)
+}
+
+// CreatePaymentRequest 创建支付请求
+type CreatePaymentRequest struct {
+	TradeNo   string          `json:"trade_no"`   // 商户交易号
+	Subject   string          `json:"subject"`    // 商品描述
+	Amount    decimal.Decimal `json:"amount"`     // 支付金额
+	Currency  string          `json:"currency"`   // 币种，为空表示人民币
+	PayType   string          `json:"pay_type"`   // qrcode、redirect
+	ClientIP  string          `json:"client_ip"`  // 用户IP
+	ReturnURL string          `json:"return_url"` // 支付完成跳转地址
+	ExpireAt  time.Time       `json:"expire_at"`  // 支付截止时间
+}
+
+// CreatePaymentResponse 创建支付响应
+type CreatePaymentResponse struct {
+	TradeNo string `json:"trade_no"` // 商户交易号
+	PayType string `json:"pay_type"` // qrcode、redirect
+	CodeURL string `json:"code_url"` // 二维码内容
+	PayURL  string `json:"pay_url"`  // 跳转地址
+}
+
+// NotifyResult 异步通知解析结果
+type NotifyResult struct {
+	TradeNo       string          `json:"trade_no"`       // 商户交易号
+	TransactionID string          `json:"transaction_id"` // 第三方交易号
+	Amount        decimal.Decimal `json:"amount"`         // 实付金额（元）
+	Paid          bool            `json:"paid"`           // 是否支付成功
+	PaidAt        time.Time       `json:"paid_at"`        // 支付时间
+}
+
+// RefundRequest 退款请求
+type RefundRequest struct {
+	TradeNo     string          `json:"trade_no"`     // 原支付的商户交易号
+	RefundNo    string          `json:"refund_no"`    // 商户退款单号，同一单号重复请求不会重复退款
+	TotalAmount decimal.Decimal `json:"total_amount"` // 原支付金额（元）
+	Amount      decimal.Decimal `json:"amount"`       // 退款金额
+	Currency    string          `json:"currency"`     // 币种，为空表示人民币
+	Reason      string          `json:"reason"`       // 退款原因
+}
+
+// RefundResponse 退款响应
+type RefundResponse struct {
+	RefundNo string `json:"refund_no"` // 商户退款单号
+	RefundID string `json:"refund_id"` // 第三方退款单号
+}
+
+// NotifyResponse 异步通知应答
+type NotifyResponse struct {
+	StatusCode  int    `json:"status_code"`
+	ContentType string `json:"content_type"`
+	Body        string `json:"body"`
+}
+
+// requireCNY 境内通道仅支持人民币
+func requireCNY(gateway Gateway, currency string) error {
+	if currency != "" && currency != CurrencyCNY {
+		return fmt.Errorf("%s不支持%s币种", gateway.GetName(), currency)
+	}
+	return nil
+}
+
+// Manager 支付通道管理器
+type Manager struct {
+	gateways map[string]Gateway
+}
+
+// NewManager 创建支付通道管理器
+func NewManager() *Manager {
+	return &Manager{
+		gateways: make(map[string]Gateway),
+	}
+}
+
+// RegisterGateway 注册支付通道
+func (m *Manager) RegisterGateway(gateway Gateway) {
+	m.gateways[gateway.GetCode()] = gateway
+}
+
+// GetGateway 获取支付通道
+func (m *Manager) GetGateway(code string) (Gateway, bool) {
+	gateway, exists := m.gateways[code]
+	return gateway, exists
+}
+
+// GetAllGateways 获取所有支付通道，按代码排序
+func (m *Manager) GetAllGateways() []Gateway {
+	gateways := make([]Gateway, 0, len(m.gateways))
+	for _, gateway := range m.gateways {
+		gateways = append(gateways, gateway)
+	}
+	sort.Slice(gateways, func(i, j int) bool {
+		return gateways[i].GetCode() < gateways[j].GetCode()
+	})
+	return gateways
+}
@@ -0,0 +1,117 @@
+package payment
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"cloudbp-backend/internal/config"
+
+	"github.com/shopspring/decimal"
+)
+
+// MockPay 本地模拟支付通道，用于开发联调和测试，回调使用HMAC签名
+type MockPay struct {
+	secret string
+	payURL string
+}
+
+// NewMockPay 创建模拟支付通道，payURL 为模拟收银台地址
+func NewMockPay(cfg config.MockPayConfig, payURL string) (*MockPay, error) {
+	if cfg.Secret == "" || cfg.Secret == config.MockPaySecretPlaceholder {
+		return nil, errors.New("模拟支付密钥未配置或仍为示例值")
+	}
+
+	return &MockPay{
+		secret: cfg.Secret,
+		payURL: payURL,
+	}, nil
+}
+
+// GetName 获取通道名称
+func (m *MockPay) GetName() string {
+	return "模拟支付"
+}
+
+// GetCode 获取通道代码
+func (m *MockPay) GetCode() string {
+	return "mock"
+}
+
+// CreatePayment 创建支付，返回模拟收银台地址
+func (m *MockPay) CreatePayment(ctx context.Context, req *CreatePaymentRequest) (*CreatePaymentResponse, error) {
+	payURL := m.payURL + "?" + url.Values{"trade_no": {req.TradeNo}}.Encode()
+
+	return &CreatePaymentResponse{
+		TradeNo: req.TradeNo,
+		PayType: PayTypeRedirect,
+		CodeURL: payURL,
+		PayURL:  payURL,
+	}, nil
+}
+
+// ParseNotify 校验HMAC签名并解析模拟通知
+func (m *MockPay) ParseNotify(ctx context.Context, r *http.Request) (*NotifyResult, error) {
+	if err := r.ParseForm(); err != nil {
+		return nil, fmt.Errorf("解析通知内容失败: %w", err)
+	}
+	form := r.PostForm
+
+	expected := m.sign(form)
+	if !hmac.Equal([]byte(expected), []byte(form.Get("sign"))) {
+		return nil, errors.New("签名校验失败")
+	}
+
+	amount, err := decimal.NewFromString(form.Get("amount"))
+	if err != nil {
+		return nil, errors.New("通知金额格式错误")
+	}
+
+	return &NotifyResult{
+		TradeNo:       form.Get("trade_no"),
+		TransactionID: form.Get("transaction_id"),
+		Amount:        amount,
+		Paid:          form.Get("status") == "SUCCESS",
+		PaidAt:        time.Now(),
+	}, nil
+}
+
+// NotifyResponse 异步通知应答
+func (m *MockPay) NotifyResponse(success bool) *NotifyResponse {
+	if success {
+		return &NotifyResponse{StatusCode: http.StatusOK, ContentType: "text/plain", Body: "success"}
+	}
+	return &NotifyResponse{StatusCode: http.StatusBadRequest, ContentType: "text/plain", Body: "failure"}
+}
+
+// Refund 模拟退款，直接返回成功
+func (m *MockPay) Refund(ctx context.Context, req *RefundRequest) (*RefundResponse, error) {
+	return &RefundResponse{
+		RefundNo: req.RefundNo,
+		RefundID: fmt.Sprintf("MOCKREFUND%d", time.Now().UnixNano()),
+	}, nil
+}
+
+// BuildNotify 构造一条已签名的支付成功通知，模拟第三方回调
+func (m *MockPay) BuildNotify(tradeNo string, amount decimal.Decimal) url.Values {
+	form := url.Values{}
+	form.Set("trade_no", tradeNo)
+	form.Set("transaction_id", fmt.Sprintf("MOCK%d", time.Now().UnixNano()))
+	form.Set("amount", amount.StringFixed(2))
+	form.Set("status", "SUCCESS")
+	form.Set("sign", m.sign(form))
+	return form
+}
+
+// sign 计算通知签名
+func (m *MockPay) sign(form url.Values) string {
+	mac := hmac.New(sha256.New, []byte(m.secret))
+	mac.Write([]byte(signContent(form, "sign")))
+	return hex.EncodeToString(mac.Sum(nil))
+}
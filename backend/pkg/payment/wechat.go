@@ -0,0 +1,318 @@
+package payment
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"cloudbp-backend/internal/config"
+
+	"github.com/shopspring/decimal"
+)
+
+const wechatPayAPIBase = "https://api.mch.weixin.qq.com"
+
+// WechatPay 微信支付v3适配器（Native扫码支付）
+type WechatPay struct {
+	config      config.WechatPayConfig
+	notifyURL   string
+	privateKey  *rsa.PrivateKey
+	platformKey *rsa.PublicKey
+	client      *http.Client
+}
+
+// NewWechatPay 创建微信支付适配器
+func NewWechatPay(cfg config.WechatPayConfig, notifyURL string) (*WechatPay, error) {
+	// 验证配置
+	if cfg.AppID == "" || cfg.MchID == "" || cfg.SerialNo == "" || len(cfg.APIv3Key) != 32 {
+		return nil, errors.New("微信支付配置不完整")
+	}
+
+	privateKey, err := loadPrivateKey(cfg.PrivateKeyPath)
+	if err != nil {
+		return nil, err
+	}
+
+	platformKey, err := loadPublicKey(cfg.PlatformCertPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return &WechatPay{
+		config:      cfg,
+		notifyURL:   notifyURL,
+		privateKey:  privateKey,
+		platformKey: platformKey,
+		client:      &http.Client{Timeout: 10 * time.Second},
+	}, nil
+}
+
+// GetName 获取通道名称
+func (w *WechatPay) GetName() string {
+	return "微信支付"
+}
+
+// GetCode 获取通道代码
+func (w *WechatPay) GetCode() string {
+	return "wechat"
+}
+
+// CreatePayment 创建Native支付，返回二维码链接
+func (w *WechatPay) CreatePayment(ctx context.Context, req *CreatePaymentRequest) (*CreatePaymentResponse, error) {
+	if err := requireCNY(w, req.Currency); err != nil {
+		return nil, err
+	}
+
+	body := map[string]interface{}{
+		"appid":        w.config.AppID,
+		"mchid":        w.config.MchID,
+		"description":  req.Subject,
+		"out_trade_no": req.TradeNo,
+		"notify_url":   w.notifyURL,
+		"amount": map[string]interface{}{
+			"total":    req.Amount.Mul(decimal.NewFromInt(100)).IntPart(),
+			"currency": CurrencyCNY,
+		},
+	}
+	if !req.ExpireAt.IsZero() {
+		body["time_expire"] = req.ExpireAt.Format(time.RFC3339)
+	}
+
+	var resp struct {
+		CodeURL string `json:"code_url"`
+	}
+	if err := w.do(ctx, http.MethodPost, "/v3/pay/transactions/native", body, &resp); err != nil {
+		return nil, err
+	}
+
+	return &CreatePaymentResponse{
+		TradeNo: req.TradeNo,
+		PayType: PayTypeQRCode,
+		CodeURL: resp.CodeURL,
+	}, nil
+}
+
+// ParseNotify 验签并解密支付结果通知
+func (w *WechatPay) ParseNotify(ctx context.Context, r *http.Request) (*NotifyResult, error) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, fmt.Errorf("读取通知内容失败: %w", err)
+	}
+
+	if err := w.verifyNotify(r.Header, body); err != nil {
+		return nil, err
+	}
+
+	var notify struct {
+		EventType string `json:"event_type"`
+		Resource  struct {
+			Algorithm      string `json:"algorithm"`
+			Ciphertext     string `json:"ciphertext"`
+			AssociatedData string `json:"associated_data"`
+			Nonce          string `json:"nonce"`
+		} `json:"resource"`
+	}
+	if err := json.Unmarshal(body, &notify); err != nil {
+		return nil, fmt.Errorf("解析通知内容失败: %w", err)
+	}
+
+	plaintext, err := w.decrypt(notify.Resource.Ciphertext, notify.Resource.Nonce, notify.Resource.AssociatedData)
+	if err != nil {
+		return nil, err
+	}
+
+	var transaction struct {
+		OutTradeNo    string `json:"out_trade_no"`
+		TransactionID string `json:"transaction_id"`
+		TradeState    string `json:"trade_state"`
+		SuccessTime   string `json:"success_time"`
+		Amount        struct {
+			Total int64 `json:"total"`
+		} `json:"amount"`
+	}
+	if err := json.Unmarshal(plaintext, &transaction); err != nil {
+		return nil, fmt.Errorf("解析交易信息失败: %w", err)
+	}
+
+	result := &NotifyResult{
+		TradeNo:       transaction.OutTradeNo,
+		TransactionID: transaction.TransactionID,
+		Amount:        decimal.New(transaction.Amount.Total, -2),
+		Paid:          notify.EventType == "TRANSACTION.SUCCESS" && transaction.TradeState == "SUCCESS",
+	}
+	if paidAt, err := time.Parse(time.RFC3339, transaction.SuccessTime); err == nil {
+		result.PaidAt = paidAt
+	}
+
+	return result, nil
+}
+
+// NotifyResponse 异步通知应答
+func (w *WechatPay) NotifyResponse(success bool) *NotifyResponse {
+	if success {
+		return &NotifyResponse{
+			StatusCode:  http.StatusOK,
+			ContentType: "application/json",
+			Body:        `{"code":"SUCCESS","message":"成功"}`,
+		}
+	}
+	return &NotifyResponse{
+		StatusCode:  http.StatusInternalServerError,
+		ContentType: "application/json",
+		Body:        `{"code":"FAIL","message":"失败"}`,
+	}
+}
+
+// Refund 申请退款，微信受理后资金原路退回
+func (w *WechatPay) Refund(ctx context.Context, req *RefundRequest) (*RefundResponse, error) {
+	if err := requireCNY(w, req.Currency); err != nil {
+		return nil, err
+	}
+
+	body := map[string]interface{}{
+		"out_trade_no":  req.TradeNo,
+		"out_refund_no": req.RefundNo,
+		"reason":        req.Reason,
+		"amount": map[string]interface{}{
+			"refund":   req.Amount.Mul(decimal.NewFromInt(100)).IntPart(),
+			"total":    req.TotalAmount.Mul(decimal.NewFromInt(100)).IntPart(),
+			"currency": CurrencyCNY,
+		},
+	}
+
+	var resp struct {
+		RefundID string `json:"refund_id"`
+		Status   string `json:"status"`
+	}
+	if err := w.do(ctx, http.MethodPost, "/v3/refund/domestic/refunds", body, &resp); err != nil {
+		return nil, err
+	}
+	if resp.Status == "CLOSED" || resp.Status == "ABNORMAL" {
+		return nil, fmt.Errorf("微信退款失败: %s", resp.Status)
+	}
+
+	return &RefundResponse{
+		RefundNo: req.RefundNo,
+		RefundID: resp.RefundID,
+	}, nil
+}
+
+// do 调用微信支付API
+func (w *WechatPay) do(ctx context.Context, method, path string, body interface{}, result interface{}) error {
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = json.Marshal(body); err != nil {
+			return fmt.Errorf("序列化请求失败: %w", err)
+		}
+	}
+
+	authorization, err := w.authorization(method, path, payload)
+	if err != nil {
+		return err
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, method, wechatPayAPIBase+path, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("创建请求失败: %w", err)
+	}
+	httpReq.Header.Set("Authorization", authorization)
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Accept", "application/json")
+
+	httpResp, err := w.client.Do(httpReq)
+	if err != nil {
+		return fmt.Errorf("请求微信支付失败: %w", err)
+	}
+	defer httpResp.Body.Close()
+
+	respBody, err := io.ReadAll(httpResp.Body)
+	if err != nil {
+		return fmt.Errorf("读取微信支付响应失败: %w", err)
+	}
+
+	if httpResp.StatusCode >= 300 {
+		var apiErr struct {
+			Code    string `json:"code"`
+			Message string `json:"message"`
+		}
+		_ = json.Unmarshal(respBody, &apiErr)
+		return fmt.Errorf("微信支付返回错误: %s %s", apiErr.Code, apiErr.Message)
+	}
+
+	if result != nil && len(respBody) > 0 {
+		if err := json.Unmarshal(respBody, result); err != nil {
+			return fmt.Errorf("解析微信支付响应失败: %w", err)
+		}
+	}
+	return nil
+}
+
+// authorization 生成请求签名头
+func (w *WechatPay) authorization(method, path string, body []byte) (string, error) {
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	nonce := nonceStr()
+	message := fmt.Sprintf("%s\n%s\n%s\n%s\n%s\n", method, path, timestamp, nonce, body)
+
+	signature, err := signSHA256WithRSA(w.privateKey, message)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf(`WECHATPAY2-SHA256-RSA2048 mchid="%s",nonce_str="%s",signature="%s",timestamp="%s",serial_no="%s"`,
+		w.config.MchID, nonce, signature, timestamp, w.config.SerialNo), nil
+}
+
+// verifyNotify 使用平台证书校验通知签名，并拒绝超过5分钟的通知
+func (w *WechatPay) verifyNotify(header http.Header, body []byte) error {
+	timestamp := header.Get("Wechatpay-Timestamp")
+	nonce := header.Get("Wechatpay-Nonce")
+	signature := header.Get("Wechatpay-Signature")
+	if timestamp == "" || nonce == "" || signature == "" {
+		return errors.New("缺少微信支付签名头")
+	}
+
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return errors.New("通知时间戳格式错误")
+	}
+	if time.Since(time.Unix(ts, 0)).Abs() > 5*time.Minute {
+		return errors.New("通知已过期")
+	}
+
+	message := fmt.Sprintf("%s\n%s\n%s\n", timestamp, nonce, body)
+	return verifySHA256WithRSA(w.platformKey, message, signature)
+}
+
+// decrypt 使用APIv3密钥解密AEAD_AES_256_GCM密文
+func (w *WechatPay) decrypt(ciphertext, nonce, associatedData string) ([]byte, error) {
+	data, err := base64.StdEncoding.DecodeString(ciphertext)
+	if err != nil {
+		return nil, errors.New("密文格式错误")
+	}
+
+	block, err := aes.NewCipher([]byte(w.config.APIv3Key))
+	if err != nil {
+		return nil, fmt.Errorf("初始化解密失败: %w", err)
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("初始化解密失败: %w", err)
+	}
+
+	plaintext, err := gcm.Open(nil, []byte(nonce), data, []byte(associatedData))
+	if err != nil {
+		return nil, errors.New("解密通知内容失败")
+	}
+	return plaintext, nil
+}
@@ -5,7 +5,8 @@ import (
 	"time"
 )
 
-// CloudProvider 云厂商接口
+// CloudProvider 云厂商接口，所有厂商都须实现的基础能力；
+// 变更规格、快照、防火墙、密钥对、控制台、续费等可选能力见下方的小接口，通过类型断言检测
 type CloudProvider interface {
 	// 获取厂商名称
 	GetName() string
@@ -55,15 +56,18 @@ type CloudProvider interface {
 
 // CreateInstanceRequest 创建实例请求
 type CreateInstanceRequest struct {
-	Name         string `json:"name"`          // 实例名称
-	Region       string `json:"region"`        // 地域
-	Zone         string `json:"zone"`          // 可用区
-	ImageID      string `json:"image_id"`      // 镜像ID
-	InstanceType string `json:"instance_type"` // 实例规格
-	Password     string `json:"password"`      // 登录密码
-	Period       int    `json:"period"`        // 购买时长（月）
-	AutoRenew    bool   `json:"auto_renew"`    // 是否自动续费
-	UserData     string `json:"user_data"`     // 用户数据
+	Name                 string   `json:"name"`                   // 实例名称
+	Region               string   `json:"region"`                 // 地域
+	Zone                 string   `json:"zone"`                   // 可用区
+	ImageID              string   `json:"image_id"`               // 镜像ID
+	InstanceType         string   `json:"instance_type"`          // 实例规格
+	Password             string   `json:"password"`               // 登录密码
+	Period               int      `json:"period"`                 // 购买时长（月）
+	AutoRenew            bool     `json:"auto_renew"`             // 是否自动续费
+	UserData             string   `json:"user_data"`              // 用户数据
+	KeyIDs               []string `json:"key_ids"`                // 绑定的密钥对ID，仅实现 KeyPairManager 的厂商使用
+	DisablePasswordLogin bool     `json:"disable_password_login"` // 禁用密码登录，只允许密钥登录
+	ClientToken          string   `json:"client_token"`           // 幂等令牌，重试时传入相同令牌，厂商返回已创建的实例而不重复创建
 }
 
 // CreateInstanceResponse 创建实例响应
@@ -163,9 +167,190 @@ type ResetInstancePasswordRequest struct {
 
 // RebuildInstanceRequest 重装实例系统请求
 type RebuildInstanceRequest struct {
+	InstanceID           string   `json:"instance_id"`            // 实例ID
+	ImageID              string   `json:"image_id"`               // 镜像ID
+	Password             string   `json:"password"`               // 登录密码
+	UserData             string   `json:"user_data"`              // 用户数据
+	KeyIDs               []string `json:"key_ids"`                // 绑定的密钥对ID，仅实现 KeyPairManager 的厂商使用
+	DisablePasswordLogin bool     `json:"disable_password_login"` // 禁用密码登录，只允许密钥登录
+}
+
+// KeyPairManager 支持密钥对的厂商实现该接口，创建和重装实例时通过 KeyIDs 绑定公钥；
+// 未实现的厂商由平台通过 UserData（cloud-init）注入公钥
+type KeyPairManager interface {
+	// 导入公钥为厂商密钥对
+	ImportKeyPair(ctx context.Context, req *ImportKeyPairRequest) (*ImportKeyPairResponse, error)
+	
+	// 删除厂商密钥对
+	DeleteKeyPair(ctx context.Context, req *DeleteKeyPairRequest) error
+}
+
+// ImportKeyPairRequest 导入密钥对请求
+type ImportKeyPairRequest struct {
+	Region    string `json:"region"`     // 地域，密钥对按地域隔离的厂商使用
+	Name      string `json:"name"`       // 密钥对名称
+	PublicKey string `json:"public_key"` // OpenSSH格式公钥
+}
+
+// ImportKeyPairResponse 导入密钥对响应
+type ImportKeyPairResponse struct {
+	KeyID string `json:"key_id"` // 厂商密钥对ID
+}
+
+// DeleteKeyPairRequest 删除密钥对请求
+type DeleteKeyPairRequest struct {
+	Region string `json:"region"` // 地域
+	KeyID  string `json:"key_id"` // 厂商密钥对ID
+}
+
+// FirewallManager 支持实例防火墙的厂商实现该接口，规则按内容匹配增删
+type FirewallManager interface {
+	// 获取实例防火墙规则
+	GetFirewallRules(ctx context.Context, req *GetFirewallRulesRequest) (*GetFirewallRulesResponse, error)
+	
+	// 添加实例防火墙规则
+	CreateFirewallRules(ctx context.Context, req *CreateFirewallRulesRequest) error
+	
+	// 删除实例防火墙规则
+	DeleteFirewallRules(ctx context.Context, req *DeleteFirewallRulesRequest) error
+}
+
+// FirewallRule 防火墙规则
+type FirewallRule struct {
+	Protocol    string `json:"protocol"`    // TCP、UDP、ICMP、ALL
+	Port        string `json:"port"`        // 端口，如 22、80,443、8000-9000，ICMP和ALL协议为ALL
+	CIDR        string `json:"cidr"`        // 来源网段
+	Action      string `json:"action"`      // ACCEPT、DROP
+	Description string `json:"description"` // 备注
+}
+
+// GetFirewallRulesRequest 获取防火墙规则请求
+type GetFirewallRulesRequest struct {
+	InstanceID string `json:"instance_id"` // 实例ID
+}
+
+// GetFirewallRulesResponse 获取防火墙规则响应
+type GetFirewallRulesResponse struct {
+	Rules []FirewallRule `json:"rules"` // 防火墙规则
+}
+
+// CreateFirewallRulesRequest 添加防火墙规则请求
+type CreateFirewallRulesRequest struct {
+	InstanceID string         `json:"instance_id"` // 实例ID
+	Rules      []FirewallRule `json:"rules"`       // 待添加的规则
+}
+
+// DeleteFirewallRulesRequest 删除防火墙规则请求
+type DeleteFirewallRulesRequest struct {
+	InstanceID string         `json:"instance_id"` // 实例ID
+	Rules      []FirewallRule `json:"rules"`       // 待删除的规则
+}
+
+// Snapshotter 支持实例快照的厂商实现该接口
+type Snapshotter interface {
+	// 创建快照
+	CreateSnapshot(ctx context.Context, req *CreateSnapshotRequest) (*CreateSnapshotResponse, error)
+	
+	// 删除快照
+	DeleteSnapshot(ctx context.Context, req *DeleteSnapshotRequest) error
+	
+	// 使用快照回滚实例
+	RestoreSnapshot(ctx context.Context, req *RestoreSnapshotRequest) error
+}
+
+// CreateSnapshotRequest 创建快照请求
+type CreateSnapshotRequest struct {
+	InstanceID string `json:"instance_id"` // 实例ID
+	Name       string `json:"name"`        // 快照名称
+}
+
+// CreateSnapshotResponse 创建快照响应
+type CreateSnapshotResponse struct {
+	SnapshotID string `json:"snapshot_id"` // 快照ID
+	Size       int    `json:"size"`        // 快照大小GB
+}
+
+// DeleteSnapshotRequest 删除快照请求
+type DeleteSnapshotRequest struct {
+	SnapshotID string `json:"snapshot_id"` // 快照ID
+}
+
+// RestoreSnapshotRequest 回滚快照请求
+type RestoreSnapshotRequest struct {
+	InstanceID string `json:"instance_id"` // 实例ID
+	SnapshotID string `json:"snapshot_id"` // 快照ID
+}
+
+// Resizer 支持变更实例规格的厂商实现该接口，未实现的厂商不能升降配
+type Resizer interface {
+	// 变更实例规格
+	ResizeInstance(ctx context.Context, req *ResizeInstanceRequest) error
+}
+
+// ResizeInstanceRequest 变更实例规格请求
+type ResizeInstanceRequest struct {
+	InstanceID   string `json:"instance_id"`   // 实例ID
+	InstanceType string `json:"instance_type"` // 目标实例规格
+}
+
+// Renewer 包年包月实例的厂商实现该接口，续费订单支付后先续费厂商实例，成功后平台再顺延到期时间；
+// 未实现的厂商实例按量计费，到期时间只由平台管理
+type Renewer interface {
+	// 续费实例
+	RenewInstance(ctx context.Context, req *RenewInstanceRequest) error
+}
+
+// RenewInstanceRequest 续费实例请求
+type RenewInstanceRequest struct {
+	InstanceID  string `json:"instance_id"`  // 实例ID
+	Period      int    `json:"period"`       // 续费时长（月）
+	ClientToken string `json:"client_token"` // 幂等令牌，重试时传入相同令牌，厂商不会重复续费
+}
+
+// ConsoleProvider 支持网页控制台（VNC）的厂商实现该接口
+type ConsoleProvider interface {
+	// 获取实例控制台地址
+	GetConsole(ctx context.Context, req *GetConsoleRequest) (*GetConsoleResponse, error)
+}
+
+// GetConsoleRequest 获取控制台地址请求
+type GetConsoleRequest struct {
 	InstanceID string `json:"instance_id"` // 实例ID
-	ImageID    string `json:"image_id"`    // 镜像ID
-	Password   string `json:"password"`    // 登录密码
+}
+
+// GetConsoleResponse 获取控制台地址响应
+type GetConsoleResponse struct {
+	Type      string    `json:"type"`       // url:厂商控制台网页 websocket:VNC WebSocket地址
+	URL       string    `json:"url"`        // 控制台地址
+	ExpiresAt time.Time `json:"expires_at"` // 地址失效时间
+}
+
+// 控制台地址类型
+const (
+	ConsoleTypeURL       = "url"
+	ConsoleTypeWebSocket = "websocket"
+)
+
+// Capabilities 厂商支持的可选能力
+type Capabilities struct {
+	Resize   bool `json:"resize"`   // 变更规格
+	Snapshot bool `json:"snapshot"` // 快照
+	Firewall bool `json:"firewall"` // 实例防火墙
+	KeyPair  bool `json:"key_pair"` // 厂商密钥对，不支持时平台通过cloud-init注入公钥
+	Console  bool `json:"console"`  // 网页控制台
+	Renew    bool `json:"renew"`    // 厂商侧续费
+}
+
+// GetCapabilities 通过类型断言检测厂商实现的可选接口
+func GetCapabilities(p CloudProvider) *Capabilities {
+	caps := &Capabilities{}
+	_, caps.Resize = p.(Resizer)
+	_, caps.Snapshot = p.(Snapshotter)
+	_, caps.Firewall = p.(FirewallManager)
+	_, caps.KeyPair = p.(KeyPairManager)
+	_, caps.Console = p.(ConsoleProvider)
+	_, caps.Renew = p.(Renewer)
+	return caps
 }
 
 // GetRegionsResponse 获取可用区域列表响应
@@ -222,6 +407,7 @@ type GetInstanceTypesResponse struct {
 // InstanceType 实例规格信息
 type InstanceType struct {
 	InstanceType string  `json:"instance_type"` // 实例规格
+	Family       string  `json:"family"`        // 产品类型，如lighthouse、cvm
 	CPU          int     `json:"cpu"`           // CPU核数
 	Memory       int     `json:"memory"`        // 内存GB
 	Storage      int     `json:"storage"`       // 存储GB
@@ -229,6 +415,7 @@ type InstanceType struct {
 	Traffic      int     `json:"traffic"`       // 流量包GB
 	Price        float64 `json:"price"`         // 价格
 	Description  string  `json:"description"`   // 描述
+	SoldOut      bool    `json:"sold_out"`      // 是否售罄
 }
 
 // ProviderConfig 厂商配置
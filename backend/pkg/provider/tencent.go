@@ -12,6 +12,16 @@ type TencentCloudProvider struct {
 	config *ProviderConfig
 }
 
+// 腾讯云轻量应用服务器支持的可选能力
+var (
+	_ Resizer         = (*TencentCloudProvider)(nil)
+	_ Renewer         = (*TencentCloudProvider)(nil)
+	_ Snapshotter     = (*TencentCloudProvider)(nil)
+	_ FirewallManager = (*TencentCloudProvider)(nil)
+	_ KeyPairManager  = (*TencentCloudProvider)(nil)
+	_ ConsoleProvider = (*TencentCloudProvider)(nil)
+)
+
 // NewTencentCloudProvider 创建腾讯云适配器
 func NewTencentCloudProvider(config *ProviderConfig) (*TencentCloudProvider, error) {
 	// 验证配置
@@ -36,7 +46,7 @@ func (t *TencentCloudProvider) GetCode() string {
 
 // CreateInstance 创建实例
 func (t *TencentCloudProvider) CreateInstance(ctx context.Context, req *CreateInstanceRequest) (*CreateInstanceResponse, error) {
-	// 模拟创建实例，实际应用中需要调用腾讯云API
+	// 模拟创建实例，实际应用中需要调用腾讯云API，并将 ClientToken 传给接口保证重试幂等
 	instanceID := fmt.Sprintf("lhins-%d", time.Now().Unix())
 	
 	return &CreateInstanceResponse{
@@ -162,6 +172,87 @@ func (t *TencentCloudProvider) RebuildInstance(ctx context.Context, req *Rebuild
 	return nil
 }
 
+// ImportKeyPair 导入密钥对
+func (t *TencentCloudProvider) ImportKeyPair(ctx context.Context, req *ImportKeyPairRequest) (*ImportKeyPairResponse, error) {
+	// 模拟导入密钥对
+	return &ImportKeyPairResponse{
+		KeyID: fmt.Sprintf("lhkp-%d", time.Now().UnixNano()),
+	}, nil
+}
+
+// DeleteKeyPair 删除密钥对
+func (t *TencentCloudProvider) DeleteKeyPair(ctx context.Context, req *DeleteKeyPairRequest) error {
+	// 模拟删除密钥对
+	return nil
+}
+
+// GetFirewallRules 获取实例防火墙规则
+func (t *TencentCloudProvider) GetFirewallRules(ctx context.Context, req *GetFirewallRulesRequest) (*GetFirewallRulesResponse, error) {
+	// 模拟轻量应用服务器的默认防火墙规则
+	return &GetFirewallRulesResponse{
+		Rules: []FirewallRule{
+			{Protocol: "TCP", Port: "22", CIDR: "0.0.0.0/0", Action: "ACCEPT", Description: "SSH"},
+			{Protocol: "TCP", Port: "80", CIDR: "0.0.0.0/0", Action: "ACCEPT", Description: "HTTP"},
+			{Protocol: "TCP", Port: "443", CIDR: "0.0.0.0/0", Action: "ACCEPT", Description: "HTTPS"},
+			{Protocol: "ICMP", Port: "ALL", CIDR: "0.0.0.0/0", Action: "ACCEPT", Description: "Ping"},
+		},
+	}, nil
+}
+
+// CreateFirewallRules 添加实例防火墙规则
+func (t *TencentCloudProvider) CreateFirewallRules(ctx context.Context, req *CreateFirewallRulesRequest) error {
+	// 模拟添加防火墙规则
+	return nil
+}
+
+// DeleteFirewallRules 删除实例防火墙规则
+func (t *TencentCloudProvider) DeleteFirewallRules(ctx context.Context, req *DeleteFirewallRulesRequest) error {
+	// 模拟删除防火墙规则
+	return nil
+}
+
+// CreateSnapshot 创建快照
+func (t *TencentCloudProvider) CreateSnapshot(ctx context.Context, req *CreateSnapshotRequest) (*CreateSnapshotResponse, error) {
+	// 模拟创建快照
+	return &CreateSnapshotResponse{
+		SnapshotID: fmt.Sprintf("lhsnap-%d", time.Now().UnixNano()),
+	}, nil
+}
+
+// DeleteSnapshot 删除快照
+func (t *TencentCloudProvider) DeleteSnapshot(ctx context.Context, req *DeleteSnapshotRequest) error {
+	// 模拟删除快照
+	return nil
+}
+
+// RestoreSnapshot 使用快照回滚实例
+func (t *TencentCloudProvider) RestoreSnapshot(ctx context.Context, req *RestoreSnapshotRequest) error {
+	// 模拟回滚快照
+	return nil
+}
+
+// ResizeInstance 变更实例规格
+func (t *TencentCloudProvider) ResizeInstance(ctx context.Context, req *ResizeInstanceRequest) error {
+	// 模拟变更规格
+	return nil
+}
+
+// RenewInstance 续费实例
+func (t *TencentCloudProvider) RenewInstance(ctx context.Context, req *RenewInstanceRequest) error {
+	// 模拟续费实例
+	return nil
+}
+
+// GetConsole 获取实例VNC地址，腾讯云返回的WebSocket地址15秒内有效且只能使用一次
+func (t *TencentCloudProvider) GetConsole(ctx context.Context, req *GetConsoleRequest) (*GetConsoleResponse, error) {
+	// 模拟获取VNC地址
+	return &GetConsoleResponse{
+		Type:      ConsoleTypeWebSocket,
+		URL:       fmt.Sprintf("wss://lhvnc.tencentcloudapi.com/?InstanceId=%s&Token=%d", req.InstanceID, time.Now().UnixNano()),
+		ExpiresAt: time.Now().Add(15 * time.Second),
+	}, nil
+}
+
 // GetRegions 获取可用区域列表
 func (t *TencentCloudProvider) GetRegions(ctx context.Context) (*GetRegionsResponse, error) {
 	// 模拟返回区域列表
@@ -252,6 +343,7 @@ func (t *TencentCloudProvider) GetInstanceTypes(ctx context.Context, req *GetIns
 	instanceTypes := []InstanceType{
 		{
 			InstanceType: "lighthouse-1c2g",
+			Family:       "lighthouse",
 			CPU:          1,
 			Memory:       2,
 			Storage:      50,
@@ -262,6 +354,7 @@ func (t *TencentCloudProvider) GetInstanceTypes(ctx context.Context, req *GetIns
 		},
 		{
 			InstanceType: "lighthouse-2c4g",
+			Family:       "lighthouse",
 			CPU:          2,
 			Memory:       4,
 			Storage:      80,
@@ -272,6 +365,7 @@ func (t *TencentCloudProvider) GetInstanceTypes(ctx context.Context, req *GetIns
 		},
 		{
 			InstanceType: "lighthouse-2c8g",
+			Family:       "lighthouse",
 			CPU:          2,
 			Memory:       8,
 			Storage:      100,
@@ -282,6 +376,7 @@ func (t *TencentCloudProvider) GetInstanceTypes(ctx context.Context, req *GetIns
 		},
 		{
 			InstanceType: "lighthouse-4c8g",
+			Family:       "lighthouse",
 			CPU:          4,
 			Memory:       8,
 			Storage:      180,
@@ -292,6 +387,7 @@ func (t *TencentCloudProvider) GetInstanceTypes(ctx context.Context, req *GetIns
 		},
 		{
 			InstanceType: "lighthouse-8c16g",
+			Family:       "lighthouse",
 			CPU:          8,
 			Memory:       16,
 			Storage:      300,